@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+const usage = `usage: admin <command>
+
+commands:
+  promote USERNAME   give USERNAME the admin role
+  demote USERNAME    take the admin role away from USERNAME
+
+The first administrator has to be created here; the /admin API cannot grant
+or revoke the admin role.
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var role string
+	switch args[0] {
+	case "promote":
+		role = model.RoleAdmin
+	case "demote":
+		role = model.RoleUser
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.LoadConfig()
+	conn, err := app.OpenDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	container := app.NewContainer(conn, cfg)
+
+	u, err := container.Svcs.Admin.SetRole(context.Background(), args[1], role)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("user %s (id %d) now has the %s role\n", u.Username, u.ID, u.Role)
+}
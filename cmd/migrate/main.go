@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/pkg/migrate"
+)
+
+const usage = `usage: migrate [-dir migrations] <command>
+
+commands:
+  up            apply all pending migrations
+  down N        roll back the last N migrations (default 1)
+  status        list migrations and whether they are applied
+  goto V        migrate up or down to version V
+  create NAME   create a new empty migration for every dialect
+`
+
+func main() {
+	dir := flag.String("dir", "migrations", "migrations source directory (used by create)")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("create requires a NAME")
+		}
+		files, err := migrate.Create(*dir, migrate.Dialects, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, f := range files {
+			fmt.Println("created", f)
+		}
+		return
+	}
+
+	cfg := config.LoadConfig()
+	conn, err := app.OpenDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	m, err := conn.Migrator()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid N %q", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("rolled back %d migration(s)\n", n)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := m.Verify(ctx); err != nil {
+			fmt.Println("WARNING:", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d  %-40s %s\n", s.Version, s.Name, state)
+		}
+	case "goto":
+		if len(args) != 2 {
+			log.Fatal("goto requires a version")
+		}
+		v, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("invalid version %q", args[1])
+		}
+		if err := m.Goto(ctx, v); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("schema is now at version %d\n", v)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
 
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
 	"github.com/MujiRahman/golang-simple-note/pkg/logger"
 )
 
@@ -12,6 +16,12 @@ func main() {
 	logger.InitLogger()
 
 	cfg := config.LoadConfig()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+
 	connection := app.NewDB(cfg)
 
 	// centralize wiring of repos & services
@@ -19,6 +29,53 @@ func main() {
 	userService := container.Svcs.User
 	noteService := container.Svcs.Note
 
-	router := app.NewRouter(userService, noteService, cfg)
-	http.ListenAndServe(":8080", router)
+	router := app.NewRouter(userService, noteService, cfg, container.RouterOptions()...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.MetricsAddr != "" {
+		admin := app.NewServer(cfg, app.NewAdminRouter(cfg))
+		admin.HTTP.Addr = cfg.MetricsAddr
+		go func() {
+			if err := admin.Run(ctx); err != nil {
+				log.Printf("metrics server stopped with error: %v", err)
+			}
+		}()
+	}
+
+	server := app.NewServer(cfg, router)
+	if container.KeyDir != nil {
+		// picks up keys rotated by other instances and rotates when due
+		server.OnShutdown("jwt key sync", app.StartPurge(ctx, "jwt key sync", app.KeySyncInterval, container.KeyDir.Sync))
+	}
+	if err := app.ScheduleJobs(ctx, container.Svcs.Job, cfg); err != nil {
+		log.Fatalf("failed to schedule jobs: %v", err)
+	}
+	if cfg.JobWorkers > 0 {
+		server.OnShutdown("jobs", app.StartJobWorkers(ctx, container.Svcs.Job, cfg.JobWorkers, cfg.JobPollInterval))
+	}
+	if cfg.OutboxPollInterval > 0 {
+		server.OnShutdown("outbox relay", app.StartWorker(ctx, "outbox relay", cfg.OutboxPollInterval, container.Svcs.Outbox.Relay))
+	}
+	if cfg.WebhookPollInterval > 0 {
+		server.OnShutdown("webhook deliveries", app.StartWorker(ctx, "webhook deliveries", cfg.WebhookPollInterval, container.Svcs.Webhook.DeliverDue))
+	}
+	if cfg.CollabSnapshotInterval > 0 {
+		server.OnShutdown("collab snapshots", app.StartWorker(ctx, "collab snapshots", cfg.CollabSnapshotInterval, container.Svcs.Collab.Snapshot))
+	}
+	if cfg.EventsPollInterval > 0 {
+		server.OnShutdown("event streams", app.StartWorker(ctx, "event streams", cfg.EventsPollInterval, container.Svcs.EventStream.Poll))
+	}
+	// the server waits for open event streams before shutting down
+	server.OnDrain(container.Svcs.EventStream.Close)
+	// editing sessions run on hijacked connections, which the server does not
+	// drain; closing them saves the open documents
+	server.OnShutdown("collab", container.Svcs.Collab.Close)
+	server.OnShutdown("database", func(ctx context.Context) error { return connection.Close() })
+	server.OnShutdown("tracing", shutdownTracing)
+	if err := server.Run(ctx); err != nil {
+		log.Fatalf("server stopped with error: %v", err)
+	}
+	log.Println("server stopped")
 }
@@ -2,19 +2,174 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/MujiRahman/golang-simple-note/internal/helper"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBUser     string `yaml:"db_user"`
-	DBPassword string `yaml:"db_password"`
-	DBHost     string `yaml:"db_host"`
-	DBPort     string `yaml:"db_port"`
-	DBName     string `yaml:"db_name"`
-	JWTSecret  string
-	TokenTTL   int
+	DBDriver      string `yaml:"db_driver"` // mysql (default), postgres or sqlite
+	DatabaseURL   string `yaml:"database_url"`
+	DBUser        string `yaml:"db_user"`
+	DBPassword    string `yaml:"db_password"`
+	DBHost        string `yaml:"db_host"`
+	DBPort        string `yaml:"db_port"`
+	DBName        string `yaml:"db_name"` // file path when DBDriver is sqlite
+	DBSSLMode     string `yaml:"db_sslmode"`
+	DBTimezone    string `yaml:"db_timezone"`
+	DBAutoMigrate bool   `yaml:"db_auto_migrate"`
+	JWTSecret     string
+	TokenTTL      int
+
+	// JWT signing: HS256 with JWTSecret, or RS256/EdDSA with PEM keys in
+	// JWTKeysDir (named <kid>.pem; the last name signs, all verify). Secrets in
+	// JWTPreviousSecrets still verify HS256 tokens after JWTSecret is rotated.
+	JWTAlgorithm           string        `yaml:"jwt_algorithm"`
+	JWTPreviousSecrets     []string      `yaml:"jwt_previous_secrets"`
+	JWTKeysDir             string        `yaml:"jwt_keys_dir"`
+	JWTKeyRotationInterval time.Duration `yaml:"jwt_key_rotation_interval"` // 0 disables automatic rotation
+	JWTIssuer              string        `yaml:"jwt_issuer"`                // "iss" claim; checked when set
+	JWTAudience            string        `yaml:"jwt_audience"`              // "aud" claim; checked when set
+
+	HTTPAddr              string        `yaml:"http_addr"`
+	HTTPReadTimeout       time.Duration `yaml:"http_read_timeout"`
+	HTTPReadHeaderTimeout time.Duration `yaml:"http_read_header_timeout"`
+	HTTPWriteTimeout      time.Duration `yaml:"http_write_timeout"`
+	HTTPIdleTimeout       time.Duration `yaml:"http_idle_timeout"`
+	HTTPMaxHeaderBytes    int           `yaml:"http_max_header_bytes"`
+	ShutdownTimeout       time.Duration `yaml:"shutdown_timeout"`
+
+	MetricsAddr  string `yaml:"metrics_addr"`  // separate admin listener for /metrics; empty serves it on the main router
+	MetricsToken string `yaml:"metrics_token"` // optional bearer token required to scrape /metrics
+
+	ServiceName        string  `yaml:"service_name"`
+	TracingExporter    string  `yaml:"tracing_exporter"` // otlp, stdout or none
+	OTLPEndpoint       string  `yaml:"otlp_endpoint"`    // collector URL or host:port (OTLP/HTTP)
+	OTLPInsecure       bool    `yaml:"otlp_insecure"`
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio"`
+
+	// Rate limits are requests per minute.
+	RateLimitEnabled       bool `yaml:"rate_limit_enabled"`
+	RateLimitPublic        int  `yaml:"rate_limit_public"`        // per IP, unauthenticated routes
+	RateLimitAuthenticated int  `yaml:"rate_limit_authenticated"` // per user, authenticated routes
+	RateLimitLogin         int  `yaml:"rate_limit_login"`         // per IP
+	RateLimitRegister      int  `yaml:"rate_limit_register"`      // per IP
+	RateLimitExport        int  `yaml:"rate_limit_export"`        // per user, data export requests
+	RateLimitSearch        int  `yaml:"rate_limit_search"`        // per user, user and audit searches
+
+	// Progressive lockout: after LoginLockoutThreshold consecutive failures the
+	// account is locked for LoginLockoutBase, doubling on every further failure
+	// up to LoginLockoutMax. A threshold of 0 disables lockout.
+	LoginLockoutThreshold int           `yaml:"login_lockout_threshold"`
+	LoginLockoutBase      time.Duration `yaml:"login_lockout_base"`
+	LoginLockoutMax       time.Duration `yaml:"login_lockout_max"`
+
+	TOTPIssuer            string        `yaml:"totp_issuer"`              // shown by authenticator apps
+	TwoFactorChallengeTTL time.Duration `yaml:"two_factor_challenge_ttl"` // lifetime of the token returned by /login when 2FA is on
+
+	MailDriver   string `yaml:"mail_driver"` // smtp, log or memory
+	MailFrom     string `yaml:"mail_from"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+
+	AppBaseURL           string        `yaml:"app_base_url"` // used to build links in emails
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl"`
+
+	// AccountDeletionGracePeriod delays the hard deletion of an account deleted
+	// through DELETE /me; 0 deletes immediately. The purge runs every AccountPurgeInterval.
+	AccountDeletionGracePeriod time.Duration `yaml:"account_deletion_grace_period"`
+	AccountPurgeInterval       time.Duration `yaml:"account_purge_interval"`
+
+	DataExportDir string        `yaml:"data_export_dir"` // where "download my data" archives are written
+	DataExportTTL time.Duration `yaml:"data_export_ttl"` // how long a finished archive can be downloaded
+
+	// Background jobs: JobWorkers run concurrently per server, polling every
+	// JobPollInterval. A failed job is retried after JobRetryBase, doubling
+	// after each further failure, and is dead after JobMaxAttempts. A job
+	// running longer than JobTimeout is cancelled.
+	JobWorkers      int           `yaml:"job_workers"` // 0 disables the workers
+	JobPollInterval time.Duration `yaml:"job_poll_interval"`
+	JobMaxAttempts  int           `yaml:"job_max_attempts"`
+	JobRetryBase    time.Duration `yaml:"job_retry_base"`
+	JobTimeout      time.Duration `yaml:"job_timeout"`
+
+	// Transactional outbox: the relay publishes pending domain events every
+	// OutboxPollInterval, retrying a failed event after OutboxRetryBase,
+	// doubling after each further failure, until it is dead after
+	// OutboxMaxAttempts. Published events are deleted after OutboxRetention.
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval"` // 0 disables the relay
+	OutboxMaxAttempts  int           `yaml:"outbox_max_attempts"`
+	OutboxRetryBase    time.Duration `yaml:"outbox_retry_base"`
+	OutboxRetention    time.Duration `yaml:"outbox_retention"` // 0 keeps them
+
+	// Real-time co-editing: open documents are saved to their notes every
+	// CollabSnapshotInterval and when their last editor leaves. An open
+	// document keeps its last CollabMaxHistory operations, so that editors
+	// lagging behind further have to reload it.
+	CollabSnapshotInterval time.Duration `yaml:"collab_snapshot_interval"` // 0 saves only when the last editor leaves
+	CollabMaxHistory       int           `yaml:"collab_max_history"`
+
+	// Server-sent events: every server reads the note events of the outbox
+	// every EventsPollInterval and keeps the last EventsReplayBuffer of them
+	// for clients reconnecting with Last-Event-ID. Idle streams get a
+	// heartbeat every EventsHeartbeatInterval.
+	EventsPollInterval      time.Duration `yaml:"events_poll_interval"` // 0 stops delivering events
+	EventsReplayBuffer      int           `yaml:"events_replay_buffer"`
+	EventsHeartbeatInterval time.Duration `yaml:"events_heartbeat_interval"`
+
+	// Delta sync: the tombstones of deleted notes are kept for
+	// SyncTombstoneRetention. Clients that last synced before that have to
+	// start over with a full sync.
+	SyncTombstoneRetention time.Duration `yaml:"sync_tombstone_retention"` // 0 keeps them
+
+	// Outgoing webhooks. A delivery is attempted up to WebhookMaxAttempts
+	// times, waiting WebhookRetryBase after the first failure and doubling
+	// after each further one. A webhook is disabled after WebhookDisableAfter
+	// consecutive failed attempts. Receivers on loopback and private addresses
+	// are refused unless WebhookAllowPrivateNetworks is set.
+	WebhookPollInterval         time.Duration `yaml:"webhook_poll_interval"`
+	WebhookTimeout              time.Duration `yaml:"webhook_timeout"`
+	WebhookMaxAttempts          int           `yaml:"webhook_max_attempts"`
+	WebhookRetryBase            time.Duration `yaml:"webhook_retry_base"`
+	WebhookDisableAfter         int           `yaml:"webhook_disable_after"`
+	WebhookAllowPrivateNetworks bool          `yaml:"webhook_allow_private_networks"`
+
+	// Password hashing: argon2id (default) or bcrypt. Hashes made with another
+	// algorithm or other parameters are upgraded on the next successful login.
+	PasswordHashAlgorithm string `yaml:"password_hash_algorithm"`
+	BcryptCost            int    `yaml:"bcrypt_cost"`
+	Argon2Memory          uint32 `yaml:"argon2_memory"` // KiB
+	Argon2Iterations      uint32 `yaml:"argon2_iterations"`
+	Argon2Parallelism     uint8  `yaml:"argon2_parallelism"`
+
+	// Password policy for registration and password changes.
+	PasswordMinLength     int    `yaml:"password_min_length"`
+	PasswordCheckBreached bool   `yaml:"password_check_breached"` // refuse passwords from the built-in common list
+	PasswordBreachedList  string `yaml:"password_breached_list"`  // optional file with more breached passwords, one per line
+
+	// Login through external OpenID Connect providers. A provider login is
+	// linked to the local account with the same verified email
+	// (OIDCLinkByEmail), or creates a new account (OIDCAutoProvision).
+	OIDCProviders     []OIDCProvider `yaml:"oidc_providers"`
+	OIDCLinkByEmail   bool           `yaml:"oidc_link_by_email"`
+	OIDCAutoProvision bool           `yaml:"oidc_auto_provision"`
+}
+
+// OIDCProvider is a client registration at an OpenID provider, served under
+// /auth/oidc/<Name>/.
+type OIDCProvider struct {
+	Name         string   `yaml:"name"`
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"` // defaults to AppBaseURL + /auth/oidc/<Name>/callback
+	Scopes       []string `yaml:"scopes"`
 }
 
 func LoadConfig() *Config {
@@ -22,12 +177,187 @@ func LoadConfig() *Config {
 	helper.LogIfError(err, "Warning: .env file not found, using system env")
 
 	return &Config{
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBName:     os.Getenv("DB_NAME"),
-		JWTSecret:  os.Getenv("JWT_SECRET"),
-		TokenTTL:   3600, // Default token TTL in seconds
+		DBDriver:      os.Getenv("DB_DRIVER"),
+		DatabaseURL:   os.Getenv("DATABASE_URL"),
+		DBUser:        os.Getenv("DB_USER"),
+		DBPassword:    os.Getenv("DB_PASSWORD"),
+		DBHost:        os.Getenv("DB_HOST"),
+		DBPort:        os.Getenv("DB_PORT"),
+		DBName:        os.Getenv("DB_NAME"),
+		DBSSLMode:     getEnv("DB_SSLMODE", "disable"),
+		DBTimezone:    getEnv("DB_TIMEZONE", "UTC"),
+		DBAutoMigrate: getEnvBool("DB_AUTO_MIGRATE", false),
+		JWTSecret:     os.Getenv("JWT_SECRET"),
+		TokenTTL:      3600, // Default token TTL in seconds
+
+		JWTAlgorithm:           getEnv("JWT_ALGORITHM", "HS256"),
+		JWTPreviousSecrets:     getEnvList("JWT_PREVIOUS_SECRETS"),
+		JWTKeysDir:             os.Getenv("JWT_KEYS_DIR"),
+		JWTKeyRotationInterval: getEnvDuration("JWT_KEY_ROTATION_INTERVAL", 0),
+		JWTIssuer:              getEnv("JWT_ISSUER", "simple-note"),
+		JWTAudience:            getEnv("JWT_AUDIENCE", "simple-note"),
+
+		HTTPAddr:              getEnv("HTTP_ADDR", ":"+getEnv("APP_PORT", "8080")),
+		HTTPReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPWriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		HTTPIdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		HTTPMaxHeaderBytes:    getEnvInt("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:       getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+
+		MetricsAddr:  os.Getenv("METRICS_ADDR"),
+		MetricsToken: os.Getenv("METRICS_TOKEN"),
+
+		ServiceName:        getEnv("OTEL_SERVICE_NAME", "golang-simple-note"),
+		TracingExporter:    getEnv("TRACING_EXPORTER", "none"),
+		OTLPEndpoint:       os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTLPInsecure:       getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", false),
+		TracingSampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+
+		RateLimitEnabled:       getEnvBool("RATE_LIMIT_ENABLED", true),
+		RateLimitPublic:        getEnvInt("RATE_LIMIT_PUBLIC", 60),
+		RateLimitAuthenticated: getEnvInt("RATE_LIMIT_AUTHENTICATED", 300),
+		RateLimitLogin:         getEnvInt("RATE_LIMIT_LOGIN", 10),
+		RateLimitRegister:      getEnvInt("RATE_LIMIT_REGISTER", 5),
+		RateLimitExport:        getEnvInt("RATE_LIMIT_EXPORT", 2),
+		RateLimitSearch:        getEnvInt("RATE_LIMIT_SEARCH", 30),
+
+		LoginLockoutThreshold: getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LoginLockoutBase:      getEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+		LoginLockoutMax:       getEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+
+		TOTPIssuer:            getEnv("TOTP_ISSUER", "SimpleNote"),
+		TwoFactorChallengeTTL: getEnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "SimpleNote <no-reply@localhost>"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnvInt("SMTP_PORT", 1025),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+
+		AppBaseURL:           getEnv("APP_BASE_URL", "http://localhost:8080"),
+		EmailVerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		PasswordResetTTL:     getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+
+		AccountDeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 0),
+		AccountPurgeInterval:       getEnvDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+
+		DataExportDir: getEnv("DATA_EXPORT_DIR", "data/exports"),
+		DataExportTTL: getEnvDuration("DATA_EXPORT_TTL", 48*time.Hour),
+
+		JobWorkers:      getEnvInt("JOB_WORKERS", 4),
+		JobPollInterval: getEnvDuration("JOB_POLL_INTERVAL", time.Second),
+		JobMaxAttempts:  getEnvInt("JOB_MAX_ATTEMPTS", 5),
+		JobRetryBase:    getEnvDuration("JOB_RETRY_BASE", 10*time.Second),
+		JobTimeout:      getEnvDuration("JOB_TIMEOUT", 5*time.Minute),
+
+		OutboxPollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxMaxAttempts:  getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+		OutboxRetryBase:    getEnvDuration("OUTBOX_RETRY_BASE", 5*time.Second),
+		OutboxRetention:    getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		CollabSnapshotInterval: getEnvDuration("COLLAB_SNAPSHOT_INTERVAL", 5*time.Second),
+		CollabMaxHistory:       getEnvInt("COLLAB_MAX_HISTORY", 1000),
+
+		EventsPollInterval:      getEnvDuration("EVENTS_POLL_INTERVAL", time.Second),
+		EventsReplayBuffer:      getEnvInt("EVENTS_REPLAY_BUFFER", 1000),
+		EventsHeartbeatInterval: getEnvDuration("EVENTS_HEARTBEAT_INTERVAL", 15*time.Second),
+
+		SyncTombstoneRetention: getEnvDuration("SYNC_TOMBSTONE_RETENTION", 30*24*time.Hour),
+
+		WebhookPollInterval:         getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		WebhookTimeout:              getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:          getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		WebhookRetryBase:            getEnvDuration("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookDisableAfter:         getEnvInt("WEBHOOK_DISABLE_AFTER", 20),
+		WebhookAllowPrivateNetworks: getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		BcryptCost:            getEnvInt("BCRYPT_COST", 10),
+		Argon2Memory:          uint32(getEnvInt("ARGON2_MEMORY", 64*1024)),
+		Argon2Iterations:      uint32(getEnvInt("ARGON2_ITERATIONS", 3)),
+		Argon2Parallelism:     uint8(getEnvInt("ARGON2_PARALLELISM", 2)),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordCheckBreached: getEnvBool("PASSWORD_CHECK_BREACHED", true),
+		PasswordBreachedList:  os.Getenv("PASSWORD_BREACHED_LIST"),
+
+		OIDCProviders:     loadOIDCProviders(),
+		OIDCLinkByEmail:   getEnvBool("OIDC_LINK_BY_EMAIL", true),
+		OIDCAutoProvision: getEnvBool("OIDC_AUTO_PROVISION", true),
+	}
+}
+
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS, each
+// configured by OIDC_<NAME>_ISSUER_URL, _CLIENT_ID, _CLIENT_SECRET,
+// _REDIRECT_URL and _SCOPES.
+func loadOIDCProviders() []OIDCProvider {
+	var out []OIDCProvider
+	for _, name := range getEnvList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		scopes := getEnvList(prefix + "SCOPES")
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+		out = append(out, OIDCProvider{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER_URL"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       scopes,
+		})
+	}
+	return out
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getEnvInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// getEnvList splits a comma separated value, dropping empty entries.
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func getEnvFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
 	}
+	return v
 }
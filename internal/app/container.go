@@ -1,37 +1,196 @@
 package app
 
 import (
+	"log"
+
 	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
 	"github.com/MujiRahman/golang-simple-note/internal/repository"
 	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+	"github.com/MujiRahman/golang-simple-note/pkg/mailer"
 )
 
 // Container groups repositories and services for easy DI and maintenance.
 type Repositories struct {
-	User repository.UserRepository
-	Note repository.NoteRepository
+	User         repository.UserRepository
+	Note         repository.NoteRepository
+	Token        repository.TokenRepository
+	UserToken    repository.UserTokenRepository
+	DataExport   repository.DataExportRepository
+	Identity     repository.UserIdentityRepository
+	Workspace    repository.WorkspaceRepository
+	Admin        repository.AdminRepository
+	Audit        repository.AuditRepository
+	Webhook      repository.WebhookRepository
+	Job          repository.JobRepository
+	Outbox       repository.OutboxRepository
+	Comment      repository.CommentRepository
+	Notification repository.NotificationRepository
 }
 
 type Services struct {
-	User service.UserService
-	Note service.NoteService
+	User         service.UserService
+	Note         service.NoteService
+	Health       service.HealthService
+	Token        service.TokenService
+	Account      service.AccountService
+	DataExport   service.DataExportService
+	Workspace    service.WorkspaceService
+	Admin        service.AdminService
+	Audit        service.AuditService
+	Webhook      service.WebhookService
+	Job          service.JobService
+	Outbox       service.OutboxRelay
+	UnitOfWork   service.UnitOfWork
+	Comment      service.CommentService
+	Notification service.NotificationService
+	Collab       service.CollabService
+	Sync         service.SyncService
+	EventStream  service.EventStreamService
+	OIDC         service.OIDCService // nil without configured providers
 }
 
 type Container struct {
-	Repos Repositories
-	Svcs  Services
+	Repos  Repositories
+	Svcs   Services
+	Mailer mailer.Mailer
+	Keys   *jwtkeys.KeySet
+	// Events receives the domain events relayed from the outbox.
+	Events service.EventBus
+	// KeyDir is set when the JWT keys are read from JWT_KEYS_DIR and have to
+	// be synced periodically.
+	KeyDir *KeyDir
 }
 
 // NewContainer wires repositories and services using the provided DB connection and config.
 func NewContainer(conn *Connect, cfg *config.Config) *Container {
 	userRepo := repository.NewUserRepository(conn.DB)
 	noteRepo := repository.NewNoteRepository(conn.DB)
+	tokenRepo := repository.NewTokenRepository(conn.DB)
+	userTokenRepo := repository.NewUserTokenRepository(conn.DB)
+	dataExportRepo := repository.NewDataExportRepository(conn.DB)
+	identityRepo := repository.NewUserIdentityRepository(conn.DB)
+	workspaceRepo := repository.NewWorkspaceRepository(conn.DB)
+	adminRepo := repository.NewAdminRepository(conn.DB)
+	auditRepo := repository.NewAuditRepository(conn.DB)
+	webhookRepo := repository.NewWebhookRepository(conn.DB)
+	jobRepo := repository.NewJobRepository(conn.DB)
+	commentRepo := repository.NewCommentRepository(conn.DB)
+	notificationRepo := repository.NewNotificationRepository(conn.DB)
+	outboxRepo := repository.NewOutboxRepository(conn.DB)
+
+	mail, err := NewMailer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	userSvc := service.NewUserService(userRepo, cfg)
-	noteSvc := service.NewNoteService(noteRepo)
+	keys, keyDir, err := NewKeySet(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactor := repository.NewTransactor(conn.DB)
+	events := service.NewEventBus()
+	uow := service.NewUnitOfWork(transactor, outboxRepo)
+	outboxRelay := service.NewOutboxRelay(outboxRepo, events, cfg)
+	auditSvc := service.NewAuditService(auditRepo, transactor)
+	userSvc := service.NewUserService(userRepo, workspaceRepo, auditSvc, uow, keys, cfg)
+	workspaceSvc := service.NewWorkspaceService(workspaceRepo, userRepo, auditSvc, uow)
+	webhookSvc := service.NewWebhookService(webhookRepo, cfg)
+	// webhooks are fanned out from the relayed events; event streams read
+	// the outbox themselves, as every server needs every event
+	for _, topic := range model.WebhookEvents {
+		events.Subscribe(topic, inUnitOfWork(uow, webhookSvc.HandleEvent))
+	}
+	notificationSvc := service.NewNotificationService(notificationRepo, userRepo, workspaceRepo, uow)
+	noteSvc := service.NewNoteService(noteRepo, workspaceSvc, auditSvc, uow, notificationSvc)
+	collabSvc := service.NewCollabService(noteRepo, noteSvc, workspaceSvc, userRepo, outboxRepo, cfg)
+	syncSvc := service.NewSyncService(noteRepo, noteSvc, workspaceSvc, cfg)
+	eventStreamSvc := service.NewEventStreamService(outboxRepo, workspaceRepo, cfg)
+	commentSvc := service.NewCommentService(commentRepo, noteRepo, workspaceSvc, uow, notificationSvc)
+	healthSvc := service.NewHealthService(DatabaseCheck(conn), MigrationsCheck(conn))
+	tokenSvc := service.NewTokenService(tokenRepo, userRepo, auditSvc)
+	accountSvc := service.NewAccountService(userRepo, userTokenRepo, tokenRepo, uow, mail, cfg)
+	adminSvc := service.NewAdminService(adminRepo, userRepo, tokenRepo, accountSvc, auditSvc, uow)
+	jobSvc := service.NewJobService(jobRepo, cfg)
+	dataExportSvc := service.NewDataExportService(dataExportRepo, userRepo, noteRepo, tokenRepo, commentRepo, notificationRepo, workspaceRepo, auditRepo, jobSvc, uow, cfg)
+	jobSvc.Register(model.JobDataExportBuild, dataExportSvc.Build)
+	jobSvc.Register(model.JobAccountPurge, purgeJob("account purge", accountSvc.PurgeDeletedAccounts))
+	jobSvc.Register(model.JobDataExportPurge, purgeJob("data export purge", dataExportSvc.PurgeExpired))
+	jobSvc.Register(model.JobOutboxPurge, purgeJob("outbox purge", outboxRelay.Purge))
+	jobSvc.Register(model.JobNoteTombstonePurge, purgeJob("note tombstone purge", syncSvc.PurgeTombstones))
+	var oidcSvc service.OIDCService
+	if len(cfg.OIDCProviders) > 0 {
+		oidcSvc = service.NewOIDCService(userRepo, identityRepo, workspaceRepo, auditSvc, uow, userSvc, keys, cfg)
+	}
 
 	return &Container{
-		Repos: Repositories{User: userRepo, Note: noteRepo},
-		Svcs:  Services{User: userSvc, Note: noteSvc},
+		Repos: Repositories{
+			User:         userRepo,
+			Note:         noteRepo,
+			Token:        tokenRepo,
+			UserToken:    userTokenRepo,
+			DataExport:   dataExportRepo,
+			Identity:     identityRepo,
+			Workspace:    workspaceRepo,
+			Admin:        adminRepo,
+			Audit:        auditRepo,
+			Webhook:      webhookRepo,
+			Job:          jobRepo,
+			Outbox:       outboxRepo,
+			Comment:      commentRepo,
+			Notification: notificationRepo,
+		},
+		Svcs: Services{
+			User:         userSvc,
+			Note:         noteSvc,
+			Health:       healthSvc,
+			Token:        tokenSvc,
+			Account:      accountSvc,
+			DataExport:   dataExportSvc,
+			Workspace:    workspaceSvc,
+			Admin:        adminSvc,
+			Audit:        auditSvc,
+			Webhook:      webhookSvc,
+			Job:          jobSvc,
+			Outbox:       outboxRelay,
+			UnitOfWork:   uow,
+			Comment:      commentSvc,
+			Notification: notificationSvc,
+			Collab:       collabSvc,
+			Sync:         syncSvc,
+			EventStream:  eventStreamSvc,
+			OIDC:         oidcSvc,
+		},
+		Mailer: mail,
+		Keys:   keys,
+		Events: events,
+		KeyDir: keyDir,
+	}
+}
+
+// RouterOptions passes the container's optional services to NewRouter.
+func (c *Container) RouterOptions() []RouterOption {
+	opts := []RouterOption{
+		WithHealthService(c.Svcs.Health),
+		WithTokenService(c.Svcs.Token),
+		WithAccountService(c.Svcs.Account),
+		WithDataExportService(c.Svcs.DataExport),
+		WithWorkspaceService(c.Svcs.Workspace),
+		WithAdminService(c.Svcs.Admin),
+		WithAuditService(c.Svcs.Audit),
+		WithWebhookService(c.Svcs.Webhook),
+		WithJobService(c.Svcs.Job),
+		WithCommentService(c.Svcs.Comment),
+		WithNotificationService(c.Svcs.Notification),
+		WithCollabService(c.Svcs.Collab),
+		WithSyncService(c.Svcs.Sync),
+		WithEventStreamService(c.Svcs.EventStream),
+		WithKeySet(c.Keys),
+	}
+	if c.Svcs.OIDC != nil {
+		opts = append(opts, WithOIDCService(c.Svcs.OIDC))
 	}
+	return opts
 }
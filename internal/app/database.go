@@ -1,38 +1,168 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/helper"
-	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/migrations"
+	"github.com/MujiRahman/golang-simple-note/pkg/migrate"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 type Connect struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Dialect migrate.Dialect
 }
 
+// NewDB opens the database and makes sure the schema is up to date. When there
+// are pending migrations the server refuses to start, unless DB_AUTO_MIGRATE
+// is enabled in which case they are applied.
 func NewDB(cfg *config.Config) *Connect {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBName,
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	helper.LogFatalIfError(err, "failed to connect to MariaDB: %v")
+	conn, err := OpenDB(cfg)
+	helper.LogFatalIfError(err, "failed to connect to database: %v")
 
 	helper.Print("koneksi data base berhasil yey")
-	// Auto migrate
-	err = db.AutoMigrate(&model.Note{}, &model.User{})
+
+	err = conn.DB.Use(metrics.GormPlugin{})
+	helper.LogFatalIfError(err, "failed to register gorm metrics: %v")
+	err = conn.DB.Use(tracing.GormPlugin{})
+	helper.LogFatalIfError(err, "failed to register gorm tracing: %v")
+	if sqlDB, err := conn.DB.DB(); err == nil {
+		helper.LogIfError(metrics.RegisterDBStats(sqlDB, "main"), "Warning: failed to register db pool metrics")
+	}
+
+	if err := conn.EnsureSchema(context.Background(), cfg.DBAutoMigrate); err != nil {
+		log.Fatal("Migration failed: ", err)
+	}
+
+	return conn
+}
+
+// OpenDB connects to the configured database without touching the schema.
+func OpenDB(cfg *config.Config) (*Connect, error) {
+	dialect, err := DriverDialect(cfg)
 	if err != nil {
-		log.Fatal("Migration failed:", err)
+		return nil, err
+	}
+	dsn := DSN(cfg)
+
+	var dialector gorm.Dialector
+	switch dialect {
+	case migrate.Postgres:
+		dialector = postgres.Open(dsn)
+	case migrate.SQLite:
+		dialector = sqlite.Open(dsn)
+	default:
+		dialector = mysql.Open(dsn)
 	}
 
-	return &Connect{DB: db}
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &Connect{DB: db, Dialect: dialect}, nil
+}
+
+// DriverDialect resolves DB_DRIVER. When it is empty the driver is inferred
+// from a postgres:// DATABASE_URL, falling back to mysql.
+func DriverDialect(cfg *config.Config) (migrate.Dialect, error) {
+	switch strings.ToLower(cfg.DBDriver) {
+	case "", "mysql", "mariadb":
+		if cfg.DBDriver == "" && (strings.HasPrefix(cfg.DatabaseURL, "postgres://") || strings.HasPrefix(cfg.DatabaseURL, "postgresql://")) {
+			return migrate.Postgres, nil
+		}
+		return migrate.MySQL, nil
+	case "postgres", "postgresql", "pgx":
+		return migrate.Postgres, nil
+	case "sqlite", "sqlite3":
+		return migrate.SQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported DB_DRIVER %q", cfg.DBDriver)
+	}
+}
+
+// DSN builds the driver specific connection string, unless DATABASE_URL is set
+// in which case it is handed to the driver verbatim.
+func DSN(cfg *config.Config) string {
+	if cfg.DatabaseURL != "" {
+		return cfg.DatabaseURL
+	}
+	dialect, _ := DriverDialect(cfg)
+	switch dialect {
+	case migrate.Postgres:
+		return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+			cfg.DBHost,
+			cfg.DBUser,
+			cfg.DBPassword,
+			cfg.DBName,
+			cfg.DBPort,
+			cfg.DBSSLMode,
+			cfg.DBTimezone,
+		)
+	case migrate.SQLite:
+		if cfg.DBName == "" {
+			return "simple-note.db"
+		}
+		return cfg.DBName
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			cfg.DBUser,
+			cfg.DBPassword,
+			cfg.DBHost,
+			cfg.DBPort,
+			cfg.DBName,
+		)
+	}
+}
+
+// Migrator returns a migrator for the embedded migrations of the connection's dialect.
+func (c *Connect) Migrator() (*migrate.Migrator, error) {
+	sqlDB, err := c.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, c.Dialect, migrations.FS)
+}
+
+// EnsureSchema verifies applied migrations and either applies pending ones
+// (autoMigrate) or returns an error describing how far behind the schema is.
+func (c *Connect) EnsureSchema(ctx context.Context, autoMigrate bool) error {
+	m, err := c.Migrator()
+	if err != nil {
+		return err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if !autoMigrate {
+		return fmt.Errorf("database schema is behind by %d migration(s); run `go run ./cmd/migrate up` or set DB_AUTO_MIGRATE=true", len(pending))
+	}
+	n, err := m.Up(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("applied %d migration(s)", n)
+	return nil
+}
+
+// Close closes the underlying connection pool.
+func (c *Connect) Close() error {
+	sqlDB, err := c.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
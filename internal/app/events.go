@@ -0,0 +1,15 @@
+package app
+
+import (
+	"context"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+// inUnitOfWork runs h in a transaction, so that when h fails nothing it did
+// is left behind to be done twice once the relay publishes the event again.
+func inUnitOfWork(uow service.UnitOfWork, h service.EventHandler) service.EventHandler {
+	return func(ctx context.Context, e service.Event) error {
+		return uow.Do(ctx, func(ctx context.Context) error { return h(ctx, e) })
+	}
+}
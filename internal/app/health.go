@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+// DatabaseCheck pings the connection pool.
+func DatabaseCheck(conn *Connect) service.HealthCheck {
+	return service.NewHealthCheck("database", func(ctx context.Context) error {
+		sqlDB, err := conn.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// MigrationsCheck fails while migrations are pending or applied ones were modified.
+func MigrationsCheck(conn *Connect) service.HealthCheck {
+	return service.NewHealthCheck("migrations", func(ctx context.Context) error {
+		m, err := conn.Migrator()
+		if err != nil {
+			return err
+		}
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migration(s)", len(pending))
+		}
+		return nil
+	})
+}
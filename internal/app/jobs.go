@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+// StartJobWorkers runs a pool of workers taking jobs off the queue, and
+// enqueues scheduled jobs as they come due, until ctx is cancelled. Idle
+// workers poll every interval. The returned shutdown hook waits for the jobs
+// that are still running.
+func StartJobWorkers(ctx context.Context, jobs service.JobService, workers int, interval time.Duration) func(context.Context) error {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				ran, err := jobs.RunNext(ctx)
+				if err != nil {
+					log.Printf("job worker: %v", err)
+				}
+				if ran && err == nil {
+					continue // more jobs may be due
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(interval):
+				}
+			}
+		}()
+	}
+	scheduler := startLoop(ctx, interval, func(ctx context.Context) {
+		if _, err := jobs.EnqueueScheduled(ctx); err != nil {
+			log.Printf("job scheduler: %v", err)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return func(shutdownCtx context.Context) error {
+		if err := scheduler(shutdownCtx); err != nil {
+			return err
+		}
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// ScheduleJobs stores the recurring jobs of the server, such as the purges
+// run every ACCOUNT_PURGE_INTERVAL.
+func ScheduleJobs(ctx context.Context, jobs service.JobService, cfg *config.Config) error {
+	if cfg.OutboxRetention > 0 {
+		if err := jobs.Schedule(ctx, "outbox-purge", "@hourly", model.JobOutboxPurge, nil); err != nil {
+			return err
+		}
+	}
+	if cfg.SyncTombstoneRetention > 0 {
+		if err := jobs.Schedule(ctx, "note-tombstone-purge", "@hourly", model.JobNoteTombstonePurge, nil); err != nil {
+			return err
+		}
+	}
+	if cfg.AccountPurgeInterval <= 0 {
+		return nil
+	}
+	every := "@every " + cfg.AccountPurgeInterval.String()
+	if cfg.AccountDeletionGracePeriod > 0 {
+		if err := jobs.Schedule(ctx, "account-purge", every, model.JobAccountPurge, nil); err != nil {
+			return err
+		}
+	}
+	return jobs.Schedule(ctx, "data-export-purge", every, model.JobDataExportPurge, nil)
+}
+
+// purgeJob runs a cleanup task such as AccountService.PurgeDeletedAccounts
+// as a job.
+func purgeJob(name string, purge func(context.Context) (int, error)) service.JobHandler {
+	return func(ctx context.Context, _ json.RawMessage) error {
+		n, err := purge(ctx)
+		if n > 0 {
+			log.Printf("%s: removed %d", name, n)
+		}
+		return err
+	}
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+)
+
+// KeySyncInterval is how often a KeyDir is re-read, so that keys rotated by
+// another instance are picked up.
+const KeySyncInterval = time.Minute
+
+// NewKeySet loads the JWT keys configured in cfg. The returned KeyDir is nil
+// unless the keys come from cfg.JWTKeysDir.
+func NewKeySet(cfg *config.Config) (*jwtkeys.KeySet, *KeyDir, error) {
+	switch cfg.JWTAlgorithm {
+	case "", jwtkeys.HS256:
+		if cfg.JWTSecret == "" {
+			log.Println("WARNING: JWT_SECRET is empty")
+		}
+		var previous []*jwtkeys.Key
+		for _, s := range cfg.JWTPreviousSecrets {
+			previous = append(previous, jwtkeys.NewHMACKey([]byte(s)))
+		}
+		keys, err := jwtkeys.NewKeySet(jwtkeys.NewHMACKey([]byte(cfg.JWTSecret)), previous...)
+		return keys, nil, err
+	case jwtkeys.RS256, jwtkeys.EdDSA:
+		if cfg.JWTKeysDir == "" {
+			log.Printf("WARNING: JWT_KEYS_DIR is not set, signing with an ephemeral %s key; tokens will not survive a restart", cfg.JWTAlgorithm)
+			k, err := jwtkeys.Generate(newKeyID(time.Now()), cfg.JWTAlgorithm)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys, err := jwtkeys.NewKeySet(k)
+			return keys, nil, err
+		}
+		d := &KeyDir{
+			dir:    cfg.JWTKeysDir,
+			alg:    cfg.JWTAlgorithm,
+			rotate: cfg.JWTKeyRotationInterval,
+			// a retired key has to verify every token it signed until it expires
+			retain: time.Duration(cfg.TokenTTL)*time.Second + cfg.TwoFactorChallengeTTL + KeySyncInterval,
+			keys:   &jwtkeys.KeySet{},
+		}
+		if _, err := d.Sync(context.Background()); err != nil {
+			return nil, nil, err
+		}
+		return d.keys, d, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown JWT_ALGORITHM %q", cfg.JWTAlgorithm)
+	}
+}
+
+// KeyDir keeps a KeySet in sync with a directory of PEM keys named <kid>.pem.
+// The private key with the last name signs; every key verifies. With a
+// rotation interval a new key is generated once the signing key is older than
+// the interval, and keys that were replaced longer ago than a token lives are
+// deleted.
+type KeyDir struct {
+	dir    string
+	alg    string
+	rotate time.Duration
+	retain time.Duration
+	keys   *jwtkeys.KeySet
+}
+
+type keyFile struct {
+	key     *jwtkeys.Key
+	path    string
+	modTime time.Time
+}
+
+// Sync reloads the directory, rotates and prunes keys, and returns the number
+// of keys deleted. It matches the signature StartPurge expects.
+func (d *KeyDir) Sync(ctx context.Context) (int, error) {
+	files, err := d.load()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	active := lastSigning(files, d.alg)
+	if active < 0 || (d.rotate > 0 && now.Sub(files[active].modTime) >= d.rotate) {
+		f, err := d.generate(now)
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, f)
+		sort.Slice(files, func(i, j int) bool { return files[i].key.ID < files[j].key.ID })
+		active = lastSigning(files, d.alg)
+	}
+
+	removed := 0
+	if d.rotate > 0 {
+		// files[i] was retired when files[i+1] appeared
+		kept, newActive := files[:0], active
+		for i, f := range files {
+			if i < active && now.Sub(files[i+1].modTime) > d.retain {
+				if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return removed, err
+				}
+				log.Printf("jwt keys: removed retired key %s", f.key.ID)
+				removed++
+				newActive--
+				continue
+			}
+			kept = append(kept, f)
+		}
+		files, active = kept, newActive
+	}
+
+	others := make([]*jwtkeys.Key, 0, len(files)-1)
+	for i, f := range files {
+		if i != active {
+			others = append(others, f.key)
+		}
+	}
+	return removed, d.keys.Replace(files[active].key, others...)
+}
+
+// load reads all keys of the directory, sorted by id.
+func (d *KeyDir) load() ([]keyFile, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var files []keyFile
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pem" {
+			continue
+		}
+		path := filepath.Join(d.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		k, err := jwtkeys.ParsePEM(strings.TrimSuffix(e.Name(), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", path, err)
+		}
+		if k.Method.Alg() != d.alg {
+			log.Printf("WARNING: jwt key %s is %s, not %s; it is only used to verify", path, k.Method.Alg(), d.alg)
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, keyFile{key: k, path: path, modTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// generate writes a new private key. The file is linked into place so other
+// instances never read a partial key, and two instances rotating in the same
+// second end up with one key.
+func (d *KeyDir) generate(now time.Time) (keyFile, error) {
+	id := newKeyID(now)
+	path := filepath.Join(d.dir, id+".pem")
+	k, err := jwtkeys.Generate(id, d.alg)
+	if err != nil {
+		return keyFile{}, err
+	}
+	data, err := jwtkeys.MarshalPEM(k)
+	if err != nil {
+		return keyFile{}, err
+	}
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return keyFile{}, err
+	}
+	tmp, err := os.CreateTemp(d.dir, id+".pem.*.tmp")
+	if err != nil {
+		return keyFile{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return keyFile{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return keyFile{}, err
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return keyFile{}, err
+		}
+		// another instance won the race, use its key
+		if data, err = os.ReadFile(path); err != nil {
+			return keyFile{}, err
+		}
+		if k, err = jwtkeys.ParsePEM(id, data); err != nil {
+			return keyFile{}, err
+		}
+	} else {
+		log.Printf("jwt keys: generated %s key %s", d.alg, id)
+	}
+	return keyFile{key: k, path: path, modTime: now}, nil
+}
+
+// lastSigning returns the index of the last key that can sign with alg, or -1.
+func lastSigning(files []keyFile, alg string) int {
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].key.CanSign() && files[i].key.Method.Alg() == alg {
+			return i
+		}
+	}
+	return -1
+}
+
+// newKeyID names keys by creation time, so sorting by id sorts by age.
+func newKeyID(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/pkg/logger"
+	"github.com/MujiRahman/golang-simple-note/pkg/mailer"
+)
+
+// NewMailer picks the mail transport named by cfg.MailDriver.
+func NewMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case "", "log":
+		return mailer.NewLogMailer(logger.InfoLogger), nil
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", cfg.MailDriver)
+	}
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// StartPurge runs a cleanup task such as AccountService.PurgeDeletedAccounts
+// every interval until ctx is cancelled. The returned shutdown hook waits for
+// a run that is still in progress.
+func StartPurge(ctx context.Context, name string, interval time.Duration, purge func(context.Context) (int, error)) func(context.Context) error {
+	return startLoop(ctx, interval, func(ctx context.Context) {
+		n, err := purge(ctx)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+		if n > 0 {
+			log.Printf("%s: removed %d", name, n)
+		}
+	})
+}
+
+// StartWorker is StartPurge for frequent background work such as
+// WebhookService.DeliverDue, where only errors are worth logging.
+func StartWorker(ctx context.Context, name string, interval time.Duration, work func(context.Context) (int, error)) func(context.Context) error {
+	return startLoop(ctx, interval, func(ctx context.Context) {
+		if _, err := work(ctx); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+	})
+}
+
+func startLoop(ctx context.Context, interval time.Duration, run func(context.Context)) func(context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run(ctx)
+			}
+		}
+	}()
+	return func(shutdownCtx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
@@ -2,35 +2,339 @@ package app
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/controller"
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
 	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
 	"github.com/MujiRahman/golang-simple-note/pkg/middleware"
+	"github.com/MujiRahman/golang-simple-note/pkg/ratelimit"
 )
 
+// routerDeps holds the optional dependencies of NewRouter.
+type routerDeps struct {
+	healthSvc       service.HealthService
+	tokenSvc        service.TokenService
+	accountSvc      service.AccountService
+	dataExportSvc   service.DataExportService
+	oidcSvc         service.OIDCService
+	workspaceSvc    service.WorkspaceService
+	adminSvc        service.AdminService
+	auditSvc        service.AuditService
+	webhookSvc      service.WebhookService
+	jobSvc          service.JobService
+	commentSvc      service.CommentService
+	notificationSvc service.NotificationService
+	collabSvc       service.CollabService
+	syncSvc         service.SyncService
+	eventSvc        service.EventStreamService
+	keys            *jwtkeys.KeySet
+	rateLimitStore  ratelimit.Store
+}
+
+// RouterOption supplies an optional dependency to NewRouter.
+type RouterOption func(*routerDeps)
+
+// WithHealthService sets the checks behind /readyz. Without it /readyz only
+// reports that the process is up.
+func WithHealthService(hs service.HealthService) RouterOption {
+	return func(d *routerDeps) { d.healthSvc = hs }
+}
+
+// WithTokenService enables personal access tokens: /account/tokens and PAT
+// authentication in AuthMiddleware.
+func WithTokenService(ts service.TokenService) RouterOption {
+	return func(d *routerDeps) { d.tokenSvc = ts }
+}
+
+// WithAccountService enables email verification and password reset.
+func WithAccountService(as service.AccountService) RouterOption {
+	return func(d *routerDeps) { d.accountSvc = as }
+}
+
+// WithDataExportService enables the /me/data-export endpoints.
+func WithDataExportService(ds service.DataExportService) RouterOption {
+	return func(d *routerDeps) { d.dataExportSvc = ds }
+}
+
+// WithOIDCService enables login through OpenID Connect providers under /auth/oidc/.
+func WithOIDCService(oidcSvc service.OIDCService) RouterOption {
+	return func(d *routerDeps) { d.oidcSvc = oidcSvc }
+}
+
+// WithWorkspaceService enables the /workspaces and /invitations endpoints
+// for managing workspaces, members and invitations.
+func WithWorkspaceService(ws service.WorkspaceService) RouterOption {
+	return func(d *routerDeps) { d.workspaceSvc = ws }
+}
+
+// WithAdminService enables the /admin endpoints for users with the admin role.
+func WithAdminService(as service.AdminService) RouterOption {
+	return func(d *routerDeps) { d.adminSvc = as }
+}
+
+// WithAuditService enables reading the audit log at /me/audit and, with
+// WithAdminService, /admin/audit.
+func WithAuditService(as service.AuditService) RouterOption {
+	return func(d *routerDeps) { d.auditSvc = as }
+}
+
+// WithWebhookService enables managing webhooks at /webhooks.
+func WithWebhookService(ws service.WebhookService) RouterOption {
+	return func(d *routerDeps) { d.webhookSvc = ws }
+}
+
+// WithJobService lets admins inspect and retry background jobs under
+// /admin/jobs; it needs WithAdminService.
+func WithJobService(js service.JobService) RouterOption {
+	return func(d *routerDeps) { d.jobSvc = js }
+}
+
+// WithCommentService enables threaded comments under /notes/:id/comments.
+func WithCommentService(cs service.CommentService) RouterOption {
+	return func(d *routerDeps) { d.commentSvc = cs }
+}
+
+// WithNotificationService enables the caller's inbox at /notifications.
+func WithNotificationService(ns service.NotificationService) RouterOption {
+	return func(d *routerDeps) { d.notificationSvc = ns }
+}
+
+// WithCollabService enables real-time editing over a WebSocket at
+// /notes/:id/collab.
+func WithCollabService(cs service.CollabService) RouterOption {
+	return func(d *routerDeps) { d.collabSvc = cs }
+}
+
+// WithSyncService enables the delta sync of offline clients at /sync.
+func WithSyncService(ss service.SyncService) RouterOption {
+	return func(d *routerDeps) { d.syncSvc = ss }
+}
+
+// WithEventStreamService enables the server-sent events stream of note
+// changes and notifications at /events.
+func WithEventStreamService(es service.EventStreamService) RouterOption {
+	return func(d *routerDeps) { d.eventSvc = es }
+}
+
+// WithKeySet publishes the public JWT keys at /.well-known/jwks.json.
+func WithKeySet(keys *jwtkeys.KeySet) RouterOption {
+	return func(d *routerDeps) { d.keys = keys }
+}
+
+// WithRateLimitStore replaces the in-memory rate limit store, e.g. with a
+// backend shared between instances.
+func WithRateLimitStore(store ratelimit.Store) RouterOption {
+	return func(d *routerDeps) { d.rateLimitStore = store }
+}
+
 // NewRouter builds router with DI
-func NewRouter(userSvc service.UserService, noteSvc service.NoteService, cfg *config.Config) http.Handler {
+func NewRouter(userSvc service.UserService, noteSvc service.NoteService, cfg *config.Config, opts ...RouterOption) http.Handler {
+	deps := routerDeps{healthSvc: service.NewHealthService()}
+	for _, opt := range opts {
+		opt(&deps)
+	}
+	if deps.rateLimitStore == nil {
+		deps.rateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	// limit returns a per-minute rate limiter, or a pass-through when rate
+	// limiting is disabled or perMinute is not positive.
+	limit := func(name string, perMinute int, key middleware.KeyFunc) gin.HandlerFunc {
+		if !cfg.RateLimitEnabled || perMinute <= 0 {
+			return func(c *gin.Context) { c.Next() }
+		}
+		return middleware.RateLimit(deps.rateLimitStore, name, ratelimit.PerMinute(perMinute), key)
+	}
+	publicLimit := limit("public", cfg.RateLimitPublic, middleware.ByIP)
+	userLimit := limit("user", cfg.RateLimitAuthenticated, middleware.ByUser)
+	searchLimit := limit("search", cfg.RateLimitSearch, middleware.ByUser)
+
 	r := gin.Default()
+	r.Use(middleware.Tracing(), middleware.Metrics(), middleware.RequestInfo())
 
 	// controllers
-	userCtrl := controller.NewUserController(userSvc)
+	userCtrl := controller.NewUserController(userSvc, deps.accountSvc)
 	noteCtrl := controller.NewNoteController(noteSvc)
+	healthCtrl := controller.NewHealthController(deps.healthSvc)
+	twoFactorCtrl := controller.NewTwoFactorController(userSvc)
+	accountCtrl := controller.NewAccountController(deps.accountSvc)
+	meCtrl := controller.NewMeController(userSvc, deps.accountSvc)
+
+	// probes
+	r.GET("/healthz", healthCtrl.Healthz)
+	r.GET("/readyz", healthCtrl.Readyz)
+	r.GET("/version", healthCtrl.Version)
+	if cfg.MetricsAddr == "" {
+		r.GET("/metrics", middleware.BearerTokenGuard(cfg.MetricsToken), gin.WrapH(metrics.Handler()))
+	}
+
+	if deps.keys != nil {
+		r.GET("/.well-known/jwks.json", controller.NewJWKSController(deps.keys).Get)
+	}
 
 	// public
-	r.POST("/register", userCtrl.Register)
-	r.POST("/login", userCtrl.Login)
+	r.POST("/register", publicLimit, limit("register", cfg.RateLimitRegister, middleware.ByIP), userCtrl.Register)
+	loginLimit := limit("login", cfg.RateLimitLogin, middleware.ByIP)
+	r.POST("/login", publicLimit, loginLimit, userCtrl.Login)
+	r.POST("/login/2fa", publicLimit, loginLimit, twoFactorCtrl.Login)
+	if deps.accountSvc != nil {
+		r.POST("/email/verify", publicLimit, accountCtrl.VerifyEmail)
+		r.POST("/password/forgot", publicLimit, loginLimit, accountCtrl.ForgotPassword)
+		r.POST("/password/reset", publicLimit, loginLimit, accountCtrl.ResetPassword)
+	}
+	if deps.oidcSvc != nil {
+		oidcCtrl := controller.NewOIDCController(deps.oidcSvc, strings.HasPrefix(cfg.AppBaseURL, "https://"))
+		r.GET("/auth/oidc/:provider/start", publicLimit, oidcCtrl.Start)
+		r.GET("/auth/oidc/:provider/callback", publicLimit, loginLimit, oidcCtrl.Callback)
+	}
 
 	// protected group: using gin middleware
-	authMw := middleware.AuthMiddleware(userSvc)
+	authMw := middleware.AuthMiddleware(userSvc, deps.tokenSvc)
+	notesRead := middleware.RequireScope(model.ScopeNotesRead)
+	notesWrite := middleware.RequireScope(model.ScopeNotesWrite)
+	accountAdmin := middleware.RequireScope(model.ScopeAccountAdmin)
+
+	r.POST("/notes", authMw, userLimit, notesWrite, noteCtrl.Create)
+	r.GET("/notes", authMw, userLimit, notesRead, noteCtrl.List)
+	r.GET("/notes/:id", authMw, userLimit, notesRead, noteCtrl.Get)
+	r.PUT("/notes/:id", authMw, userLimit, notesWrite, noteCtrl.Update)
+	r.DELETE("/notes/:id", authMw, userLimit, notesWrite, noteCtrl.Delete)
+
+	// the same note routes, scoped to a workspace by path instead of header
+	r.POST("/workspaces/:workspace_id/notes", authMw, userLimit, notesWrite, noteCtrl.Create)
+	r.GET("/workspaces/:workspace_id/notes", authMw, userLimit, notesRead, noteCtrl.List)
+	r.GET("/workspaces/:workspace_id/notes/:id", authMw, userLimit, notesRead, noteCtrl.Get)
+	r.PUT("/workspaces/:workspace_id/notes/:id", authMw, userLimit, notesWrite, noteCtrl.Update)
+	r.DELETE("/workspaces/:workspace_id/notes/:id", authMw, userLimit, notesWrite, noteCtrl.Delete)
+
+	if deps.commentSvc != nil {
+		commentCtrl := controller.NewCommentController(deps.commentSvc)
+		for _, prefix := range []string{"/notes/:id/comments", "/workspaces/:workspace_id/notes/:id/comments"} {
+			r.GET(prefix, authMw, userLimit, notesRead, commentCtrl.List)
+			r.POST(prefix, authMw, userLimit, notesWrite, commentCtrl.Create)
+			r.GET(prefix+"/:comment_id", authMw, userLimit, notesRead, commentCtrl.Get)
+			r.PATCH(prefix+"/:comment_id", authMw, userLimit, notesWrite, commentCtrl.Update)
+			r.DELETE(prefix+"/:comment_id", authMw, userLimit, notesWrite, commentCtrl.Delete)
+			r.GET(prefix+"/:comment_id/history", authMw, userLimit, notesRead, commentCtrl.History)
+		}
+	}
+
+	if deps.collabSvc != nil {
+		collabCtrl := controller.NewCollabController(deps.collabSvc)
+		wsToken := middleware.WebSocketToken()
+		r.GET("/notes/:id/collab", wsToken, authMw, userLimit, notesRead, collabCtrl.Connect)
+		r.GET("/workspaces/:workspace_id/notes/:id/collab", wsToken, authMw, userLimit, notesRead, collabCtrl.Connect)
+	}
 
-	r.POST("/notes", authMw, noteCtrl.Create)
-	r.GET("/notes", authMw, noteCtrl.List)
-	r.GET("/notes/:id", authMw, noteCtrl.Get)
-	r.PUT("/notes/:id", authMw, noteCtrl.Update)
-	r.DELETE("/notes/:id", authMw, noteCtrl.Delete)
+	if deps.syncSvc != nil {
+		syncCtrl := controller.NewSyncController(deps.syncSvc)
+		for _, path := range []string{"/sync", "/workspaces/:workspace_id/sync"} {
+			r.GET(path, authMw, userLimit, notesRead, syncCtrl.Changes)
+			r.POST(path, authMw, userLimit, notesWrite, syncCtrl.Push)
+		}
+	}
+
+	if deps.eventSvc != nil {
+		eventCtrl := controller.NewEventController(deps.eventSvc, cfg.EventsHeartbeatInterval)
+		r.GET("/events", authMw, userLimit, notesRead, eventCtrl.Stream)
+	}
+
+	if deps.notificationSvc != nil {
+		notificationCtrl := controller.NewNotificationController(deps.notificationSvc)
+		r.GET("/notifications", authMw, userLimit, notesRead, notificationCtrl.List)
+		r.GET("/notifications/unread-count", authMw, userLimit, notesRead, notificationCtrl.UnreadCount)
+		r.POST("/notifications/read-all", authMw, userLimit, notesWrite, notificationCtrl.MarkAllRead)
+		r.POST("/notifications/:id/read", authMw, userLimit, notesWrite, notificationCtrl.MarkRead)
+		r.POST("/notifications/:id/unread", authMw, userLimit, notesWrite, notificationCtrl.MarkUnread)
+	}
+
+	if deps.workspaceSvc != nil {
+		wsCtrl := controller.NewWorkspaceController(deps.workspaceSvc)
+		r.GET("/workspaces", authMw, userLimit, notesRead, wsCtrl.List)
+		r.POST("/workspaces", authMw, userLimit, accountAdmin, wsCtrl.Create)
+		r.GET("/workspaces/:workspace_id", authMw, userLimit, notesRead, wsCtrl.Get)
+		r.PATCH("/workspaces/:workspace_id", authMw, userLimit, accountAdmin, wsCtrl.Update)
+		r.DELETE("/workspaces/:workspace_id", authMw, userLimit, accountAdmin, wsCtrl.Delete)
+		r.GET("/workspaces/:workspace_id/members", authMw, userLimit, notesRead, wsCtrl.Members)
+		r.PATCH("/workspaces/:workspace_id/members/:user_id", authMw, userLimit, accountAdmin, wsCtrl.UpdateMember)
+		r.DELETE("/workspaces/:workspace_id/members/:user_id", authMw, userLimit, accountAdmin, wsCtrl.RemoveMember)
+		r.GET("/workspaces/:workspace_id/invitations", authMw, userLimit, accountAdmin, wsCtrl.PendingInvitations)
+		r.POST("/workspaces/:workspace_id/invitations", authMw, userLimit, accountAdmin, wsCtrl.Invite)
+		r.DELETE("/workspaces/:workspace_id/invitations/:id", authMw, userLimit, accountAdmin, wsCtrl.RevokeInvitation)
+
+		r.GET("/invitations", authMw, userLimit, accountAdmin, wsCtrl.Invitations)
+		r.POST("/invitations/:id/accept", authMw, userLimit, accountAdmin, wsCtrl.AcceptInvitation)
+		r.POST("/invitations/:id/decline", authMw, userLimit, accountAdmin, wsCtrl.DeclineInvitation)
+	}
+
+	r.POST("/account/2fa/setup", authMw, userLimit, accountAdmin, twoFactorCtrl.Setup)
+	r.POST("/account/2fa/verify", authMw, userLimit, accountAdmin, twoFactorCtrl.Verify)
+	r.POST("/account/2fa/disable", authMw, userLimit, accountAdmin, twoFactorCtrl.Disable)
+
+	if deps.accountSvc != nil {
+		r.POST("/email/verify/resend", authMw, userLimit, accountAdmin, accountCtrl.ResendVerification)
+
+		r.GET("/me", authMw, userLimit, meCtrl.Get)
+		r.PATCH("/me", authMw, userLimit, accountAdmin, meCtrl.Update)
+		r.POST("/me/password", authMw, userLimit, accountAdmin, meCtrl.ChangePassword)
+		r.DELETE("/me", authMw, userLimit, accountAdmin, meCtrl.Delete)
+	}
+
+	if deps.dataExportSvc != nil {
+		exportCtrl := controller.NewDataExportController(deps.dataExportSvc)
+		r.POST("/me/data-export", authMw, userLimit, limit("export", cfg.RateLimitExport, middleware.ByUser), accountAdmin, exportCtrl.Create)
+		r.GET("/me/data-export/:id", authMw, userLimit, accountAdmin, exportCtrl.Get)
+	}
+
+	if deps.auditSvc != nil {
+		r.GET("/me/audit", authMw, userLimit, searchLimit, accountAdmin, controller.NewAuditController(deps.auditSvc).Mine)
+	}
+
+	if deps.webhookSvc != nil {
+		webhookCtrl := controller.NewWebhookController(deps.webhookSvc)
+		r.GET("/webhooks", authMw, userLimit, accountAdmin, webhookCtrl.List)
+		r.POST("/webhooks", authMw, userLimit, accountAdmin, webhookCtrl.Create)
+		r.GET("/webhooks/:id", authMw, userLimit, accountAdmin, webhookCtrl.Get)
+		r.PATCH("/webhooks/:id", authMw, userLimit, accountAdmin, webhookCtrl.Update)
+		r.DELETE("/webhooks/:id", authMw, userLimit, accountAdmin, webhookCtrl.Delete)
+		r.GET("/webhooks/:id/deliveries", authMw, userLimit, accountAdmin, webhookCtrl.Deliveries)
+		r.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", authMw, userLimit, accountAdmin, webhookCtrl.Redeliver)
+	}
+
+	if deps.tokenSvc != nil {
+		tokenCtrl := controller.NewTokenController(deps.tokenSvc)
+		r.GET("/account/tokens", authMw, userLimit, accountAdmin, tokenCtrl.List)
+		r.POST("/account/tokens", authMw, userLimit, accountAdmin, tokenCtrl.Create)
+		r.DELETE("/account/tokens/:id", authMw, userLimit, accountAdmin, tokenCtrl.Revoke)
+	}
+
+	if deps.adminSvc != nil {
+		adminCtrl := controller.NewAdminController(deps.adminSvc)
+		admin := r.Group("/admin", authMw, userLimit, middleware.RequireScope(model.ScopeAdmin), middleware.RequireAdmin(deps.adminSvc))
+		admin.GET("/users", searchLimit, adminCtrl.ListUsers)
+		admin.GET("/users/:id", adminCtrl.GetUser)
+		admin.POST("/users/:id/disable", adminCtrl.DisableUser)
+		admin.POST("/users/:id/enable", adminCtrl.EnableUser)
+		admin.POST("/users/:id/password-reset", adminCtrl.ForcePasswordReset)
+		admin.DELETE("/users/:id", adminCtrl.DeleteUser)
+		admin.GET("/stats", adminCtrl.Stats)
+		if deps.auditSvc != nil {
+			admin.GET("/audit", searchLimit, controller.NewAuditController(deps.auditSvc).List)
+		}
+		if deps.jobSvc != nil {
+			jobCtrl := controller.NewJobController(deps.jobSvc)
+			admin.GET("/jobs", jobCtrl.List)
+			admin.GET("/jobs/schedules", jobCtrl.Schedules)
+			admin.GET("/jobs/:id", jobCtrl.Get)
+			admin.POST("/jobs/:id/retry", jobCtrl.Retry)
+		}
+	}
 
 	// fallback
 	r.GET("/", func(c *gin.Context) {
@@ -39,3 +343,12 @@ func NewRouter(userSvc service.UserService, noteSvc service.NoteService, cfg *co
 
 	return r
 }
+
+// NewAdminRouter serves /metrics on its own listener (METRICS_ADDR), keeping it
+// off the public port.
+func NewAdminRouter(cfg *config.Config) http.Handler {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/metrics", middleware.BearerTokenGuard(cfg.MetricsToken), gin.WrapH(metrics.Handler()))
+	return r
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+)
+
+// Server wraps http.Server with graceful shutdown: on shutdown it stops
+// accepting connections, drains in-flight requests, then runs the registered
+// shutdown hooks (background jobs, DB pool, ...) in registration order.
+type Server struct {
+	HTTP            *http.Server
+	shutdownTimeout time.Duration
+	hooks           []shutdownHook
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewServer builds an http.Server from the listen address, timeouts and header limit in cfg.
+func NewServer(cfg *config.Config, handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              cfg.HTTPAddr,
+			Handler:           handler,
+			ReadTimeout:       cfg.HTTPReadTimeout,
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+			WriteTimeout:      cfg.HTTPWriteTimeout,
+			IdleTimeout:       cfg.HTTPIdleTimeout,
+			MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// OnShutdown registers fn to run after the HTTP server has drained.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// OnDrain registers fn to be called as soon as shutdown starts. Hijacked
+// connections such as WebSockets are not tracked by http.Server, so their
+// owners use this to tell clients to go away.
+func (s *Server) OnDrain(fn func()) {
+	s.HTTP.RegisterOnShutdown(fn)
+}
+
+// Run listens on the configured address and serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is cancelled, then shuts down gracefully
+// within the configured shutdown timeout.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", ln.Addr())
+		errCh <- s.HTTP.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		// server failed before we were asked to stop
+		s.runHooks(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("shutting down, draining connections")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	err := s.HTTP.Shutdown(shutdownCtx)
+	if serveErr := <-errCh; serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+		err = serveErr
+	}
+	if hookErr := s.runHooks(shutdownCtx); err == nil {
+		err = hookErr
+	}
+	return err
+}
+
+func (s *Server) runHooks(ctx context.Context) error {
+	var errs []error
+	for _, h := range s.hooks {
+		if err := h.fn(ctx); err != nil {
+			log.Printf("shutdown %s: %v", h.name, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+type AccountController struct {
+	accountSvc service.AccountService
+}
+
+func NewAccountController(as service.AccountService) *AccountController {
+	return &AccountController{accountSvc: as}
+}
+
+type tokenReq struct {
+	Token string `json:"token"`
+}
+
+type forgotPasswordReq struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (c *AccountController) VerifyEmail(ctx *gin.Context) {
+	var req tokenReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := c.accountSvc.VerifyEmail(ctx.Request.Context(), req.Token); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"verified": true})
+}
+
+func (c *AccountController) ResendVerification(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	if err := c.accountSvc.SendEmailVerification(ctx.Request.Context(), userID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusAccepted)
+}
+
+// ForgotPassword always answers 202 for a well-formed request so the endpoint
+// cannot be used to find out which addresses are registered.
+func (c *AccountController) ForgotPassword(ctx *gin.Context) {
+	var req forgotPasswordReq
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := c.accountSvc.ForgotPassword(ctx.Request.Context(), req.Email); err != nil {
+		log.Printf("forgot password: %v", err)
+	}
+	ctx.Status(http.StatusAccepted)
+}
+
+func (c *AccountController) ResetPassword(ctx *gin.Context) {
+	var req resetPasswordReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := c.accountSvc.ResetPassword(ctx.Request.Context(), req.Token, req.Password); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+)
+
+// AdminController serves the /admin endpoints; the router only lets
+// administrators through.
+type AdminController struct {
+	adminSvc service.AdminService
+}
+
+func NewAdminController(as service.AdminService) *AdminController {
+	return &AdminController{adminSvc: as}
+}
+
+type adminUserResp struct {
+	profileResp
+	Role                  string           `json:"role"`
+	DisabledAt            *time.Time       `json:"disabled_at"`
+	PasswordResetRequired bool             `json:"password_reset_required"`
+	DeletionScheduledAt   *time.Time       `json:"deletion_scheduled_at"`
+	Usage                 *model.UserUsage `json:"usage,omitempty"`
+}
+
+func toAdminUserResp(u *model.User) adminUserResp {
+	return adminUserResp{
+		profileResp:           toProfileResp(u),
+		Role:                  u.Role,
+		DisabledAt:            u.DisabledAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+		DeletionScheduledAt:   u.DeletionScheduledAt,
+	}
+}
+
+type userPageResp struct {
+	Users   []adminUserResp `json:"users"`
+	Total   int64           `json:"total"`
+	Page    int             `json:"page"`
+	PerPage int             `json:"per_page"`
+}
+
+// fail answers err with the status of the known admin errors, or 500.
+func (c *AdminController) fail(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrAdminTarget):
+		status = http.StatusForbidden
+	}
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
+
+// intQuery parses the optional query parameter name, answering 400 when it
+// is not a number.
+func intQuery(ctx *gin.Context, name string, def int) (int, bool) {
+	v := ctx.Query(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return n, true
+}
+
+// ListUsers searches users by ?q= and pages through them with ?page= and ?per_page=.
+func (c *AdminController) ListUsers(ctx *gin.Context) {
+	page, ok := intQuery(ctx, "page", 1)
+	if !ok {
+		return
+	}
+	perPage, ok := intQuery(ctx, "per_page", 0)
+	if !ok {
+		return
+	}
+	res, err := c.adminSvc.ListUsers(ctx.Request.Context(), ctx.Query("q"), page, perPage)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	resp := userPageResp{Users: make([]adminUserResp, 0, len(res.Users)), Total: res.Total, Page: res.Page, PerPage: res.PerPage}
+	for i := range res.Users {
+		resp.Users = append(resp.Users, toAdminUserResp(&res.Users[i]))
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (c *AdminController) GetUser(ctx *gin.Context) {
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	u, usage, err := c.adminSvc.GetUser(ctx.Request.Context(), id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	resp := toAdminUserResp(u)
+	resp.Usage = usage
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// Stats reports usage; ?days= sets the window for new registrations.
+func (c *AdminController) Stats(ctx *gin.Context) {
+	days, ok := intQuery(ctx, "days", defaultStatsDays)
+	if !ok {
+		return
+	}
+	if days < 1 || days > maxStatsDays {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and " + strconv.Itoa(maxStatsDays)})
+		return
+	}
+	stats, err := c.adminSvc.Stats(ctx.Request.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, stats)
+}
+
+func (c *AdminController) DisableUser(ctx *gin.Context) {
+	adminID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	u, err := c.adminSvc.DisableUser(ctx.Request.Context(), adminID, id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, toAdminUserResp(u))
+}
+
+func (c *AdminController) EnableUser(ctx *gin.Context) {
+	adminID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	u, err := c.adminSvc.EnableUser(ctx.Request.Context(), adminID, id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, toAdminUserResp(u))
+}
+
+func (c *AdminController) ForcePasswordReset(ctx *gin.Context) {
+	adminID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if err := c.adminSvc.ForcePasswordReset(ctx.Request.Context(), adminID, id); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *AdminController) DeleteUser(ctx *gin.Context) {
+	adminID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if err := c.adminSvc.DeleteUser(ctx.Request.Context(), adminID, id); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+// AuditController reads the audit log: /me/audit for the caller's own
+// events and /admin/audit for everything.
+type AuditController struct {
+	auditSvc service.AuditService
+}
+
+func NewAuditController(as service.AuditService) *AuditController {
+	return &AuditController{auditSvc: as}
+}
+
+type auditPageResp struct {
+	Events  []model.AuditEvent `json:"events"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	PerPage int                `json:"per_page"`
+}
+
+// Mine lists the events acted by the caller or targeting their account.
+func (c *AuditController) Mine(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	f, page, perPage, ok := auditQuery(ctx)
+	if !ok {
+		return
+	}
+	f.ActorID = 0 // the subject filter applies instead
+	res, err := c.auditSvc.ListForUser(ctx.Request.Context(), userID, f, page, perPage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, toAuditPageResp(res))
+}
+
+// List lists every event, filtered by ?actor_id=, ?action=, ?target_type=,
+// ?target_id=, ?since= and ?until= (RFC 3339).
+func (c *AuditController) List(ctx *gin.Context) {
+	f, page, perPage, ok := auditQuery(ctx)
+	if !ok {
+		return
+	}
+	res, err := c.auditSvc.List(ctx.Request.Context(), f, page, perPage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, toAuditPageResp(res))
+}
+
+func toAuditPageResp(res *service.AuditPage) auditPageResp {
+	events := res.Events
+	if events == nil {
+		events = []model.AuditEvent{}
+	}
+	return auditPageResp{Events: events, Total: res.Total, Page: res.Page, PerPage: res.PerPage}
+}
+
+// auditQuery parses the filter and page parameters, answering 400 when one
+// is malformed.
+func auditQuery(ctx *gin.Context) (f repository.AuditFilter, page, perPage int, ok bool) {
+	f.Action = ctx.Query("action")
+	f.TargetType = ctx.Query("target_type")
+	for name, dst := range map[string]*uint{"actor_id": &f.ActorID, "target_id": &f.TargetID} {
+		v := ctx.Query(name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+			return f, 0, 0, false
+		}
+		*dst = uint(id)
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		v := ctx.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + ", expected RFC 3339"})
+			return f, 0, 0, false
+		}
+		*dst = t
+	}
+	if page, ok = intQuery(ctx, "page", 1); !ok {
+		return f, 0, 0, false
+	}
+	if perPage, ok = intQuery(ctx, "per_page", 0); !ok {
+		return f, 0, 0, false
+	}
+	return f, page, perPage, true
+}
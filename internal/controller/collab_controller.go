@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+	"github.com/MujiRahman/golang-simple-note/pkg/ot"
+)
+
+// CollabProtocol is the WebSocket subprotocol of /notes/:id/collab. Browsers
+// offer it along with "bearer.<token>" to authenticate.
+const CollabProtocol = "noteapp-collab"
+
+// maxCollabMessage bounds a message from an editor.
+const maxCollabMessage = 1 << 20
+
+var errInvalidCollabMessage = errors.New("invalid message")
+
+// CollabController serves real-time editing of a note over a WebSocket.
+type CollabController struct {
+	collabSvc service.CollabService
+}
+
+func NewCollabController(cs service.CollabService) *CollabController {
+	return &CollabController{collabSvc: cs}
+}
+
+// collabInput is a message from an editor: an operation made for the
+// document at Revision, or where their cursor is in it.
+type collabInput struct {
+	Type     string          `json:"type"` // "op" or "cursor"
+	Revision int             `json:"revision"`
+	Op       *ot.Operation   `json:"op"`
+	Cursor   *service.Cursor `json:"cursor"`
+}
+
+// Connect upgrades to a WebSocket on which the server sends
+// service.CollabMessage values, starting with the document, and the editor
+// sends collabInput values. A rejected message ends the connection with an
+// error message; the editor reconnects to start over from the current
+// document.
+func (c *CollabController) Connect(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
+	noteID, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if !ctx.IsWebsocket() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "websocket upgrade required"})
+		return
+	}
+	session, err := c.collabSvc.Join(ctx.Request.Context(), userID, wsID, noteID)
+	if err != nil {
+		if workspaceError(ctx, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrNoteNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrCollabUnavailable):
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	served := false
+	server := websocket.Server{
+		Handshake: collabHandshake,
+		Handler: func(ws *websocket.Conn) {
+			served = true
+			serveCollab(ws, session)
+		},
+	}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+	if !served {
+		leaveCollab(ctx.Request.Context(), session)
+	}
+}
+
+// leaveCollab leaves session. Leaving may save the document, which must not
+// be cut short by the request ending.
+func leaveCollab(ctx context.Context, session *service.CollabSession) {
+	if err := session.Leave(context.WithoutCancel(ctx)); err != nil {
+		log.Printf("collab: %v", err)
+	}
+}
+
+// collabHandshake picks CollabProtocol when the client offers subprotocols.
+// Any origin is accepted: authentication uses bearer tokens, never cookies,
+// so other sites cannot act for the user.
+func collabHandshake(config *websocket.Config, req *http.Request) error {
+	if len(config.Protocol) == 0 {
+		return nil
+	}
+	if !slices.Contains(config.Protocol, CollabProtocol) {
+		return errors.New("unsupported subprotocol")
+	}
+	config.Protocol = []string{CollabProtocol}
+	return nil
+}
+
+func serveCollab(ws *websocket.Conn, session *service.CollabSession) {
+	ws.MaxPayloadBytes = maxCollabMessage
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range session.Messages() {
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				break
+			}
+		}
+		// unblocks the reader when the server ends the session
+		ws.Close()
+	}()
+
+	// closes Messages, which ends the writer, also when handling a message
+	// panicked
+	defer func() {
+		leaveCollab(ws.Request().Context(), session)
+		<-done
+	}()
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			break
+		}
+		if err := handleCollabInput(session, data); err != nil {
+			websocket.JSON.Send(ws, service.CollabMessage{Type: service.CollabError, Error: err.Error()})
+			break
+		}
+	}
+}
+
+func handleCollabInput(session *service.CollabSession, data []byte) error {
+	var in collabInput
+	if err := json.Unmarshal(data, &in); err != nil {
+		return errInvalidCollabMessage
+	}
+	switch {
+	case in.Type == "op" && in.Op != nil:
+		return session.Submit(in.Revision, in.Op)
+	case in.Type == "cursor" && in.Cursor != nil:
+		return session.MoveCursor(in.Revision, *in.Cursor)
+	default:
+		return errInvalidCollabMessage
+	}
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+// CommentController serves /notes/:id/comments, in the workspace selected
+// like the note routes do.
+type CommentController struct {
+	commentSvc service.CommentService
+}
+
+func NewCommentController(cs service.CommentService) *CommentController {
+	return &CommentController{commentSvc: cs}
+}
+
+type createCommentReq struct {
+	Body     string `json:"body"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type updateCommentReq struct {
+	Body string `json:"body"`
+}
+
+// commentTarget parses the caller, workspace, note and, when withComment is
+// set, comment a request is about, answering 400 itself when one is
+// malformed.
+func commentTarget(ctx *gin.Context, withComment bool) (userID, wsID, noteID, commentID uint, ok bool) {
+	userID = ctx.GetUint(string(contextkey.UserIDKey))
+	if wsID, ok = activeWorkspace(ctx); !ok {
+		return
+	}
+	if noteID, ok = uintParam(ctx, "id"); !ok || !withComment {
+		return
+	}
+	commentID, ok = uintParam(ctx, "comment_id")
+	return
+}
+
+func (c *CommentController) fail(ctx *gin.Context, err error) {
+	if workspaceError(ctx, err) {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrNoteNotFound), errors.Is(err, service.ErrCommentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrCommentForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, service.ErrCommentDeleted):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrInvalidComment), errors.Is(err, service.ErrInvalidParent):
+		status = http.StatusBadRequest
+	}
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
+
+func (c *CommentController) Create(ctx *gin.Context) {
+	userID, wsID, noteID, _, ok := commentTarget(ctx, false)
+	if !ok {
+		return
+	}
+	var req createCommentReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	comment, err := c.commentSvc.Create(ctx.Request.Context(), userID, wsID, noteID, req.ParentID, req.Body)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, comment)
+}
+
+func (c *CommentController) List(ctx *gin.Context) {
+	userID, wsID, noteID, _, ok := commentTarget(ctx, false)
+	if !ok {
+		return
+	}
+	comments, err := c.commentSvc.List(ctx.Request.Context(), userID, wsID, noteID)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	if comments == nil {
+		comments = []model.Comment{}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"comments": comments})
+}
+
+func (c *CommentController) Get(ctx *gin.Context) {
+	userID, wsID, noteID, id, ok := commentTarget(ctx, true)
+	if !ok {
+		return
+	}
+	comment, err := c.commentSvc.Get(ctx.Request.Context(), userID, wsID, noteID, id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, comment)
+}
+
+func (c *CommentController) Update(ctx *gin.Context) {
+	userID, wsID, noteID, id, ok := commentTarget(ctx, true)
+	if !ok {
+		return
+	}
+	var req updateCommentReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	comment, err := c.commentSvc.Update(ctx.Request.Context(), userID, wsID, noteID, id, req.Body)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// History lists the former bodies of a comment, oldest first.
+func (c *CommentController) History(ctx *gin.Context) {
+	userID, wsID, noteID, id, ok := commentTarget(ctx, true)
+	if !ok {
+		return
+	}
+	revs, err := c.commentSvc.History(ctx.Request.Context(), userID, wsID, noteID, id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	if revs == nil {
+		revs = []model.CommentRevision{}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"revisions": revs})
+}
+
+func (c *CommentController) Delete(ctx *gin.Context) {
+	userID, wsID, noteID, id, ok := commentTarget(ctx, true)
+	if !ok {
+		return
+	}
+	if err := c.commentSvc.Delete(ctx.Request.Context(), userID, wsID, noteID, id); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+type DataExportController struct {
+	exportSvc service.DataExportService
+}
+
+func NewDataExportController(ds service.DataExportService) *DataExportController {
+	return &DataExportController{exportSvc: ds}
+}
+
+func (c *DataExportController) Create(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	e, err := c.exportSvc.Request(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/me/data-export/%d", e.ID))
+	ctx.JSON(http.StatusAccepted, e)
+}
+
+// Get reports the export's status while it is being built, and downloads the
+// ZIP once it is ready. The download stops working when the export expires.
+func (c *DataExportController) Get(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	e, err := c.exportSvc.Get(ctx.Request.Context(), userID, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case e.Status == model.ExportPending || e.Status == model.ExportRunning:
+		ctx.JSON(http.StatusAccepted, e)
+	case e.Status == model.ExportFailed:
+		ctx.JSON(http.StatusOK, e)
+	case e.ExpiresAt != nil && time.Now().After(*e.ExpiresAt):
+		ctx.JSON(http.StatusGone, gin.H{"error": "export expired"})
+	default:
+		ctx.FileAttachment(e.Path, fmt.Sprintf("simple-note-export-%d.zip", e.ID))
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+const defaultEventHeartbeat = 15 * time.Second
+
+// EventController serves the caller's note events and notifications as
+// server-sent events, for clients that cannot hold a WebSocket.
+type EventController struct {
+	eventSvc  service.EventStreamService
+	heartbeat time.Duration
+}
+
+// NewEventController returns a controller sending a heartbeat comment on
+// idle streams every heartbeat, 15s when it is not positive.
+func NewEventController(es service.EventStreamService, heartbeat time.Duration) *EventController {
+	if heartbeat <= 0 {
+		heartbeat = defaultEventHeartbeat
+	}
+	return &EventController{eventSvc: es, heartbeat: heartbeat}
+}
+
+// Stream sends the events of the notes in the caller's workspaces and the
+// caller's new notifications, each with its id, until the client
+// disconnects. A client reconnecting with Last-Event-ID gets the events it
+// missed; when they are no longer buffered, the stream starts with a
+// "reset" event and the client reloads its notes.
+func (c *EventController) Stream(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var lastID uint
+	if h := ctx.GetHeader("Last-Event-ID"); h != "" {
+		id, err := strconv.ParseUint(h, 10, 0)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+		lastID = uint(id)
+	}
+	sub, err := c.eventSvc.Subscribe(ctx.Request.Context(), userID, lastID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrEventStreamClosed) {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer sub.Close()
+
+	// the stream outlives the server's write timeout
+	http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no") // nginx
+	ctx.Status(http.StatusOK)
+	if sub.Missed {
+		fmt.Fprint(ctx.Writer, "event: reset\ndata: {}\n\n")
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(c.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			fmt.Fprintf(ctx.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Topic, e.Data)
+		case <-heartbeat.C:
+			fmt.Fprint(ctx.Writer, ": heartbeat\n\n")
+		case <-ctx.Request.Context().Done():
+			return
+		}
+		ctx.Writer.Flush()
+	}
+}
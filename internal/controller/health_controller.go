@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/buildinfo"
+)
+
+type HealthController struct {
+	healthSvc service.HealthService
+}
+
+func NewHealthController(hs service.HealthService) *HealthController {
+	return &HealthController{healthSvc: hs}
+}
+
+// Healthz only reports that the process is alive and serving.
+func (c *HealthController) Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": service.StatusUp})
+}
+
+// Readyz reports each dependency check and answers 503 when any of them is down.
+func (c *HealthController) Readyz(ctx *gin.Context) {
+	report := c.healthSvc.Ready(ctx.Request.Context())
+	code := http.StatusOK
+	if report.Status != service.StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, report)
+}
+
+func (c *HealthController) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+// JobController lets admins inspect the background job queue under
+// /admin/jobs and retry dead jobs.
+type JobController struct {
+	jobSvc service.JobService
+}
+
+func NewJobController(js service.JobService) *JobController {
+	return &JobController{jobSvc: js}
+}
+
+type jobPageResp struct {
+	Jobs    []model.Job `json:"jobs"`
+	Total   int64       `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+}
+
+// List lists jobs, newest first, filtered by ?status= and ?kind=.
+func (c *JobController) List(ctx *gin.Context) {
+	page, ok := intQuery(ctx, "page", 1)
+	if !ok {
+		return
+	}
+	perPage, ok := intQuery(ctx, "per_page", 0)
+	if !ok {
+		return
+	}
+	f := repository.JobFilter{Status: ctx.Query("status"), Kind: ctx.Query("kind")}
+	res, err := c.jobSvc.List(ctx.Request.Context(), f, page, perPage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	jobs := res.Jobs
+	if jobs == nil {
+		jobs = []model.Job{}
+	}
+	ctx.JSON(http.StatusOK, jobPageResp{Jobs: jobs, Total: res.Total, Page: res.Page, PerPage: res.PerPage})
+}
+
+func (c *JobController) Get(ctx *gin.Context) {
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	j, err := c.jobSvc.Get(ctx.Request.Context(), id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, j)
+}
+
+// Retry queues a dead job again.
+func (c *JobController) Retry(ctx *gin.Context) {
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	j, err := c.jobSvc.Retry(ctx.Request.Context(), id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, j)
+}
+
+// Schedules lists the recurring jobs and when they next run.
+func (c *JobController) Schedules(ctx *gin.Context) {
+	schedules, err := c.jobSvc.ListSchedules(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if schedules == nil {
+		schedules = []model.JobSchedule{}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+func (c *JobController) fail(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrJobNotRetryable):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+)
+
+type JWKSController struct {
+	keys *jwtkeys.KeySet
+}
+
+func NewJWKSController(keys *jwtkeys.KeySet) *JWKSController {
+	return &JWKSController{keys: keys}
+}
+
+// Get serves the public signing keys. Verifiers cache the set for a short
+// while and refetch it when they see an unknown "kid".
+func (c *JWKSController) Get(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.keys.JWKS())
+}
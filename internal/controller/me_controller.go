@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+// MeController serves the authenticated user's own account under /me.
+type MeController struct {
+	userSvc    service.UserService
+	accountSvc service.AccountService
+}
+
+func NewMeController(us service.UserService, as service.AccountService) *MeController {
+	return &MeController{userSvc: us, accountSvc: as}
+}
+
+type updateProfileReq struct {
+	Name     *string `json:"name"`
+	Email    *string `json:"email"`
+	TimeZone *string `json:"time_zone"`
+	Locale   *string `json:"locale"`
+}
+
+type changePasswordReq struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type deleteAccountReq struct {
+	Password string `json:"password"`
+}
+
+type profileResp struct {
+	ID               uint      `json:"id"`
+	Name             string    `json:"name"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	EmailVerified    bool      `json:"email_verified"`
+	TimeZone         string    `json:"time_zone"`
+	Locale           string    `json:"locale"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func toProfileResp(u *model.User) profileResp {
+	return profileResp{
+		ID:               u.ID,
+		Name:             u.Name,
+		Username:         u.Username,
+		Email:            u.Email,
+		EmailVerified:    u.EmailVerifiedAt != nil,
+		TimeZone:         u.TimeZone,
+		Locale:           u.Locale,
+		TwoFactorEnabled: u.TOTPEnabled,
+		CreatedAt:        u.CreatedAt,
+	}
+}
+
+func (c *MeController) Get(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	u, err := c.accountSvc.Profile(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, toProfileResp(u))
+}
+
+func (c *MeController) Update(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req updateProfileReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	u, err := c.accountSvc.UpdateProfile(ctx.Request.Context(), userID, service.ProfileUpdate{
+		Name:     req.Name,
+		Email:    req.Email,
+		TimeZone: req.TimeZone,
+		Locale:   req.Locale,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Email != nil && u.EmailVerifiedAt == nil {
+		if err := c.accountSvc.SendEmailVerification(ctx.Request.Context(), u.ID); err != nil {
+			log.Printf("send verification email to user %d: %v", u.ID, err)
+		}
+	}
+	ctx.JSON(http.StatusOK, toProfileResp(u))
+}
+
+// ChangePassword signs out every other session and returns a new token for this one.
+func (c *MeController) ChangePassword(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req changePasswordReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	token, err := c.userSvc.ChangePassword(ctx.Request.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+func (c *MeController) Delete(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req deleteAccountReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	purgeAt, err := c.accountSvc.DeleteAccount(ctx.Request.Context(), userID, req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if purgeAt != nil {
+		ctx.JSON(http.StatusAccepted, gin.H{"deletion_scheduled_at": purgeAt})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
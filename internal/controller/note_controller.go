@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,6 +11,10 @@ import (
 	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
 )
 
+// WorkspaceHeader selects the active workspace of the /notes routes. The
+// /workspaces/:workspace_id/notes routes take it from the path instead.
+const WorkspaceHeader = "X-Workspace-ID"
+
 type NoteController struct {
 	noteSvc service.NoteService
 }
@@ -23,16 +28,55 @@ type createNoteReq struct {
 	Content string `json:"content"`
 }
 
+// activeWorkspace returns the workspace a request works on: the
+// :workspace_id path parameter, else the X-Workspace-ID header, else 0 for
+// the caller's personal workspace. It answers 400 itself for a malformed id.
+func activeWorkspace(ctx *gin.Context) (uint, bool) {
+	raw := ctx.Param("workspace_id")
+	if raw == "" {
+		raw = ctx.GetHeader(WorkspaceHeader)
+	}
+	if raw == "" {
+		return 0, true
+	}
+	id64, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id64 == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace id"})
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// workspaceError answers the workspace errors of the note and workspace
+// services and reports whether err was one of them.
+func workspaceError(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrWorkspaceNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrWorkspaceForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
 func (c *NoteController) Create(ctx *gin.Context) {
 	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
 	var req createNoteReq
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
-	n, err := c.noteSvc.Create(userID, req.Title, req.Content)
+	n, err := c.noteSvc.Create(ctx.Request.Context(), userID, wsID, req.Title, req.Content)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !workspaceError(ctx, err) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	ctx.JSON(http.StatusCreated, n)
@@ -40,9 +84,15 @@ func (c *NoteController) Create(ctx *gin.Context) {
 
 func (c *NoteController) List(ctx *gin.Context) {
 	userID := ctx.GetUint(string(contextkey.UserIDKey))
-	notes, err := c.noteSvc.ListByUser(userID)
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
+	notes, err := c.noteSvc.ListByWorkspace(ctx.Request.Context(), userID, wsID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !workspaceError(ctx, err) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	ctx.JSON(http.StatusOK, notes)
@@ -50,15 +100,21 @@ func (c *NoteController) List(ctx *gin.Context) {
 
 func (c *NoteController) Get(ctx *gin.Context) {
 	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
 	idStr := ctx.Param("id")
 	id64, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	n, err := c.noteSvc.GetByID(userID, uint(id64))
+	n, err := c.noteSvc.GetByID(ctx.Request.Context(), userID, wsID, uint(id64))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		if !workspaceError(ctx, err) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	ctx.JSON(http.StatusOK, n)
@@ -66,6 +122,10 @@ func (c *NoteController) Get(ctx *gin.Context) {
 
 func (c *NoteController) Update(ctx *gin.Context) {
 	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
 	idStr := ctx.Param("id")
 	id64, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
@@ -77,9 +137,11 @@ func (c *NoteController) Update(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
-	n, err := c.noteSvc.Update(userID, uint(id64), req.Title, req.Content)
+	n, err := c.noteSvc.Update(ctx.Request.Context(), userID, wsID, uint(id64), req.Title, req.Content)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if !workspaceError(ctx, err) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	ctx.JSON(http.StatusOK, n)
@@ -87,14 +149,20 @@ func (c *NoteController) Update(ctx *gin.Context) {
 
 func (c *NoteController) Delete(ctx *gin.Context) {
 	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
 	idStr := ctx.Param("id")
 	id64, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := c.noteSvc.Delete(userID, uint(id64)); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.noteSvc.Delete(ctx.Request.Context(), userID, wsID, uint(id64)); err != nil {
+		if !workspaceError(ctx, err) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	ctx.JSON(http.StatusNoContent, nil)
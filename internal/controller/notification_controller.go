@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+// NotificationController serves the caller's inbox under /notifications.
+type NotificationController struct {
+	notificationSvc service.NotificationService
+}
+
+func NewNotificationController(ns service.NotificationService) *NotificationController {
+	return &NotificationController{notificationSvc: ns}
+}
+
+type notificationPageResp struct {
+	Notifications []model.Notification `json:"notifications"`
+	Total         int64                `json:"total"`
+	Unread        int64                `json:"unread"`
+	Page          int                  `json:"page"`
+	PerPage       int                  `json:"per_page"`
+}
+
+// List lists the caller's notifications, newest first; ?unread=true keeps
+// the unread ones.
+func (c *NotificationController) List(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	page, ok := intQuery(ctx, "page", 1)
+	if !ok {
+		return
+	}
+	perPage, ok := intQuery(ctx, "per_page", 0)
+	if !ok {
+		return
+	}
+	res, err := c.notificationSvc.List(ctx.Request.Context(), userID, ctx.Query("unread") == "true", page, perPage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ns := res.Notifications
+	if ns == nil {
+		ns = []model.Notification{}
+	}
+	ctx.JSON(http.StatusOK, notificationPageResp{Notifications: ns, Total: res.Total, Unread: res.Unread, Page: res.Page, PerPage: res.PerPage})
+}
+
+func (c *NotificationController) UnreadCount(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	n, err := c.notificationSvc.UnreadCount(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"unread": n})
+}
+
+func (c *NotificationController) MarkRead(ctx *gin.Context) {
+	c.mark(ctx, true)
+}
+
+func (c *NotificationController) MarkUnread(ctx *gin.Context) {
+	c.mark(ctx, false)
+}
+
+func (c *NotificationController) mark(ctx *gin.Context, read bool) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	n, err := c.notificationSvc.MarkRead(ctx.Request.Context(), userID, id, read)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNotificationNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, n)
+}
+
+func (c *NotificationController) MarkAllRead(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	n, err := c.notificationSvc.MarkAllRead(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"marked": n})
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcCookiePath = "/auth/oidc/"
+)
+
+type OIDCController struct {
+	oidcSvc      service.OIDCService
+	secureCookie bool
+}
+
+// NewOIDCController sets the Secure flag on the flow cookie when secureCookie
+// is true, i.e. when the app is served over https.
+func NewOIDCController(oidcSvc service.OIDCService, secureCookie bool) *OIDCController {
+	return &OIDCController{oidcSvc: oidcSvc, secureCookie: secureCookie}
+}
+
+// Start redirects to the provider. The state, nonce and PKCE verifier travel
+// in a short-lived cookie scoped to the OIDC routes.
+func (c *OIDCController) Start(ctx *gin.Context) {
+	authURL, flow, err := c.oidcSvc.Start(ctx.Request.Context(), ctx.Param("provider"))
+	if errors.Is(err, service.ErrOIDCProviderNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("oidc start %s: %v", ctx.Param("provider"), err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "login provider unavailable"})
+		return
+	}
+	// Lax, so the cookie comes back on the provider's top-level redirect
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcFlowCookie, flow, int(service.OIDCFlowTTL.Seconds()), oidcCookiePath, "", c.secureCookie, true)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback answers like POST /login: a token, or a 2FA challenge.
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	flow, _ := ctx.Cookie(oidcFlowCookie)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcFlowCookie, "", -1, oidcCookiePath, "", c.secureCookie, true)
+
+	if e := ctx.Query("error"); e != "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "login was not completed: " + e})
+		return
+	}
+	token, err := c.oidcSvc.Callback(ctx.Request.Context(), ctx.Param("provider"), flow, ctx.Query("state"), ctx.Query("code"))
+	var twoFactor *service.TwoFactorRequiredError
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, gin.H{"token": token})
+	case errors.As(err, &twoFactor):
+		ctx.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": twoFactor.ChallengeToken})
+	case errors.Is(err, service.ErrOIDCProviderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCInvalidState):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCAccountConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCSignupDisabled), errors.Is(err, service.ErrAccountDisabled):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("oidc callback %s: %v", ctx.Param("provider"), err)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "login failed"})
+	}
+}
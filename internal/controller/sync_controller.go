@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+// SyncController serves the delta sync of offline clients for the active
+// workspace.
+type SyncController struct {
+	syncSvc service.SyncService
+}
+
+func NewSyncController(ss service.SyncService) *SyncController {
+	return &SyncController{syncSvc: ss}
+}
+
+type pushSyncReq struct {
+	Changes []service.SyncChange `json:"changes" binding:"required"`
+}
+
+// Changes returns the changes since ?since=<token>, everything without it,
+// at most ?limit= at a time. An expired token answers 410: the client drops
+// its copy and syncs from scratch.
+func (c *SyncController) Changes(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
+	limit, ok := intQuery(ctx, "limit", 0)
+	if !ok {
+		return
+	}
+	changes, err := c.syncSvc.Changes(ctx.Request.Context(), userID, wsID, ctx.Query("since"), limit)
+	if err != nil {
+		syncError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
+
+// Push applies a batch of changes made offline and returns the result of
+// each, in order.
+func (c *SyncController) Push(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := activeWorkspace(ctx)
+	if !ok {
+		return
+	}
+	var req pushSyncReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	results, err := c.syncSvc.Push(ctx.Request.Context(), userID, wsID, req.Changes)
+	if err != nil {
+		syncError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func syncError(ctx *gin.Context, err error) {
+	if workspaceError(ctx, err) {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidSyncToken), errors.Is(err, service.ErrSyncBatchTooLarge):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrSyncTokenExpired):
+		status = http.StatusGone
+	}
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
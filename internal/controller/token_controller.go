@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+type TokenController struct {
+	tokenSvc service.TokenService
+}
+
+func NewTokenController(ts service.TokenService) *TokenController {
+	return &TokenController{tokenSvc: ts}
+}
+
+type createTokenReq struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type tokenResp struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Token      string     `json:"token,omitempty"` // only present right after creation
+}
+
+func toTokenResp(t *model.PersonalAccessToken) tokenResp {
+	return tokenResp{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.ScopeList(),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func (c *TokenController) Create(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req createTokenReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	var via *model.PersonalAccessToken
+	if v, ok := ctx.Get(string(contextkey.TokenKey)); ok {
+		via = v.(*model.PersonalAccessToken)
+	}
+	plain, t, err := c.tokenSvc.Create(ctx.Request.Context(), userID, via, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrScopeNotGranted) {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	resp := toTokenResp(t)
+	resp.Token = plain
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+func (c *TokenController) List(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	tokens, err := c.tokenSvc.List(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]tokenResp, 0, len(tokens))
+	for i := range tokens {
+		out = append(out, toTokenResp(&tokens[i]))
+	}
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (c *TokenController) Revoke(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id64, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := c.tokenSvc.Revoke(ctx.Request.Context(), userID, uint(id64)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
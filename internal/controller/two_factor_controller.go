@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+type TwoFactorController struct {
+	userSvc service.UserService
+}
+
+func NewTwoFactorController(us service.UserService) *TwoFactorController {
+	return &TwoFactorController{userSvc: us}
+}
+
+type twoFactorCodeReq struct {
+	Code string `json:"code"`
+}
+
+type twoFactorDisableReq struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type twoFactorLoginReq struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+func (c *TwoFactorController) Setup(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	secret, uri, err := c.userSvc.SetupTwoFactor(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+func (c *TwoFactorController) Verify(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req twoFactorCodeReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	codes, err := c.userSvc.EnableTwoFactor(ctx.Request.Context(), userID, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"enabled": true, "recovery_codes": codes})
+}
+
+func (c *TwoFactorController) Disable(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req twoFactorDisableReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := c.userSvc.DisableTwoFactor(ctx.Request.Context(), userID, req.Password, req.Code); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// Login completes a login started by POST /login for an account with 2FA.
+func (c *TwoFactorController) Login(ctx *gin.Context) {
+	var req twoFactorLoginReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	token, err := c.userSvc.LoginTwoFactor(ctx.Request.Context(), req.ChallengeToken, req.Code)
+	var locked *service.AccountLockedError
+	if errors.As(err, &locked) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(locked.Until).Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrAccountDisabled) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
@@ -1,7 +1,12 @@
 package controller
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -9,15 +14,18 @@ import (
 )
 
 type UserController struct {
-	userSvc service.UserService
+	userSvc    service.UserService
+	accountSvc service.AccountService // optional, sends the verification email on register
 }
 
-func NewUserController(us service.UserService) *UserController {
-	return &UserController{userSvc: us}
+func NewUserController(us service.UserService, as service.AccountService) *UserController {
+	return &UserController{userSvc: us, accountSvc: as}
 }
 
 type registerReq struct {
+	Name     string `json:"name"`
 	Username string `json:"username"`
+	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
@@ -32,12 +40,18 @@ func (c *UserController) Register(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
-	u, err := c.userSvc.Register(req.Username, req.Password)
+	u, err := c.userSvc.Register(ctx.Request.Context(), req.Name, req.Username, req.Email, req.Password)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusCreated, gin.H{"id": u.ID, "username": u.Username})
+	if c.accountSvc != nil {
+		// the account exists either way; the user can ask for a new link later
+		if err := c.accountSvc.SendEmailVerification(ctx.Request.Context(), u.ID); err != nil {
+			log.Printf("send verification email to user %d: %v", u.ID, err)
+		}
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"id": u.ID, "username": u.Username, "name": u.Name, "email": u.Email})
 }
 
 func (c *UserController) Login(ctx *gin.Context) {
@@ -46,7 +60,22 @@ func (c *UserController) Login(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
-	token, err := c.userSvc.Login(req.Username, req.Password)
+	token, err := c.userSvc.Login(ctx.Request.Context(), req.Username, req.Password)
+	var locked *service.AccountLockedError
+	if errors.As(err, &locked) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(locked.Until).Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	var twoFactor *service.TwoFactorRequiredError
+	if errors.As(err, &twoFactor) {
+		ctx.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": twoFactor.ChallengeToken})
+		return
+	}
+	if errors.Is(err, service.ErrAccountDisabled) || errors.Is(err, service.ErrPasswordResetRequired) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
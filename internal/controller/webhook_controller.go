@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+type WebhookController struct {
+	webhookSvc service.WebhookService
+}
+
+func NewWebhookController(ws service.WebhookService) *WebhookController {
+	return &WebhookController{webhookSvc: ws}
+}
+
+type createWebhookReq struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type updateWebhookReq struct {
+	URL     *string  `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+type webhookResp struct {
+	ID                  uint       `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Secret              string     `json:"secret,omitempty"` // only present right after creation
+}
+
+type deliveryPageResp struct {
+	Deliveries []model.WebhookDelivery `json:"deliveries"`
+	Total      int64                   `json:"total"`
+	Page       int                     `json:"page"`
+	PerPage    int                     `json:"per_page"`
+}
+
+func toWebhookResp(w *model.Webhook) webhookResp {
+	return webhookResp{
+		ID:                  w.ID,
+		URL:                 w.URL,
+		Events:              w.EventList(),
+		Enabled:             w.DisabledAt == nil,
+		ConsecutiveFailures: w.ConsecutiveFailures,
+		DisabledAt:          w.DisabledAt,
+		CreatedAt:           w.CreatedAt,
+		UpdatedAt:           w.UpdatedAt,
+	}
+}
+
+// fail answers err with the status of the known webhook errors.
+func (c *WebhookController) fail(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrDeliveryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrWebhookDisabled):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrInvalidWebhookURL), errors.Is(err, service.ErrInvalidWebhookEvent), errors.Is(err, service.ErrNoWebhookEvents):
+		status = http.StatusBadRequest
+	}
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
+
+func (c *WebhookController) Create(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req createWebhookReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	w, err := c.webhookSvc.Create(ctx.Request.Context(), userID, req.URL, req.Events)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	resp := toWebhookResp(w)
+	resp.Secret = w.Secret
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+func (c *WebhookController) List(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	hooks, err := c.webhookSvc.List(ctx.Request.Context(), userID)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	out := make([]webhookResp, 0, len(hooks))
+	for i := range hooks {
+		out = append(out, toWebhookResp(&hooks[i]))
+	}
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (c *WebhookController) Get(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	w, err := c.webhookSvc.Get(ctx.Request.Context(), userID, id)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, toWebhookResp(w))
+}
+
+// Update changes the URL or events, or enables or disables the webhook with
+// {"enabled": bool}.
+func (c *WebhookController) Update(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	var req updateWebhookReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	w, err := c.webhookSvc.Update(ctx.Request.Context(), userID, id, service.WebhookUpdate{URL: req.URL, Events: req.Events, Enabled: req.Enabled})
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, toWebhookResp(w))
+}
+
+func (c *WebhookController) Delete(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if err := c.webhookSvc.Delete(ctx.Request.Context(), userID, id); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// Deliveries pages through the delivery log with ?page= and ?per_page=.
+func (c *WebhookController) Deliveries(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	page, ok := intQuery(ctx, "page", 1)
+	if !ok {
+		return
+	}
+	perPage, ok := intQuery(ctx, "per_page", 0)
+	if !ok {
+		return
+	}
+	res, err := c.webhookSvc.Deliveries(ctx.Request.Context(), userID, id, page, perPage)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	deliveries := res.Deliveries
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	ctx.JSON(http.StatusOK, deliveryPageResp{Deliveries: deliveries, Total: res.Total, Page: res.Page, PerPage: res.PerPage})
+}
+
+// Redeliver queues a past delivery's event again and answers 202 with the
+// new delivery.
+func (c *WebhookController) Redeliver(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	id, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := uintParam(ctx, "delivery_id")
+	if !ok {
+		return
+	}
+	d, err := c.webhookSvc.Redeliver(ctx.Request.Context(), userID, id, deliveryID)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, d)
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+)
+
+type WorkspaceController struct {
+	workspaceSvc service.WorkspaceService
+}
+
+func NewWorkspaceController(ws service.WorkspaceService) *WorkspaceController {
+	return &WorkspaceController{workspaceSvc: ws}
+}
+
+type workspaceReq struct {
+	Name string `json:"name"`
+}
+
+type memberRoleReq struct {
+	Role model.WorkspaceRole `json:"role"`
+}
+
+type inviteReq struct {
+	Username string              `json:"username"`
+	Email    string              `json:"email"`
+	Role     model.WorkspaceRole `json:"role"` // defaults to member
+}
+
+// fail answers err with the status of the known workspace errors, or fallback.
+func (c *WorkspaceController) fail(ctx *gin.Context, err error, fallback int) {
+	if workspaceError(ctx, err) {
+		return
+	}
+	status := fallback
+	switch {
+	case errors.Is(err, service.ErrMemberNotFound), errors.Is(err, service.ErrInvitationNotFound), errors.Is(err, service.ErrInviteeNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrAlreadyMember), errors.Is(err, service.ErrAlreadyInvited), errors.Is(err, service.ErrLastOwner):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrPersonalWorkspace), errors.Is(err, service.ErrInvalidWorkspaceRole):
+		status = http.StatusBadRequest
+	}
+	ctx.JSON(status, gin.H{"error": err.Error()})
+}
+
+// uintParam parses the path parameter name, answering 400 when it is not an id.
+func uintParam(ctx *gin.Context, name string) (uint, bool) {
+	id64, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+func (c *WorkspaceController) Create(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	var req workspaceReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	ws, err := c.workspaceSvc.Create(ctx.Request.Context(), userID, req.Name)
+	if err != nil {
+		c.fail(ctx, err, http.StatusBadRequest)
+		return
+	}
+	ctx.JSON(http.StatusCreated, ws)
+}
+
+func (c *WorkspaceController) List(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	list, err := c.workspaceSvc.List(ctx.Request.Context(), userID)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (c *WorkspaceController) Get(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	ws, err := c.workspaceSvc.Get(ctx.Request.Context(), userID, wsID)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, ws)
+}
+
+func (c *WorkspaceController) Update(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	var req workspaceReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	ws, err := c.workspaceSvc.Rename(ctx.Request.Context(), userID, wsID, req.Name)
+	if err != nil {
+		c.fail(ctx, err, http.StatusBadRequest)
+		return
+	}
+	ctx.JSON(http.StatusOK, ws)
+}
+
+func (c *WorkspaceController) Delete(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	if err := c.workspaceSvc.Delete(ctx.Request.Context(), userID, wsID); err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *WorkspaceController) Members(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	members, err := c.workspaceSvc.Members(ctx.Request.Context(), userID, wsID)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, members)
+}
+
+func (c *WorkspaceController) UpdateMember(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	memberID, ok := uintParam(ctx, "user_id")
+	if !ok {
+		return
+	}
+	var req memberRoleReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	m, err := c.workspaceSvc.UpdateMemberRole(ctx.Request.Context(), userID, wsID, memberID, req.Role)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
+
+// RemoveMember removes a member; members remove themselves to leave.
+func (c *WorkspaceController) RemoveMember(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	memberID, ok := uintParam(ctx, "user_id")
+	if !ok {
+		return
+	}
+	if err := c.workspaceSvc.RemoveMember(ctx.Request.Context(), userID, wsID, memberID); err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// Invite takes either a username or an email address.
+func (c *WorkspaceController) Invite(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	var req inviteReq
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.Username == "") == (req.Email == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "either username or email is required"})
+		return
+	}
+	invitee := req.Username
+	if invitee == "" {
+		invitee = req.Email
+	}
+	if req.Role == "" {
+		req.Role = model.WorkspaceRoleMember
+	}
+	inv, err := c.workspaceSvc.Invite(ctx.Request.Context(), userID, wsID, invitee, req.Role)
+	if err != nil {
+		c.fail(ctx, err, http.StatusBadRequest)
+		return
+	}
+	ctx.JSON(http.StatusCreated, inv)
+}
+
+func (c *WorkspaceController) PendingInvitations(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	list, err := c.workspaceSvc.PendingInvitations(ctx.Request.Context(), userID, wsID)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (c *WorkspaceController) RevokeInvitation(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	wsID, ok := uintParam(ctx, "workspace_id")
+	if !ok {
+		return
+	}
+	invID, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if err := c.workspaceSvc.RevokeInvitation(ctx.Request.Context(), userID, wsID, invID); err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// Invitations lists the invitations addressed to the caller.
+func (c *WorkspaceController) Invitations(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	list, err := c.workspaceSvc.Invitations(ctx.Request.Context(), userID)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (c *WorkspaceController) AcceptInvitation(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	invID, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	ws, err := c.workspaceSvc.AcceptInvitation(ctx.Request.Context(), userID, invID)
+	if err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, ws)
+}
+
+func (c *WorkspaceController) DeclineInvitation(ctx *gin.Context) {
+	userID := ctx.GetUint(string(contextkey.UserIDKey))
+	invID, ok := uintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if err := c.workspaceSvc.DeclineInvitation(ctx.Request.Context(), userID, invID); err != nil {
+		c.fail(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
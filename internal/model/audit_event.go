@@ -0,0 +1,79 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditUserRegister    = "user.register"
+	AuditUserLogin       = "user.login"
+	AuditUserLoginFailed = "user.login_failed"
+	AuditTokenCreate     = "token.create"
+	AuditTokenRevoke     = "token.revoke"
+	AuditNoteCreate      = "note.create"
+	AuditNoteUpdate      = "note.update"
+	AuditNoteDelete      = "note.delete"
+
+	AuditWorkspaceDelete = "workspace.delete"
+
+	AuditAdminUserDisable   = "admin.user_disable"
+	AuditAdminUserEnable    = "admin.user_enable"
+	AuditAdminPasswordReset = "admin.password_reset"
+	AuditAdminUserDelete    = "admin.user_delete"
+)
+
+// Audit target types.
+const (
+	AuditTargetUser  = "user"
+	AuditTargetToken = "token"
+	AuditTargetNote  = "note"
+
+	AuditTargetWorkspace = "workspace"
+)
+
+// AuditSummary is a small JSON object describing a target before or after
+// a change. It never holds secrets or full note contents.
+type AuditSummary map[string]any
+
+func (s AuditSummary) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *AuditSummary) Scan(v any) error {
+	switch v := v.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case []byte:
+		return json.Unmarshal(v, s)
+	}
+	return errors.New("unsupported audit summary value")
+}
+
+// AuditEvent records who did what to which target. Events are append-only:
+// they are never updated, and they outlive the users and targets they name.
+type AuditEvent struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// ActorID is the user who acted, 0 for anonymous requests such as a
+	// failed login with an unknown username.
+	ActorID    uint         `gorm:"index;not null;default:0" json:"actor_id"`
+	Action     string       `gorm:"size:64;index;not null" json:"action"`
+	TargetType string       `gorm:"size:32;not null;default:'';index:idx_audit_events_target" json:"target_type,omitempty"`
+	TargetID   uint         `gorm:"not null;default:0;index:idx_audit_events_target" json:"target_id,omitempty"`
+	IP         string       `gorm:"column:ip;size:64" json:"ip,omitempty"`
+	UserAgent  string       `gorm:"size:255" json:"user_agent,omitempty"`
+	RequestID  string       `gorm:"size:64" json:"request_id,omitempty"`
+	Before     AuditSummary `gorm:"column:before_state;type:text" json:"before,omitempty"`
+	After      AuditSummary `gorm:"column:after_state;type:text" json:"after,omitempty"`
+	CreatedAt  time.Time    `gorm:"column:created_at;autoCreateTime;<-:create;index" json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Comment is a message on a note. A reply points at the comment it answers
+// with ParentID. A deleted comment keeps its place in the thread with its
+// body removed.
+type Comment struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	NoteID      uint       `gorm:"index;not null" json:"note_id"`
+	WorkspaceID uint       `gorm:"index;not null" json:"workspace_id"` // the note's, so that deleting a workspace finds its comments
+	UserID      uint       `gorm:"index;not null" json:"user_id"`      // author
+	ParentID    *uint      `json:"parent_id,omitempty"`
+	Body        string     `gorm:"type:text;not null" json:"body"`
+	EditedAt    *time.Time `json:"edited_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+}
+
+// CommentRevision is the body a comment had before one of its edits.
+type CommentRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CommentID uint      `gorm:"index;not null" json:"comment_id"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"` // when it was replaced
+}
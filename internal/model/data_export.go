@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Statuses of a DataExport.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a "download my data" archive built in the background. The
+// ZIP file lives on disk at Path until ExpiresAt.
+type DataExport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"-"`
+	Status      string     `gorm:"size:16;not null" json:"status"`
+	Error       string     `gorm:"size:255" json:"error,omitempty"`
+	Path        string     `gorm:"size:255" json:"-"`
+	Size        int64      `json:"size,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// Job statuses. A failed attempt puts the job back in the queue until it has
+// used MaxAttempts, then it is dead and only runs again when retried by an
+// admin.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// Job kinds run by the server.
+const (
+	JobAccountPurge       = "account.purge"
+	JobDataExportBuild    = "data_export.build"
+	JobDataExportPurge    = "data_export.purge"
+	JobOutboxPurge        = "outbox.purge"
+	JobNoteTombstonePurge = "note_tombstone.purge"
+)
+
+// Job is a unit of background work, run by one of the server's workers once
+// RunAt has passed.
+type Job struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Kind        string    `gorm:"size:64;not null;index" json:"kind"`
+	Payload     string    `gorm:"type:text" json:"payload,omitempty"` // JSON
+	Status      string    `gorm:"size:16;not null;index:idx_jobs_due" json:"status"`
+	Attempts    int       `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int       `gorm:"not null" json:"max_attempts"`
+	RunAt       time.Time `gorm:"not null;index:idx_jobs_due" json:"run_at"`
+	// LockedBy and LockedUntil identify the worker running the job. A running
+	// job whose lock expired, e.g. because its worker died, is claimed again.
+	LockedBy    string     `gorm:"size:64" json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+}
+
+// JobSchedule enqueues a job of Kind whenever its cron Spec comes due. Its
+// NextRunAt is advanced atomically, so with several servers only one of them
+// enqueues each run.
+type JobSchedule struct {
+	Name      string     `gorm:"primaryKey;size:64" json:"name"`
+	Spec      string     `gorm:"size:100;not null" json:"spec"`
+	Kind      string     `gorm:"size:64;not null" json:"kind"`
+	Payload   string     `gorm:"type:text" json:"payload,omitempty"`
+	NextRunAt time.Time  `gorm:"not null" json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+}
@@ -1,12 +1,26 @@
 package model
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Note is a note of a workspace. Deleting a note leaves a tombstone, with
+// title and content cleared, that only the sync queries see, so that
+// offline clients learn of the deletion; tombstones are purged after
+// SYNC_TOMBSTONE_RETENTION.
 type Note struct {
-	ID        uint      `gorm:"primaryKey"`
-	UserID    uint      `gorm:"index;not null" json:"user_id"`
-	Title     string    `gorm:"size:255;not null"`
-	Content   string    `gorm:"type:text"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"index;not null" json:"user_id"`                // author
+	WorkspaceID uint   `gorm:"index;not null;default:0" json:"workspace_id"` // decides who may read or change the note
+	Title       string `gorm:"size:255;not null"`
+	Content     string `gorm:"type:text"`
+	// ChangeSeq is the workspace's change sequence number of the note's last
+	// change. Clients sync from it and send it back as the base of their
+	// changes.
+	ChangeSeq uint64         `gorm:"not null;default:0" json:"change_seq"`
+	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime;<-:create"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
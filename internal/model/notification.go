@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Notification types.
+const (
+	NotificationMention = "mention" // the user was mentioned in a note or comment
+	NotificationComment = "comment" // someone commented on the user's note
+	NotificationReply   = "reply"   // someone replied to the user's comment
+)
+
+// Notification is an entry in a user's inbox about something ActorID did.
+type Notification struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index:idx_notifications_user;not null" json:"user_id"` // recipient
+	Type        string     `gorm:"size:32;not null" json:"type"`
+	ActorID     uint       `gorm:"index;not null" json:"actor_id"`
+	WorkspaceID uint       `gorm:"index;not null" json:"workspace_id"`
+	NoteID      uint       `gorm:"index;not null" json:"note_id"`
+	CommentID   *uint      `json:"comment_id,omitempty"`
+	ReadAt      *time.Time `gorm:"index:idx_notifications_user" json:"read_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+}
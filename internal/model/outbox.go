@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// Domain event topics written to the outbox.
+const (
+	EventNoteCreated    = "note.created"
+	EventNoteUpdated    = "note.updated"
+	EventNoteDeleted    = "note.deleted"
+	EventUserRegistered = "user.registered"
+	EventUserUpdated    = "user.updated"
+	EventUserDisabled   = "user.disabled"
+	EventUserEnabled    = "user.enabled"
+	EventUserDeleted    = "user.deleted"
+	// EventUserPasswordChanged is emitted when the user changes or resets
+	// their password.
+	EventUserPasswordChanged = "user.password_changed"
+
+	EventCommentCreated      = "comment.created"
+	EventCommentUpdated      = "comment.updated"
+	EventCommentDeleted      = "comment.deleted"
+	EventNotificationCreated = "notification.created"
+	// EventWorkspaceDeleted stands for the notes, comments and notifications
+	// deleted with the workspace, which get no events of their own.
+	EventWorkspaceDeleted = "workspace.deleted"
+)
+
+// Aggregate types of outbox events.
+const (
+	AggregateNote         = "note"
+	AggregateUser         = "user"
+	AggregateComment      = "comment"
+	AggregateNotification = "notification"
+	AggregateWorkspace    = "workspace"
+)
+
+// Outbox event statuses. An event that keeps failing to publish is dead
+// after OUTBOX_MAX_ATTEMPTS so that it stops holding back the events after
+// it.
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+	OutboxDead      = "dead"
+)
+
+// OutboxEvent is a domain event stored in the same transaction as the change
+// that raised it. The outbox relay publishes it afterwards, in ID order.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Topic         string     `gorm:"size:64;not null" json:"topic"`
+	AggregateType string     `gorm:"size:32;not null" json:"aggregate_type"`
+	AggregateID   uint       `gorm:"not null" json:"aggregate_id"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"` // JSON
+	Status        string     `gorm:"size:16;not null;index:idx_outbox_events_status" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	PublishedAt   *time.Time `json:"published_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	// TraceParent is the W3C trace context of the change, which the
+	// handlers of the event continue.
+	TraceParent string `gorm:"size:55;not null;default:''" json:"-"`
+}
+
+// Lease is held by one server at a time until it expires, e.g. by the
+// outbox relay so that a single server publishes the events in order.
+type Lease struct {
+	Name      string    `gorm:"primaryKey;size:64"`
+	Holder    string    `gorm:"size:64;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes a personal access token can be granted. Password/JWT sessions carry
+// all of them. ScopeAdmin only has an effect for users with the admin role.
+const (
+	ScopeNotesRead    = "notes:read"
+	ScopeNotesWrite   = "notes:write"
+	ScopeAccountAdmin = "account:admin"
+	ScopeAdmin        = "admin"
+)
+
+var AllScopes = []string{ScopeNotesRead, ScopeNotesWrite, ScopeAccountAdmin, ScopeAdmin}
+
+// PATPrefix marks a bearer token as a personal access token rather than a JWT.
+const PATPrefix = "snp_"
+
+type PersonalAccessToken struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	Name       string `gorm:"size:100;not null"`
+	TokenHash  string `gorm:"size:64;uniqueIndex;not null"` // sha256 of the token, the token itself is never stored
+	Prefix     string `gorm:"size:16;not null"`             // first characters, to recognise a token in listings
+	Scopes     string `gorm:"size:255;not null"`            // space separated
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
+}
+
+func (t *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
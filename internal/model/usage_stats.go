@@ -0,0 +1,21 @@
+package model
+
+// UsageStats summarises the whole installation for administrators.
+type UsageStats struct {
+	Users           int64 `json:"users"`
+	Admins          int64 `json:"admins"`
+	DisabledUsers   int64 `json:"disabled_users"`
+	PendingDeletion int64 `json:"pending_deletion"`
+	// NewUsers counts the users registered since the start of the requested window.
+	NewUsers     int64 `json:"new_users"`
+	Notes        int64 `json:"notes"`
+	Workspaces   int64 `json:"workspaces"`
+	ActiveTokens int64 `json:"active_tokens"`
+}
+
+// UserUsage is what a single user has stored.
+type UserUsage struct {
+	Notes        int64 `json:"notes"`
+	Workspaces   int64 `json:"workspaces"`
+	ActiveTokens int64 `json:"active_tokens"`
+}
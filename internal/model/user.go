@@ -2,12 +2,54 @@ package model
 
 import "time"
 
+// Defaults for new accounts.
+const (
+	DefaultTimeZone = "UTC"
+	DefaultLocale   = "en"
+)
+
+// User roles. Admins reach the /admin endpoints; the first one is created
+// with cmd/admin.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uint      `gorm:"primaryKey"`
-	Name      string    `gorm:"size:100;not null"`
-	Username  string    `gorm:"uniqueIndex;size:100" json:"username"`
-	Password  string    `json:"-"` // hashed
-	Email     string    `gorm:"uniqueIndex;size:100;not null"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	ID           uint       `gorm:"primaryKey"`
+	Name         string     `gorm:"size:100;not null"`
+	Username     string     `gorm:"uniqueIndex;size:100" json:"username"`
+	Password     string     `json:"-"` // hashed
+	Email        string     `gorm:"uniqueIndex;size:100;not null"`
+	FailedLogins int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil  *time.Time `json:"-"`
+	// TOTPSecret is set by 2FA setup; 2FA is only enforced once TOTPEnabled
+	// is true. RecoveryCodes holds space-separated sha256 hashes of the
+	// unused recovery codes. TOTPLastStep is the time step of the last code
+	// accepted, which cannot be used again. RecoveryCodes and TOTPLastStep
+	// are not written by UserRepository.Update.
+	TOTPSecret    string `gorm:"column:totp_secret;size:64" json:"-"`
+	TOTPEnabled   bool   `gorm:"column:totp_enabled;not null;default:false" json:"-"`
+	RecoveryCodes string `gorm:"type:text" json:"-"`
+	TOTPLastStep  int64  `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+	// EmailVerifiedAt is set once the user follows the verification link.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TimeZone        string     `gorm:"size:64;not null;default:UTC" json:"time_zone"`
+	Locale          string     `gorm:"size:16;not null;default:en" json:"locale"`
+	// TokenVersion is embedded in issued JWTs; incrementing it revokes every session.
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+	// DeletionScheduledAt is set while a deleted account waits out the grace
+	// period; the account is purged once it has passed.
+	DeletionScheduledAt *time.Time `json:"-"`
+	Role                string     `gorm:"size:16;not null;default:user" json:"role"`
+	// DisabledAt is set while an admin has disabled the account: it cannot
+	// log in and its tokens are rejected.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// PasswordResetRequired blocks password logins until the password is
+	// reset through the emailed link.
+	PasswordResetRequired bool      `gorm:"not null;default:false" json:"password_reset_required"`
+	CreatedAt             time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
+	UpdatedAt             time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
 }
+
+func (u *User) IsAdmin() bool { return u.Role == RoleAdmin }
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's "sub" claim.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	Provider  string    `gorm:"size:64;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email     string    `gorm:"size:100"` // as reported by the provider when linked
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
+}
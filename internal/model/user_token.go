@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Purposes of a UserToken.
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+)
+
+// UserToken is a single-use, expiring token sent to the user by email, e.g.
+// to verify an address or reset a password. Only its sha256 hash is stored.
+// Email is the address it was sent to: following the link proves control of
+// that address only, not of one the user changed to since.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	Purpose   string    `gorm:"size:32;not null"`
+	Email     string    `gorm:"size:100;not null;default:''"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
+}
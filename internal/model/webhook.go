@@ -0,0 +1,78 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook event types, named after the domain events they report.
+const (
+	WebhookNoteCreated = EventNoteCreated
+	WebhookNoteUpdated = EventNoteUpdated
+	WebhookNoteDeleted = EventNoteDeleted
+)
+
+var WebhookEvents = []string{WebhookNoteCreated, WebhookNoteUpdated, WebhookNoteDeleted}
+
+// Webhook delivery statuses. A pending delivery is retried until it succeeds
+// or runs out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a user's subscription: the events in the workspaces the user is
+// a member of are POSTed to URL, signed with Secret.
+type Webhook struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"-"`
+	URL    string `gorm:"size:2048;not null" json:"url"`
+	// Secret keys the HMAC signature. It has to be stored in the clear and is
+	// only shown when the webhook is created.
+	Secret string `gorm:"size:64;not null" json:"-"`
+	Events string `gorm:"size:255;not null" json:"-"` // space separated
+	// ConsecutiveFailures counts failed attempts since the last success; the
+	// webhook is disabled when it reaches the configured limit.
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	CreatedAt           time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+}
+
+func (w *Webhook) EventList() []string {
+	return strings.Fields(w.Events)
+}
+
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued for a webhook, with the outcome of its
+// latest attempt. Redelivering creates a new delivery of the same event.
+type WebhookDelivery struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	WebhookID uint   `gorm:"index;not null" json:"webhook_id"`
+	EventID   string `gorm:"size:32;not null" json:"event_id"` // shared by redeliveries of the event
+	Event     string `gorm:"size:64;not null" json:"event"`
+	Payload   string `gorm:"type:text;not null" json:"-"`
+	Status    string `gorm:"size:16;not null;index:idx_webhook_deliveries_due" json:"status"`
+	Attempts  int    `gorm:"not null;default:0" json:"attempts"`
+	// TraceParent is the W3C trace context of the change that queued the
+	// delivery, which the request to the receiver continues.
+	TraceParent string `gorm:"size:55;not null;default:''" json:"-"`
+	// NextAttemptAt is when a pending delivery is due; it is also pushed
+	// forward while a worker holds the delivery.
+	NextAttemptAt *time.Time `gorm:"index:idx_webhook_deliveries_due" json:"next_attempt_at,omitempty"`
+	ResponseCode  int        `gorm:"not null;default:0" json:"response_code,omitempty"`
+	ResponseBody  string     `gorm:"type:text" json:"response_body,omitempty"` // truncated
+	Error         string     `gorm:"size:255" json:"error,omitempty"`
+	DurationMS    int64      `gorm:"column:duration_ms;not null;default:0" json:"duration_ms"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,111 @@
+package model
+
+import "time"
+
+// WorkspaceRole is a member's role in a workspace. Each role has every
+// permission of the roles below it: owner > admin > member > guest.
+type WorkspaceRole string
+
+const (
+	WorkspaceRoleOwner  WorkspaceRole = "owner"
+	WorkspaceRoleAdmin  WorkspaceRole = "admin"
+	WorkspaceRoleMember WorkspaceRole = "member"
+	WorkspaceRoleGuest  WorkspaceRole = "guest"
+)
+
+// PersonalWorkspaceName is the name of the workspace created at registration.
+const PersonalWorkspaceName = "Personal"
+
+var workspaceRoleRank = map[WorkspaceRole]int{
+	WorkspaceRoleGuest:  1,
+	WorkspaceRoleMember: 2,
+	WorkspaceRoleAdmin:  3,
+	WorkspaceRoleOwner:  4,
+}
+
+func (r WorkspaceRole) Valid() bool {
+	_, ok := workspaceRoleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r ranks the same as or above min.
+func (r WorkspaceRole) AtLeast(min WorkspaceRole) bool {
+	return r.Valid() && workspaceRoleRank[r] >= workspaceRoleRank[min]
+}
+
+// WorkspacePermission is an action checked against a member's role.
+type WorkspacePermission string
+
+const (
+	PermNotesRead        WorkspacePermission = "notes:read"
+	PermNotesWrite       WorkspacePermission = "notes:write"
+	PermCommentsWrite    WorkspacePermission = "comments:write"
+	PermCommentsModerate WorkspacePermission = "comments:moderate"
+	PermMembersManage    WorkspacePermission = "members:manage"
+	PermWorkspaceUpdate  WorkspacePermission = "workspace:update"
+	PermWorkspaceDelete  WorkspacePermission = "workspace:delete"
+)
+
+// workspacePermissionRole is the lowest role granted each permission.
+var workspacePermissionRole = map[WorkspacePermission]WorkspaceRole{
+	PermNotesRead:        WorkspaceRoleGuest,
+	PermNotesWrite:       WorkspaceRoleMember,
+	PermCommentsWrite:    WorkspaceRoleGuest,
+	PermCommentsModerate: WorkspaceRoleAdmin,
+	PermMembersManage:    WorkspaceRoleAdmin,
+	PermWorkspaceUpdate:  WorkspaceRoleAdmin,
+	PermWorkspaceDelete:  WorkspaceRoleOwner,
+}
+
+// Can reports whether r grants p.
+func (r WorkspaceRole) Can(p WorkspacePermission) bool {
+	lowest, ok := workspacePermissionRole[p]
+	return ok && r.AtLeast(lowest)
+}
+
+// Workspace owns notes collectively; access is granted through
+// WorkspaceMember rows. Every user has exactly one personal workspace, which
+// cannot be shared or deleted; a unique index on created_by of the personal
+// workspaces enforces it.
+type Workspace struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"size:100;not null" json:"name"`
+	Personal  bool   `gorm:"not null;default:false" json:"personal"`
+	CreatedBy uint   `gorm:"index;not null" json:"created_by"`
+	// Role is the requesting user's role, filled in by queries that join
+	// the memberships.
+	Role WorkspaceRole `gorm:"->;-:migration" json:"role,omitempty"`
+	// ChangeSeq counts the changes to the workspace's notes. Note
+	// tombstones up to PurgedChangeSeq have been purged. Both are only
+	// written by the note repository, never by saving the workspace.
+	ChangeSeq       uint64    `gorm:"<-:false;not null;default:0" json:"-"`
+	PurgedChangeSeq uint64    `gorm:"<-:false;not null;default:0" json:"-"`
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+}
+
+// NewPersonalWorkspace returns the personal workspace of userID, not yet saved.
+func NewPersonalWorkspace(userID uint) *Workspace {
+	return &Workspace{Name: PersonalWorkspaceName, Personal: true, CreatedBy: userID}
+}
+
+type WorkspaceMember struct {
+	ID          uint          `gorm:"primaryKey" json:"-"`
+	WorkspaceID uint          `gorm:"not null;uniqueIndex:idx_workspace_members_workspace_user" json:"workspace_id"`
+	UserID      uint          `gorm:"not null;index;uniqueIndex:idx_workspace_members_workspace_user" json:"user_id"`
+	Role        WorkspaceRole `gorm:"size:16;not null" json:"role"`
+	Username    string        `gorm:"->;-:migration" json:"username,omitempty"`
+	CreatedAt   time.Time     `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+}
+
+// WorkspaceInvitation is a pending offer to join a workspace with Role. It is
+// deleted once the invitee accepts or declines, or an admin revokes it.
+type WorkspaceInvitation struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	WorkspaceID   uint          `gorm:"not null;uniqueIndex:idx_workspace_invitations_workspace_user" json:"workspace_id"`
+	UserID        uint          `gorm:"not null;index;uniqueIndex:idx_workspace_invitations_workspace_user" json:"user_id"`
+	InvitedBy     uint          `gorm:"not null" json:"invited_by"`
+	Role          WorkspaceRole `gorm:"size:16;not null" json:"role"`
+	WorkspaceName string        `gorm:"->;-:migration" json:"workspace_name,omitempty"`
+	CreatedAt     time.Time     `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+}
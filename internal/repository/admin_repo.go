@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+// AdminRepository holds the queries across all users that only
+// administrators run.
+type AdminRepository interface {
+	// SearchUsers returns one page of users ordered by id, and the number of
+	// users matching. A non-empty query matches a part of the username,
+	// email or name, ignoring case.
+	SearchUsers(ctx context.Context, query string, offset, limit int) ([]model.User, int64, error)
+	// Stats counts users and their data; NewUsers counts registrations after since.
+	Stats(ctx context.Context, since time.Time) (*model.UsageStats, error)
+	UserUsage(ctx context.Context, userID uint) (*model.UserUsage, error)
+}
+
+type adminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) AdminRepository {
+	return &adminRepository{db: db}
+}
+
+func (r *adminRepository) SearchUsers(ctx context.Context, query string, offset, limit int) ([]model.User, int64, error) {
+	matching := func() *gorm.DB {
+		q := dbFor(ctx, r.db).Model(&model.User{})
+		if query = strings.TrimSpace(query); query != "" {
+			like := "%" + escapeLike(strings.ToLower(query)) + "%"
+			q = q.Where("LOWER(username) LIKE ? ESCAPE '!' OR LOWER(email) LIKE ? ESCAPE '!' OR LOWER(name) LIKE ? ESCAPE '!'", like, like, like)
+		}
+		return q
+	}
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []model.User
+	if err := matching().Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s with "!", an escape character
+// that needs no quoting in any of the supported dialects.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func (r *adminRepository) Stats(ctx context.Context, since time.Time) (*model.UsageStats, error) {
+	db := dbFor(ctx, r.db)
+	var s model.UsageStats
+	counts := []struct {
+		dst   *int64
+		model any
+		where string
+		args  []any
+	}{
+		{&s.Users, &model.User{}, "", nil},
+		{&s.Admins, &model.User{}, "role = ?", []any{model.RoleAdmin}},
+		{&s.DisabledUsers, &model.User{}, "disabled_at IS NOT NULL", nil},
+		{&s.PendingDeletion, &model.User{}, "deletion_scheduled_at IS NOT NULL", nil},
+		{&s.NewUsers, &model.User{}, "created_at >= ?", []any{since}},
+		{&s.Notes, &model.Note{}, "", nil},
+		{&s.Workspaces, &model.Workspace{}, "", nil},
+		{&s.ActiveTokens, &model.PersonalAccessToken{}, "revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", []any{time.Now()}},
+	}
+	for _, c := range counts {
+		q := db.Model(c.model)
+		if c.where != "" {
+			q = q.Where(c.where, c.args...)
+		}
+		if err := q.Count(c.dst).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+func (r *adminRepository) UserUsage(ctx context.Context, userID uint) (*model.UserUsage, error) {
+	db := dbFor(ctx, r.db)
+	var u model.UserUsage
+	if err := db.Model(&model.Note{}).Where("user_id = ?", userID).Count(&u.Notes).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&model.WorkspaceMember{}).Where("user_id = ?", userID).Count(&u.Workspaces).Error; err != nil {
+		return nil, err
+	}
+	err := db.Model(&model.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&u.ActiveTokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
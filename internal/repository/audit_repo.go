@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+// AuditFilter selects audit events; zero fields do not filter.
+type AuditFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	Since      time.Time
+	Until      time.Time
+	// SubjectID keeps the events acted by the user or targeting their account.
+	SubjectID uint
+}
+
+// AuditRepository is append-only: events cannot be changed or deleted.
+type AuditRepository interface {
+	Create(ctx context.Context, e *model.AuditEvent) error
+	// List returns one page of the events matching f, newest first, and the
+	// number of events matching.
+	List(ctx context.Context, f AuditFilter, offset, limit int) ([]model.AuditEvent, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, e *model.AuditEvent) error {
+	return dbFor(ctx, r.db).Create(e).Error
+}
+
+func (r *auditRepository) List(ctx context.Context, f AuditFilter, offset, limit int) ([]model.AuditEvent, int64, error) {
+	matching := func() *gorm.DB {
+		q := dbFor(ctx, r.db).Model(&model.AuditEvent{})
+		if f.ActorID != 0 {
+			q = q.Where("actor_id = ?", f.ActorID)
+		}
+		if f.Action != "" {
+			q = q.Where("action = ?", f.Action)
+		}
+		if f.TargetType != "" {
+			q = q.Where("target_type = ?", f.TargetType)
+		}
+		if f.TargetID != 0 {
+			q = q.Where("target_id = ?", f.TargetID)
+		}
+		if !f.Since.IsZero() {
+			q = q.Where("created_at >= ?", f.Since)
+		}
+		if !f.Until.IsZero() {
+			q = q.Where("created_at < ?", f.Until)
+		}
+		if f.SubjectID != 0 {
+			q = q.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", f.SubjectID, model.AuditTargetUser, f.SubjectID)
+		}
+		return q
+	}
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []model.AuditEvent
+	if err := matching().Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
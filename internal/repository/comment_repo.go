@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type CommentRepository interface {
+	Create(ctx context.Context, c *model.Comment) error
+	FindByID(ctx context.Context, id uint) (*model.Comment, error)
+	// ListByNote returns the comments of a note, deleted ones included, in
+	// the order they were written.
+	ListByNote(ctx context.Context, noteID uint) ([]model.Comment, error)
+	// EachByUser calls fn with the comments the user wrote in batches of
+	// batchSize, ordered by id.
+	EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Comment) error) error
+	Update(ctx context.Context, c *model.Comment) error
+
+	CreateRevision(ctx context.Context, r *model.CommentRevision) error
+	// ListRevisions returns the earlier bodies of a comment, oldest first.
+	ListRevisions(ctx context.Context, commentID uint) ([]model.CommentRevision, error)
+	DeleteRevisions(ctx context.Context, commentID uint) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+func (r *commentRepository) Create(ctx context.Context, c *model.Comment) error {
+	return dbFor(ctx, r.db).Create(c).Error
+}
+
+func (r *commentRepository) FindByID(ctx context.Context, id uint) (*model.Comment, error) {
+	var c model.Comment
+	if err := dbFor(ctx, r.db).First(&c, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *commentRepository) ListByNote(ctx context.Context, noteID uint) ([]model.Comment, error) {
+	var comments []model.Comment
+	if err := dbFor(ctx, r.db).Where("note_id = ?", noteID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *commentRepository) EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Comment) error) error {
+	var batch []model.Comment
+	return dbFor(ctx, r.db).Where("user_id = ?", userID).Order("id").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func (r *commentRepository) Update(ctx context.Context, c *model.Comment) error {
+	return dbFor(ctx, r.db).Save(c).Error
+}
+
+func (r *commentRepository) CreateRevision(ctx context.Context, rev *model.CommentRevision) error {
+	return dbFor(ctx, r.db).Create(rev).Error
+}
+
+func (r *commentRepository) ListRevisions(ctx context.Context, commentID uint) ([]model.CommentRevision, error) {
+	var revs []model.CommentRevision
+	if err := dbFor(ctx, r.db).Where("comment_id = ?", commentID).Order("id").Find(&revs).Error; err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+func (r *commentRepository) DeleteRevisions(ctx context.Context, commentID uint) error {
+	return dbFor(ctx, r.db).Where("comment_id = ?", commentID).Delete(&model.CommentRevision{}).Error
+}
+
+// deleteComments removes the comments matching query, their revisions and
+// the notifications about them.
+func deleteComments(tx *gorm.DB, query string, args ...any) error {
+	ids := tx.Model(&model.Comment{}).Select("id").Where(query, args...)
+	if err := tx.Where("comment_id IN (?)", ids).Delete(&model.CommentRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("comment_id IN (?)", ids).Delete(&model.Notification{}).Error; err != nil {
+		return err
+	}
+	return tx.Where(query, args...).Delete(&model.Comment{}).Error
+}
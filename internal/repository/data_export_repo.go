@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type DataExportRepository interface {
+	Create(ctx context.Context, export *model.DataExport) error
+	FindByID(ctx context.Context, id uint) (*model.DataExport, error)
+	// FindInProgressByUser returns the user's pending or running export, if any.
+	FindInProgressByUser(ctx context.Context, userID uint) (*model.DataExport, error)
+	Update(ctx context.Context, export *model.DataExport) error
+	// ListStale returns exports that expired before t or whose user no longer exists.
+	ListStale(ctx context.Context, t time.Time) ([]model.DataExport, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+func (r *dataExportRepository) Create(ctx context.Context, export *model.DataExport) error {
+	return dbFor(ctx, r.db).Create(export).Error
+}
+
+func (r *dataExportRepository) FindByID(ctx context.Context, id uint) (*model.DataExport, error) {
+	var e model.DataExport
+	if err := dbFor(ctx, r.db).First(&e, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *dataExportRepository) FindInProgressByUser(ctx context.Context, userID uint) (*model.DataExport, error) {
+	var e model.DataExport
+	err := dbFor(ctx, r.db).
+		Where("user_id = ? AND status IN ?", userID, []string{model.ExportPending, model.ExportRunning}).
+		First(&e).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *dataExportRepository) Update(ctx context.Context, export *model.DataExport) error {
+	return dbFor(ctx, r.db).Save(export).Error
+}
+
+func (r *dataExportRepository) ListStale(ctx context.Context, t time.Time) ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := dbFor(ctx, r.db).
+		Where("expires_at <= ? OR user_id NOT IN (?)", t, r.db.Model(&model.User{}).Select("id")).
+		Find(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+func (r *dataExportRepository) Delete(ctx context.Context, id uint) error {
+	return dbFor(ctx, r.db).Delete(&model.DataExport{}, id).Error
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+// JobFilter selects jobs; zero fields do not filter.
+type JobFilter struct {
+	Status string
+	Kind   string
+}
+
+type JobRepository interface {
+	Create(ctx context.Context, j *model.Job) error
+	FindByID(ctx context.Context, id uint) (*model.Job, error)
+	// List returns one page of the jobs matching f, newest first, and the
+	// number of jobs matching.
+	List(ctx context.Context, f JobFilter, offset, limit int) ([]model.Job, int64, error)
+	// Claim locks the next due job for worker until the given time and counts
+	// the attempt. Queued jobs are due once their RunAt has passed, running
+	// ones once their lock has expired. It returns nil when no job is due.
+	// Where the database supports it, the due job is selected with
+	// FOR UPDATE SKIP LOCKED so that concurrent workers do not wait on it.
+	Claim(ctx context.Context, worker string, now, until time.Time) (*model.Job, error)
+	// Release saves the outcome of an attempt if worker still holds the job,
+	// reporting whether it did.
+	Release(ctx context.Context, j *model.Job, worker string) (bool, error)
+	// Requeue queues a dead job again with a fresh set of attempts,
+	// reporting false when the job is not dead.
+	Requeue(ctx context.Context, id uint, at time.Time) (bool, error)
+
+	// SaveSchedule creates or replaces the schedule called s.Name. The next
+	// run of an existing schedule is kept unless its spec changed.
+	SaveSchedule(ctx context.Context, s *model.JobSchedule) error
+	ListSchedules(ctx context.Context) ([]model.JobSchedule, error)
+	// AdvanceSchedule moves a schedule's next run from from to next,
+	// reporting false when another server advanced it first.
+	AdvanceSchedule(ctx context.Context, name string, from, next time.Time) (bool, error)
+}
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func (r *jobRepository) Create(ctx context.Context, j *model.Job) error {
+	return dbFor(ctx, r.db).Create(j).Error
+}
+
+func (r *jobRepository) FindByID(ctx context.Context, id uint) (*model.Job, error) {
+	var j model.Job
+	if err := dbFor(ctx, r.db).First(&j, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *jobRepository) List(ctx context.Context, f JobFilter, offset, limit int) ([]model.Job, int64, error) {
+	matching := func() *gorm.DB {
+		q := dbFor(ctx, r.db).Model(&model.Job{})
+		if f.Status != "" {
+			q = q.Where("status = ?", f.Status)
+		}
+		if f.Kind != "" {
+			q = q.Where("kind = ?", f.Kind)
+		}
+		return q
+	}
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []model.Job
+	if err := matching().Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+func (r *jobRepository) Claim(ctx context.Context, worker string, now, until time.Time) (*model.Job, error) {
+	var claimed *model.Job
+	err := dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		due := "(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)"
+		args := []any{model.JobQueued, now, model.JobRunning, now}
+
+		q := tx.Where(due, args...).Order("run_at, id").Limit(1)
+		if tx.Dialector.Name() != "sqlite" {
+			// sqlite serializes writers instead
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		var jobs []model.Job
+		if err := q.Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+		j := jobs[0]
+		res := tx.Model(&model.Job{}).
+			Where("id = ?", j.ID).
+			Where(due, args...).
+			UpdateColumns(map[string]any{
+				"status":       model.JobRunning,
+				"attempts":     j.Attempts + 1,
+				"locked_by":    worker,
+				"locked_until": until,
+				"updated_at":   now,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		j.Status = model.JobRunning
+		j.Attempts++
+		j.LockedBy = worker
+		j.LockedUntil = &until
+		claimed = &j
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *jobRepository) Release(ctx context.Context, j *model.Job, worker string) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", j.ID, model.JobRunning, worker).
+		Select("status", "run_at", "locked_by", "locked_until", "last_error", "finished_at", "updated_at").
+		Updates(j)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *jobRepository) Requeue(ctx context.Context, id uint, at time.Time) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.Job{}).
+		Where("id = ? AND status = ?", id, model.JobDead).
+		UpdateColumns(map[string]any{
+			"status":      model.JobQueued,
+			"attempts":    0,
+			"run_at":      at,
+			"finished_at": nil,
+			"last_error":  "",
+			"updated_at":  at,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *jobRepository) SaveSchedule(ctx context.Context, s *model.JobSchedule) error {
+	return dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var existing model.JobSchedule
+		err := tx.Where("name = ?", s.Name).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(s).Error
+		}
+		if err != nil {
+			return err
+		}
+		if existing.Spec == s.Spec {
+			s.NextRunAt = existing.NextRunAt
+		}
+		s.LastRunAt = existing.LastRunAt
+		return tx.Save(s).Error
+	})
+}
+
+func (r *jobRepository) ListSchedules(ctx context.Context) ([]model.JobSchedule, error) {
+	var schedules []model.JobSchedule
+	if err := dbFor(ctx, r.db).Order("name").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *jobRepository) AdvanceSchedule(ctx context.Context, name string, from, next time.Time) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.JobSchedule{}).
+		Where("name = ? AND next_run_at = ?", name, from).
+		UpdateColumns(map[string]any{"next_run_at": next, "last_run_at": from, "updated_at": time.Now()})
+	return res.RowsAffected == 1, res.Error
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -9,11 +12,29 @@ import (
 )
 
 type NoteRepository interface {
-	Create(note *model.Note) error
-	FindByID(id uint) (*model.Note, error)
-	FindByUser(userID uint) ([]model.Note, error)
-	Update(note *model.Note) error
-	Delete(id uint) error
+	Create(ctx context.Context, note *model.Note) error
+	FindByID(ctx context.Context, id uint) (*model.Note, error)
+	FindByUser(ctx context.Context, userID uint) ([]model.Note, error)
+	FindByWorkspace(ctx context.Context, workspaceID uint) ([]model.Note, error)
+	// EachByUser calls fn with the user's notes in batches of batchSize,
+	// ordered by id, without loading them all at once.
+	EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Note) error) error
+	Update(ctx context.Context, note *model.Note) error
+	// Delete replaces note id with a tombstone recording the change
+	// changeSeq. Its comments and notifications are deleted.
+	Delete(ctx context.Context, id uint, changeSeq uint64) error
+	// NextChangeSeq increments the change sequence of the workspace and
+	// returns it. Within the transaction of a change it also holds back the
+	// other changes to the workspace's notes until that transaction ends, so
+	// that changes commit in sequence order.
+	NextChangeSeq(ctx context.Context, workspaceID uint) (uint64, error)
+	// Changes returns up to limit notes of the workspace changed after the
+	// change sequence number since, tombstones included, in sequence order.
+	Changes(ctx context.Context, workspaceID uint, since uint64, limit int) ([]model.Note, error)
+	// PurgeDeleted removes the tombstones of notes deleted before t,
+	// recording the last purged change of each workspace, and returns how
+	// many it removed.
+	PurgeDeleted(ctx context.Context, t time.Time) (int64, error)
 }
 
 type noteRepository struct {
@@ -24,13 +45,13 @@ func NewNoteRepository(db *gorm.DB) NoteRepository {
 	return &noteRepository{db: db}
 }
 
-func (r *noteRepository) Create(note *model.Note) error {
-	return r.db.Create(note).Error
+func (r *noteRepository) Create(ctx context.Context, note *model.Note) error {
+	return dbFor(ctx, r.db).Create(note).Error
 }
 
-func (r *noteRepository) FindByID(id uint) (*model.Note, error) {
+func (r *noteRepository) FindByID(ctx context.Context, id uint) (*model.Note, error) {
 	var n model.Note
-	if err := r.db.First(&n, id).Error; err != nil {
+	if err := dbFor(ctx, r.db).First(&n, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -39,18 +60,105 @@ func (r *noteRepository) FindByID(id uint) (*model.Note, error) {
 	return &n, nil
 }
 
-func (r *noteRepository) FindByUser(userID uint) ([]model.Note, error) {
+func (r *noteRepository) FindByUser(ctx context.Context, userID uint) ([]model.Note, error) {
 	var notes []model.Note
-	if err := r.db.Where("user_id = ?", userID).Find(&notes).Error; err != nil {
+	if err := dbFor(ctx, r.db).Where("user_id = ?", userID).Find(&notes).Error; err != nil {
 		return nil, err
 	}
 	return notes, nil
 }
 
-func (r *noteRepository) Update(note *model.Note) error {
-	return r.db.Save(note).Error
+func (r *noteRepository) FindByWorkspace(ctx context.Context, workspaceID uint) ([]model.Note, error) {
+	var notes []model.Note
+	if err := dbFor(ctx, r.db).Where("workspace_id = ?", workspaceID).Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	return notes, nil
 }
 
-func (r *noteRepository) Delete(id uint) error {
-	return r.db.Delete(&model.Note{}, id).Error
+func (r *noteRepository) EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Note) error) error {
+	var batch []model.Note
+	return dbFor(ctx, r.db).Where("user_id = ?", userID).Order("id").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+// Update saves every field of note. Unlike Save, it never recreates a note
+// that was deleted meanwhile.
+func (r *noteRepository) Update(ctx context.Context, note *model.Note) error {
+	return dbFor(ctx, r.db).Model(note).Select("*").Updates(note).Error
+}
+
+func (r *noteRepository) Delete(ctx context.Context, id uint, changeSeq uint64) error {
+	return dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := deleteComments(tx, "note_id = ?", id); err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", id).Delete(&model.Notification{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Note{}).Where("id = ?", id).UpdateColumns(map[string]any{
+			"title":      "",
+			"content":    "",
+			"change_seq": changeSeq,
+			"deleted_at": time.Now(),
+		}).Error
+	})
+}
+
+func (r *noteRepository) NextChangeSeq(ctx context.Context, workspaceID uint) (uint64, error) {
+	db := dbFor(ctx, r.db)
+	res := db.Exec("UPDATE workspaces SET change_seq = change_seq + 1 WHERE id = ?", workspaceID)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, fmt.Errorf("workspace %d not found", workspaceID)
+	}
+	var seq uint64
+	err := db.Model(&model.Workspace{}).Select("change_seq").Where("id = ?", workspaceID).Scan(&seq).Error
+	return seq, err
+}
+
+func (r *noteRepository) Changes(ctx context.Context, workspaceID uint, since uint64, limit int) ([]model.Note, error) {
+	var notes []model.Note
+	err := dbFor(ctx, r.db).Unscoped().
+		Where("workspace_id = ? AND change_seq > ?", workspaceID, since).
+		Order("change_seq").
+		Limit(limit).
+		Find(&notes).Error
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (r *noteRepository) PurgeDeleted(ctx context.Context, t time.Time) (int64, error) {
+	var removed int64
+	err := dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var purged []struct {
+			WorkspaceID uint
+			ChangeSeq   uint64
+		}
+		err := tx.Unscoped().Model(&model.Note{}).
+			Select("workspace_id, MAX(change_seq) AS change_seq").
+			Where("deleted_at < ?", t).
+			Group("workspace_id").
+			Scan(&purged).Error
+		if err != nil {
+			return err
+		}
+		for _, p := range purged {
+			err := tx.Exec("UPDATE workspaces SET purged_change_seq = ? WHERE id = ? AND purged_change_seq < ?",
+				p.ChangeSeq, p.WorkspaceID, p.ChangeSeq).Error
+			if err != nil {
+				return err
+			}
+		}
+		res := tx.Unscoped().Where("deleted_at < ?", t).Delete(&model.Note{})
+		removed = res.RowsAffected
+		return res.Error
+	})
+	return removed, err
 }
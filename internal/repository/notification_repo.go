@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, n *model.Notification) error
+	FindByID(ctx context.Context, id uint) (*model.Notification, error)
+	// List returns one page of the user's notifications, newest first, and
+	// how many there are.
+	List(ctx context.Context, userID uint, unreadOnly bool, offset, limit int) ([]model.Notification, int64, error)
+	// EachByUser calls fn with the user's notifications in batches of
+	// batchSize, ordered by id.
+	EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Notification) error) error
+	CountUnread(ctx context.Context, userID uint) (int64, error)
+	// SetReadAt marks a notification read at the given time, or unread when
+	// it is nil.
+	SetReadAt(ctx context.Context, id uint, at *time.Time) error
+	// MarkAllRead marks the user's unread notifications read and returns
+	// how many it marked.
+	MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Create(ctx context.Context, n *model.Notification) error {
+	return dbFor(ctx, r.db).Create(n).Error
+}
+
+func (r *notificationRepository) FindByID(ctx context.Context, id uint) (*model.Notification, error) {
+	var n model.Notification
+	if err := dbFor(ctx, r.db).First(&n, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (r *notificationRepository) EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Notification) error) error {
+	var batch []model.Notification
+	return dbFor(ctx, r.db).Where("user_id = ?", userID).Order("id").
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func (r *notificationRepository) List(ctx context.Context, userID uint, unreadOnly bool, offset, limit int) ([]model.Notification, int64, error) {
+	matching := func() *gorm.DB {
+		q := dbFor(ctx, r.db).Model(&model.Notification{}).Where("user_id = ?", userID)
+		if unreadOnly {
+			q = q.Where("read_at IS NULL")
+		}
+		return q
+	}
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var ns []model.Notification
+	if err := matching().Order("id DESC").Offset(offset).Limit(limit).Find(&ns).Error; err != nil {
+		return nil, 0, err
+	}
+	return ns, total, nil
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := dbFor(ctx, r.db).Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *notificationRepository) SetReadAt(ctx context.Context, id uint, at *time.Time) error {
+	return dbFor(ctx, r.db).Model(&model.Notification{}).Where("id = ?", id).Update("read_at", at).Error
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	res := dbFor(ctx, r.db).Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", at)
+	return res.RowsAffected, res.Error
+}
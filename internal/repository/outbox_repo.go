@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type OutboxRepository interface {
+	Create(ctx context.Context, e *model.OutboxEvent) error
+	// ListPending returns up to limit pending events in ID order.
+	ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	// ListAfter returns up to limit events of any status with an ID above
+	// id, in ID order.
+	ListAfter(ctx context.Context, id uint, limit int) ([]model.OutboxEvent, error)
+	// ListLatest returns the last limit events in ID order.
+	ListLatest(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	// Update saves the outcome of a publishing attempt.
+	Update(ctx context.Context, e *model.OutboxEvent) error
+	// DeletePublishedBefore deletes the events published before t and
+	// returns how many it deleted.
+	DeletePublishedBefore(ctx context.Context, t time.Time) (int64, error)
+	// AcquireLease takes or renews the lease called name for holder until
+	// the given time, reporting false while another holder's lease has not
+	// expired.
+	AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error)
+	// ReleaseLease gives up the lease called name if holder has it.
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Create(ctx context.Context, e *model.OutboxEvent) error {
+	return dbFor(ctx, r.db).Create(e).Error
+}
+
+func (r *outboxRepository) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := dbFor(ctx, r.db).
+		Where("status = ?", model.OutboxPending).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) ListAfter(ctx context.Context, id uint, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	if err := dbFor(ctx, r.db).Where("id > ?", id).Order("id").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) ListLatest(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	if err := dbFor(ctx, r.db).Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(events)
+	return events, nil
+}
+
+func (r *outboxRepository) Update(ctx context.Context, e *model.OutboxEvent) error {
+	return dbFor(ctx, r.db).Model(e).
+		Select("status", "attempts", "next_attempt_at", "last_error", "published_at").
+		Updates(e).Error
+}
+
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, t time.Time) (int64, error) {
+	res := dbFor(ctx, r.db).
+		Where("status = ? AND published_at < ?", model.OutboxPublished, t).
+		Delete(&model.OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *outboxRepository) AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error) {
+	db := dbFor(ctx, r.db)
+	res := db.Model(&model.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		UpdateColumns(map[string]any{"holder": holder, "expires_at": until})
+	if res.Error != nil || res.RowsAffected == 1 {
+		return res.RowsAffected == 1, res.Error
+	}
+	// the lease does not exist yet, or another holder has it
+	res = db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.Lease{Name: name, Holder: holder, ExpiresAt: until})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *outboxRepository) ReleaseLease(ctx context.Context, name, holder string) error {
+	return dbFor(ctx, r.db).Where("name = ? AND holder = ?", name, holder).Delete(&model.Lease{}).Error
+}
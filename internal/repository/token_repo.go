@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type TokenRepository interface {
+	Create(ctx context.Context, token *model.PersonalAccessToken) error
+	FindByHash(ctx context.Context, hash string) (*model.PersonalAccessToken, error)
+	FindByID(ctx context.Context, id uint) (*model.PersonalAccessToken, error)
+	ListActiveByUser(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error)
+	ListByUser(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error)
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	RevokeAllForUser(ctx context.Context, userID uint, at time.Time) error
+	TouchLastUsed(ctx context.Context, id uint, at time.Time) error
+}
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) Create(ctx context.Context, token *model.PersonalAccessToken) error {
+	return dbFor(ctx, r.db).Create(token).Error
+}
+
+func (r *tokenRepository) FindByHash(ctx context.Context, hash string) (*model.PersonalAccessToken, error) {
+	var t model.PersonalAccessToken
+	if err := dbFor(ctx, r.db).Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tokenRepository) FindByID(ctx context.Context, id uint) (*model.PersonalAccessToken, error) {
+	var t model.PersonalAccessToken
+	if err := dbFor(ctx, r.db).First(&t, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tokenRepository) ListActiveByUser(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error) {
+	var tokens []model.PersonalAccessToken
+	err := dbFor(ctx, r.db).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("id").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *tokenRepository) ListByUser(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error) {
+	var tokens []model.PersonalAccessToken
+	if err := dbFor(ctx, r.db).Where("user_id = ?", userID).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *tokenRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return dbFor(ctx, r.db).Model(&model.PersonalAccessToken{}).Where("id = ?", id).Update("revoked_at", at).Error
+}
+
+func (r *tokenRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	return dbFor(ctx, r.db).Model(&model.PersonalAccessToken{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r *tokenRepository) RevokeAllForUser(ctx context.Context, userID uint, at time.Time) error {
+	return dbFor(ctx, r.db).Model(&model.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -9,9 +12,35 @@ import (
 )
 
 type UserRepository interface {
-	Create(user *model.User) error
-	FindByUsername(username string) (*model.User, error)
-	FindByID(id uint) (*model.User, error)
+	Create(ctx context.Context, user *model.User) error
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
+	FindByID(ctx context.Context, id uint) (*model.User, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	// Update saves the user, except for the second factor state that is
+	// only changed through ClaimTOTPStep and ReplaceRecoveryCodes.
+	Update(ctx context.Context, user *model.User) error
+	// ClaimTOTPStep records step as the last used TOTP step and reports
+	// whether it is later than the one recorded, i.e. its code was not used.
+	ClaimTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+	// ReplaceRecoveryCodes sets the user's recovery codes to codes and
+	// reports whether they still were old, so that concurrent requests
+	// cannot both spend one code.
+	ReplaceRecoveryCodes(ctx context.Context, id uint, old, codes string) (bool, error)
+	// RecordFailedLogin adds one to the user's failed login count in a
+	// single statement, so that concurrent attempts all count, and returns
+	// the new count.
+	RecordFailedLogin(ctx context.Context, id uint) (int, error)
+	// LockUntil locks the user until t, unless their failed login count is
+	// no longer failedLogins: a later failure sets its own lock, and a
+	// successful login cleared the count.
+	LockUntil(ctx context.Context, id uint, failedLogins int, t time.Time) error
+	// ListDueForDeletion returns users whose deletion grace period ended before t.
+	ListDueForDeletion(ctx context.Context, t time.Time) ([]model.User, error)
+	// DeleteWithData removes the user together with everything they own in a
+	// single transaction. Notes they wrote in shared workspaces belong to the
+	// workspace and are kept. It returns the paths of the user's data export
+	// archives, which the caller removes once the transaction commits.
+	DeleteWithData(ctx context.Context, id uint) ([]string, error)
 }
 
 type userRepository struct {
@@ -22,13 +51,13 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *model.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	return dbFor(ctx, r.db).Create(user).Error
 }
 
-func (r *userRepository) FindByUsername(username string) (*model.User, error) {
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
 	var u model.User
-	if err := r.db.Where("username = ?", username).First(&u).Error; err != nil {
+	if err := dbFor(ctx, r.db).Where("username = ?", username).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -37,9 +66,9 @@ func (r *userRepository) FindByUsername(username string) (*model.User, error) {
 	return &u, nil
 }
 
-func (r *userRepository) FindByID(id uint) (*model.User, error) {
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
 	var u model.User
-	if err := r.db.First(&u, id).Error; err != nil {
+	if err := dbFor(ctx, r.db).First(&u, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -47,3 +76,156 @@ func (r *userRepository) FindByID(id uint) (*model.User, error) {
 	}
 	return &u, nil
 }
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	var u model.User
+	if err := dbFor(ctx, r.db).Where("email = ?", email).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *model.User) error {
+	return dbFor(ctx, r.db).Omit("recovery_codes", "totp_last_step").Save(user).Error
+}
+
+func (r *userRepository) ClaimTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *userRepository) ReplaceRecoveryCodes(ctx context.Context, id uint, old, codes string) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.User{}).
+		Where("id = ? AND COALESCE(recovery_codes, '') = ?", id, old).
+		UpdateColumn("recovery_codes", codes)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *userRepository) RecordFailedLogin(ctx context.Context, id uint) (int, error) {
+	var n int
+	// the UPDATE locks the row until commit, so the count read back is this
+	// attempt's
+	err := dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.User{}).Where("id = ?", id).UpdateColumn("failed_logins", gorm.Expr("failed_logins + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("user %d not found", id)
+		}
+		return tx.Model(&model.User{}).Select("failed_logins").Where("id = ?", id).Scan(&n).Error
+	})
+	return n, err
+}
+
+func (r *userRepository) LockUntil(ctx context.Context, id uint, failedLogins int, t time.Time) error {
+	return dbFor(ctx, r.db).Model(&model.User{}).
+		Where("id = ? AND failed_logins = ?", id, failedLogins).
+		UpdateColumn("locked_until", t).Error
+}
+
+func (r *userRepository) ListDueForDeletion(ctx context.Context, t time.Time) ([]model.User, error) {
+	var users []model.User
+	err := dbFor(ctx, r.db).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", t).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) DeleteWithData(ctx context.Context, id uint) ([]string, error) {
+	var archives []string
+	err := dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := leaveWorkspaces(tx, id); err != nil {
+			return err
+		}
+		if err := eraseComments(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? OR actor_id = ?", id, id).Delete(&model.Notification{}).Error; err != nil {
+			return err
+		}
+		hooks := tx.Model(&model.Webhook{}).Select("id").Where("user_id = ?", id)
+		if err := tx.Where("webhook_id IN (?)", hooks).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.DataExport{}).Where("user_id = ? AND path <> ''", id).Pluck("path", &archives).Error; err != nil {
+			return err
+		}
+		owned := []any{&model.Webhook{}, &model.PersonalAccessToken{}, &model.UserToken{}, &model.UserIdentity{}, &model.WorkspaceMember{}, &model.WorkspaceInvitation{}, &model.DataExport{}}
+		for _, m := range owned {
+			if err := tx.Where("user_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.User{}, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archives, nil
+}
+
+// eraseComments deletes the bodies and history of userID's comments. Like
+// their notes in shared workspaces, the comments keep their place so that
+// the threads stay intact.
+func eraseComments(tx *gorm.DB, userID uint) error {
+	ids := tx.Model(&model.Comment{}).Select("id").Where("user_id = ?", userID)
+	if err := tx.Where("comment_id IN (?)", ids).Delete(&model.CommentRevision{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.Comment{}).
+		Where("user_id = ? AND deleted_at IS NULL", userID).
+		UpdateColumns(map[string]any{"body": "", "deleted_at": time.Now()}).Error
+}
+
+// leaveWorkspaces deletes the workspaces userID is the only member of, notes
+// included. Shared workspaces keep their notes; if userID was their last
+// owner, the longest-standing remaining member becomes owner, admins first.
+func leaveWorkspaces(tx *gorm.DB, userID uint) error {
+	var memberships []model.WorkspaceMember
+	if err := tx.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return err
+	}
+	var solo []uint
+	for _, m := range memberships {
+		var others []model.WorkspaceMember
+		if err := tx.Where("workspace_id = ? AND user_id <> ?", m.WorkspaceID, userID).Order("id").Find(&others).Error; err != nil {
+			return err
+		}
+		if len(others) == 0 {
+			solo = append(solo, m.WorkspaceID)
+			continue
+		}
+		if m.Role != model.WorkspaceRoleOwner || hasRole(others, model.WorkspaceRoleOwner) {
+			continue
+		}
+		heir := others[0]
+		for _, o := range others {
+			if o.Role == model.WorkspaceRoleAdmin {
+				heir = o
+				break
+			}
+		}
+		if err := tx.Model(&model.WorkspaceMember{}).Where("id = ?", heir.ID).Update("role", model.WorkspaceRoleOwner).Error; err != nil {
+			return err
+		}
+	}
+	return deleteWorkspaces(tx, solo)
+}
+
+func hasRole(members []model.WorkspaceMember, role model.WorkspaceRole) bool {
+	for _, m := range members {
+		if m.Role == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *model.UserToken) error
+	FindByHash(ctx context.Context, hash string) (*model.UserToken, error)
+	// MarkUsed consumes the token and reports false if it was already used.
+	MarkUsed(ctx context.Context, id uint, at time.Time) (bool, error)
+	// InvalidateForUser consumes every unused token of purpose for the user.
+	InvalidateForUser(ctx context.Context, userID uint, purpose string, at time.Time) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *model.UserToken) error {
+	return dbFor(ctx, r.db).Create(token).Error
+}
+
+func (r *userTokenRepository) FindByHash(ctx context.Context, hash string) (*model.UserToken, error) {
+	var t model.UserToken
+	if err := dbFor(ctx, r.db).Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *userTokenRepository) MarkUsed(ctx context.Context, id uint, at time.Time) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *userTokenRepository) InvalidateForUser(ctx context.Context, userID uint, purpose string, at time.Time) error {
+	return dbFor(ctx, r.db).Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
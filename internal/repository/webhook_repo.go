@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, w *model.Webhook) error
+	FindByID(ctx context.Context, id uint) (*model.Webhook, error)
+	ListByUser(ctx context.Context, userID uint) ([]model.Webhook, error)
+	Update(ctx context.Context, w *model.Webhook) error
+	// Delete removes the webhook together with its deliveries.
+	Delete(ctx context.Context, id uint) error
+	// ListSubscribers returns the enabled webhooks of the members of the
+	// workspace, whatever events they subscribe to.
+	ListSubscribers(ctx context.Context, workspaceID uint) ([]model.Webhook, error)
+	// RecordFailure counts a failed attempt and disables the webhook once
+	// limit consecutive attempts have failed, reporting whether it did.
+	RecordFailure(ctx context.Context, id uint, limit int, at time.Time) (bool, error)
+	ResetFailures(ctx context.Context, id uint) error
+
+	CreateDelivery(ctx context.Context, d *model.WebhookDelivery) error
+	FindDelivery(ctx context.Context, id uint) (*model.WebhookDelivery, error)
+	// ListDeliveries returns one page of the webhook's deliveries, newest
+	// first, and their total number.
+	ListDeliveries(ctx context.Context, webhookID uint, offset, limit int) ([]model.WebhookDelivery, int64, error)
+	// ListDue returns up to limit pending deliveries due at now, oldest first.
+	ListDue(ctx context.Context, now time.Time, limit int) ([]model.WebhookDelivery, error)
+	// Claim moves a due delivery's next attempt to until, so that other
+	// workers skip it while it is sent. It reports false when another worker
+	// claimed it first.
+	Claim(ctx context.Context, id uint, now, until time.Time) (bool, error)
+	UpdateDelivery(ctx context.Context, d *model.WebhookDelivery) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Create(ctx context.Context, w *model.Webhook) error {
+	return dbFor(ctx, r.db).Create(w).Error
+}
+
+func (r *webhookRepository) FindByID(ctx context.Context, id uint) (*model.Webhook, error) {
+	var w model.Webhook
+	if err := dbFor(ctx, r.db).First(&w, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *webhookRepository) ListByUser(ctx context.Context, userID uint) ([]model.Webhook, error) {
+	var hooks []model.Webhook
+	if err := dbFor(ctx, r.db).Where("user_id = ?", userID).Order("id").Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (r *webhookRepository) Update(ctx context.Context, w *model.Webhook) error {
+	return dbFor(ctx, r.db).Save(w).Error
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, id uint) error {
+	return dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Webhook{}, id).Error
+	})
+}
+
+func (r *webhookRepository) ListSubscribers(ctx context.Context, workspaceID uint) ([]model.Webhook, error) {
+	var hooks []model.Webhook
+	err := dbFor(ctx, r.db).
+		Joins("JOIN workspace_members ON workspace_members.user_id = webhooks.user_id").
+		Where("workspace_members.workspace_id = ? AND webhooks.disabled_at IS NULL", workspaceID).
+		Order("webhooks.id").
+		Find(&hooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (r *webhookRepository) RecordFailure(ctx context.Context, id uint, limit int, at time.Time) (bool, error) {
+	db := dbFor(ctx, r.db)
+	err := db.Model(&model.Webhook{}).Where("id = ?", id).
+		UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+	if err != nil {
+		return false, err
+	}
+	res := db.Model(&model.Webhook{}).
+		Where("id = ? AND disabled_at IS NULL AND consecutive_failures >= ?", id, limit).
+		UpdateColumn("disabled_at", at)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *webhookRepository) ResetFailures(ctx context.Context, id uint) error {
+	return dbFor(ctx, r.db).Model(&model.Webhook{}).Where("id = ?", id).UpdateColumn("consecutive_failures", 0).Error
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	return dbFor(ctx, r.db).Create(d).Error
+}
+
+func (r *webhookRepository) FindDelivery(ctx context.Context, id uint) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	if err := dbFor(ctx, r.db).First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookID uint, offset, limit int) ([]model.WebhookDelivery, int64, error) {
+	var total int64
+	if err := dbFor(ctx, r.db).Model(&model.WebhookDelivery{}).Where("webhook_id = ?", webhookID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []model.WebhookDelivery
+	err := dbFor(ctx, r.db).Where("webhook_id = ?", webhookID).Order("id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+func (r *webhookRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := dbFor(ctx, r.db).
+		Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) Claim(ctx context.Context, id uint, now, until time.Time) (bool, error) {
+	res := dbFor(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, model.DeliveryPending, now).
+		UpdateColumn("next_attempt_at", until)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	return dbFor(ctx, r.db).Save(d).Error
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type WorkspaceRepository interface {
+	// CreateWithOwner saves ws and makes ownerID its owner in one transaction.
+	CreateWithOwner(ctx context.Context, ws *model.Workspace, ownerID uint) error
+	FindByID(ctx context.Context, id uint) (*model.Workspace, error)
+	FindPersonal(ctx context.Context, userID uint) (*model.Workspace, error)
+	// CreatePersonal saves ws as the personal workspace of ownerID and
+	// reports whether it did, i.e. the user did not have one already.
+	CreatePersonal(ctx context.Context, ws *model.Workspace, ownerID uint) (bool, error)
+	// ListByUser returns the workspaces userID is a member of, with Role set.
+	ListByUser(ctx context.Context, userID uint) ([]model.Workspace, error)
+	Update(ctx context.Context, ws *model.Workspace) error
+	// Delete removes the workspace with its notes, members and invitations.
+	Delete(ctx context.Context, id uint) error
+
+	FindMember(ctx context.Context, workspaceID, userID uint) (*model.WorkspaceMember, error)
+	// ListMembers returns the members ordered by join date, with Username set.
+	ListMembers(ctx context.Context, workspaceID uint) ([]model.WorkspaceMember, error)
+	UpdateMember(ctx context.Context, m *model.WorkspaceMember) error
+	RemoveMember(ctx context.Context, workspaceID, userID uint) error
+	CountOwners(ctx context.Context, workspaceID uint) (int64, error)
+
+	CreateInvitation(ctx context.Context, inv *model.WorkspaceInvitation) error
+	FindInvitation(ctx context.Context, id uint) (*model.WorkspaceInvitation, error)
+	FindInvitationFor(ctx context.Context, workspaceID, userID uint) (*model.WorkspaceInvitation, error)
+	// ListInvitationsByUser returns the invitations addressed to userID, with
+	// WorkspaceName set.
+	ListInvitationsByUser(ctx context.Context, userID uint) ([]model.WorkspaceInvitation, error)
+	ListInvitationsByWorkspace(ctx context.Context, workspaceID uint) ([]model.WorkspaceInvitation, error)
+	// AcceptInvitation turns the invitation into a membership in one transaction.
+	AcceptInvitation(ctx context.Context, inv *model.WorkspaceInvitation) (*model.WorkspaceMember, error)
+	DeleteInvitation(ctx context.Context, id uint) error
+}
+
+type workspaceRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkspaceRepository(db *gorm.DB) WorkspaceRepository {
+	return &workspaceRepository{db: db}
+}
+
+func (r *workspaceRepository) CreateWithOwner(ctx context.Context, ws *model.Workspace, ownerID uint) error {
+	return dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ws).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.WorkspaceMember{WorkspaceID: ws.ID, UserID: ownerID, Role: model.WorkspaceRoleOwner}).Error
+	})
+}
+
+func (r *workspaceRepository) FindByID(ctx context.Context, id uint) (*model.Workspace, error) {
+	var ws model.Workspace
+	if err := dbFor(ctx, r.db).First(&ws, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ws, nil
+}
+
+func (r *workspaceRepository) FindPersonal(ctx context.Context, userID uint) (*model.Workspace, error) {
+	var ws model.Workspace
+	if err := dbFor(ctx, r.db).Where("personal = ? AND created_by = ?", true, userID).Order("id").First(&ws).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ws, nil
+}
+
+func (r *workspaceRepository) CreatePersonal(ctx context.Context, ws *model.Workspace, ownerID uint) (bool, error) {
+	created := false
+	err := dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(ws)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		created = true
+		return tx.Create(&model.WorkspaceMember{WorkspaceID: ws.ID, UserID: ownerID, Role: model.WorkspaceRoleOwner}).Error
+	})
+	return created, err
+}
+
+func (r *workspaceRepository) ListByUser(ctx context.Context, userID uint) ([]model.Workspace, error) {
+	var list []model.Workspace
+	err := dbFor(ctx, r.db).
+		Select("workspaces.*, workspace_members.role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userID).
+		Order("workspaces.personal DESC, workspaces.id").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *workspaceRepository) Update(ctx context.Context, ws *model.Workspace) error {
+	return dbFor(ctx, r.db).Save(ws).Error
+}
+
+func (r *workspaceRepository) Delete(ctx context.Context, id uint) error {
+	return dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return deleteWorkspaces(tx, []uint{id})
+	})
+}
+
+// deleteWorkspaces removes the workspaces ids and everything they own.
+func deleteWorkspaces(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := deleteComments(tx, "workspace_id IN ?", ids); err != nil {
+		return err
+	}
+	owned := []any{&model.Note{}, &model.Notification{}, &model.WorkspaceMember{}, &model.WorkspaceInvitation{}}
+	for _, m := range owned {
+		// Unscoped: the notes go for good, tombstones included
+		if err := tx.Unscoped().Where("workspace_id IN ?", ids).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&model.Workspace{}, ids).Error
+}
+
+func (r *workspaceRepository) FindMember(ctx context.Context, workspaceID, userID uint) (*model.WorkspaceMember, error) {
+	var m model.WorkspaceMember
+	if err := dbFor(ctx, r.db).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *workspaceRepository) ListMembers(ctx context.Context, workspaceID uint) ([]model.WorkspaceMember, error) {
+	var list []model.WorkspaceMember
+	err := dbFor(ctx, r.db).
+		Select("workspace_members.*, users.username").
+		Joins("JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ?", workspaceID).
+		Order("workspace_members.id").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *workspaceRepository) UpdateMember(ctx context.Context, m *model.WorkspaceMember) error {
+	return dbFor(ctx, r.db).Model(&model.WorkspaceMember{}).Where("id = ?", m.ID).Update("role", m.Role).Error
+}
+
+func (r *workspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID uint) error {
+	return dbFor(ctx, r.db).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Delete(&model.WorkspaceMember{}).Error
+}
+
+func (r *workspaceRepository) CountOwners(ctx context.Context, workspaceID uint) (int64, error) {
+	var n int64
+	err := dbFor(ctx, r.db).Model(&model.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ?", workspaceID, model.WorkspaceRoleOwner).
+		Count(&n).Error
+	return n, err
+}
+
+func (r *workspaceRepository) CreateInvitation(ctx context.Context, inv *model.WorkspaceInvitation) error {
+	return dbFor(ctx, r.db).Create(inv).Error
+}
+
+func (r *workspaceRepository) FindInvitation(ctx context.Context, id uint) (*model.WorkspaceInvitation, error) {
+	var inv model.WorkspaceInvitation
+	if err := dbFor(ctx, r.db).First(&inv, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *workspaceRepository) FindInvitationFor(ctx context.Context, workspaceID, userID uint) (*model.WorkspaceInvitation, error) {
+	var inv model.WorkspaceInvitation
+	if err := dbFor(ctx, r.db).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *workspaceRepository) ListInvitationsByUser(ctx context.Context, userID uint) ([]model.WorkspaceInvitation, error) {
+	var list []model.WorkspaceInvitation
+	err := dbFor(ctx, r.db).
+		Select("workspace_invitations.*, workspaces.name AS workspace_name").
+		Joins("JOIN workspaces ON workspaces.id = workspace_invitations.workspace_id").
+		Where("workspace_invitations.user_id = ?", userID).
+		Order("workspace_invitations.id").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *workspaceRepository) ListInvitationsByWorkspace(ctx context.Context, workspaceID uint) ([]model.WorkspaceInvitation, error) {
+	var list []model.WorkspaceInvitation
+	if err := dbFor(ctx, r.db).Where("workspace_id = ?", workspaceID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *workspaceRepository) AcceptInvitation(ctx context.Context, inv *model.WorkspaceInvitation) (*model.WorkspaceMember, error) {
+	m := &model.WorkspaceMember{WorkspaceID: inv.WorkspaceID, UserID: inv.UserID, Role: inv.Role}
+	err := dbFor(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.WorkspaceInvitation{}, inv.ID).Error; err != nil {
+			return err
+		}
+		return tx.Create(m).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *workspaceRepository) DeleteInvitation(ctx context.Context, id uint) error {
+	return dbFor(ctx, r.db).Delete(&model.WorkspaceInvitation{}, id).Error
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/mailer"
+	"github.com/MujiRahman/golang-simple-note/pkg/password"
+)
+
+const (
+	defaultEmailVerificationTTL = 24 * time.Hour
+	defaultPasswordResetTTL     = time.Hour
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// AccountService covers self-service account management: the profile, account
+// deletion, and the email based flows (address verification, password reset)
+// whose tokens are single-use, expire, and are stored hashed.
+type AccountService interface {
+	Profile(ctx context.Context, userID uint) (*model.User, error)
+	// UpdateProfile applies the non-nil fields of p. Changing the email
+	// marks the address unverified.
+	UpdateProfile(ctx context.Context, userID uint, p ProfileUpdate) (*model.User, error)
+	// DeleteAccount removes the account and everything it owns. With a grace
+	// period configured the account is only deactivated and the time of the
+	// hard deletion is returned; logging in before then cancels it.
+	DeleteAccount(ctx context.Context, userID uint, password string) (*time.Time, error)
+	// PurgeDeletedAccounts hard-deletes accounts whose grace period has passed.
+	PurgeDeletedAccounts(ctx context.Context) (int, error)
+
+	SendEmailVerification(ctx context.Context, userID uint) error
+	VerifyEmail(ctx context.Context, token string) error
+	// ForgotPassword mails a reset link if email belongs to a user. It does
+	// not reveal whether the address is registered.
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+}
+
+// ProfileUpdate lists the profile fields a user may change; nil means unchanged.
+type ProfileUpdate struct {
+	Name     *string
+	Email    *string
+	TimeZone *string
+	Locale   *string
+}
+
+var localeRe = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+type accountService struct {
+	users     repository.UserRepository
+	tokens    repository.UserTokenRepository
+	pats      repository.TokenRepository
+	events    UnitOfWork
+	mailer    mailer.Mailer
+	cfg       *config.Config
+	verifyTTL time.Duration
+	resetTTL  time.Duration
+	hasher    password.Hasher
+	policy    *password.Policy
+}
+
+// NewAccountService writes profile changes and deletions to the outbox
+// through events.
+func NewAccountService(users repository.UserRepository, tokens repository.UserTokenRepository, pats repository.TokenRepository, events UnitOfWork, m mailer.Mailer, cfg *config.Config) AccountService {
+	s := &accountService{
+		users:     users,
+		tokens:    tokens,
+		pats:      pats,
+		events:    events,
+		mailer:    m,
+		cfg:       cfg,
+		verifyTTL: cfg.EmailVerificationTTL,
+		resetTTL:  cfg.PasswordResetTTL,
+		hasher:    newPasswordHasher(cfg),
+		policy:    newPasswordPolicy(cfg),
+	}
+	if s.verifyTTL <= 0 {
+		s.verifyTTL = defaultEmailVerificationTTL
+	}
+	if s.resetTTL <= 0 {
+		s.resetTTL = defaultPasswordResetTTL
+	}
+	return s
+}
+
+func (s *accountService) Profile(ctx context.Context, userID uint) (*model.User, error) {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errors.New("user not found")
+	}
+	return u, nil
+}
+
+func (s *accountService) UpdateProfile(ctx context.Context, userID uint, p ProfileUpdate) (_ *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.UpdateProfile")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.Profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	emailChanged := false
+	if p.Name != nil {
+		name := strings.TrimSpace(*p.Name)
+		if name == "" || len(name) > 100 {
+			return nil, errors.New("name must be between 1 and 100 characters")
+		}
+		u.Name = name
+	}
+	if p.Email != nil {
+		email, err := normalizeEmail(*p.Email)
+		if err != nil {
+			return nil, err
+		}
+		if email != u.Email {
+			other, err := s.users.FindByEmail(ctx, email)
+			if err != nil {
+				return nil, err
+			}
+			if other != nil {
+				return nil, errors.New("email already used")
+			}
+			u.Email = email
+			u.EmailVerifiedAt = nil
+			emailChanged = true
+		}
+	}
+	if p.TimeZone != nil {
+		if _, err := time.LoadLocation(*p.TimeZone); err != nil || *p.TimeZone == "" || *p.TimeZone == "Local" {
+			return nil, fmt.Errorf("unknown time zone %q", *p.TimeZone)
+		}
+		u.TimeZone = *p.TimeZone
+	}
+	if p.Locale != nil {
+		if !localeRe.MatchString(*p.Locale) {
+			return nil, fmt.Errorf("invalid locale %q", *p.Locale)
+		}
+		u.Locale = *p.Locale
+	}
+	err = s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.users.Update(ctx, u); err != nil {
+			return err
+		}
+		if emailChanged {
+			// the links sent to the previous address are void
+			for _, purpose := range []string{model.TokenPurposeEmailVerification, model.TokenPurposePasswordReset} {
+				if err := s.tokens.InvalidateForUser(ctx, u.ID, purpose, time.Now()); err != nil {
+					return err
+				}
+			}
+		}
+		return s.events.Emit(ctx, model.EventUserUpdated, model.AggregateUser, u.ID, userEventData(u.ID, u))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *accountService) DeleteAccount(ctx context.Context, userID uint, pw string) (_ *time.Time, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.DeleteAccount")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.Profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := s.hasher.Verify(u.Password, pw); !ok {
+		return nil, errors.New("password is incorrect")
+	}
+	if s.cfg.AccountDeletionGracePeriod <= 0 {
+		return nil, s.delete(ctx, u.ID, u)
+	}
+
+	now := time.Now()
+	purgeAt := now.Add(s.cfg.AccountDeletionGracePeriod)
+	u.DeletionScheduledAt = &purgeAt
+	u.TokenVersion++ // sign out everywhere
+	if err := s.users.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	if err := s.pats.RevokeAllForUser(ctx, u.ID, now); err != nil {
+		return nil, err
+	}
+	return &purgeAt, nil
+}
+
+func (s *accountService) PurgeDeletedAccounts(ctx context.Context) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.PurgeDeletedAccounts")
+	defer func() { tracing.End(span, err) }()
+
+	due, err := s.users.ListDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if err := s.delete(ctx, 0, &due[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// delete deletes u with its data and reports it to the outbox. actorID is 0
+// when the account is purged after its grace period.
+func (s *accountService) delete(ctx context.Context, actorID uint, u *model.User) error {
+	var archives []string
+	err := s.events.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if archives, err = s.users.DeleteWithData(ctx, u.ID); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventUserDeleted, model.AggregateUser, u.ID, userEventData(actorID, u))
+	})
+	if err != nil {
+		return err
+	}
+	removeExportArchives(archives)
+	return nil
+}
+
+func (s *accountService) SendEmailVerification(ctx context.Context, userID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.SendEmailVerification")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return errors.New("user not found")
+	}
+	if u.EmailVerifiedAt != nil {
+		return errors.New("email already verified")
+	}
+	token, err := s.issueToken(ctx, u, model.TokenPurposeEmailVerification, s.verifyTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			u.Name, s.link("/verify-email", token), s.verifyTTL),
+	})
+}
+
+func (s *accountService) VerifyEmail(ctx context.Context, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+
+	t, err := s.consumeToken(ctx, token, model.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	u, err := s.users.FindByID(ctx, t.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil || t.Email != u.Email {
+		return errInvalidUserToken
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	return s.users.Update(ctx, u)
+}
+
+func (s *accountService) ForgotPassword(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ForgotPassword")
+	defer func() { tracing.End(span, err) }()
+
+	email, err = normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	u, err := s.users.FindByEmail(ctx, email)
+	if err != nil || u == nil {
+		return err
+	}
+	token, err := s.issueToken(ctx, u, model.TokenPurposePasswordReset, s.resetTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. If it was you, open the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for this, you can ignore this email.\n",
+			u.Name, s.link("/reset-password", token), s.resetTTL),
+	})
+}
+
+func (s *accountService) ResetPassword(ctx context.Context, token, newPassword string) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Check(newPassword); err != nil {
+		return err
+	}
+	t, err := s.consumeToken(ctx, token, model.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	u, err := s.users.FindByID(ctx, t.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return errInvalidUserToken
+	}
+	hashed, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	u.Password = hashed
+	u.PasswordResetRequired = false
+	u.FailedLogins = 0
+	u.LockedUntil = nil
+	u.TokenVersion++ // sign out everywhere
+	// the reset link proves control of the mailbox it was sent to
+	if u.EmailVerifiedAt == nil && t.Email == u.Email {
+		u.EmailVerifiedAt = &now
+	}
+	return s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.users.Update(ctx, u); err != nil {
+			return err
+		}
+		if err := s.tokens.InvalidateForUser(ctx, u.ID, model.TokenPurposePasswordReset, now); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventUserPasswordChanged, model.AggregateUser, u.ID, userEventData(u.ID, u))
+	})
+}
+
+// issueToken replaces any outstanding token of the same purpose with a new one
+// for u's current address and returns its plain value.
+func (s *accountService) issueToken(ctx context.Context, u *model.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := s.tokens.InvalidateForUser(ctx, u.ID, purpose, now); err != nil {
+		return "", err
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	plain := hex.EncodeToString(raw)
+	t := &model.UserToken{
+		UserID:    u.ID,
+		Purpose:   purpose,
+		Email:     u.Email,
+		TokenHash: hashToken(plain),
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.tokens.Create(ctx, t); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// consumeToken checks plain against purpose and expiry and marks it used.
+func (s *accountService) consumeToken(ctx context.Context, plain, purpose string) (*model.UserToken, error) {
+	if plain == "" {
+		return nil, errInvalidUserToken
+	}
+	t, err := s.tokens.FindByHash(ctx, hashToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if t == nil || t.Purpose != purpose || t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+	ok, err := s.tokens.MarkUsed(ctx, t.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// consumed concurrently
+		return nil, errInvalidUserToken
+	}
+	return t, nil
+}
+
+func (s *accountService) link(path, token string) string {
+	return s.cfg.AppBaseURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrAdminTarget is returned when an admin action targets an
+	// administrator, the caller included. Admins are managed with cmd/admin.
+	ErrAdminTarget = errors.New("administrators cannot be managed through the API")
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// UserPage is one page of AdminService.ListUsers.
+type UserPage struct {
+	Users   []model.User
+	Total   int64
+	Page    int
+	PerPage int
+}
+
+// AdminService backs the /admin endpoints. The adminID arguments identify
+// the administrator acting.
+type AdminService interface {
+	IsAdmin(ctx context.Context, userID uint) (bool, error)
+	// ListUsers returns a page of the users matching query (see
+	// AdminRepository.SearchUsers). page starts at 1; perPage is capped at 100.
+	ListUsers(ctx context.Context, query string, page, perPage int) (*UserPage, error)
+	GetUser(ctx context.Context, userID uint) (*model.User, *model.UserUsage, error)
+	// Stats reports installation-wide usage, counting registrations after since.
+	Stats(ctx context.Context, since time.Time) (*model.UsageStats, error)
+
+	// DisableUser blocks logins and rejects the user's existing tokens until
+	// EnableUser; nothing is revoked, so re-enabling restores them.
+	DisableUser(ctx context.Context, adminID, userID uint) (*model.User, error)
+	EnableUser(ctx context.Context, adminID, userID uint) (*model.User, error)
+	// ForcePasswordReset signs the user out everywhere, blocks password
+	// logins and mails a reset link.
+	ForcePasswordReset(ctx context.Context, adminID, userID uint) error
+	// DeleteUser deletes the account immediately, without a grace period.
+	DeleteUser(ctx context.Context, adminID, userID uint) error
+
+	// SetRole changes the role of username. It is not exposed over HTTP:
+	// cmd/admin uses it to promote the first administrator.
+	SetRole(ctx context.Context, username, role string) (*model.User, error)
+}
+
+type adminService struct {
+	repo     repository.AdminRepository
+	users    repository.UserRepository
+	pats     repository.TokenRepository
+	accounts AccountService
+	audit    AuditService
+	events   UnitOfWork
+}
+
+// NewAdminService mails forced password resets through accounts. Every
+// change is recorded in the audit log; disabling, enabling and deleting
+// accounts are also written to the outbox through events.
+func NewAdminService(repo repository.AdminRepository, users repository.UserRepository, pats repository.TokenRepository, accounts AccountService, audit AuditService, events UnitOfWork) AdminService {
+	return &adminService{repo: repo, users: users, pats: pats, accounts: accounts, audit: audit, events: events}
+}
+
+func (s *adminService) IsAdmin(ctx context.Context, userID uint) (bool, error) {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return u != nil && u.IsAdmin(), nil
+}
+
+func (s *adminService) ListUsers(ctx context.Context, query string, page, perPage int) (_ *UserPage, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.ListUsers", attribute.Int("page", page))
+	defer func() { tracing.End(span, err) }()
+
+	page, perPage = normalizePage(page, perPage)
+	users, total, err := s.repo.SearchUsers(ctx, query, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &UserPage{Users: users, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// normalizePage defaults and caps the page parameters of a listing.
+func normalizePage(page, perPage int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+func (s *adminService) GetUser(ctx context.Context, userID uint) (*model.User, *model.UserUsage, error) {
+	u, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage, err := s.repo.UserUsage(ctx, u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, usage, nil
+}
+
+func (s *adminService) Stats(ctx context.Context, since time.Time) (_ *model.UsageStats, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.Stats")
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.Stats(ctx, since)
+}
+
+func (s *adminService) DisableUser(ctx context.Context, adminID, userID uint) (_ *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.DisableUser", attribute.Int("admin.id", int(adminID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.target(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.DisabledAt != nil {
+		return u, nil
+	}
+	now := time.Now()
+	u.DisabledAt = &now
+	err = s.audit.Record(ctx, s.event(adminID, model.AuditAdminUserDisable, u), func(ctx context.Context) error {
+		if err := s.users.Update(ctx, u); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventUserDisabled, model.AggregateUser, u.ID, userEventData(adminID, u))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *adminService) EnableUser(ctx context.Context, adminID, userID uint) (_ *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.EnableUser", attribute.Int("admin.id", int(adminID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.target(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.DisabledAt == nil {
+		return u, nil
+	}
+	u.DisabledAt = nil
+	err = s.audit.Record(ctx, s.event(adminID, model.AuditAdminUserEnable, u), func(ctx context.Context) error {
+		if err := s.users.Update(ctx, u); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventUserEnabled, model.AggregateUser, u.ID, userEventData(adminID, u))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *adminService) ForcePasswordReset(ctx context.Context, adminID, userID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.ForcePasswordReset", attribute.Int("admin.id", int(adminID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.target(ctx, userID)
+	if err != nil {
+		return err
+	}
+	u.PasswordResetRequired = true
+	u.TokenVersion++ // sign out everywhere
+	err = s.audit.Record(ctx, s.event(adminID, model.AuditAdminPasswordReset, u), func(ctx context.Context) error {
+		if err := s.users.Update(ctx, u); err != nil {
+			return err
+		}
+		return s.pats.RevokeAllForUser(ctx, u.ID, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+	return s.accounts.ForgotPassword(ctx, u.Email)
+}
+
+func (s *adminService) DeleteUser(ctx context.Context, adminID, userID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.DeleteUser", attribute.Int("admin.id", int(adminID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.target(ctx, userID)
+	if err != nil {
+		return err
+	}
+	event := s.event(adminID, model.AuditAdminUserDelete, u)
+	event.Before = userSummary(u)
+	var archives []string
+	err = s.audit.Record(ctx, event, func(ctx context.Context) error {
+		var err error
+		if archives, err = s.users.DeleteWithData(ctx, u.ID); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventUserDeleted, model.AggregateUser, u.ID, userEventData(adminID, u))
+	})
+	if err != nil {
+		return err
+	}
+	removeExportArchives(archives)
+	return nil
+}
+
+func (s *adminService) SetRole(ctx context.Context, username, role string) (*model.User, error) {
+	if role != model.RoleUser && role != model.RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	u, err := s.users.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	u.Role = role
+	if err := s.users.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *adminService) find(ctx context.Context, userID uint) (*model.User, error) {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// event returns the audit event of adminID acting on u.
+func (s *adminService) event(adminID uint, action string, u *model.User) *model.AuditEvent {
+	return &model.AuditEvent{ActorID: adminID, Action: action, TargetType: model.AuditTargetUser, TargetID: u.ID}
+}
+
+// target finds the user an admin action applies to, refusing administrators.
+func (s *adminService) target(ctx context.Context, userID uint) (*model.User, error) {
+	u, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsAdmin() {
+		return nil, ErrAdminTarget
+	}
+	return u, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/pkg/requestinfo"
+)
+
+// AuditPage is one page of audit events, newest first.
+type AuditPage struct {
+	Events  []model.AuditEvent
+	Total   int64
+	Page    int
+	PerPage int
+}
+
+type AuditService interface {
+	// Record runs change, if not nil, and appends event in the same
+	// transaction, so the event is stored if and only if the change is.
+	// change may fill in event fields only known once it ran, such as the
+	// id of a created target. IP, user agent and request ID come from ctx.
+	Record(ctx context.Context, event *model.AuditEvent, change func(ctx context.Context) error) error
+	// ListForUser returns the events acted by userID or targeting their account.
+	ListForUser(ctx context.Context, userID uint, f repository.AuditFilter, page, perPage int) (*AuditPage, error)
+	List(ctx context.Context, f repository.AuditFilter, page, perPage int) (*AuditPage, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+	tx   repository.Transactor
+}
+
+func NewAuditService(repo repository.AuditRepository, tx repository.Transactor) AuditService {
+	return &auditService{repo: repo, tx: tx}
+}
+
+func (s *auditService) Record(ctx context.Context, event *model.AuditEvent, change func(ctx context.Context) error) error {
+	info := requestinfo.FromContext(ctx)
+	event.IP = info.IP
+	event.UserAgent = truncate(info.UserAgent, 255)
+	event.RequestID = info.RequestID
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if change != nil {
+			if err := change(ctx); err != nil {
+				return err
+			}
+		}
+		return s.repo.Create(ctx, event)
+	})
+}
+
+func (s *auditService) ListForUser(ctx context.Context, userID uint, f repository.AuditFilter, page, perPage int) (*AuditPage, error) {
+	f.SubjectID = userID
+	return s.List(ctx, f, page, perPage)
+}
+
+func (s *auditService) List(ctx context.Context, f repository.AuditFilter, page, perPage int) (*AuditPage, error) {
+	page, perPage = normalizePage(page, perPage)
+	events, total, err := s.repo.List(ctx, f, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditPage{Events: events, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+// noteSummary describes a note in audit events without its content.
+func noteSummary(n *model.Note) model.AuditSummary {
+	return model.AuditSummary{"title": n.Title, "workspace_id": n.WorkspaceID, "content_length": len(n.Content)}
+}
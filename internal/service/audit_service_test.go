@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/pkg/requestinfo"
+)
+
+// mockAuditRepo keeps the recorded events in memory.
+type mockAuditRepo struct {
+	events []model.AuditEvent
+}
+
+func (m *mockAuditRepo) Create(ctx context.Context, e *model.AuditEvent) error {
+	e.ID = uint(len(m.events) + 1)
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *mockAuditRepo) List(ctx context.Context, f repository.AuditFilter, offset, limit int) ([]model.AuditEvent, int64, error) {
+	return m.events, int64(len(m.events)), nil
+}
+
+// actions returns the recorded actions in order.
+func (m *mockAuditRepo) actions() []string {
+	var out []string
+	for _, e := range m.events {
+		out = append(out, e.Action)
+	}
+	return out
+}
+
+// inlineTransactor runs fn without a transaction, for services on mock repositories.
+type inlineTransactor struct{}
+
+func (inlineTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newMockAuditService() (AuditService, *mockAuditRepo) {
+	repo := &mockAuditRepo{}
+	return NewAuditService(repo, inlineTransactor{}), repo
+}
+
+func TestAuditService_Record(t *testing.T) {
+	svc, repo := newMockAuditService()
+	ctx := requestinfo.NewContext(context.Background(), requestinfo.Info{IP: "203.0.113.7", UserAgent: "curl/8", RequestID: "req-1"})
+
+	event := &model.AuditEvent{ActorID: 1, Action: model.AuditNoteCreate}
+	err := svc.Record(ctx, event, func(ctx context.Context) error {
+		event.TargetID = 42
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	got := repo.events[0]
+	if got.TargetID != 42 || got.IP != "203.0.113.7" || got.UserAgent != "curl/8" || got.RequestID != "req-1" {
+		t.Fatalf("unexpected event: %+v", got)
+	}
+
+	// a failed change leaves no event behind
+	boom := errors.New("boom")
+	if err := svc.Record(ctx, &model.AuditEvent{Action: model.AuditNoteDelete}, func(ctx context.Context) error { return boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected the change's error, got %v", err)
+	}
+	if len(repo.events) != 1 {
+		t.Fatalf("expected no event for a failed change, got %d events", len(repo.events))
+	}
+}
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/ot"
+)
+
+var (
+	ErrCollabReadOnly    = errors.New("editing needs write access to the note")
+	ErrCollabUnavailable = errors.New("the note is being edited through another server")
+	ErrCollabClosed      = errors.New("editing session closed")
+	ErrInvalidCursor     = errors.New("cursor outside the document")
+
+	errCollabShutdown = errors.New("server is shutting down")
+)
+
+// Types of the messages a CollabSession sends.
+const (
+	// CollabInit is the first message: the document, its revision, the
+	// session's client ID and who else is there.
+	CollabInit = "init"
+	// CollabAck acknowledges the session's last operation, which made
+	// Revision.
+	CollabAck = "ack"
+	// CollabOp carries an operation of another editor, or of a change made
+	// to the note outside the session when ClientID is empty.
+	CollabOp = "op"
+	// CollabPresence lists who is there and their cursors.
+	CollabPresence = "presence"
+	// CollabError reports why the server ends the session.
+	CollabError = "error"
+)
+
+const (
+	collabSendBuffer   = 256
+	collabLeaseTTL     = time.Minute
+	collabSaveAttempts = 3
+)
+
+// Cursor is an editor's selection, from Anchor to Position, or the caret
+// when they are equal.
+type Cursor struct {
+	Position int `json:"position"`
+	Anchor   int `json:"anchor"`
+}
+
+// Collaborator is one session on a document.
+type Collaborator struct {
+	ClientID string  `json:"client_id"`
+	UserID   uint    `json:"user_id"`
+	Username string  `json:"username"`
+	CanEdit  bool    `json:"can_edit"`
+	Cursor   *Cursor `json:"cursor,omitempty"`
+}
+
+// CollabMessage is a message from the server to an editor.
+type CollabMessage struct {
+	Type          string         `json:"type"`
+	Revision      int            `json:"revision"`
+	ClientID      string         `json:"client_id,omitempty"`
+	Content       *string        `json:"content,omitempty"`
+	Op            *ot.Operation  `json:"op,omitempty"`
+	Collaborators []Collaborator `json:"collaborators,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// CollabService hosts real-time editing sessions on notes. The editors of a
+// note share one ot.Document: they send operations based on the revision
+// they last saw, and the service transforms them against the operations
+// accepted since, acknowledges them to their sender and forwards them to
+// everyone else. Documents are saved to their notes through NoteService, by
+// their last editor, periodically and when the last editor leaves.
+//
+// A note's document lives on one server at a time, which holds a lease on
+// it; joining it through another server fails with ErrCollabUnavailable.
+type CollabService interface {
+	// Join opens a session on a note the user can read. Editing needs write
+	// access too.
+	Join(ctx context.Context, userID, workspaceID, noteID uint) (*CollabSession, error)
+	// Snapshot renews the leases of the open documents and saves the ones
+	// edited since their last save, merging changes made to their notes
+	// meanwhile, e.g. through PUT /notes/:id. It returns how many it saved.
+	Snapshot(ctx context.Context) (int, error)
+	// Close ends all sessions and saves their documents.
+	Close(ctx context.Context) error
+}
+
+type collabService struct {
+	notes      repository.NoteRepository
+	noteSvc    NoteService
+	workspaces WorkspaceService
+	users      repository.UserRepository
+	leases     repository.OutboxRepository
+	holder     string
+	leaseTTL   time.Duration
+	maxHistory int
+
+	mu    sync.Mutex
+	rooms map[uint]*collabRoom
+}
+
+func NewCollabService(notes repository.NoteRepository, noteSvc NoteService, workspaces WorkspaceService, users repository.UserRepository, leases repository.OutboxRepository, cfg *config.Config) CollabService {
+	return &collabService{
+		notes:      notes,
+		noteSvc:    noteSvc,
+		workspaces: workspaces,
+		users:      users,
+		leases:     leases,
+		holder:     leaseHolder(),
+		leaseTTL:   max(collabLeaseTTL, 3*cfg.CollabSnapshotInterval),
+		maxHistory: cfg.CollabMaxHistory,
+		rooms:      make(map[uint]*collabRoom),
+	}
+}
+
+// collabRoom is the open document of a note and its sessions.
+type collabRoom struct {
+	noteID      uint
+	workspaceID uint
+
+	saving sync.Mutex // held while saving
+
+	mu       sync.Mutex
+	doc      *ot.Document
+	sessions []*CollabSession
+	saved    string // the note's content as last read or written
+	savedRev int    // the revision of doc that saved holds
+	editor   uint   // the user who made the last edit
+	closed   bool
+}
+
+// CollabSession is one editor's connection to a document. The caller reads
+// the messages for the editor from Messages until it is closed, and calls
+// Leave when the editor goes away.
+type CollabSession struct {
+	ID       string
+	UserID   uint
+	Username string
+	CanEdit  bool
+
+	svc    *collabService
+	room   *collabRoom
+	out    chan CollabMessage
+	cursor *Cursor
+	closed bool // guarded by room.mu
+}
+
+func collabLease(noteID uint) string {
+	return fmt.Sprintf("collab-note-%d", noteID)
+}
+
+func (s *collabService) Join(ctx context.Context, userID, workspaceID, noteID uint) (_ *CollabSession, err error) {
+	ctx, span := tracing.Start(ctx, "CollabService.Join", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(noteID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.workspaces.Authorize(ctx, userID, workspaceID, model.PermNotesRead)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.notes.FindByID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil || n.WorkspaceID != ws.ID {
+		return nil, ErrNoteNotFound
+	}
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	session := &CollabSession{ID: id, UserID: userID, CanEdit: ws.Role.Can(model.PermNotesWrite), svc: s, out: make(chan CollabMessage, collabSendBuffer)}
+	if u != nil {
+		session.Username = u.Username
+	}
+	for {
+		room, err := s.room(ctx, n.ID)
+		if err != nil {
+			return nil, err
+		}
+		// a room that was closed meanwhile is gone from s.rooms by now
+		if room.join(session) {
+			return session, nil
+		}
+	}
+}
+
+// room returns the open document of a note, opening it if needed.
+func (s *collabService) room(ctx context.Context, noteID uint) (*collabRoom, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if room := s.rooms[noteID]; room != nil {
+		return room, nil
+	}
+	now := time.Now()
+	ok, err := s.leases.AcquireLease(ctx, collabLease(noteID), s.holder, now, now.Add(s.leaseTTL))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCollabUnavailable
+	}
+	// load the note again, as a room closed meanwhile may have saved it
+	n, err := s.notes.FindByID(ctx, noteID)
+	if err == nil && n == nil {
+		err = ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, errors.Join(err, s.leases.ReleaseLease(ctx, collabLease(noteID), s.holder))
+	}
+	room := &collabRoom{
+		noteID:      n.ID,
+		workspaceID: n.WorkspaceID,
+		doc:         ot.NewDocument(n.Content, s.maxHistory),
+		saved:       n.Content,
+	}
+	s.rooms[n.ID] = room
+	return room, nil
+}
+
+func (s *collabService) Snapshot(ctx context.Context) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "CollabService.Snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	s.mu.Lock()
+	rooms := make([]*collabRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	saved := 0
+	now := time.Now()
+	for _, room := range rooms {
+		ok, err := s.leases.AcquireLease(ctx, collabLease(room.noteID), s.holder, now, now.Add(s.leaseTTL))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			// another server took over; saving would overwrite its edits
+			room.mu.Lock()
+			room.kick(ErrCollabUnavailable)
+			room.mu.Unlock()
+			continue
+		}
+		ok, err = s.save(ctx, room)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("note %d: %w", room.noteID, err))
+		}
+		if ok {
+			saved++
+		}
+	}
+	return saved, errors.Join(errs...)
+}
+
+func (s *collabService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	rooms := make([]*collabRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, room := range rooms {
+		room.mu.Lock()
+		room.kick(errCollabShutdown)
+		room.mu.Unlock()
+		if err := s.closeRoom(ctx, room); err != nil {
+			errs = append(errs, fmt.Errorf("note %d: %w", room.noteID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeRoom saves and closes room once its last session has left.
+func (s *collabService) closeRoom(ctx context.Context, room *collabRoom) error {
+	// holding s.mu keeps a new room for the note from loading it before it
+	// is saved
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room.mu.Lock()
+	if room.closed || len(room.sessions) > 0 {
+		room.mu.Unlock()
+		return nil
+	}
+	room.closed = true
+	room.mu.Unlock()
+	if s.rooms[room.noteID] == room {
+		delete(s.rooms, room.noteID)
+	}
+	_, err := s.save(ctx, room)
+	return errors.Join(err, s.leases.ReleaseLease(ctx, collabLease(room.noteID), s.holder))
+}
+
+// save writes room's document to its note if it changed since the last
+// save. Changes made to the note meanwhile are merged into the document
+// first and sent to its editors; when one comes in between the merge and
+// the write, it is merged and the write tried again.
+func (s *collabService) save(ctx context.Context, room *collabRoom) (bool, error) {
+	room.saving.Lock()
+	defer room.saving.Unlock()
+
+	var mergeErr error
+	for attempt := 1; ; attempt++ {
+		n, err := s.notes.FindByID(ctx, room.noteID)
+		if err != nil {
+			return false, errors.Join(mergeErr, err)
+		}
+		room.mu.Lock()
+		if n == nil {
+			room.kick(ErrNoteNotFound)
+			room.mu.Unlock()
+			return false, mergeErr
+		}
+		if n.Content != room.saved {
+			if op, err := room.doc.Receive(room.savedRev, ot.Diff(room.saved, n.Content)); err != nil {
+				mergeErr = errors.Join(mergeErr, fmt.Errorf("dropping a change made to the note outside the session: %w", err))
+			} else {
+				room.apply(nil, op)
+				// the note is now part of the document; it only equals a
+				// revision of it without concurrent edits, which matters no
+				// more once the document is written below
+				room.saved, room.savedRev = n.Content, room.doc.Revision()
+			}
+		}
+		text, rev, editor := room.doc.Text(), room.doc.Revision(), room.editor
+		room.mu.Unlock()
+
+		changed := text != n.Content
+		if changed && editor != 0 {
+			_, err := s.noteSvc.UpdateIfUnchanged(ctx, editor, room.workspaceID, room.noteID, n.ChangeSeq, n.Title, text)
+			if errors.Is(err, ErrNoteConflict) && attempt < collabSaveAttempts {
+				continue
+			}
+			if err != nil {
+				return false, errors.Join(mergeErr, err)
+			}
+		}
+		room.mu.Lock()
+		room.saved, room.savedRev = text, rev
+		room.mu.Unlock()
+		return changed, mergeErr
+	}
+}
+
+// join adds session to the room, unless the room was closed.
+func (r *collabRoom) join(session *CollabSession) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	session.room = r
+	r.sessions = append(r.sessions, session)
+	metrics.CollabSessions.Inc()
+	content := r.doc.Text()
+	r.send(session, CollabMessage{Type: CollabInit, Revision: r.doc.Revision(), ClientID: session.ID, Content: &content, Collaborators: r.collaborators()})
+	r.broadcastPresence(session)
+	return true
+}
+
+// apply sends op, accepted from session or, when it is nil, merged from
+// outside the session, to the other sessions and moves their cursors.
+// r.mu must be held.
+func (r *collabRoom) apply(from *CollabSession, op *ot.Operation) {
+	msg := CollabMessage{Type: CollabOp, Revision: r.doc.Revision(), Op: op}
+	if from != nil {
+		msg.ClientID = from.ID
+		r.editor = from.UserID
+	}
+	for _, session := range slices.Clone(r.sessions) {
+		if c := session.cursor; c != nil {
+			c.Position = ot.TransformIndex(c.Position, op)
+			c.Anchor = ot.TransformIndex(c.Anchor, op)
+		}
+		if session == from {
+			r.send(session, CollabMessage{Type: CollabAck, Revision: r.doc.Revision()})
+		} else {
+			r.send(session, msg)
+		}
+	}
+}
+
+// send queues msg for session. A session that does not keep up is dropped,
+// and its editor has to reconnect. r.mu must be held.
+func (r *collabRoom) send(session *CollabSession, msg CollabMessage) {
+	select {
+	case session.out <- msg:
+	default:
+		r.remove(session)
+	}
+}
+
+// kick ends every session, telling the editors why. r.mu must be held.
+func (r *collabRoom) kick(reason error) {
+	for _, session := range slices.Clone(r.sessions) {
+		select {
+		case session.out <- CollabMessage{Type: CollabError, Error: reason.Error()}:
+		default:
+		}
+		r.remove(session)
+	}
+}
+
+// remove ends session and reports whether it was still in the room. r.mu
+// must be held.
+func (r *collabRoom) remove(session *CollabSession) bool {
+	if session.closed {
+		return false
+	}
+	session.closed = true
+	close(session.out)
+	r.sessions = slices.DeleteFunc(r.sessions, func(s *CollabSession) bool { return s == session })
+	metrics.CollabSessions.Dec()
+	return true
+}
+
+// broadcastPresence sends the collaborators to every session but except.
+// r.mu must be held.
+func (r *collabRoom) broadcastPresence(except *CollabSession) {
+	msg := CollabMessage{Type: CollabPresence, Revision: r.doc.Revision(), Collaborators: r.collaborators()}
+	for _, session := range slices.Clone(r.sessions) {
+		if session != except {
+			r.send(session, msg)
+		}
+	}
+}
+
+func (r *collabRoom) collaborators() []Collaborator {
+	out := make([]Collaborator, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		c := Collaborator{ClientID: s.ID, UserID: s.UserID, Username: s.Username, CanEdit: s.CanEdit}
+		if s.cursor != nil {
+			cursor := *s.cursor
+			c.Cursor = &cursor
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Messages returns the messages for the editor, starting with CollabInit.
+// It is closed when the session ends.
+func (s *CollabSession) Messages() <-chan CollabMessage {
+	return s.out
+}
+
+// Submit applies op, made for the document at revision. The server answers
+// with CollabAck once the session has received every operation before it.
+func (s *CollabSession) Submit(revision int, op *ot.Operation) error {
+	if !s.CanEdit {
+		return ErrCollabReadOnly
+	}
+	r := s.room
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.closed {
+		return ErrCollabClosed
+	}
+	op, err := r.doc.Receive(revision, op)
+	if err != nil {
+		return err
+	}
+	r.apply(s, op)
+	return nil
+}
+
+// MoveCursor sets the editor's cursor, given in the document at revision,
+// and shows it to the others. Later operations move it along.
+func (s *CollabSession) MoveCursor(revision int, c Cursor) error {
+	r := s.room
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.closed {
+		return ErrCollabClosed
+	}
+	var err error
+	if c.Position, err = r.doc.TransformIndex(revision, c.Position); err != nil {
+		return err
+	}
+	if c.Anchor, err = r.doc.TransformIndex(revision, c.Anchor); err != nil {
+		return err
+	}
+	length := utf8.RuneCountInString(r.doc.Text())
+	if c.Position < 0 || c.Anchor < 0 || c.Position > length || c.Anchor > length {
+		return ErrInvalidCursor
+	}
+	s.cursor = &c
+	r.broadcastPresence(s)
+	return nil
+}
+
+// Leave ends the session. The document is saved and closed once its last
+// editor leaves.
+func (s *CollabSession) Leave(ctx context.Context) error {
+	r := s.room
+	r.mu.Lock()
+	if r.remove(s) {
+		r.broadcastPresence(nil)
+	}
+	empty := len(r.sessions) == 0 && !r.closed
+	r.mu.Unlock()
+	if !empty {
+		return nil
+	}
+	return s.svc.closeRoom(ctx, r)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/pkg/ot"
+)
+
+func TestCollabService_OneServerPerNote(t *testing.T) {
+	notes, users, workspaces := newMockNoteRepo(), newMockUserRepo(), newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	outbox := &mockUnitOfWork{}
+	wsSvc := NewWorkspaceService(workspaces, users, audit, outbox)
+	noteSvc := NewNoteService(notes, wsSvc, audit, outbox, NewNotificationService(&mockNotificationRepo{}, users, workspaces, outbox))
+	leases := &mockOutboxRepo{}
+	svc := NewCollabService(notes, noteSvc, wsSvc, users, leases, &config.Config{})
+	ctx := context.Background()
+
+	n, err := noteSvc.Create(ctx, 1, 0, "t", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another server hosts the note
+	leases.leaseHolder, leases.leaseUntil = "other", time.Now().Add(time.Minute)
+	if _, err := svc.Join(ctx, 1, 0, n.ID); !errors.Is(err, ErrCollabUnavailable) {
+		t.Fatalf("expected ErrCollabUnavailable, got %v", err)
+	}
+
+	// its lease expired
+	leases.leaseUntil = time.Now().Add(-time.Second)
+	session, err := svc.Join(ctx, 1, 0, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if init := <-session.Messages(); init.Type != CollabInit || *init.Content != "shared" || !session.CanEdit {
+		t.Fatalf("unexpected init %+v", init)
+	}
+
+	// losing the lease ends the sessions without saving
+	leases.leaseHolder, leases.leaseUntil = "other", time.Now().Add(time.Minute)
+	if saved, err := svc.Snapshot(ctx); err != nil || saved != 0 {
+		t.Fatalf("snapshot: %d, %v", saved, err)
+	}
+	if msg := <-session.Messages(); msg.Type != CollabError || msg.Error != ErrCollabUnavailable.Error() {
+		t.Fatalf("expected the session to be ended, got %+v", msg)
+	}
+	if _, open := <-session.Messages(); open {
+		t.Fatalf("expected Messages to be closed")
+	}
+	if err := session.Leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// racingNoteService changes the note once, as another request would, right
+// before the first conditional update of it.
+type racingNoteService struct {
+	NoteService
+	change func()
+}
+
+func (s *racingNoteService) UpdateIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64, title, content string) (*model.Note, error) {
+	if s.change != nil {
+		s.change()
+		s.change = nil
+	}
+	return s.NoteService.UpdateIfUnchanged(ctx, userID, workspaceID, id, baseSeq, title, content)
+}
+
+func TestCollabService_SaveMergesConcurrentChanges(t *testing.T) {
+	notes, users, workspaces := newMockNoteRepo(), newMockUserRepo(), newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	outbox := &mockUnitOfWork{}
+	wsSvc := NewWorkspaceService(workspaces, users, audit, outbox)
+	noteSvc := NewNoteService(notes, wsSvc, audit, outbox, NewNotificationService(&mockNotificationRepo{}, users, workspaces, outbox))
+	racing := &racingNoteService{NoteService: noteSvc}
+	svc := NewCollabService(notes, racing, wsSvc, users, &mockOutboxRepo{}, &config.Config{})
+	ctx := context.Background()
+
+	n, err := noteSvc.Create(ctx, 1, 0, "t", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := svc.Join(ctx, 1, 0, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := <-session.Messages()
+	if err := session.Submit(init.Revision, ot.New().Retain(6).Insert(" doc")); err != nil {
+		t.Fatal(err)
+	}
+
+	racing.change = func() {
+		if _, err := noteSvc.Update(ctx, 1, 0, n.ID, "t", "a shared"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if saved, err := svc.Snapshot(ctx); err != nil || saved != 1 {
+		t.Fatalf("snapshot: %d, %v", saved, err)
+	}
+	if got, _ := notes.FindByID(ctx, n.ID); got.Content != "a shared doc" {
+		t.Fatalf("expected both changes saved, got %q", got.Content)
+	}
+	if err := session.Leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+const maxCommentLength = 10000
+
+var (
+	ErrNoteNotFound     = errors.New("note not found")
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrCommentForbidden = errors.New("not allowed to change this comment")
+	ErrCommentDeleted   = errors.New("comment was deleted")
+	ErrInvalidComment   = errors.New("comment must be between 1 and 10000 characters")
+	ErrInvalidParent    = errors.New("parent comment not found on this note")
+)
+
+// CommentService works on the comments of a note, found in a workspace like
+// NoteService's notes. Any role may read and write comments; their authors
+// edit them, and authors or workspace admins delete them. Comments notify
+// the users they @mention, the author of the comment they reply to and the
+// note's author.
+type CommentService interface {
+	// Create adds a comment to the note, replying to parentID if not nil.
+	Create(ctx context.Context, userID, workspaceID, noteID uint, parentID *uint, body string) (*model.Comment, error)
+	// List returns the comments of a note in the order they were written.
+	List(ctx context.Context, userID, workspaceID, noteID uint) ([]model.Comment, error)
+	Get(ctx context.Context, userID, workspaceID, noteID, id uint) (*model.Comment, error)
+	// Update changes the body of the caller's comment, keeping the former
+	// body in its history.
+	Update(ctx context.Context, userID, workspaceID, noteID, id uint, body string) (*model.Comment, error)
+	// History returns the former bodies of a comment, oldest first.
+	History(ctx context.Context, userID, workspaceID, noteID, id uint) ([]model.CommentRevision, error)
+	// Delete removes the body and history of a comment; the comment keeps
+	// its place in the thread.
+	Delete(ctx context.Context, userID, workspaceID, noteID, id uint) error
+}
+
+type commentService struct {
+	repo          repository.CommentRepository
+	notes         repository.NoteRepository
+	workspaces    WorkspaceService
+	events        UnitOfWork
+	notifications NotificationService
+}
+
+func NewCommentService(repo repository.CommentRepository, notes repository.NoteRepository, workspaces WorkspaceService, events UnitOfWork, notifications NotificationService) CommentService {
+	return &commentService{repo: repo, notes: notes, workspaces: workspaces, events: events, notifications: notifications}
+}
+
+func (s *commentService) Create(ctx context.Context, userID, workspaceID, noteID uint, parentID *uint, body string) (_ *model.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentService.Create", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(noteID)))
+	defer func() { tracing.End(span, err) }()
+
+	body, err = commentBody(body)
+	if err != nil {
+		return nil, err
+	}
+	_, n, err := s.note(ctx, userID, workspaceID, noteID, model.PermCommentsWrite)
+	if err != nil {
+		return nil, err
+	}
+	var parent *model.Comment
+	if parentID != nil {
+		if parent, err = s.repo.FindByID(ctx, *parentID); err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.NoteID != n.ID {
+			return nil, ErrInvalidParent
+		}
+	}
+	c := &model.Comment{NoteID: n.ID, WorkspaceID: n.WorkspaceID, UserID: userID, ParentID: parentID, Body: body}
+	err = s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, c); err != nil {
+			return err
+		}
+		if err := s.events.Emit(ctx, model.EventCommentCreated, model.AggregateComment, c.ID, commentEventData(userID, c)); err != nil {
+			return err
+		}
+		mentioned, err := s.notifications.Mentioned(ctx, body)
+		if err != nil {
+			return err
+		}
+		if err := s.notify(ctx, model.NotificationMention, c, mentioned); err != nil {
+			return err
+		}
+		// one notification per user: a mention says more than a reply
+		notified := append(mentioned, userID)
+		if parent != nil && !slices.Contains(notified, parent.UserID) {
+			if err := s.notify(ctx, model.NotificationReply, c, []uint{parent.UserID}); err != nil {
+				return err
+			}
+			notified = append(notified, parent.UserID)
+		}
+		if !slices.Contains(notified, n.UserID) {
+			return s.notify(ctx, model.NotificationComment, c, []uint{n.UserID})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *commentService) List(ctx context.Context, userID, workspaceID, noteID uint) (_ []model.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentService.List", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(noteID)))
+	defer func() { tracing.End(span, err) }()
+
+	_, n, err := s.note(ctx, userID, workspaceID, noteID, model.PermNotesRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListByNote(ctx, n.ID)
+}
+
+func (s *commentService) Get(ctx context.Context, userID, workspaceID, noteID, id uint) (*model.Comment, error) {
+	_, c, err := s.comment(ctx, userID, workspaceID, noteID, id, model.PermNotesRead)
+	return c, err
+}
+
+func (s *commentService) Update(ctx context.Context, userID, workspaceID, noteID, id uint, body string) (_ *model.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentService.Update", attribute.Int("user.id", int(userID)), attribute.Int("comment.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	body, err = commentBody(body)
+	if err != nil {
+		return nil, err
+	}
+	_, c, err := s.comment(ctx, userID, workspaceID, noteID, id, model.PermCommentsWrite)
+	if err != nil {
+		return nil, err
+	}
+	if c.DeletedAt != nil {
+		return nil, ErrCommentDeleted
+	}
+	if c.UserID != userID {
+		return nil, ErrCommentForbidden
+	}
+	if c.Body == body {
+		return c, nil
+	}
+	before := c.Body
+	now := time.Now()
+	c.Body = body
+	c.EditedAt = &now
+	err = s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateRevision(ctx, &model.CommentRevision{CommentID: c.ID, Body: before}); err != nil {
+			return err
+		}
+		if err := s.repo.Update(ctx, c); err != nil {
+			return err
+		}
+		if err := s.events.Emit(ctx, model.EventCommentUpdated, model.AggregateComment, c.ID, commentEventData(userID, c)); err != nil {
+			return err
+		}
+		mentioned, err := newMentions(ctx, s.notifications, before, body)
+		if err != nil {
+			return err
+		}
+		return s.notify(ctx, model.NotificationMention, c, mentioned)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *commentService) History(ctx context.Context, userID, workspaceID, noteID, id uint) ([]model.CommentRevision, error) {
+	_, c, err := s.comment(ctx, userID, workspaceID, noteID, id, model.PermNotesRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListRevisions(ctx, c.ID)
+}
+
+func (s *commentService) Delete(ctx context.Context, userID, workspaceID, noteID, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "CommentService.Delete", attribute.Int("user.id", int(userID)), attribute.Int("comment.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, c, err := s.comment(ctx, userID, workspaceID, noteID, id, model.PermCommentsWrite)
+	if err != nil {
+		return err
+	}
+	if c.UserID != userID && !ws.Role.Can(model.PermCommentsModerate) {
+		return ErrCommentForbidden
+	}
+	if c.DeletedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	c.Body = ""
+	c.DeletedAt = &now
+	return s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteRevisions(ctx, c.ID); err != nil {
+			return err
+		}
+		if err := s.repo.Update(ctx, c); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventCommentDeleted, model.AggregateComment, c.ID, commentEventData(userID, c))
+	})
+}
+
+// note loads a note of the workspace after checking that the caller's role
+// there grants perm.
+func (s *commentService) note(ctx context.Context, userID, workspaceID, noteID uint, perm model.WorkspacePermission) (*model.Workspace, *model.Note, error) {
+	ws, err := s.workspaces.Authorize(ctx, userID, workspaceID, perm)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := s.notes.FindByID(ctx, noteID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n == nil || n.WorkspaceID != ws.ID {
+		return nil, nil, ErrNoteNotFound
+	}
+	return ws, n, nil
+}
+
+// comment loads comment id of a note like note does.
+func (s *commentService) comment(ctx context.Context, userID, workspaceID, noteID, id uint, perm model.WorkspacePermission) (*model.Workspace, *model.Comment, error) {
+	ws, n, err := s.note(ctx, userID, workspaceID, noteID, perm)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c == nil || c.NoteID != n.ID {
+		return nil, nil, ErrCommentNotFound
+	}
+	return ws, c, nil
+}
+
+func (s *commentService) notify(ctx context.Context, kind string, c *model.Comment, recipients []uint) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	n := model.Notification{Type: kind, ActorID: c.UserID, WorkspaceID: c.WorkspaceID, NoteID: c.NoteID, CommentID: &c.ID}
+	return s.notifications.Notify(ctx, n, recipients)
+}
+
+// newMentions returns the users mentioned in after but not in before.
+func newMentions(ctx context.Context, notifications NotificationService, before, after string) ([]uint, error) {
+	was, err := notifications.Mentioned(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	is, err := notifications.Mentioned(ctx, after)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(is, func(id uint) bool { return slices.Contains(was, id) }), nil
+}
+
+func commentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		return "", ErrInvalidComment
+	}
+	return body, nil
+}
+
+// commentEventData is the data of the comment.* events: the comment, without
+// its body once deleted, and who changed it.
+func commentEventData(actorID uint, c *model.Comment) any {
+	return struct {
+		ActorID uint          `json:"actor_id"`
+		Comment model.Comment `json:"comment"`
+	}{actorID, *c}
+}
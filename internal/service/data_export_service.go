@@ -0,0 +1,542 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+const (
+	defaultDataExportTTL = 48 * time.Hour
+	// an export still pending or running after this long was lost, e.g. its
+	// job ran out of attempts, and no longer holds back a new request
+	dataExportStaleAfter = 6 * time.Hour
+	exportBatchSize      = 200
+	exportFormatVersion  = 1
+)
+
+// DataExportService builds "download my data" archives: a ZIP of JSON files
+// written by a background job and kept for a limited time.
+type DataExportService interface {
+	// Request starts an export, or returns the one already in progress.
+	Request(ctx context.Context, userID uint) (*model.DataExport, error)
+	Get(ctx context.Context, userID, id uint) (*model.DataExport, error)
+	// PurgeExpired deletes expired exports and those of deleted users.
+	PurgeExpired(ctx context.Context) (int, error)
+	// Build writes the archive of the export in payload. It is the handler
+	// of the data_export.build job.
+	Build(ctx context.Context, payload json.RawMessage) error
+}
+
+type dataExportService struct {
+	exports       repository.DataExportRepository
+	users         repository.UserRepository
+	notes         repository.NoteRepository
+	tokens        repository.TokenRepository
+	comments      repository.CommentRepository
+	notifications repository.NotificationRepository
+	workspaces    repository.WorkspaceRepository
+	audit         repository.AuditRepository
+	jobs          JobService
+	uow           UnitOfWork
+	dir           string
+	ttl           time.Duration
+}
+
+func NewDataExportService(exports repository.DataExportRepository, users repository.UserRepository, notes repository.NoteRepository, tokens repository.TokenRepository, comments repository.CommentRepository, notifications repository.NotificationRepository, workspaces repository.WorkspaceRepository, audit repository.AuditRepository, jobs JobService, uow UnitOfWork, cfg *config.Config) DataExportService {
+	s := &dataExportService{
+		exports:       exports,
+		users:         users,
+		notes:         notes,
+		tokens:        tokens,
+		comments:      comments,
+		notifications: notifications,
+		workspaces:    workspaces,
+		audit:         audit,
+		jobs:          jobs,
+		uow:           uow,
+		dir:           cfg.DataExportDir,
+		ttl:           cfg.DataExportTTL,
+	}
+	if s.dir == "" {
+		s.dir = filepath.Join(os.TempDir(), "simple-note-exports")
+	}
+	if s.ttl <= 0 {
+		s.ttl = defaultDataExportTTL
+	}
+	return s
+}
+
+// dataExportJob is the payload of the data_export.build job.
+type dataExportJob struct {
+	ExportID uint `json:"export_id"`
+}
+
+func (s *dataExportService) Request(ctx context.Context, userID uint) (_ *model.DataExport, err error) {
+	ctx, span := tracing.Start(ctx, "DataExportService.Request")
+	defer func() { tracing.End(span, err) }()
+
+	e, err := s.exports.FindInProgressByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		if time.Since(e.CreatedAt) < dataExportStaleAfter {
+			return e, nil
+		}
+		if err := s.fail(ctx, e, "export timed out"); err != nil {
+			return nil, err
+		}
+	}
+
+	e = &model.DataExport{UserID: userID, Status: model.ExportPending}
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.exports.Create(ctx, e); err != nil {
+			return err
+		}
+		_, err := s.jobs.Enqueue(ctx, model.JobDataExportBuild, dataExportJob{ExportID: e.ID}, JobOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *dataExportService) Get(ctx context.Context, userID, id uint) (*model.DataExport, error) {
+	e, err := s.exports.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.UserID != userID {
+		return nil, errors.New("not found or access denied")
+	}
+	return e, nil
+}
+
+func (s *dataExportService) PurgeExpired(ctx context.Context) (int, error) {
+	stale, err := s.exports.ListStale(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for i, e := range stale {
+		if e.Path != "" {
+			if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return i, err
+			}
+		}
+		if err := s.exports.Delete(ctx, e.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(stale), nil
+}
+
+// removeExportArchives removes the archives of a deleted user's exports. The
+// user is gone already, so failing to remove one is only logged.
+func removeExportArchives(paths []string) {
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("WARNING: remove data export %s: %v", p, err)
+		}
+	}
+}
+
+func (s *dataExportService) Build(ctx context.Context, payload json.RawMessage) (err error) {
+	var p dataExportJob
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	ctx, span := tracing.Start(ctx, "DataExportService.Build", attribute.Int("export.id", int(p.ExportID)))
+	defer func() { tracing.End(span, err) }()
+
+	e, err := s.exports.FindByID(ctx, p.ExportID)
+	if err != nil {
+		return err
+	}
+	if e == nil || (e.Status != model.ExportPending && e.Status != model.ExportRunning) {
+		return nil // purged, or finished by an earlier attempt
+	}
+	// a running export is one whose earlier attempt failed or whose worker
+	// died: start it over
+	e.Status = model.ExportRunning
+	if err := s.exports.Update(ctx, e); err != nil {
+		return err
+	}
+
+	path, size, err := s.writeArchive(ctx, e)
+	if err != nil {
+		// the job is retried, until its last attempt fails the export
+		if LastAttempt(ctx) {
+			log.Printf("data export %d failed: %v", e.ID, err)
+			return errors.Join(err, s.fail(ctx, e, "export failed"))
+		}
+		return err
+	}
+	now := time.Now()
+	expires := now.Add(s.ttl)
+	e.Status = model.ExportReady
+	e.Path = path
+	e.Size = size
+	e.CompletedAt = &now
+	e.ExpiresAt = &expires
+	return s.exports.Update(ctx, e)
+}
+
+// fail marks the export failed with reason, which the user is shown.
+func (s *dataExportService) fail(ctx context.Context, e *model.DataExport, reason string) error {
+	now := time.Now()
+	e.Status = model.ExportFailed
+	e.Error = reason
+	e.CompletedAt = &now
+	return s.exports.Update(ctx, e)
+}
+
+// writeArchive streams the user's data into a ZIP file next to its final
+// path and renames it into place once complete.
+func (s *dataExportService) writeArchive(ctx context.Context, e *model.DataExport) (string, int64, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	f, err := os.CreateTemp(s.dir, fmt.Sprintf("export-%d-*.zip.tmp", e.ID))
+	if err != nil {
+		return "", 0, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after the rename
+
+	zw := zip.NewWriter(f)
+	if err := s.writeEntries(ctx, zw, e.UserID); err != nil {
+		f.Close()
+		return "", 0, err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return "", 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return "", 0, err
+	}
+	if err := f.Close(); err != nil {
+		return "", 0, err
+	}
+	final := tmp[:len(tmp)-len(".tmp")]
+	if err := os.Rename(tmp, final); err != nil {
+		return "", 0, err
+	}
+	return final, info.Size(), nil
+}
+
+type exportManifest struct {
+	FormatVersion int       `json:"format_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	UserID        uint      `json:"user_id"`
+	Files         []string  `json:"files"`
+}
+
+type exportProfile struct {
+	ID               uint       `json:"id"`
+	Name             string     `json:"name"`
+	Username         string     `json:"username"`
+	Email            string     `json:"email"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TimeZone         string     `json:"time_zone"`
+	Locale           string     `json:"locale"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type exportNote struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type exportAccessToken struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type exportWorkspace struct {
+	ID       uint                `json:"id"`
+	Name     string              `json:"name"`
+	Personal bool                `json:"personal"`
+	Role     model.WorkspaceRole `json:"role"`
+}
+
+type exportComment struct {
+	ID          uint       `json:"id"`
+	NoteID      uint       `json:"note_id"`
+	WorkspaceID uint       `json:"workspace_id"`
+	ParentID    *uint      `json:"parent_id,omitempty"`
+	Body        string     `json:"body"`
+	EditedAt    *time.Time `json:"edited_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type exportNotification struct {
+	ID          uint       `json:"id"`
+	Type        string     `json:"type"`
+	ActorID     uint       `json:"actor_id"`
+	WorkspaceID uint       `json:"workspace_id"`
+	NoteID      uint       `json:"note_id"`
+	CommentID   *uint      `json:"comment_id,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type exportAuditEvent struct {
+	ID         uint               `json:"id"`
+	ActorID    uint               `json:"actor_id"`
+	Action     string             `json:"action"`
+	TargetType string             `json:"target_type,omitempty"`
+	TargetID   uint               `json:"target_id,omitempty"`
+	IP         string             `json:"ip,omitempty"`
+	UserAgent  string             `json:"user_agent,omitempty"`
+	Before     model.AuditSummary `json:"before,omitempty"`
+	After      model.AuditSummary `json:"after,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+func (s *dataExportService) writeEntries(ctx context.Context, zw *zip.Writer, userID uint) error {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return errors.New("user not found")
+	}
+	files := []string{"manifest.json", "profile.json", "workspaces.json", "notes.json", "comments.json", "notifications.json", "access_tokens.json", "audit_events.json"}
+	generatedAt := time.Now()
+
+	if err := writeJSONEntry(zw, "manifest.json", exportManifest{
+		FormatVersion: exportFormatVersion,
+		GeneratedAt:   generatedAt.UTC(),
+		UserID:        u.ID,
+		Files:         files,
+	}); err != nil {
+		return err
+	}
+	if err := writeJSONEntry(zw, "profile.json", exportProfile{
+		ID:               u.ID,
+		Name:             u.Name,
+		Username:         u.Username,
+		Email:            u.Email,
+		EmailVerifiedAt:  u.EmailVerifiedAt,
+		TimeZone:         u.TimeZone,
+		Locale:           u.Locale,
+		TwoFactorEnabled: u.TOTPEnabled,
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
+	}); err != nil {
+		return err
+	}
+
+	workspaces, err := s.workspaces.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	memberships := make([]exportWorkspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		memberships = append(memberships, exportWorkspace{ID: ws.ID, Name: ws.Name, Personal: ws.Personal, Role: ws.Role})
+	}
+	if err := writeJSONEntry(zw, "workspaces.json", memberships); err != nil {
+		return err
+	}
+
+	// notes, comments, notifications and audit events can be many: write
+	// them batch by batch as JSON arrays
+	err = writeJSONArrayEntry(zw, "notes.json", func(arr *jsonArrayWriter) error {
+		return s.notes.EachByUser(ctx, userID, exportBatchSize, func(batch []model.Note) error {
+			for _, n := range batch {
+				if err := arr.Write(exportNote{ID: n.ID, Title: n.Title, Content: n.Content, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = writeJSONArrayEntry(zw, "comments.json", func(arr *jsonArrayWriter) error {
+		return s.comments.EachByUser(ctx, userID, exportBatchSize, func(batch []model.Comment) error {
+			for _, c := range batch {
+				if err := arr.Write(exportComment{
+					ID:          c.ID,
+					NoteID:      c.NoteID,
+					WorkspaceID: c.WorkspaceID,
+					ParentID:    c.ParentID,
+					Body:        c.Body,
+					EditedAt:    c.EditedAt,
+					DeletedAt:   c.DeletedAt,
+					CreatedAt:   c.CreatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = writeJSONArrayEntry(zw, "notifications.json", func(arr *jsonArrayWriter) error {
+		return s.notifications.EachByUser(ctx, userID, exportBatchSize, func(batch []model.Notification) error {
+			for _, n := range batch {
+				if err := arr.Write(exportNotification{
+					ID:          n.ID,
+					Type:        n.Type,
+					ActorID:     n.ActorID,
+					WorkspaceID: n.WorkspaceID,
+					NoteID:      n.NoteID,
+					CommentID:   n.CommentID,
+					ReadAt:      n.ReadAt,
+					CreatedAt:   n.CreatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	tokens, err := s.tokens.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	out := make([]exportAccessToken, 0, len(tokens))
+	for _, t := range tokens {
+		out = append(out, exportAccessToken{
+			ID:         t.ID,
+			Name:       t.Name,
+			Prefix:     t.Prefix,
+			Scopes:     t.ScopeList(),
+			ExpiresAt:  t.ExpiresAt,
+			LastUsedAt: t.LastUsedAt,
+			RevokedAt:  t.RevokedAt,
+			CreatedAt:  t.CreatedAt,
+		})
+	}
+	if err := writeJSONEntry(zw, "access_tokens.json", out); err != nil {
+		return err
+	}
+
+	// events recorded while the export runs would shift the pages
+	f := repository.AuditFilter{SubjectID: userID, Until: generatedAt}
+	return writeJSONArrayEntry(zw, "audit_events.json", func(arr *jsonArrayWriter) error {
+		for offset := 0; ; offset += exportBatchSize {
+			events, _, err := s.audit.List(ctx, f, offset, exportBatchSize)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if err := arr.Write(exportAuditEvent{
+					ID:         e.ID,
+					ActorID:    e.ActorID,
+					Action:     e.Action,
+					TargetType: e.TargetType,
+					TargetID:   e.TargetID,
+					IP:         e.IP,
+					UserAgent:  e.UserAgent,
+					Before:     e.Before,
+					After:      e.After,
+					CreatedAt:  e.CreatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			if len(events) < exportBatchSize {
+				return nil
+			}
+		}
+	})
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeJSONArrayEntry writes the elements written by fill as a JSON array.
+func writeJSONArrayEntry(zw *zip.Writer, name string, fill func(*jsonArrayWriter) error) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	arr := newJSONArrayWriter(w)
+	if err := fill(arr); err != nil {
+		return err
+	}
+	return arr.Close()
+}
+
+// jsonArrayWriter writes values as the elements of a JSON array, one at a time.
+type jsonArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w}
+}
+
+func (a *jsonArrayWriter) Write(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if a.count == 0 {
+		sep = "[\n  "
+	}
+	a.count++
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	_, err = a.w.Write(b)
+	return err
+}
+
+func (a *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if a.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
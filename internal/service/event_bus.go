@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event is a domain event as published on the EventBus.
+type Event struct {
+	// ID is the ID of the outbox event. An event is delivered at least once,
+	// so handlers that must not act twice remember the IDs they have seen.
+	ID            uint
+	Topic         string
+	AggregateType string
+	AggregateID   uint
+	Data          json.RawMessage
+	OccurredAt    time.Time
+}
+
+// EventHandler handles an event. A returned error makes the outbox relay
+// publish the event again later, to every handler of its topic.
+type EventHandler func(ctx context.Context, e Event) error
+
+// EventBus delivers domain events to the handlers subscribed in this
+// process. Events reach it through the outbox relay (see UnitOfWork), in
+// the order they were written, on the one server relaying at a time.
+type EventBus interface {
+	// Publish calls the handlers of e's topic in the order they subscribed
+	// and returns their errors joined.
+	Publish(ctx context.Context, e Event) error
+	// Subscribe calls h for the events of topic, or of every topic when
+	// topic is "*". The returned function unsubscribes h.
+	Subscribe(topic string, h EventHandler) (unsubscribe func())
+}
+
+type subscription struct {
+	id    int
+	topic string
+	h     EventHandler
+}
+
+type eventBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []subscription // in subscription order
+}
+
+func NewEventBus() EventBus {
+	return &eventBus{}
+}
+
+func (b *eventBus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	var handlers []EventHandler
+	for _, s := range b.subs {
+		if s.topic == "*" || s.topic == e.Topic {
+			handlers = append(handlers, s.h)
+		}
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := callHandler(ctx, h, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *eventBus) Subscribe(topic string, h EventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs = append(b.subs, subscription{id: id, topic: topic, h: h})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subs = slices.DeleteFunc(b.subs, func(s subscription) bool { return s.id == id })
+	}
+}
+
+// callHandler calls h, turning a panic into an error.
+func callHandler(ctx context.Context, h EventHandler, e Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("event handler panicked: %v", p)
+		}
+	}()
+	return h(ctx, e)
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+var ErrEventStreamClosed = errors.New("event streams closed")
+
+const (
+	defaultEventReplayBuffer = 1000
+	eventPollBatch           = 500
+	eventSendBuffer          = 64
+	// eventGapTimeout is how long Poll waits for a missing outbox ID, whose
+	// transaction may not have committed yet, before going past it.
+	eventGapTimeout = 5 * time.Second
+)
+
+// StreamEvent is a note or notification event sent to event streams. ID
+// is the outbox event's, so that it is the same on every server.
+type StreamEvent struct {
+	ID    uint
+	Topic string
+	Data  json.RawMessage
+
+	workspaceID uint
+	userID      uint // the recipient of a notification
+}
+
+// EventStreamService streams note events to the members of the notes'
+// workspaces, and notifications to their recipients. Every server reads the
+// events from the outbox, whichever server relays them, and keeps the last
+// EVENTS_REPLAY_BUFFER of them so that a client reconnecting with the ID of
+// the last event it received, from this server or another, gets the events
+// it missed.
+type EventStreamService interface {
+	// Subscribe starts a stream of userID's events after the event
+	// lastEventID, or after the latest one when it is 0.
+	Subscribe(ctx context.Context, userID, lastEventID uint) (*EventSubscription, error)
+	// Poll reads the new events of the outbox, sends them to the
+	// subscribers and returns how many streamed events it read.
+	Poll(ctx context.Context) (int, error)
+	// Close ends every stream and refuses new ones, on shutdown.
+	Close()
+}
+
+type eventStreamService struct {
+	outbox     repository.OutboxRepository
+	workspaces repository.WorkspaceRepository
+	capacity   int
+	gapTimeout time.Duration
+
+	pollMu   sync.Mutex // serializes Poll and Subscribe, and guards the fields below
+	loaded   bool
+	cursor   uint          // the last outbox ID read
+	floor    uint          // buffer holds every streamed event after floor
+	buffer   []StreamEvent // in ID order
+	gapSince time.Time     // when Poll started waiting for the ID after cursor
+
+	mu     sync.Mutex
+	subs   map[*EventSubscription]struct{}
+	closed bool
+}
+
+func NewEventStreamService(outbox repository.OutboxRepository, workspaces repository.WorkspaceRepository, cfg *config.Config) EventStreamService {
+	capacity := cfg.EventsReplayBuffer
+	if capacity <= 0 {
+		capacity = defaultEventReplayBuffer
+	}
+	return &eventStreamService{
+		outbox:     outbox,
+		workspaces: workspaces,
+		capacity:   capacity,
+		gapTimeout: eventGapTimeout,
+		subs:       make(map[*EventSubscription]struct{}),
+	}
+}
+
+// EventSubscription is one client's stream.
+type EventSubscription struct {
+	// Missed reports that some events after the requested ID are no longer
+	// buffered: the client has to reload what it shows.
+	Missed bool
+
+	userID uint
+	after  uint // the events up to this ID were sent or skipped
+	svc    *eventStreamService
+	out    chan StreamEvent
+	closed bool // guarded by svc.mu
+}
+
+// Events returns the subscription's events. The channel is closed when the
+// subscription ends, also when the client does not keep up: it then
+// reconnects with the ID of the last event it received.
+func (s *EventSubscription) Events() <-chan StreamEvent {
+	return s.out
+}
+
+// Close ends the subscription.
+func (s *EventSubscription) Close() {
+	s.svc.mu.Lock()
+	defer s.svc.mu.Unlock()
+	s.svc.remove(s)
+}
+
+func (s *eventStreamService) Subscribe(ctx context.Context, userID, lastEventID uint) (_ *EventSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "EventStreamService.Subscribe", attribute.Int("user.id", int(userID)), attribute.Int("event.last_id", int(lastEventID)))
+	defer func() { tracing.End(span, err) }()
+
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	sub := &EventSubscription{userID: userID, after: max(lastEventID, s.cursor), svc: s}
+	var replay []StreamEvent
+	switch {
+	case lastEventID == 0:
+	case lastEventID < s.floor:
+		sub.Missed = true
+	default:
+		member := make(map[uint]bool)
+		for _, e := range s.buffer {
+			if e.ID <= lastEventID {
+				continue
+			}
+			if e.userID != 0 {
+				if e.userID == userID {
+					replay = append(replay, e)
+				}
+				continue
+			}
+			ok, seen := member[e.workspaceID]
+			if !seen {
+				m, err := s.workspaces.FindMember(ctx, e.workspaceID, userID)
+				if err != nil {
+					return nil, err
+				}
+				ok = m != nil
+				member[e.workspaceID] = ok
+			}
+			if ok {
+				replay = append(replay, e)
+			}
+		}
+	}
+	sub.out = make(chan StreamEvent, len(replay)+eventSendBuffer)
+	for _, e := range replay {
+		sub.out <- e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrEventStreamClosed
+	}
+	s.subs[sub] = struct{}{}
+	metrics.EventStreams.Inc()
+	return sub, nil
+}
+
+func (s *eventStreamService) Poll(ctx context.Context) (int, error) {
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return 0, err
+	}
+	events, err := s.outbox.ListAfter(ctx, s.cursor, eventPollBatch)
+	if err != nil {
+		return 0, err
+	}
+	var fresh []StreamEvent
+	for _, e := range events {
+		if s.cursor != 0 && e.ID != s.cursor+1 {
+			// IDs are allocated before commit: the missing ones may still
+			// show up, or belong to rolled back transactions
+			if s.gapSince.IsZero() {
+				s.gapSince = time.Now()
+			}
+			if time.Since(s.gapSince) < s.gapTimeout {
+				break
+			}
+		}
+		s.gapSince = time.Time{}
+		s.cursor = e.ID
+		if se, ok := s.push(e); ok {
+			fresh = append(fresh, se)
+		}
+	}
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+	return len(fresh), s.deliver(ctx, fresh)
+}
+
+// load fills the buffer with the latest events of the outbox, once.
+// s.pollMu must be held.
+func (s *eventStreamService) load(ctx context.Context) error {
+	if s.loaded {
+		return nil
+	}
+	events, err := s.outbox.ListLatest(ctx, s.capacity)
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		s.floor = events[0].ID - 1
+	}
+	for _, e := range events {
+		s.cursor = e.ID
+		s.push(e)
+	}
+	s.loaded = true
+	return nil
+}
+
+// push adds e to the buffer if it is a note event or a new notification,
+// evicting the oldest one when it is full. s.pollMu must be held.
+func (s *eventStreamService) push(e model.OutboxEvent) (StreamEvent, bool) {
+	if e.Topic != model.EventNotificationCreated && !strings.HasPrefix(e.Topic, model.AggregateNote+".") {
+		return StreamEvent{}, false
+	}
+	var payload struct {
+		Note struct {
+			WorkspaceID uint `json:"workspace_id"`
+		} `json:"note"`
+		Notification struct {
+			UserID uint `json:"user_id"`
+		} `json:"notification"`
+	}
+	if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+		return StreamEvent{}, false
+	}
+	se := StreamEvent{ID: e.ID, Topic: e.Topic, Data: json.RawMessage(e.Payload), workspaceID: payload.Note.WorkspaceID}
+	if e.Topic == model.EventNotificationCreated {
+		if payload.Notification.UserID == 0 {
+			return StreamEvent{}, false
+		}
+		se.userID = payload.Notification.UserID
+	}
+	s.buffer = append(s.buffer, se)
+	if len(s.buffer) > s.capacity {
+		s.floor = s.buffer[0].ID
+		s.buffer = s.buffer[1:]
+	}
+	return se, true
+}
+
+// deliver sends events to the subscribers who are members of their
+// workspaces, or their recipients. If the members cannot be read, every
+// stream is ended: the clients reconnect and get the events from the buffer.
+func (s *eventStreamService) deliver(ctx context.Context, events []StreamEvent) error {
+	s.mu.Lock()
+	idle := len(s.subs) == 0
+	s.mu.Unlock()
+	if idle {
+		return nil
+	}
+	members := make(map[uint]map[uint]bool)
+	var err error
+	for _, e := range events {
+		if _, ok := members[e.workspaceID]; ok || e.userID != 0 {
+			continue
+		}
+		var list []model.WorkspaceMember
+		if list, err = s.workspaces.ListMembers(ctx, e.workspaceID); err != nil {
+			break
+		}
+		members[e.workspaceID] = make(map[uint]bool, len(list))
+		for _, m := range list {
+			members[e.workspaceID][m.UserID] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		if err != nil {
+			s.remove(sub)
+			continue
+		}
+		for _, e := range events {
+			if e.ID <= sub.after {
+				continue
+			}
+			if e.userID != 0 && e.userID != sub.userID || e.userID == 0 && !members[e.workspaceID][sub.userID] {
+				continue
+			}
+			select {
+			case sub.out <- e:
+				sub.after = e.ID
+			default:
+				s.remove(sub)
+			}
+			if sub.closed {
+				break
+			}
+		}
+	}
+	return err
+}
+
+func (s *eventStreamService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for sub := range s.subs {
+		s.remove(sub)
+	}
+}
+
+// remove ends sub. s.mu must be held.
+func (s *eventStreamService) remove(sub *EventSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.out)
+	delete(s.subs, sub)
+	metrics.EventStreams.Dec()
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+func noteOutboxEvent(id, workspaceID uint) model.OutboxEvent {
+	return model.OutboxEvent{
+		ID: id, Topic: model.EventNoteUpdated, AggregateType: model.AggregateNote, AggregateID: 1,
+		Payload: fmt.Sprintf(`{"actor_id":1,"note":{"id":1,"workspace_id":%d}}`, workspaceID),
+	}
+}
+
+func receivedIDs(sub *EventSubscription) []uint {
+	var ids []uint
+	for {
+		select {
+		case e := <-sub.Events():
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestEventStreamService_WaitsForOutboxGaps(t *testing.T) {
+	outbox := &mockOutboxRepo{}
+	workspaces := newMockWorkspaceRepo()
+	workspaces.addMember(1, 7, model.WorkspaceRoleGuest)
+	svc := NewEventStreamService(outbox, workspaces, &config.Config{}).(*eventStreamService)
+	ctx := context.Background()
+
+	sub, err := svc.Subscribe(ctx, 7, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	poll := func() []uint {
+		t.Helper()
+		if _, err := svc.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return receivedIDs(sub)
+	}
+
+	outbox.events = append(outbox.events, noteOutboxEvent(1, 1), noteOutboxEvent(2, 2))
+	outbox.events = append(outbox.events, model.OutboxEvent{ID: 3, Topic: model.EventUserUpdated, Payload: `{}`})
+	if got := poll(); fmt.Sprint(got) != "[1]" {
+		t.Fatalf("expected only the member's note event, got %v", got)
+	}
+
+	// event 5 may commit before event 4: it waits for it
+	outbox.events = append(outbox.events, noteOutboxEvent(5, 1))
+	if got := poll(); len(got) != 0 {
+		t.Fatalf("expected event 5 held back, got %v", got)
+	}
+	outbox.events = slices.Insert(outbox.events, 3, noteOutboxEvent(4, 1))
+	if got := poll(); fmt.Sprint(got) != "[4 5]" {
+		t.Fatalf("expected events 4 and 5 in order, got %v", got)
+	}
+
+	// but not forever, as event 6 may have rolled back
+	outbox.events = append(outbox.events, noteOutboxEvent(7, 1))
+	if got := poll(); len(got) != 0 {
+		t.Fatalf("expected event 7 held back, got %v", got)
+	}
+	svc.gapSince = time.Now().Add(-svc.gapTimeout)
+	if got := poll(); fmt.Sprint(got) != "[7]" {
+		t.Fatalf("expected event 7 once the gap timed out, got %v", got)
+	}
+
+	// a resuming subscriber gets what it missed from the buffer
+	resumed, err := svc.Subscribe(ctx, 7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	if got := receivedIDs(resumed); fmt.Sprint(got) != "[4 5 7]" || resumed.Missed {
+		t.Fatalf("expected events 4, 5 and 7 replayed, got %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// HealthCheck is a single readiness dependency (database, migrations, storage, cache, ...).
+type HealthCheck interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type healthCheckFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewHealthCheck adapts a plain function into a HealthCheck.
+func NewHealthCheck(name string, fn func(ctx context.Context) error) HealthCheck {
+	return healthCheckFunc{name: name, fn: fn}
+}
+
+func (h healthCheckFunc) Name() string                    { return h.name }
+func (h healthCheckFunc) Check(ctx context.Context) error { return h.fn(ctx) }
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadinessReport struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type HealthService interface {
+	Ready(ctx context.Context) ReadinessReport
+}
+
+type healthService struct {
+	checks  []HealthCheck
+	timeout time.Duration
+}
+
+func NewHealthService(checks ...HealthCheck) HealthService {
+	return &healthService{checks: checks, timeout: 2 * time.Second}
+}
+
+// Ready runs every check concurrently, each bounded by its own timeout.
+func (s *healthService) Ready(ctx context.Context) ReadinessReport {
+	results := make([]CheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, c := range s.checks {
+		wg.Add(1)
+		go func(i int, c HealthCheck) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Check(cctx)
+			res := CheckResult{
+				Name:      c.Name(),
+				Status:    StatusUp,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				res.Status = StatusDown
+				res.Error = err.Error()
+			}
+			results[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := ReadinessReport{Status: StatusUp, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
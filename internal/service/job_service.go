@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/cron"
+)
+
+const (
+	defaultJobMaxAttempts = 5
+	defaultJobRetryBase   = 10 * time.Second
+	defaultJobTimeout     = 5 * time.Minute
+	maxJobBackoff         = time.Hour
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobNotRetryable = errors.New("only dead jobs can be retried")
+	ErrUnknownJobKind  = errors.New("no handler for job kind")
+)
+
+// JobHandler runs a job with its JSON payload. A returned error fails the
+// attempt; the job is retried with backoff until it runs out of attempts.
+type JobHandler func(ctx context.Context, payload json.RawMessage) error
+
+type lastAttemptKey struct{}
+
+// LastAttempt reports whether the job running with ctx is on its last
+// attempt, for handlers that record a failure once it is final.
+func LastAttempt(ctx context.Context) bool {
+	last, _ := ctx.Value(lastAttemptKey{}).(bool)
+	return last
+}
+
+// JobOptions adjust how Enqueue schedules a job; zero fields use the
+// defaults.
+type JobOptions struct {
+	RunAt       time.Time // defaults to now
+	MaxAttempts int       // defaults to JOB_MAX_ATTEMPTS
+}
+
+// JobPage is one page of JobService.List.
+type JobPage struct {
+	Jobs    []model.Job
+	Total   int64
+	Page    int
+	PerPage int
+}
+
+// JobService is a durable queue of background jobs stored in the database.
+// Jobs are run by the worker pool of app.StartJobWorkers, which calls
+// RunNext and EnqueueScheduled.
+type JobService interface {
+	// Register sets the handler of kind. It is called while wiring the
+	// application, before the workers start.
+	Register(kind string, h JobHandler)
+	// Enqueue queues a job of kind with payload encoded as JSON. Within a
+	// transaction the job is only queued if it commits.
+	Enqueue(ctx context.Context, kind string, payload any, opts JobOptions) (*model.Job, error)
+	// Schedule enqueues a job of kind whenever the cron spec comes due (see
+	// package cron). Schedules are stored under name and shared by all
+	// servers, so each run is enqueued once.
+	Schedule(ctx context.Context, name, spec, kind string, payload any) error
+	// EnqueueScheduled enqueues the scheduled jobs that are due and returns
+	// how many it enqueued.
+	EnqueueScheduled(ctx context.Context) (int, error)
+	// RunNext claims the next due job and runs it, reporting false when no
+	// job was due. The job runs with a context that ctx's cancellation does
+	// not reach, so that shutting down lets it finish.
+	RunNext(ctx context.Context) (bool, error)
+
+	List(ctx context.Context, f repository.JobFilter, page, perPage int) (*JobPage, error)
+	Get(ctx context.Context, id uint) (*model.Job, error)
+	// Retry queues a dead job again with a fresh set of attempts.
+	Retry(ctx context.Context, id uint) (*model.Job, error)
+	ListSchedules(ctx context.Context) ([]model.JobSchedule, error)
+}
+
+type jobService struct {
+	repo        repository.JobRepository
+	worker      string
+	maxAttempts int
+	retryBase   time.Duration
+	timeout     time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]JobHandler
+}
+
+func NewJobService(repo repository.JobRepository, cfg *config.Config) JobService {
+	s := &jobService{
+		repo:        repo,
+		maxAttempts: cfg.JobMaxAttempts,
+		retryBase:   cfg.JobRetryBase,
+		timeout:     cfg.JobTimeout,
+		handlers:    map[string]JobHandler{},
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultJobMaxAttempts
+	}
+	if s.retryBase <= 0 {
+		s.retryBase = defaultJobRetryBase
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultJobTimeout
+	}
+	host, _ := os.Hostname()
+	suffix, _ := randomHex(4)
+	s.worker = truncate(fmt.Sprintf("%s-%d-%s", host, os.Getpid(), suffix), 64)
+	return s
+}
+
+func (s *jobService) Register(kind string, h JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = h
+}
+
+func (s *jobService) handler(kind string) JobHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers[kind]
+}
+
+func (s *jobService) Enqueue(ctx context.Context, kind string, payload any, opts JobOptions) (*model.Job, error) {
+	encoded, err := encodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	j := &model.Job{
+		Kind:        kind,
+		Payload:     encoded,
+		Status:      model.JobQueued,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+	}
+	if j.MaxAttempts <= 0 {
+		j.MaxAttempts = s.maxAttempts
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now()
+	}
+	if err := s.repo.Create(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (s *jobService) Schedule(ctx context.Context, name, spec, kind string, payload any) error {
+	sched, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodePayload(payload)
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveSchedule(ctx, &model.JobSchedule{
+		Name:      name,
+		Spec:      spec,
+		Kind:      kind,
+		Payload:   encoded,
+		NextRunAt: sched.Next(time.Now().UTC()),
+	})
+}
+
+func (s *jobService) EnqueueScheduled(ctx context.Context) (int, error) {
+	schedules, err := s.repo.ListSchedules(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	n := 0
+	for _, sc := range schedules {
+		if sc.NextRunAt.After(now) {
+			continue
+		}
+		sched, err := cron.Parse(sc.Spec)
+		if err != nil {
+			log.Printf("job schedule %s: %v", sc.Name, err)
+			continue
+		}
+		// runs missed while no server was up are not made up for
+		next := sched.Next(now.UTC())
+		ok, err := s.repo.AdvanceSchedule(ctx, sc.Name, sc.NextRunAt, next)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			continue
+		}
+		j := &model.Job{Kind: sc.Kind, Payload: sc.Payload, Status: model.JobQueued, MaxAttempts: s.maxAttempts, RunAt: now}
+		if err := s.repo.Create(ctx, j); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *jobService) RunNext(ctx context.Context) (bool, error) {
+	now := time.Now()
+	// the lock outlasts the job's timeout, so a job is never run twice at once
+	j, err := s.repo.Claim(ctx, s.worker, now, now.Add(s.timeout+time.Minute))
+	if err != nil || j == nil {
+		return false, err
+	}
+	runErr := s.run(context.WithoutCancel(ctx), j)
+
+	finished := time.Now()
+	j.LockedBy = ""
+	j.LockedUntil = nil
+	switch {
+	case runErr == nil:
+		j.Status = model.JobSucceeded
+		j.LastError = ""
+		j.FinishedAt = &finished
+	case j.Attempts >= j.MaxAttempts || errors.Is(runErr, ErrUnknownJobKind):
+		log.Printf("job %d (%s) is dead after %d attempts: %v", j.ID, j.Kind, j.Attempts, runErr)
+		j.Status = model.JobDead
+		j.LastError = runErr.Error()
+		j.FinishedAt = &finished
+	default:
+		j.Status = model.JobQueued
+		j.LastError = runErr.Error()
+		j.RunAt = finished.Add(s.backoff(j.Attempts))
+	}
+	if _, err := s.repo.Release(context.WithoutCancel(ctx), j, s.worker); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// run calls the job's handler with the job timeout, turning a panic into an
+// error.
+func (s *jobService) run(ctx context.Context, j *model.Job) (err error) {
+	ctx, span := tracing.Start(ctx, "JobService.run", attribute.String("job.kind", j.Kind), attribute.Int("job.id", int(j.ID)), attribute.Int("job.attempt", j.Attempts))
+	defer func() { tracing.End(span, err) }()
+
+	h := s.handler(j.Kind)
+	if h == nil {
+		return fmt.Errorf("%w %q", ErrUnknownJobKind, j.Kind)
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, lastAttemptKey{}, j.Attempts >= j.MaxAttempts), s.timeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return h(ctx, json.RawMessage(j.Payload))
+}
+
+// backoff returns the wait after the attempts-th failed attempt: the retry
+// base, doubled for every attempt after the first, up to an hour.
+func (s *jobService) backoff(attempts int) time.Duration {
+	d := s.retryBase
+	for i := 1; i < attempts && d < maxJobBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxJobBackoff)
+}
+
+func (s *jobService) List(ctx context.Context, f repository.JobFilter, page, perPage int) (*JobPage, error) {
+	page, perPage = normalizePage(page, perPage)
+	jobs, total, err := s.repo.List(ctx, f, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &JobPage{Jobs: jobs, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+func (s *jobService) Get(ctx context.Context, id uint) (*model.Job, error) {
+	j, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, ErrJobNotFound
+	}
+	return j, nil
+}
+
+func (s *jobService) Retry(ctx context.Context, id uint) (*model.Job, error) {
+	j, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status != model.JobDead {
+		return nil, ErrJobNotRetryable
+	}
+	ok, err := s.repo.Requeue(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobNotRetryable
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *jobService) ListSchedules(ctx context.Context) ([]model.JobSchedule, error) {
+	return s.repo.ListSchedules(ctx)
+}
+
+func encodePayload(payload any) (string, error) {
+	if payload == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(payload)
+	return string(b), err
+}
@@ -1,78 +1,270 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
 	"github.com/MujiRahman/golang-simple-note/internal/model"
 	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
 )
 
+// NoteService works on the notes of one workspace at a time. A workspaceID of
+// 0 selects the caller's personal workspace; reading needs any role there,
+// writing at least the member role. Every change is recorded in the audit log
+// and written to the outbox as a domain event, from which the outbox relay
+// publishes it to the webhooks of the workspace's members. Users @mentioned in a note's content are
+// notified once. Every change takes the next number of the workspace's
+// change sequence, which becomes the note's ChangeSeq.
 type NoteService interface {
-	Create(userID uint, title, content string) (*model.Note, error)
-	GetByID(userID, id uint) (*model.Note, error)
-	ListByUser(userID uint) ([]model.Note, error)
-	Update(userID, id uint, title, content string) (*model.Note, error)
-	Delete(userID, id uint) error
+	Create(ctx context.Context, userID, workspaceID uint, title, content string) (*model.Note, error)
+	GetByID(ctx context.Context, userID, workspaceID, id uint) (*model.Note, error)
+	ListByWorkspace(ctx context.Context, userID, workspaceID uint) ([]model.Note, error)
+	Update(ctx context.Context, userID, workspaceID, id uint, title, content string) (*model.Note, error)
+	Delete(ctx context.Context, userID, workspaceID, id uint) error
+	// UpdateIfUnchanged and DeleteIfUnchanged are Update and Delete for a
+	// client that last saw the note at change baseSeq. They fail with
+	// ErrNoteConflict if it has changed since.
+	UpdateIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64, title, content string) (*model.Note, error)
+	DeleteIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64) error
 }
 
+var (
+	// ErrNoteConflict means that a note was changed after the change a
+	// client based its own on.
+	ErrNoteConflict = errors.New("note was changed meanwhile")
+	// errNoteAccess reports a note of another workspace than the active one.
+	errNoteAccess = errors.New("not found or access denied")
+)
+
 type noteService struct {
-	repo repository.NoteRepository
+	repo          repository.NoteRepository
+	workspaces    WorkspaceService
+	audit         AuditService
+	events        UnitOfWork
+	notifications NotificationService
 }
 
-func NewNoteService(repo repository.NoteRepository) NoteService {
-	return &noteService{repo: repo}
+func NewNoteService(repo repository.NoteRepository, workspaces WorkspaceService, audit AuditService, events UnitOfWork, notifications NotificationService) NoteService {
+	return &noteService{repo: repo, workspaces: workspaces, audit: audit, events: events, notifications: notifications}
 }
 
-func (s *noteService) Create(userID uint, title, content string) (*model.Note, error) {
+func (s *noteService) Create(ctx context.Context, userID, workspaceID uint, title, content string) (_ *model.Note, err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.Create", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.workspaces.Authorize(ctx, userID, workspaceID, model.PermNotesWrite)
+	if err != nil {
+		return nil, err
+	}
 	n := &model.Note{
-		UserID:  userID,
-		Title:   title,
-		Content: content,
+		UserID:      userID,
+		WorkspaceID: ws.ID,
+		Title:       title,
+		Content:     content,
 	}
-	if err := s.repo.Create(n); err != nil {
+	event := &model.AuditEvent{ActorID: userID, Action: model.AuditNoteCreate, TargetType: model.AuditTargetNote, After: noteSummary(n)}
+	err = s.audit.Record(ctx, event, func(ctx context.Context) error {
+		seq, err := s.repo.NextChangeSeq(ctx, ws.ID)
+		if err != nil {
+			return err
+		}
+		n.ChangeSeq = seq
+		if err := s.repo.Create(ctx, n); err != nil {
+			return err
+		}
+		event.TargetID = n.ID
+		if err := s.publish(ctx, model.EventNoteCreated, userID, n); err != nil {
+			return err
+		}
+		return s.notifyMentions(ctx, userID, n, "")
+	})
+	if err != nil {
 		return nil, err
 	}
+	metrics.NoteEvents.WithLabelValues("created").Inc()
 	return n, nil
 }
 
-func (s *noteService) GetByID(userID, id uint) (*model.Note, error) {
-	n, err := s.repo.FindByID(id)
+func (s *noteService) GetByID(ctx context.Context, userID, workspaceID, id uint) (_ *model.Note, err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.GetByID", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.find(ctx, userID, workspaceID, id, model.PermNotesRead)
+}
+
+func (s *noteService) ListByWorkspace(ctx context.Context, userID, workspaceID uint) (_ []model.Note, err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.ListByWorkspace", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.workspaces.Authorize(ctx, userID, workspaceID, model.PermNotesRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindByWorkspace(ctx, ws.ID)
+}
+
+func (s *noteService) Update(ctx context.Context, userID, workspaceID, id uint, title, content string) (_ *model.Note, err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.Update", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.update(ctx, userID, workspaceID, id, nil, title, content)
+}
+
+func (s *noteService) UpdateIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64, title, content string) (_ *model.Note, err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.UpdateIfUnchanged", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.update(ctx, userID, workspaceID, id, &baseSeq, title, content)
+}
+
+func (s *noteService) update(ctx context.Context, userID, workspaceID, id uint, baseSeq *uint64, title, content string) (*model.Note, error) {
+	n, err := s.find(ctx, userID, workspaceID, id, model.PermNotesWrite)
 	if err != nil || n == nil {
 		return nil, err
 	}
-	if n.UserID != userID {
-		return nil, errors.New("not found or access denied")
+	event := &model.AuditEvent{ActorID: userID, Action: model.AuditNoteUpdate, TargetType: model.AuditTargetNote, TargetID: n.ID}
+	err = s.audit.Record(ctx, event, func(ctx context.Context) error {
+		cur, err := s.lockForChange(ctx, n, baseSeq)
+		if err != nil {
+			return err
+		}
+		n = cur
+		event.Before = noteSummary(n)
+		before := n.Content
+		n.Title = title
+		n.Content = content
+		event.After = noteSummary(n)
+		if err := s.repo.Update(ctx, n); err != nil {
+			return err
+		}
+		if err := s.publish(ctx, model.EventNoteUpdated, userID, n); err != nil {
+			return err
+		}
+		return s.notifyMentions(ctx, userID, n, before)
+	})
+	if err != nil {
+		return nil, err
 	}
+	metrics.NoteEvents.WithLabelValues("updated").Inc()
 	return n, nil
 }
 
-func (s *noteService) ListByUser(userID uint) ([]model.Note, error) {
-	return s.repo.FindByUser(userID)
+func (s *noteService) Delete(ctx context.Context, userID, workspaceID, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.Delete", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.delete(ctx, userID, workspaceID, id, nil)
+}
+
+func (s *noteService) DeleteIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64) (err error) {
+	ctx, span := tracing.Start(ctx, "NoteService.DeleteIfUnchanged", attribute.Int("user.id", int(userID)), attribute.Int("note.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.delete(ctx, userID, workspaceID, id, &baseSeq)
 }
 
-func (s *noteService) Update(userID, id uint, title, content string) (*model.Note, error) {
-	n, err := s.repo.FindByID(id)
+func (s *noteService) delete(ctx context.Context, userID, workspaceID, id uint, baseSeq *uint64) error {
+	n, err := s.find(ctx, userID, workspaceID, id, model.PermNotesWrite)
 	if err != nil || n == nil {
-		return nil, err
+		return err
 	}
-	if n.UserID != userID {
-		return nil, errors.New("not found or access denied")
+	event := &model.AuditEvent{ActorID: userID, Action: model.AuditNoteDelete, TargetType: model.AuditTargetNote, TargetID: n.ID}
+	err = s.audit.Record(ctx, event, func(ctx context.Context) error {
+		cur, err := s.lockForChange(ctx, n, baseSeq)
+		if err != nil {
+			return err
+		}
+		event.Before = noteSummary(cur)
+		if err := s.repo.Delete(ctx, id, cur.ChangeSeq); err != nil {
+			return err
+		}
+		return s.publish(ctx, model.EventNoteDeleted, userID, cur)
+	})
+	if err != nil {
+		return err
 	}
-	n.Title = title
-	n.Content = content
-	if err := s.repo.Update(n); err != nil {
+	metrics.NoteEvents.WithLabelValues("deleted").Inc()
+	return nil
+}
+
+// lockForChange takes the next change sequence number of n's workspace and
+// returns n re-read, now that no other change can come in between, with
+// that number as ChangeSeq. With baseSeq it fails with ErrNoteConflict if
+// n has changed after baseSeq.
+func (s *noteService) lockForChange(ctx context.Context, n *model.Note, baseSeq *uint64) (*model.Note, error) {
+	seq, err := s.repo.NextChangeSeq(ctx, n.WorkspaceID)
+	if err != nil {
 		return nil, err
 	}
-	return n, nil
+	cur, err := s.repo.FindByID(ctx, n.ID)
+	if err != nil {
+		return nil, err
+	}
+	if cur == nil {
+		return nil, ErrNoteNotFound
+	}
+	if baseSeq != nil && cur.ChangeSeq != *baseSeq {
+		return nil, ErrNoteConflict
+	}
+	cur.ChangeSeq = seq
+	return cur, nil
 }
 
-func (s *noteService) Delete(userID, id uint) error {
-	n, err := s.repo.FindByID(id)
-	if err != nil || n == nil {
+// publish writes a change of n to the outbox, within the transaction of the
+// change.
+func (s *noteService) publish(ctx context.Context, event string, actorID uint, n *model.Note) error {
+	return s.events.Emit(ctx, event, model.AggregateNote, n.ID, noteEventData(actorID, n))
+}
+
+// notifyMentions notifies the users mentioned in n's content but not in its
+// content before the change.
+func (s *noteService) notifyMentions(ctx context.Context, actorID uint, n *model.Note, before string) error {
+	mentioned, err := newMentions(ctx, s.notifications, before, n.Content)
+	if err != nil || len(mentioned) == 0 {
 		return err
 	}
-	if n.UserID != userID {
-		return errors.New("not found or access denied")
+	notification := model.Notification{Type: model.NotificationMention, ActorID: actorID, WorkspaceID: n.WorkspaceID, NoteID: n.ID}
+	return s.notifications.Notify(ctx, notification, mentioned)
+}
+
+// eventNote is a note as sent to webhooks and the event bus.
+type eventNote struct {
+	ID          uint      `json:"id"`
+	WorkspaceID uint      `json:"workspace_id"`
+	UserID      uint      `json:"user_id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// noteEventData is the data of the note.* events: the note, as it was before
+// deletion for note.deleted, and who changed it.
+func noteEventData(actorID uint, n *model.Note) any {
+	return struct {
+		ActorID uint      `json:"actor_id"`
+		Note    eventNote `json:"note"`
+	}{actorID, eventNote{n.ID, n.WorkspaceID, n.UserID, n.Title, n.Content, n.CreatedAt, n.UpdatedAt}}
+}
+
+// find loads note id after checking that the caller's role in the workspace
+// grants perm. Notes of other workspaces are reported like missing access.
+func (s *noteService) find(ctx context.Context, userID, workspaceID, id uint, perm model.WorkspacePermission) (*model.Note, error) {
+	ws, err := s.workspaces.Authorize(ctx, userID, workspaceID, perm)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.repo.FindByID(ctx, id)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	if n.WorkspaceID != ws.ID {
+		return nil, errNoteAccess
 	}
-	return s.repo.Delete(id)
+	return n, nil
 }
@@ -1,22 +1,27 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/MujiRahman/golang-simple-note/internal/model"
 )
 
 type mockNoteRepo struct {
-	notes  map[uint]*model.Note
-	nextID uint
+	notes      map[uint]*model.Note
+	tombstones map[uint]*model.Note
+	seqs       map[uint]uint64 // change sequence by workspace
+	nextID     uint
 }
 
 func newMockNoteRepo() *mockNoteRepo {
-	return &mockNoteRepo{notes: make(map[uint]*model.Note), nextID: 1}
+	return &mockNoteRepo{notes: make(map[uint]*model.Note), tombstones: make(map[uint]*model.Note), seqs: make(map[uint]uint64), nextID: 1}
 }
 
-func (m *mockNoteRepo) Create(note *model.Note) error {
+func (m *mockNoteRepo) Create(ctx context.Context, note *model.Note) error {
 	if note.ID == 0 {
 		note.ID = m.nextID
 		m.nextID++
@@ -25,7 +30,7 @@ func (m *mockNoteRepo) Create(note *model.Note) error {
 	return nil
 }
 
-func (m *mockNoteRepo) FindByID(id uint) (*model.Note, error) {
+func (m *mockNoteRepo) FindByID(ctx context.Context, id uint) (*model.Note, error) {
 	n, ok := m.notes[id]
 	if !ok {
 		return nil, nil
@@ -33,7 +38,7 @@ func (m *mockNoteRepo) FindByID(id uint) (*model.Note, error) {
 	return n, nil
 }
 
-func (m *mockNoteRepo) FindByUser(userID uint) ([]model.Note, error) {
+func (m *mockNoteRepo) FindByUser(ctx context.Context, userID uint) ([]model.Note, error) {
 	var out []model.Note
 	for _, n := range m.notes {
 		if n.UserID == userID {
@@ -43,7 +48,22 @@ func (m *mockNoteRepo) FindByUser(userID uint) ([]model.Note, error) {
 	return out, nil
 }
 
-func (m *mockNoteRepo) Update(note *model.Note) error {
+func (m *mockNoteRepo) FindByWorkspace(ctx context.Context, workspaceID uint) ([]model.Note, error) {
+	var out []model.Note
+	for _, n := range m.notes {
+		if n.WorkspaceID == workspaceID {
+			out = append(out, *n)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockNoteRepo) EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Note) error) error {
+	notes, _ := m.FindByUser(ctx, userID)
+	return fn(notes)
+}
+
+func (m *mockNoteRepo) Update(ctx context.Context, note *model.Note) error {
 	if _, ok := m.notes[note.ID]; !ok {
 		return errors.New("not found")
 	}
@@ -51,29 +71,64 @@ func (m *mockNoteRepo) Update(note *model.Note) error {
 	return nil
 }
 
-func (m *mockNoteRepo) Delete(id uint) error {
-	if _, ok := m.notes[id]; !ok {
+func (m *mockNoteRepo) Delete(ctx context.Context, id uint, changeSeq uint64) error {
+	n, ok := m.notes[id]
+	if !ok {
 		return errors.New("not found")
 	}
 	delete(m.notes, id)
+	m.tombstones[id] = &model.Note{ID: id, UserID: n.UserID, WorkspaceID: n.WorkspaceID, ChangeSeq: changeSeq}
+	m.tombstones[id].DeletedAt.Time, m.tombstones[id].DeletedAt.Valid = time.Now(), true
 	return nil
 }
 
+func (m *mockNoteRepo) NextChangeSeq(ctx context.Context, workspaceID uint) (uint64, error) {
+	m.seqs[workspaceID]++
+	return m.seqs[workspaceID], nil
+}
+
+func (m *mockNoteRepo) Changes(ctx context.Context, workspaceID uint, since uint64, limit int) ([]model.Note, error) {
+	var out []model.Note
+	for _, set := range []map[uint]*model.Note{m.notes, m.tombstones} {
+		for _, n := range set {
+			if n.WorkspaceID == workspaceID && n.ChangeSeq > since {
+				out = append(out, *n)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ChangeSeq < out[j].ChangeSeq })
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *mockNoteRepo) PurgeDeleted(ctx context.Context, t time.Time) (int64, error) {
+	var n int64
+	for id, tomb := range m.tombstones {
+		if tomb.DeletedAt.Time.Before(t) {
+			delete(m.tombstones, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
 func TestNoteService_CRUD(t *testing.T) {
 	repo := newMockNoteRepo()
-	svc := NewNoteService(repo)
+	audit, events := newMockAuditService()
+	outbox := &mockUnitOfWork{}
+	workspaces, users := newMockWorkspaceRepo(), newMockUserRepo()
+	svc := NewNoteService(repo, NewWorkspaceService(workspaces, users, audit, outbox), audit, outbox, NewNotificationService(&mockNotificationRepo{}, users, workspaces, outbox))
 
 	// Create
-	n, err := svc.Create(10, "t1", "c1")
+	n, err := svc.Create(context.Background(), 10, 0, "t1", "c1")
 	if err != nil {
 		t.Fatalf("Create failed: %v", err)
 	}
-	if n.UserID != 10 || n.Title != "t1" {
+	if n.UserID != 10 || n.Title != "t1" || n.WorkspaceID == 0 {
 		t.Fatalf("Create returned unexpected note: %+v", n)
 	}
 
 	// GetByID success
-	got, err := svc.GetByID(10, n.ID)
+	got, err := svc.GetByID(context.Background(), 10, 0, n.ID)
 	if err != nil {
 		t.Fatalf("GetByID failed: %v", err)
 	}
@@ -82,22 +137,22 @@ func TestNoteService_CRUD(t *testing.T) {
 	}
 
 	// GetByID access denied
-	_, err = svc.GetByID(11, n.ID)
+	_, err = svc.GetByID(context.Background(), 11, 0, n.ID)
 	if err == nil {
 		t.Fatalf("expected error when accessing note with wrong user")
 	}
 
-	// ListByUser
-	notes, err := svc.ListByUser(10)
+	// ListByWorkspace
+	notes, err := svc.ListByWorkspace(context.Background(), 10, 0)
 	if err != nil {
-		t.Fatalf("ListByUser failed: %v", err)
+		t.Fatalf("ListByWorkspace failed: %v", err)
 	}
 	if len(notes) != 1 {
-		t.Fatalf("ListByUser returned unexpected length: %d", len(notes))
+		t.Fatalf("ListByWorkspace returned unexpected length: %d", len(notes))
 	}
 
 	// Update
-	updated, err := svc.Update(10, n.ID, "t2", "c2")
+	updated, err := svc.Update(context.Background(), 10, 0, n.ID, "t2", "c2")
 	if err != nil {
 		t.Fatalf("Update failed: %v", err)
 	}
@@ -106,12 +161,90 @@ func TestNoteService_CRUD(t *testing.T) {
 	}
 
 	// Delete
-	if err := svc.Delete(10, n.ID); err != nil {
+	if err := svc.Delete(context.Background(), 10, 0, n.ID); err != nil {
 		t.Fatalf("Delete failed: %v", err)
 	}
 	// confirm deleted
-	got2, _ := svc.GetByID(10, n.ID)
+	got2, _ := svc.GetByID(context.Background(), 10, 0, n.ID)
 	if got2 != nil {
 		t.Fatalf("expected note to be deleted")
 	}
+
+	// every change is audited with the note's state around it
+	if got := events.actions(); len(got) != 3 || got[0] != model.AuditNoteCreate || got[1] != model.AuditNoteUpdate || got[2] != model.AuditNoteDelete {
+		t.Fatalf("unexpected audit actions: %v", got)
+	}
+	upd := events.events[1]
+	if upd.ActorID != 10 || upd.TargetID != n.ID || upd.Before["title"] != "t1" || upd.After["title"] != "t2" {
+		t.Fatalf("unexpected update event: %+v", upd)
+	}
+	if got := outbox.topics; len(got) != 3 || got[0] != model.EventNoteCreated || got[1] != model.EventNoteUpdated || got[2] != model.EventNoteDeleted {
+		t.Fatalf("unexpected outbox events: %v", got)
+	}
+}
+
+func TestNoteService_SharedWorkspace(t *testing.T) {
+	workspaces := newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	outbox := &mockUnitOfWork{}
+	wsSvc := NewWorkspaceService(workspaces, newMockUserRepo(), audit, outbox)
+	svc := NewNoteService(newMockNoteRepo(), wsSvc, audit, outbox, NewNotificationService(&mockNotificationRepo{}, newMockUserRepo(), workspaces, outbox))
+	ctx := context.Background()
+
+	team, _ := wsSvc.Create(ctx, 10, "Team")
+	workspaces.addMember(team.ID, 11, model.WorkspaceRoleMember)
+	workspaces.addMember(team.ID, 12, model.WorkspaceRoleGuest)
+
+	n, err := svc.Create(ctx, 10, team.ID, "shared", "c")
+	if err != nil || n.WorkspaceID != team.ID {
+		t.Fatalf("Create in team: %+v, %v", n, err)
+	}
+	// members edit each other's notes, guests only read
+	if _, err := svc.Update(ctx, 11, team.ID, n.ID, "edited", "c"); err != nil {
+		t.Fatalf("member update: %v", err)
+	}
+	if got, err := svc.GetByID(ctx, 12, team.ID, n.ID); err != nil || got.Title != "edited" {
+		t.Fatalf("guest read: %+v, %v", got, err)
+	}
+	if err := svc.Delete(ctx, 12, team.ID, n.ID); !errors.Is(err, ErrWorkspaceForbidden) {
+		t.Fatalf("expected ErrWorkspaceForbidden for a guest, got %v", err)
+	}
+	// the note is not reachable through another workspace
+	if _, err := svc.GetByID(ctx, 10, 0, n.ID); err == nil {
+		t.Fatalf("expected the team note to be hidden from the personal workspace")
+	}
+	if list, _ := svc.ListByWorkspace(ctx, 10, 0); len(list) != 0 {
+		t.Fatalf("expected an empty personal workspace, got %d notes", len(list))
+	}
+}
+
+func TestNoteService_ConditionalChanges(t *testing.T) {
+	workspaces := newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	outbox := &mockUnitOfWork{}
+	svc := NewNoteService(newMockNoteRepo(), NewWorkspaceService(workspaces, newMockUserRepo(), audit, outbox), audit, outbox, NewNotificationService(&mockNotificationRepo{}, newMockUserRepo(), workspaces, outbox))
+	ctx := context.Background()
+
+	n, _ := svc.Create(ctx, 10, 0, "t", "c")
+	base := n.ChangeSeq
+	// every change moves the note on in the workspace's sequence
+	if n, _ = svc.Update(ctx, 10, 0, n.ID, "t", "c2"); n.ChangeSeq != base+1 {
+		t.Fatalf("expected change %d, got %d", base+1, n.ChangeSeq)
+	}
+	if _, err := svc.UpdateIfUnchanged(ctx, 10, 0, n.ID, base, "t", "stale"); !errors.Is(err, ErrNoteConflict) {
+		t.Fatalf("expected ErrNoteConflict, got %v", err)
+	}
+	if err := svc.DeleteIfUnchanged(ctx, 10, 0, n.ID, base); !errors.Is(err, ErrNoteConflict) {
+		t.Fatalf("expected ErrNoteConflict, got %v", err)
+	}
+	got, err := svc.UpdateIfUnchanged(ctx, 10, 0, n.ID, base+1, "t", "c3")
+	if err != nil || got.Content != "c3" || got.ChangeSeq <= base+1 {
+		t.Fatalf("UpdateIfUnchanged: %+v, %v", got, err)
+	}
+	if err := svc.DeleteIfUnchanged(ctx, 10, 0, n.ID, got.ChangeSeq); err != nil {
+		t.Fatalf("DeleteIfUnchanged: %v", err)
+	}
+	if got, _ := svc.GetByID(ctx, 10, 0, n.ID); got != nil {
+		t.Fatalf("expected the note deleted, got %+v", got)
+	}
 }
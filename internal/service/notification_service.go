@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/mention"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// NotificationPage is one page of a user's notifications, newest first.
+type NotificationPage struct {
+	Notifications []model.Notification
+	Total         int64
+	Unread        int64
+	Page          int
+	PerPage       int
+}
+
+// NotificationService keeps the users' inboxes. Notifications are created in
+// the transaction of the change they report and written to the outbox as
+// notification.created events, for real-time channels to push.
+type NotificationService interface {
+	// Notify sends n to each of recipients, skipping n's actor and the users
+	// who cannot read n's workspace. It must run within a unit of work.
+	Notify(ctx context.Context, n model.Notification, recipients []uint) error
+	// Mentioned returns the users mentioned in text with @username who
+	// exist.
+	Mentioned(ctx context.Context, text string) ([]uint, error)
+
+	List(ctx context.Context, userID uint, unreadOnly bool, page, perPage int) (*NotificationPage, error)
+	UnreadCount(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks one of the user's notifications read, or unread.
+	MarkRead(ctx context.Context, userID, id uint, read bool) (*model.Notification, error)
+	// MarkAllRead marks every unread notification of the user read and
+	// returns how many it marked.
+	MarkAllRead(ctx context.Context, userID uint) (int64, error)
+}
+
+type notificationService struct {
+	repo       repository.NotificationRepository
+	users      repository.UserRepository
+	workspaces repository.WorkspaceRepository
+	events     UnitOfWork
+}
+
+func NewNotificationService(repo repository.NotificationRepository, users repository.UserRepository, workspaces repository.WorkspaceRepository, events UnitOfWork) NotificationService {
+	return &notificationService{repo: repo, users: users, workspaces: workspaces, events: events}
+}
+
+func (s *notificationService) Notify(ctx context.Context, n model.Notification, recipients []uint) (err error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.Notify", attribute.String("notification.type", n.Type), attribute.Int("note.id", int(n.NoteID)))
+	defer func() { tracing.End(span, err) }()
+
+	seen := map[uint]bool{n.ActorID: true}
+	for _, userID := range recipients {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		m, err := s.workspaces.FindMember(ctx, n.WorkspaceID, userID)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			continue
+		}
+		sent := n
+		sent.UserID = userID
+		if err := s.repo.Create(ctx, &sent); err != nil {
+			return err
+		}
+		data := struct {
+			Notification model.Notification `json:"notification"`
+		}{sent}
+		if err := s.events.Emit(ctx, model.EventNotificationCreated, model.AggregateNotification, sent.ID, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *notificationService) Mentioned(ctx context.Context, text string) ([]uint, error) {
+	var ids []uint
+	for _, username := range mention.Usernames(text) {
+		u, err := s.users.FindByUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil {
+			ids = append(ids, u.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *notificationService) List(ctx context.Context, userID uint, unreadOnly bool, page, perPage int) (*NotificationPage, error) {
+	page, perPage = normalizePage(page, perPage)
+	ns, total, err := s.repo.List(ctx, userID, unreadOnly, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationPage{Notifications: ns, Total: total, Unread: unread, Page: page, PerPage: perPage}, nil
+}
+
+func (s *notificationService) UnreadCount(ctx context.Context, userID uint) (int64, error) {
+	return s.repo.CountUnread(ctx, userID)
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID, id uint, read bool) (*model.Notification, error) {
+	n, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil || n.UserID != userID {
+		return nil, ErrNotificationNotFound
+	}
+	switch {
+	case read && n.ReadAt == nil:
+		now := time.Now()
+		n.ReadAt = &now
+	case !read && n.ReadAt != nil:
+		n.ReadAt = nil
+	default:
+		return n, nil
+	}
+	if err := s.repo.SetReadAt(ctx, n.ID, n.ReadAt); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	return s.repo.MarkAllRead(ctx, userID, time.Now())
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+// mockNotificationRepo keeps notifications in memory.
+type mockNotificationRepo struct {
+	notifications []*model.Notification
+}
+
+func (m *mockNotificationRepo) Create(ctx context.Context, n *model.Notification) error {
+	n.ID = uint(len(m.notifications) + 1)
+	stored := *n
+	m.notifications = append(m.notifications, &stored)
+	return nil
+}
+
+func (m *mockNotificationRepo) FindByID(ctx context.Context, id uint) (*model.Notification, error) {
+	for _, n := range m.notifications {
+		if n.ID == id {
+			found := *n
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockNotificationRepo) List(ctx context.Context, userID uint, unreadOnly bool, offset, limit int) ([]model.Notification, int64, error) {
+	var out []model.Notification
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		n := m.notifications[i]
+		if n.UserID == userID && (!unreadOnly || n.ReadAt == nil) {
+			out = append(out, *n)
+		}
+	}
+	total := int64(len(out))
+	out = out[min(offset, len(out)):min(offset+limit, len(out))]
+	return out, total, nil
+}
+
+func (m *mockNotificationRepo) EachByUser(ctx context.Context, userID uint, batchSize int, fn func([]model.Notification) error) error {
+	var batch []model.Notification
+	for _, n := range m.notifications {
+		if n.UserID == userID {
+			batch = append(batch, *n)
+		}
+	}
+	return fn(batch)
+}
+
+func (m *mockNotificationRepo) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	for _, x := range m.notifications {
+		if x.UserID == userID && x.ReadAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockNotificationRepo) SetReadAt(ctx context.Context, id uint, at *time.Time) error {
+	for _, n := range m.notifications {
+		if n.ID == id {
+			n.ReadAt = at
+		}
+	}
+	return nil
+}
+
+func (m *mockNotificationRepo) MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	var marked int64
+	for _, n := range m.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			n.ReadAt = &at
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func TestNotificationService_Notify(t *testing.T) {
+	repo := &mockNotificationRepo{}
+	users := newMockUserRepo()
+	workspaces := newMockWorkspaceRepo()
+	outbox := &mockUnitOfWork{}
+	svc := NewNotificationService(repo, users, workspaces, outbox)
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if err := users.Create(ctx, &model.User{Username: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspaces.addMember(7, 1, model.WorkspaceRoleOwner)
+	workspaces.addMember(7, 2, model.WorkspaceRoleGuest)
+
+	ids, err := svc.Mentioned(ctx, "hi @bob, @carol and @nobody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Fatalf("Mentioned = %v, want [2 3]", ids)
+	}
+
+	// alice is the actor and carol is no member: only bob is notified, once.
+	n := model.Notification{Type: model.NotificationMention, ActorID: 1, WorkspaceID: 7, NoteID: 3}
+	if err := svc.Notify(ctx, n, []uint{1, 2, 3, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.notifications) != 1 || repo.notifications[0].UserID != 2 {
+		t.Fatalf("notifications = %+v, want one for bob", repo.notifications)
+	}
+	if len(outbox.topics) != 1 || outbox.topics[0] != model.EventNotificationCreated {
+		t.Fatalf("topics = %v", outbox.topics)
+	}
+
+	if _, err := svc.MarkRead(ctx, 1, 1, true); err != ErrNotificationNotFound {
+		t.Fatalf("MarkRead of another user's notification: got %v", err)
+	}
+	read, err := svc.MarkRead(ctx, 2, 1, true)
+	if err != nil || read.ReadAt == nil {
+		t.Fatalf("MarkRead: %+v, %v", read, err)
+	}
+	if unread, _ := svc.UnreadCount(ctx, 2); unread != 0 {
+		t.Fatalf("unread = %d, want 0", unread)
+	}
+	if _, err := svc.MarkRead(ctx, 2, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	page, err := svc.List(ctx, 2, true, 1, 0)
+	if err != nil || page.Total != 1 || page.Unread != 1 {
+		t.Fatalf("List: %+v, %v", page, err)
+	}
+	if marked, _ := svc.MarkAllRead(ctx, 2); marked != 1 {
+		t.Fatalf("MarkAllRead marked %d, want 1", marked)
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+	"github.com/MujiRahman/golang-simple-note/pkg/oidc"
+)
+
+const (
+	tokenTypeOIDCFlow = "oidc_flow"
+	// OIDCFlowTTL is how long a user has to complete a login at the provider.
+	OIDCFlowTTL = 10 * time.Minute
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("unknown login provider")
+	ErrOIDCInvalidState     = errors.New("invalid or expired login attempt")
+	ErrOIDCAccountConflict  = errors.New("an account with this email already exists; sign in with your password and verify the email to link it")
+	ErrOIDCSignupDisabled   = errors.New("no account is linked to this login")
+)
+
+// OIDCService logs users in through external OpenID Connect providers using
+// the authorization code flow with PKCE.
+type OIDCService interface {
+	// Start begins a login at provider. It returns the URL to send the user to
+	// and a flow token that has to come back with the callback, e.g. in a cookie.
+	Start(ctx context.Context, provider string) (authURL, flow string, err error)
+	// Callback finishes the login with the state and code the provider
+	// redirected back with, links or provisions the local account and returns
+	// an access token, or a TwoFactorRequiredError.
+	Callback(ctx context.Context, provider, flow, state, code string) (string, error)
+}
+
+// oidcFlowClaims carry the state, nonce and PKCE verifier of a login from
+// Start to Callback, signed so they cannot be tampered with.
+type oidcFlowClaims struct {
+	Type     string `json:"typ"`
+	Provider string `json:"prv"`
+	State    string `json:"st"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"cv"`
+	jwt.RegisteredClaims
+}
+
+var usernameStripRe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+type oidcService struct {
+	providers  map[string]*oidc.Provider
+	users      repository.UserRepository
+	identities repository.UserIdentityRepository
+	workspaces repository.WorkspaceRepository
+	audit      AuditService
+	events     UnitOfWork
+	userSvc    UserService
+	keys       *jwtkeys.KeySet
+	cfg        *config.Config
+}
+
+func NewOIDCService(users repository.UserRepository, identities repository.UserIdentityRepository, workspaces repository.WorkspaceRepository, audit AuditService, events UnitOfWork, userSvc UserService, keys *jwtkeys.KeySet, cfg *config.Config) OIDCService {
+	s := &oidcService{
+		providers:  make(map[string]*oidc.Provider, len(cfg.OIDCProviders)),
+		users:      users,
+		identities: identities,
+		workspaces: workspaces,
+		audit:      audit,
+		events:     events,
+		userSvc:    userSvc,
+		keys:       keys,
+		cfg:        cfg,
+	}
+	for _, p := range cfg.OIDCProviders {
+		redirect := p.RedirectURL
+		if redirect == "" {
+			redirect = strings.TrimSuffix(cfg.AppBaseURL, "/") + "/auth/oidc/" + p.Name + "/callback"
+		}
+		s.providers[p.Name] = oidc.NewProvider(oidc.Config{
+			IssuerURL:    p.IssuerURL,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  redirect,
+			Scopes:       p.Scopes,
+		})
+	}
+	return s
+}
+
+func (s *oidcService) Start(ctx context.Context, name string) (_ string, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.Start")
+	defer func() { tracing.End(span, err) }()
+
+	p, ok := s.providers[name]
+	if !ok {
+		return "", "", ErrOIDCProviderNotFound
+	}
+	var values [3]string // state, nonce, verifier
+	for i := range values {
+		if values[i], err = oidc.NewVerifier(); err != nil {
+			return "", "", err
+		}
+	}
+	now := time.Now()
+	flow, err := s.keys.Sign(oidcFlowClaims{
+		Type:     tokenTypeOIDCFlow,
+		Provider: name,
+		State:    values[0],
+		Nonce:    values[1],
+		Verifier: values[2],
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.JWTIssuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCFlowTTL)),
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	authURL, err := p.AuthCodeURL(ctx, values[0], values[1], values[2])
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, flow, nil
+}
+
+func (s *oidcService) Callback(ctx context.Context, name, flow, state, code string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.Callback")
+	defer func() { tracing.End(span, err) }()
+
+	p, ok := s.providers[name]
+	if !ok {
+		return "", ErrOIDCProviderNotFound
+	}
+	var fc oidcFlowClaims
+	if _, err := jwt.ParseWithClaims(flow, &fc, s.keys.Keyfunc); err != nil {
+		return "", ErrOIDCInvalidState
+	}
+	if fc.Type != tokenTypeOIDCFlow || fc.Provider != name || state == "" ||
+		subtle.ConstantTimeCompare([]byte(fc.State), []byte(state)) != 1 {
+		return "", ErrOIDCInvalidState
+	}
+	claims, err := p.Exchange(ctx, code, fc.Verifier, fc.Nonce)
+	if err != nil {
+		return "", err
+	}
+	userID, err := s.resolveUser(ctx, name, claims)
+	if err != nil {
+		return "", err
+	}
+	return s.userSvc.CompleteLogin(ctx, userID)
+}
+
+// resolveUser finds the account of a provider login: an identity linked
+// before, the local account with the same verified email, or a new account.
+func (s *oidcService) resolveUser(ctx context.Context, provider string, claims *oidc.Claims) (uint, error) {
+	ident, err := s.identities.FindByProviderSubject(ctx, provider, claims.Subject)
+	if err != nil {
+		return 0, err
+	}
+	if ident != nil {
+		return ident.UserID, nil
+	}
+
+	var email string
+	if claims.EmailVerified && claims.Email != "" {
+		if email, err = normalizeEmail(claims.Email); err != nil {
+			return 0, err
+		}
+	}
+	if email != "" {
+		u, err := s.users.FindByEmail(ctx, email)
+		if err != nil {
+			return 0, err
+		}
+		if u != nil {
+			// linking to an unverified address would hand the account to
+			// whoever registered it first
+			if !s.cfg.OIDCLinkByEmail || u.EmailVerifiedAt == nil {
+				return 0, ErrOIDCAccountConflict
+			}
+			return u.ID, s.link(ctx, u.ID, provider, claims)
+		}
+	}
+
+	if !s.cfg.OIDCAutoProvision {
+		return 0, ErrOIDCSignupDisabled
+	}
+	if email == "" {
+		return 0, errors.New("the login provider did not confirm an email address")
+	}
+	username, err := s.freeUsername(ctx, claims)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	u := &model.User{
+		Name:     strings.TrimSpace(claims.Name),
+		Username: username,
+		Email:    email,
+		// no password: the user can set one through the password reset flow
+		EmailVerifiedAt: &now,
+		TimeZone:        model.DefaultTimeZone,
+		Locale:          model.DefaultLocale,
+		Role:            model.RoleUser,
+	}
+	if u.Name == "" || len(u.Name) > 100 {
+		u.Name = username
+	}
+	event := &model.AuditEvent{Action: model.AuditUserRegister, TargetType: model.AuditTargetUser, After: userSummary(u)}
+	event.After["provider"] = provider
+	err = s.audit.Record(ctx, event, func(ctx context.Context) error {
+		if err := s.users.Create(ctx, u); err != nil {
+			return err
+		}
+		event.ActorID, event.TargetID = u.ID, u.ID
+		if err := s.link(ctx, u.ID, provider, claims); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventUserRegistered, model.AggregateUser, u.ID, userEventData(u.ID, u))
+	})
+	if err != nil {
+		return 0, err
+	}
+	createPersonalWorkspace(ctx, s.workspaces, u)
+	return u.ID, nil
+}
+
+func (s *oidcService) link(ctx context.Context, userID uint, provider string, claims *oidc.Claims) error {
+	return s.identities.Create(ctx, &model.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+}
+
+// freeUsername derives an unused username from the preferred username or the
+// local part of the email address.
+func (s *oidcService) freeUsername(ctx context.Context, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Trim(usernameStripRe.ReplaceAllString(strings.ToLower(base), ""), "._-")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	for i := 1; i <= 100; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		u, err := s.users.FindByUsername(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if u == nil {
+			return name, nil
+		}
+	}
+	return "", errors.New("could not find a free username")
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+const (
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxRetryBase   = 5 * time.Second
+	maxOutboxBackoff         = 10 * time.Minute
+	outboxBatchSize          = 100
+	outboxLease              = "outbox-relay"
+	outboxLeaseTTL           = time.Minute
+)
+
+var ErrNoUnitOfWork = errors.New("outbox events must be emitted within a unit of work")
+
+// UnitOfWork runs repository calls and the domain events they raise
+// atomically: the events are written to the outbox in the same transaction
+// as the changes, and only published by the OutboxRelay once it committed.
+type UnitOfWork interface {
+	// Do runs fn in a transaction, like Transactor.WithinTransaction.
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+	// Emit writes an event about the aggregate to the outbox, with data
+	// encoded as JSON. It must be called within Do, or within any other
+	// transaction of a repository.Transactor such as AuditService.Record's.
+	Emit(ctx context.Context, topic, aggregateType string, aggregateID uint, data any) error
+}
+
+type unitOfWork struct {
+	tx     repository.Transactor
+	outbox repository.OutboxRepository
+}
+
+func NewUnitOfWork(tx repository.Transactor, outbox repository.OutboxRepository) UnitOfWork {
+	return &unitOfWork{tx: tx, outbox: outbox}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.tx.WithinTransaction(ctx, fn)
+}
+
+func (u *unitOfWork) Emit(ctx context.Context, topic, aggregateType string, aggregateID uint, data any) error {
+	if !repository.InTransaction(ctx) {
+		return ErrNoUnitOfWork
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return u.outbox.Create(ctx, &model.OutboxEvent{
+		Topic:         topic,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       string(payload),
+		TraceParent:   tracing.TraceParent(ctx),
+		Status:        model.OutboxPending,
+		NextAttemptAt: time.Now(),
+	})
+}
+
+// OutboxRelay publishes the events of the outbox to the EventBus.
+type OutboxRelay interface {
+	// Relay publishes the pending events in order and returns how many it
+	// published. A failed event is retried with backoff, and the events
+	// after it wait, until it is dead after OUTBOX_MAX_ATTEMPTS. Only one
+	// server relays at a time; on the others Relay does nothing.
+	Relay(ctx context.Context) (int, error)
+	// Purge deletes the events published more than OUTBOX_RETENTION ago and
+	// returns how many it deleted.
+	Purge(ctx context.Context) (int, error)
+}
+
+type outboxRelay struct {
+	repo        repository.OutboxRepository
+	bus         EventBus
+	holder      string
+	maxAttempts int
+	retryBase   time.Duration
+	retention   time.Duration
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, bus EventBus, cfg *config.Config) OutboxRelay {
+	r := &outboxRelay{
+		repo:        repo,
+		bus:         bus,
+		maxAttempts: cfg.OutboxMaxAttempts,
+		retryBase:   cfg.OutboxRetryBase,
+		retention:   cfg.OutboxRetention,
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = defaultOutboxMaxAttempts
+	}
+	if r.retryBase <= 0 {
+		r.retryBase = defaultOutboxRetryBase
+	}
+	r.holder = leaseHolder()
+	return r
+}
+
+// leaseHolder returns a name for this process to take leases under.
+func leaseHolder() string {
+	host, _ := os.Hostname()
+	suffix, _ := randomHex(4)
+	return truncate(fmt.Sprintf("%s-%d-%s", host, os.Getpid(), suffix), 64)
+}
+
+func (r *outboxRelay) Relay(ctx context.Context) (int, error) {
+	now := time.Now()
+	ok, err := r.repo.AcquireLease(ctx, outboxLease, r.holder, now, now.Add(outboxLeaseTTL))
+	if err != nil || !ok {
+		return 0, err
+	}
+	pending, err := r.repo.ListPending(ctx, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for i := range pending {
+		e := &pending[i]
+		if ctx.Err() != nil || e.NextAttemptAt.After(now) {
+			break
+		}
+		failed, err := r.publish(ctx, e)
+		if err != nil {
+			return published, err
+		}
+		if failed {
+			// later events wait for this one, so that they stay in order
+			break
+		}
+		if e.Status == model.OutboxPublished {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// publish publishes e, in the trace of the change that raised it, and saves
+// the outcome, reporting whether the event failed and will be retried.
+func (r *outboxRelay) publish(ctx context.Context, e *model.OutboxEvent) (retry bool, err error) {
+	ctx, span := tracing.Start(tracing.WithTraceParent(ctx, e.TraceParent), "OutboxRelay.publish", attribute.String("event.topic", e.Topic), attribute.Int("event.id", int(e.ID)))
+	defer func() { tracing.End(span, err) }()
+
+	pubErr := r.bus.Publish(ctx, Event{
+		ID:            e.ID,
+		Topic:         e.Topic,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		Data:          json.RawMessage(e.Payload),
+		OccurredAt:    e.CreatedAt,
+	})
+	now := time.Now()
+	e.Attempts++
+	switch {
+	case pubErr == nil:
+		e.Status = model.OutboxPublished
+		e.LastError = ""
+		e.PublishedAt = &now
+	case e.Attempts >= r.maxAttempts:
+		log.Printf("outbox event %d (%s) is dead after %d attempts: %v", e.ID, e.Topic, e.Attempts, pubErr)
+		e.Status = model.OutboxDead
+		e.LastError = pubErr.Error()
+	default:
+		e.LastError = pubErr.Error()
+		e.NextAttemptAt = now.Add(r.backoff(e.Attempts))
+		retry = true
+	}
+	return retry, r.repo.Update(ctx, e)
+}
+
+// backoff returns the wait after the attempts-th failed attempt: the retry
+// base, doubled for every attempt after the first, up to ten minutes.
+func (r *outboxRelay) backoff(attempts int) time.Duration {
+	d := r.retryBase
+	for i := 1; i < attempts && d < maxOutboxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxOutboxBackoff)
+}
+
+func (r *outboxRelay) Purge(ctx context.Context) (int, error) {
+	if r.retention <= 0 {
+		return 0, nil
+	}
+	n, err := r.repo.DeletePublishedBefore(ctx, time.Now().Add(-r.retention))
+	return int(n), err
+}
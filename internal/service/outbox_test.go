@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+// mockUnitOfWork runs fn without a transaction and records the emitted
+// topics.
+type mockUnitOfWork struct {
+	topics []string
+}
+
+func (m *mockUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m *mockUnitOfWork) Emit(ctx context.Context, topic, aggregateType string, aggregateID uint, data any) error {
+	m.topics = append(m.topics, topic)
+	return nil
+}
+
+// mockOutboxRepo keeps the outbox and its lease in memory.
+type mockOutboxRepo struct {
+	events      []model.OutboxEvent
+	leaseHolder string
+	leaseUntil  time.Time
+}
+
+func (m *mockOutboxRepo) Create(ctx context.Context, e *model.OutboxEvent) error {
+	e.ID = uint(len(m.events) + 1)
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *mockOutboxRepo) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var out []model.OutboxEvent
+	for _, e := range m.events {
+		if e.Status == model.OutboxPending && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockOutboxRepo) ListAfter(ctx context.Context, id uint, limit int) ([]model.OutboxEvent, error) {
+	var out []model.OutboxEvent
+	for _, e := range m.events {
+		if e.ID > id && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockOutboxRepo) ListLatest(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	return m.events[max(0, len(m.events)-limit):], nil
+}
+
+func (m *mockOutboxRepo) Update(ctx context.Context, e *model.OutboxEvent) error {
+	m.events[e.ID-1] = *e
+	return nil
+}
+
+func (m *mockOutboxRepo) DeletePublishedBefore(ctx context.Context, t time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockOutboxRepo) AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error) {
+	if m.leaseHolder != "" && m.leaseHolder != holder && m.leaseUntil.After(now) {
+		return false, nil
+	}
+	m.leaseHolder, m.leaseUntil = holder, until
+	return true, nil
+}
+
+func (m *mockOutboxRepo) ReleaseLease(ctx context.Context, name, holder string) error {
+	if m.leaseHolder == holder {
+		m.leaseHolder = ""
+	}
+	return nil
+}
+
+func (m *mockOutboxRepo) add(topics ...string) {
+	for _, topic := range topics {
+		m.Create(context.Background(), &model.OutboxEvent{Topic: topic, AggregateType: model.AggregateNote, Payload: "{}", Status: model.OutboxPending, NextAttemptAt: time.Now()})
+	}
+}
+
+func (m *mockOutboxRepo) makeDue() {
+	for i := range m.events {
+		m.events[i].NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+func TestUnitOfWork_EmitNeedsTransaction(t *testing.T) {
+	repo := &mockOutboxRepo{}
+	uow := NewUnitOfWork(inlineTransactor{}, repo)
+	err := uow.Do(context.Background(), func(ctx context.Context) error {
+		return uow.Emit(ctx, model.EventNoteCreated, model.AggregateNote, 1, nil)
+	})
+	if !errors.Is(err, ErrNoUnitOfWork) || len(repo.events) != 0 {
+		t.Fatalf("expected an event outside a transaction to be refused, got %v", err)
+	}
+}
+
+func TestOutboxRelay_PublishesInOrderAndRetries(t *testing.T) {
+	repo := &mockOutboxRepo{}
+	repo.add("a", "b", "c")
+	bus := NewEventBus()
+	var got []string
+	failB := true
+	bus.Subscribe("*", func(ctx context.Context, e Event) error {
+		if e.Topic == "b" && failB {
+			failB = false
+			return errors.New("not now")
+		}
+		got = append(got, e.Topic)
+		return nil
+	})
+	relay := NewOutboxRelay(repo, bus, &config.Config{OutboxRetryBase: time.Minute})
+	ctx := context.Background()
+
+	if n, err := relay.Relay(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one event before the failure, got %d, %v", n, err)
+	}
+	b := repo.events[1]
+	if b.Status != model.OutboxPending || b.Attempts != 1 || b.LastError != "not now" || time.Until(b.NextAttemptAt) < 50*time.Second {
+		t.Fatalf("expected the failed event to be retried later, got %+v", b)
+	}
+	if n, _ := relay.Relay(ctx); n != 0 {
+		t.Fatalf("expected the events to wait for the failed one, %d published", n)
+	}
+
+	repo.makeDue()
+	if n, err := relay.Relay(ctx); err != nil || n != 2 {
+		t.Fatalf("expected the remaining events, got %d, %v", n, err)
+	}
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("expected the events in order, got %v", got)
+	}
+	for _, e := range repo.events {
+		if e.Status != model.OutboxPublished || e.PublishedAt == nil {
+			t.Fatalf("expected every event to be published, got %+v", e)
+		}
+	}
+}
+
+func TestOutboxRelay_DeadEventsStopBlocking(t *testing.T) {
+	repo := &mockOutboxRepo{}
+	repo.add("poison", "next")
+	bus := NewEventBus()
+	var got []string
+	bus.Subscribe("poison", func(ctx context.Context, e Event) error { panic("boom") })
+	bus.Subscribe("next", func(ctx context.Context, e Event) error {
+		got = append(got, e.Topic)
+		return nil
+	})
+	relay := NewOutboxRelay(repo, bus, &config.Config{OutboxMaxAttempts: 2})
+	ctx := context.Background()
+
+	relay.Relay(ctx)
+	repo.makeDue()
+	if n, _ := relay.Relay(ctx); n != 1 {
+		t.Fatalf("expected the next event once the poison one is dead, %d published", n)
+	}
+	if dead := repo.events[0]; dead.Status != model.OutboxDead || dead.Attempts != 2 || dead.LastError != "event handler panicked: boom" {
+		t.Fatalf("unexpected dead event: %+v", dead)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected the next event to be published, got %v", got)
+	}
+}
+
+func TestOutboxRelay_OneServerRelaysAtATime(t *testing.T) {
+	repo := &mockOutboxRepo{}
+	repo.add("a")
+	bus := NewEventBus()
+	first := NewOutboxRelay(repo, bus, &config.Config{})
+	second := NewOutboxRelay(repo, bus, &config.Config{})
+	if n, _ := first.Relay(context.Background()); n != 1 {
+		t.Fatalf("expected the first relay to publish, %d published", n)
+	}
+	repo.add("b")
+	if n, _ := second.Relay(context.Background()); n != 0 {
+		t.Fatalf("expected the second relay to wait for the lease, %d published", n)
+	}
+}
+
+func TestEventBus_Subscribe(t *testing.T) {
+	bus := NewEventBus()
+	var got []string
+	unsubscribe := bus.Subscribe(model.EventNoteCreated, func(ctx context.Context, e Event) error {
+		got = append(got, "created")
+		return nil
+	})
+	bus.Subscribe("*", func(ctx context.Context, e Event) error {
+		got = append(got, "all")
+		return errors.New("failed")
+	})
+	if err := bus.Publish(context.Background(), Event{Topic: model.EventNoteCreated}); err == nil {
+		t.Fatal("expected the handler's error")
+	}
+	unsubscribe()
+	bus.Publish(context.Background(), Event{Topic: model.EventNoteCreated})
+	bus.Publish(context.Background(), Event{Topic: model.EventNoteDeleted})
+	if len(got) != 4 || got[0] != "created" || got[1] != "all" || got[2] != "all" || got[3] != "all" {
+		t.Fatalf("unexpected deliveries: %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/password"
+)
+
+// newPasswordHasher builds the hasher configured in cfg, falling back to
+// argon2id with default parameters when the algorithm is unknown.
+func newPasswordHasher(cfg *config.Config) password.Hasher {
+	h, err := password.New(cfg.PasswordHashAlgorithm, cfg.BcryptCost, password.Argon2Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+	})
+	if err != nil {
+		log.Printf("WARNING: %v, using argon2id", err)
+		return password.NewArgon2id(password.Argon2Params{})
+	}
+	return h
+}
+
+// newPasswordPolicy builds the policy for new passwords from cfg. With bcrypt
+// it also refuses passwords the hasher cannot take.
+func newPasswordPolicy(cfg *config.Config) *password.Policy {
+	var breached []string
+	if cfg.PasswordCheckBreached {
+		breached = password.CommonPasswords()
+		if cfg.PasswordBreachedList != "" {
+			more, err := password.LoadList(cfg.PasswordBreachedList)
+			if err != nil {
+				log.Printf("WARNING: breached password list: %v", err)
+			}
+			breached = append(breached, more...)
+		}
+	}
+	p := password.NewPolicy(cfg.PasswordMinLength, breached)
+	if cfg.PasswordHashAlgorithm == password.Bcrypt {
+		p.MaxBytes = password.BcryptMaxBytes
+	}
+	return p
+}
+
+func (s *userService) hashPassword(ctx context.Context, pw string) (string, error) {
+	_, span := tracing.Start(ctx, "password.Hash")
+	defer span.End()
+	return s.hasher.Hash(pw)
+}
+
+// checkPassword reports whether pw matches the stored hash. A hash that cannot
+// be parsed counts as a mismatch.
+func (s *userService) checkPassword(ctx context.Context, u *model.User, pw string) bool {
+	if u.Password == "" {
+		// accounts created through an OpenID provider have no password yet
+		return false
+	}
+	_, span := tracing.Start(ctx, "password.Verify")
+	defer span.End()
+	ok, err := s.hasher.Verify(u.Password, pw)
+	if err != nil {
+		log.Printf("WARNING: password hash of user %d: %v", u.ID, err)
+	}
+	return ok
+}
+
+// rehashPassword upgrades the stored hash of u to the configured algorithm and
+// parameters after a successful login. Failing to do so does not fail the login.
+func (s *userService) rehashPassword(ctx context.Context, u *model.User, pw string) {
+	if !s.hasher.NeedsRehash(u.Password) {
+		return
+	}
+	hashed, err := s.hashPassword(ctx, pw)
+	if err == nil {
+		u.Password = hashed
+		err = s.repo.Update(ctx, u)
+	}
+	if err != nil {
+		log.Printf("WARNING: rehash password of user %d: %v", u.ID, err)
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+var (
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	// ErrSyncTokenExpired means that tombstones the client has not seen were
+	// purged: it has to drop its copy and sync from scratch.
+	ErrSyncTokenExpired  = errors.New("sync token expired, sync from scratch")
+	ErrSyncBatchTooLarge = fmt.Errorf("a sync batch holds at most %d changes", maxSyncBatch)
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+	maxSyncBatch     = 500
+)
+
+// Change operations of SyncChange.
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// Outcomes of a SyncChange.
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncRejected = "rejected"
+)
+
+// SyncChanges is what changed in a workspace since a sync token.
+type SyncChanges struct {
+	Notes   []model.Note    `json:"notes"`   // created or updated
+	Deleted []SyncTombstone `json:"deleted"` // none on a sync from scratch
+	// Token is where the next sync starts. With HasMore, there are more
+	// changes to fetch with it right away.
+	Token   string `json:"token"`
+	HasMore bool   `json:"has_more"`
+}
+
+// SyncTombstone reports a deleted note.
+type SyncTombstone struct {
+	ID        uint      `json:"id"`
+	ChangeSeq uint64    `json:"change_seq"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncChange is a change a client made offline. Updates and deletions name
+// the note and the ChangeSeq it had when the client last synced it.
+type SyncChange struct {
+	Op            string `json:"op"`                  // "create", "update" or "delete"
+	ClientID      string `json:"client_id,omitempty"` // returned in the result, to match a created note with the client's copy
+	ID            uint   `json:"id,omitempty"`
+	BaseChangeSeq uint64 `json:"base_change_seq,omitempty"`
+	Title         string `json:"title"`
+	Content       string `json:"content"`
+}
+
+// SyncResult is the outcome of a SyncChange. Note is the saved note when the
+// change was applied, and the server's copy on a conflict, nil if the note
+// was deleted.
+type SyncResult struct {
+	ClientID string      `json:"client_id,omitempty"`
+	ID       uint        `json:"id,omitempty"`
+	Status   string      `json:"status"` // "applied", "conflict" or "rejected"
+	Note     *model.Note `json:"note,omitempty"`
+	Deleted  bool        `json:"deleted,omitempty"` // on a conflict: the note was deleted
+	Error    string      `json:"error,omitempty"`   // why the change was rejected
+}
+
+// SyncService lets clients keep an offline copy of a workspace's notes.
+// Changes returns what changed since the token of the client's last sync,
+// or everything for an empty token; Push applies the changes the client
+// made meanwhile through NoteService, so that they are audited and
+// published like any other. The token is opaque to clients: it holds the
+// workspace's change sequence number they are at.
+type SyncService interface {
+	Changes(ctx context.Context, userID, workspaceID uint, token string, limit int) (*SyncChanges, error)
+	// Push applies changes in order. A change to a note that was changed
+	// since the client's base is not applied but reported as a conflict
+	// with the server's copy.
+	Push(ctx context.Context, userID, workspaceID uint, changes []SyncChange) ([]SyncResult, error)
+	// PurgeTombstones removes the tombstones of the notes deleted more than
+	// SYNC_TOMBSTONE_RETENTION ago and returns how many it removed.
+	PurgeTombstones(ctx context.Context) (int, error)
+}
+
+type syncService struct {
+	notes      repository.NoteRepository
+	noteSvc    NoteService
+	workspaces WorkspaceService
+	retention  time.Duration
+}
+
+func NewSyncService(notes repository.NoteRepository, noteSvc NoteService, workspaces WorkspaceService, cfg *config.Config) SyncService {
+	return &syncService{notes: notes, noteSvc: noteSvc, workspaces: workspaces, retention: cfg.SyncTombstoneRetention}
+}
+
+func (s *syncService) Changes(ctx context.Context, userID, workspaceID uint, token string, limit int) (_ *SyncChanges, err error) {
+	ctx, span := tracing.Start(ctx, "SyncService.Changes", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.workspaces.Authorize(ctx, userID, workspaceID, model.PermNotesRead)
+	if err != nil {
+		return nil, err
+	}
+	var since uint64
+	if token != "" {
+		if since, err = decodeSyncToken(token, ws.ID); err != nil {
+			return nil, err
+		}
+		if since > ws.ChangeSeq {
+			return nil, ErrInvalidSyncToken
+		}
+		if since < ws.PurgedChangeSeq {
+			return nil, ErrSyncTokenExpired
+		}
+	}
+	if limit < 1 {
+		limit = defaultSyncLimit
+	}
+	limit = min(limit, maxSyncLimit)
+
+	// one query for notes and tombstones: it sees a consistent prefix of the
+	// workspace's changes, which commit in sequence order
+	notes, err := s.notes.Changes(ctx, ws.ID, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	res := &SyncChanges{Notes: []model.Note{}, Deleted: []SyncTombstone{}, HasMore: len(notes) > limit}
+	for _, n := range notes[:min(limit, len(notes))] {
+		since = n.ChangeSeq
+		switch {
+		case !n.DeletedAt.Valid:
+			res.Notes = append(res.Notes, n)
+		case token != "":
+			res.Deleted = append(res.Deleted, SyncTombstone{ID: n.ID, ChangeSeq: n.ChangeSeq, DeletedAt: n.DeletedAt.Time})
+		}
+	}
+	res.Token = encodeSyncToken(ws.ID, since)
+	return res, nil
+}
+
+func (s *syncService) Push(ctx context.Context, userID, workspaceID uint, changes []SyncChange) (_ []SyncResult, err error) {
+	ctx, span := tracing.Start(ctx, "SyncService.Push", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)), attribute.Int("sync.changes", len(changes)))
+	defer func() { tracing.End(span, err) }()
+
+	if len(changes) > maxSyncBatch {
+		return nil, ErrSyncBatchTooLarge
+	}
+	if _, err := s.workspaces.Authorize(ctx, userID, workspaceID, model.PermNotesWrite); err != nil {
+		return nil, err
+	}
+	results := make([]SyncResult, 0, len(changes))
+	for _, c := range changes {
+		r, err := s.apply(ctx, userID, workspaceID, c)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// apply applies one change. Errors other than those reported in the result
+// end the push.
+func (s *syncService) apply(ctx context.Context, userID, workspaceID uint, c SyncChange) (SyncResult, error) {
+	r := SyncResult{ClientID: c.ClientID, ID: c.ID, Status: SyncApplied}
+	if (c.Op == SyncUpdate || c.Op == SyncDelete) && c.ID == 0 {
+		r.Status, r.Error = SyncRejected, "id is required"
+		return r, nil
+	}
+	var err error
+	switch c.Op {
+	case SyncCreate:
+		r.Note, err = s.noteSvc.Create(ctx, userID, workspaceID, c.Title, c.Content)
+		if r.Note != nil {
+			r.ID = r.Note.ID
+		}
+	case SyncUpdate:
+		r.Note, err = s.noteSvc.UpdateIfUnchanged(ctx, userID, workspaceID, c.ID, c.BaseChangeSeq, c.Title, c.Content)
+		if err == nil && r.Note == nil {
+			err = ErrNoteNotFound
+		}
+	case SyncDelete:
+		err = s.noteSvc.DeleteIfUnchanged(ctx, userID, workspaceID, c.ID, c.BaseChangeSeq)
+		if isNoteGone(err) {
+			err = nil // already deleted
+		}
+	default:
+		r.Status, r.Error = SyncRejected, fmt.Sprintf("unknown op %q", c.Op)
+		return r, nil
+	}
+	if err == nil {
+		return r, nil
+	}
+	if !errors.Is(err, ErrNoteConflict) && !isNoteGone(err) {
+		return r, err
+	}
+
+	r.Status = SyncConflict
+	if r.Note, err = s.noteSvc.GetByID(ctx, userID, workspaceID, c.ID); isNoteGone(err) {
+		r.Note, err = nil, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	r.Deleted = r.Note == nil
+	if c.Op == SyncUpdate && r.Note != nil && r.Note.Title == c.Title && r.Note.Content == c.Content {
+		// the server already has the client's version, e.g. when it retries
+		// a push whose response it did not get
+		r.Status = SyncApplied
+	}
+	return r, nil
+}
+
+// isNoteGone reports whether err is how NoteService reports a note that is
+// missing, or belongs to another workspace.
+func isNoteGone(err error) bool {
+	return errors.Is(err, ErrNoteNotFound) || errors.Is(err, errNoteAccess)
+}
+
+func (s *syncService) PurgeTombstones(ctx context.Context) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	n, err := s.notes.PurgeDeleted(ctx, time.Now().Add(-s.retention))
+	return int(n), err
+}
+
+func encodeSyncToken(workspaceID uint, seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", workspaceID, seq))
+}
+
+// decodeSyncToken returns the change sequence number in token, which must
+// have been issued for workspaceID.
+func decodeSyncToken(token string, workspaceID uint) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidSyncToken
+	}
+	ws, seq, ok := strings.Cut(string(raw), ":")
+	if !ok || ws != strconv.FormatUint(uint64(workspaceID), 10) {
+		return 0, ErrInvalidSyncToken
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSyncToken
+	}
+	return n, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+)
+
+// ErrScopeNotGranted means a token asked for a scope that the credential
+// creating it does not have.
+var ErrScopeNotGranted = errors.New("scope not granted to the current credential")
+
+// lastUsedResolution limits how often LastUsedAt is written for a busy token.
+const lastUsedResolution = time.Minute
+
+type TokenService interface {
+	// Create mints a token and returns its plain value, which is shown only
+	// once. via is the token the request authenticated with, nil for an
+	// interactive session: a token can only hand on scopes it has, and
+	// never ScopeAdmin.
+	Create(ctx context.Context, userID uint, via *model.PersonalAccessToken, name string, scopes []string, expiresAt *time.Time) (string, *model.PersonalAccessToken, error)
+	List(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error)
+	Revoke(ctx context.Context, userID, id uint) error
+	// Authenticate resolves a plain token to an active (not revoked, not
+	// expired) token whose owner's account is not disabled.
+	Authenticate(ctx context.Context, plain string) (*model.PersonalAccessToken, error)
+}
+
+type tokenService struct {
+	repo  repository.TokenRepository
+	users repository.UserRepository
+	audit AuditService
+}
+
+// NewTokenService records the creation and revocation of tokens in audit.
+func NewTokenService(repo repository.TokenRepository, users repository.UserRepository, audit AuditService) TokenService {
+	return &tokenService{repo: repo, users: users, audit: audit}
+}
+
+func (s *tokenService) Create(ctx context.Context, userID uint, via *model.PersonalAccessToken, name string, scopes []string, expiresAt *time.Time) (string, *model.PersonalAccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+	for _, sc := range scopes {
+		if !validScope(sc) {
+			return "", nil, fmt.Errorf("unknown scope %q", sc)
+		}
+		if via != nil && (sc == model.ScopeAdmin || !slices.Contains(via.ScopeList(), sc)) {
+			return "", nil, fmt.Errorf("%w: %q", ErrScopeNotGranted, sc)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, errors.New("expires_at must be in the future")
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	plain := model.PATPrefix + hex.EncodeToString(raw)
+
+	t := &model.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(plain),
+		Prefix:    plain[:len(model.PATPrefix)+6],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	}
+	event := &model.AuditEvent{ActorID: userID, Action: model.AuditTokenCreate, TargetType: model.AuditTargetToken, After: tokenSummary(t)}
+	err := s.audit.Record(ctx, event, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, t); err != nil {
+			return err
+		}
+		event.TargetID = t.ID
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, t, nil
+}
+
+func (s *tokenService) List(ctx context.Context, userID uint) ([]model.PersonalAccessToken, error) {
+	return s.repo.ListActiveByUser(ctx, userID)
+}
+
+func (s *tokenService) Revoke(ctx context.Context, userID, id uint) error {
+	t, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if t == nil || t.UserID != userID || t.RevokedAt != nil {
+		return errors.New("not found or access denied")
+	}
+	event := &model.AuditEvent{ActorID: userID, Action: model.AuditTokenRevoke, TargetType: model.AuditTargetToken, TargetID: t.ID, Before: tokenSummary(t)}
+	return s.audit.Record(ctx, event, func(ctx context.Context) error {
+		return s.repo.Revoke(ctx, id, time.Now())
+	})
+}
+
+// tokenSummary describes a token in audit events by its public parts.
+func tokenSummary(t *model.PersonalAccessToken) model.AuditSummary {
+	return model.AuditSummary{"name": t.Name, "prefix": t.Prefix, "scopes": t.Scopes}
+}
+
+func (s *tokenService) Authenticate(ctx context.Context, plain string) (*model.PersonalAccessToken, error) {
+	if !strings.HasPrefix(plain, model.PATPrefix) {
+		return nil, errors.New("invalid token")
+	}
+	t, err := s.repo.FindByHash(ctx, hashToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if t == nil || t.RevokedAt != nil || (t.ExpiresAt != nil && now.After(*t.ExpiresAt)) {
+		return nil, errors.New("invalid token")
+	}
+	u, err := s.users.FindByID(ctx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.DisabledAt != nil {
+		return nil, errors.New("invalid token")
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > lastUsedResolution {
+		if err := s.repo.TouchLastUsed(ctx, t.ID, now); err != nil {
+			return nil, err
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range model.AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew accepts codes from one step before and after the current one.
+	totpSkew = 1
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// SetupTwoFactor generates a new TOTP secret for the user. 2FA is not enforced
+// until the secret is confirmed with EnableTwoFactor.
+func (s *userService) SetupTwoFactor(ctx context.Context, userID uint) (_ string, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SetupTwoFactor")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.findUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if u.TOTPEnabled {
+		return "", "", errors.New("two-factor authentication already enabled")
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	u.TOTPSecret = secret
+	if err := s.repo.Update(ctx, u); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(s.cfg.TOTPIssuer, u.Username, secret), nil
+}
+
+// EnableTwoFactor confirms the pending secret with a code from the
+// authenticator app and returns the recovery codes, which are shown only once.
+func (s *userService) EnableTwoFactor(ctx context.Context, userID uint, code string) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.EnableTwoFactor")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+	if u.TOTPSecret == "" {
+		return nil, errors.New("two-factor setup has not been started")
+	}
+	step, ok := totp.Match(u.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok || step <= u.TOTPLastStep {
+		return nil, errors.New("invalid code")
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		c, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = c
+		hashes[i] = hashToken(normalizeRecoveryCode(c))
+	}
+	u.TOTPEnabled = true
+	err = s.events.Do(ctx, func(ctx context.Context) error {
+		// the code that enables 2FA cannot be used again to log in
+		ok, err := s.repo.ClaimTOTPStep(ctx, u.ID, step)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("invalid code")
+		}
+		if err := s.repo.Update(ctx, u); err != nil {
+			return err
+		}
+		return s.replaceRecoveryCodes(ctx, u, strings.Join(hashes, " "))
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.TOTPLastStep = step
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off. It requires the current password and a
+// valid TOTP or recovery code.
+func (s *userService) DisableTwoFactor(ctx context.Context, userID uint, password, code string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DisableTwoFactor")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !u.TOTPEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if !s.checkPassword(ctx, u, password) {
+		return errors.New("invalid credentials")
+	}
+	ok, err := s.checkSecondFactor(ctx, u, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid code")
+	}
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	return s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, u); err != nil {
+			return err
+		}
+		return s.replaceRecoveryCodes(ctx, u, "")
+	})
+}
+
+// LoginTwoFactor exchanges a challenge token from Login plus a TOTP or
+// recovery code for an access token. Wrong codes count towards the lockout.
+func (s *userService) LoginTwoFactor(ctx context.Context, challengeToken, code string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.LoginTwoFactor")
+	defer func() { tracing.End(span, err) }()
+
+	userID, version, err := s.parseToken(challengeToken, tokenTypeChallenge)
+	if err != nil {
+		return "", errors.New("invalid or expired challenge")
+	}
+	u, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if u == nil || !u.TOTPEnabled || u.TokenVersion != version {
+		return "", errors.New("invalid or expired challenge")
+	}
+	if u.DisabledAt != nil {
+		if err := s.loginFailed(ctx, u, u.Username, "account disabled", nil); err != nil {
+			return "", err
+		}
+		return "", ErrAccountDisabled
+	}
+	now := time.Now()
+	if u.LockedUntil != nil && now.Before(*u.LockedUntil) {
+		if err := s.loginFailed(ctx, u, u.Username, "locked", nil); err != nil {
+			return "", err
+		}
+		return "", &AccountLockedError{Until: *u.LockedUntil}
+	}
+	ok, err := s.checkSecondFactor(ctx, u, code)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		err := s.loginFailed(ctx, u, u.Username, "wrong second factor", func(ctx context.Context) error {
+			return s.recordFailedLogin(ctx, u, now)
+		})
+		if err != nil {
+			return "", err
+		}
+		return "", errors.New("invalid code")
+	}
+
+	tokenString, err := s.signToken(u, tokenTypeAccess, time.Duration(s.cfg.TokenTTL)*time.Second)
+	if err != nil {
+		return "", err
+	}
+	u.FailedLogins = 0
+	u.LockedUntil = nil
+	u.DeletionScheduledAt = nil
+	err = s.audit.Record(ctx, loginEvent(u), func(ctx context.Context) error {
+		return s.repo.Update(ctx, u)
+	})
+	if err != nil {
+		return "", err
+	}
+	metrics.Logins.WithLabelValues("succeeded").Inc()
+	return tokenString, nil
+}
+
+func (s *userService) findUser(ctx context.Context, id uint) (*model.User, error) {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errors.New("user not found")
+	}
+	return u, nil
+}
+
+// checkSecondFactor accepts a TOTP code of a later time step than the last
+// one accepted, or an unused recovery code, and records that it was used.
+// The records are conditional updates, so that of concurrent requests with
+// the same code only one succeeds; one with another recovery code may fail
+// too, and the user tries again.
+func (s *userService) checkSecondFactor(ctx context.Context, u *model.User, code string) (bool, error) {
+	if step, ok := totp.Match(u.TOTPSecret, code, time.Now(), totpSkew); ok {
+		if step <= u.TOTPLastStep {
+			return false, nil
+		}
+		ok, err := s.repo.ClaimTOTPStep(ctx, u.ID, step)
+		if ok {
+			u.TOTPLastStep = step
+		}
+		return ok, err
+	}
+	want := hashToken(normalizeRecoveryCode(code))
+	hashes := strings.Fields(u.RecoveryCodes)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(want)) == 1 {
+			remaining := strings.Join(slices.Delete(hashes, i, i+1), " ")
+			ok, err := s.repo.ReplaceRecoveryCodes(ctx, u.ID, u.RecoveryCodes, remaining)
+			if ok {
+				u.RecoveryCodes = remaining
+			}
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes sets u's recovery codes to codes, unless they changed
+// since u was read.
+func (s *userService) replaceRecoveryCodes(ctx context.Context, u *model.User, codes string) error {
+	if codes == u.RecoveryCodes {
+		return nil
+	}
+	ok, err := s.repo.ReplaceRecoveryCodes(ctx, u.ID, u.RecoveryCodes, codes)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("two-factor settings changed concurrently, try again")
+	}
+	u.RecoveryCodes = codes
+	return nil
+}
+
+// newRecoveryCode returns a code like "k3vq-7xtm-a2pd-9wce".
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(recoveryEncoding.EncodeToString(raw))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -1,109 +1,451 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"log"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
 	"github.com/MujiRahman/golang-simple-note/internal/model"
 	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+	"github.com/MujiRahman/golang-simple-note/pkg/password"
 )
 
 type UserService interface {
-	Register(username, password string) (*model.User, error)
-	Login(username, password string) (string, error) // returns JWT token
-	ParseToken(tokenStr string) (uint, error)
+	Register(ctx context.Context, name, username, email, password string) (*model.User, error)
+	Login(ctx context.Context, username, password string) (string, error) // returns JWT token
+	ParseToken(ctx context.Context, tokenStr string) (uint, error)
+	// ChangePassword verifies the current password, revokes every session and
+	// returns a fresh token for the caller.
+	ChangePassword(ctx context.Context, userID uint, current, newPassword string) (string, error)
+	// CompleteLogin finishes a login whose credentials were checked elsewhere,
+	// e.g. by an OpenID provider. Like Login it returns a
+	// TwoFactorRequiredError when the account has 2FA enabled.
+	CompleteLogin(ctx context.Context, userID uint) (string, error)
+
+	// two-factor authentication, see two_factor.go
+	SetupTwoFactor(ctx context.Context, userID uint) (secret, uri string, err error)
+	EnableTwoFactor(ctx context.Context, userID uint, code string) (recoveryCodes []string, err error)
+	DisableTwoFactor(ctx context.Context, userID uint, password, code string) error
+	LoginTwoFactor(ctx context.Context, challengeToken, code string) (string, error)
+}
+
+const (
+	tokenTypeAccess    = "access"
+	tokenTypeChallenge = "2fa_challenge"
+
+	defaultChallengeTTL = 5 * time.Minute
+)
+
+var (
+	// ErrAccountDisabled is returned by the logins of an account an admin has disabled.
+	ErrAccountDisabled = errors.New("account disabled")
+	// ErrPasswordResetRequired is returned by Login after an admin forced a
+	// password reset, until the user sets a new password.
+	ErrPasswordResetRequired = errors.New("password reset required")
+)
+
+// AccountLockedError is returned by Login while an account is locked out
+// after too many failed attempts.
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return "account temporarily locked due to too many failed logins"
+}
+
+// TwoFactorRequiredError is returned by Login when the password is correct but
+// the account has 2FA enabled. The challenge token must be exchanged together
+// with a TOTP or recovery code through LoginTwoFactor.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+// tokenClaims are the claims of the JWTs issued by userService.
+type tokenClaims struct {
+	UserID       uint   `json:"user_id"`
+	Type         string `json:"typ,omitempty"`
+	TokenVersion int    `json:"tv"`
+	jwt.RegisteredClaims
 }
 
 type userService struct {
-	repo repository.UserRepository
-	cfg  *config.Config
+	repo       repository.UserRepository
+	workspaces repository.WorkspaceRepository
+	audit      AuditService
+	events     UnitOfWork
+	keys       *jwtkeys.KeySet
+	cfg        *config.Config
+	hasher     password.Hasher
+	policy     *password.Policy
 }
 
-func NewUserService(repo repository.UserRepository, cfg *config.Config) UserService {
-	return &userService{repo: repo, cfg: cfg}
+// NewUserService signs tokens with the active key of keys and accepts tokens
+// signed by any key in the set. Registration creates the personal workspace
+// of the new user in workspaces. Registrations and logins, failed ones
+// included, are recorded in audit; registrations are also written to the
+// outbox through events.
+func NewUserService(repo repository.UserRepository, workspaces repository.WorkspaceRepository, audit AuditService, events UnitOfWork, keys *jwtkeys.KeySet, cfg *config.Config) UserService {
+	return &userService{repo: repo, workspaces: workspaces, audit: audit, events: events, keys: keys, cfg: cfg, hasher: newPasswordHasher(cfg), policy: newPasswordPolicy(cfg)}
 }
 
-func (s *userService) Register(username, password string) (*model.User, error) {
+func (s *userService) Register(ctx context.Context, name, username, email, pw string) (_ *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Check(pw); err != nil {
+		return nil, err
+	}
+	email, err = normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = username
+	}
 	// check existing
-	exist, err := s.repo.FindByUsername(username)
+	exist, err := s.repo.FindByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 	if exist != nil {
 		return nil, errors.New("username already used")
 	}
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	exist, err = s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if exist != nil {
+		return nil, errors.New("email already used")
+	}
+	hashed, err := s.hashPassword(ctx, pw)
 	if err != nil {
 		return nil, err
 	}
 	u := &model.User{
+		Name:     name,
 		Username: username,
-		Password: string(hashed),
+		Email:    email,
+		Password: hashed,
+		TimeZone: model.DefaultTimeZone,
+		Locale:   model.DefaultLocale,
+		Role:     model.RoleUser,
 	}
-	if err := s.repo.Create(u); err != nil {
+	event := &model.AuditEvent{Action: model.AuditUserRegister, TargetType: model.AuditTargetUser, After: userSummary(u)}
+	err = s.audit.Record(ctx, event, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, u); err != nil {
+			return err
+		}
+		event.ActorID, event.TargetID = u.ID, u.ID
+		return s.events.Emit(ctx, model.EventUserRegistered, model.AggregateUser, u.ID, userEventData(u.ID, u))
+	})
+	if err != nil {
 		return nil, err
 	}
+	createPersonalWorkspace(ctx, s.workspaces, u)
 	return u, nil
 }
 
-func (s *userService) Login(username, password string) (string, error) {
-	u, err := s.repo.FindByUsername(username)
+// createPersonalWorkspace creates the personal workspace of a new account.
+// Failing to do so does not fail the registration: WorkspaceService creates
+// it on first use.
+func createPersonalWorkspace(ctx context.Context, workspaces repository.WorkspaceRepository, u *model.User) {
+	if err := workspaces.CreateWithOwner(ctx, model.NewPersonalWorkspace(u.ID), u.ID); err != nil {
+		log.Printf("WARNING: personal workspace of user %d: %v", u.ID, err)
+	}
+}
+
+// normalizeEmail validates a bare address (no display name) and lowercases it.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New("invalid email address")
+	}
+	return email, nil
+}
+
+func (s *userService) Login(ctx context.Context, username, pw string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.repo.FindByUsername(ctx, username)
 	if err != nil {
 		return "", err
 	}
 	if u == nil {
+		if err := s.loginFailed(ctx, nil, username, "unknown user", nil); err != nil {
+			return "", err
+		}
 		return "", errors.New("invalid credentials")
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+	now := time.Now()
+	if u.LockedUntil != nil && now.Before(*u.LockedUntil) {
+		if err := s.loginFailed(ctx, u, username, "locked", nil); err != nil {
+			return "", err
+		}
+		return "", &AccountLockedError{Until: *u.LockedUntil}
+	}
+	if !s.checkPassword(ctx, u, pw) {
+		err := s.loginFailed(ctx, u, username, "wrong password", func(ctx context.Context) error {
+			return s.recordFailedLogin(ctx, u, now)
+		})
+		if err != nil {
+			return "", err
+		}
 		return "", errors.New("invalid credentials")
 	}
+	if err := s.resetFailedLogins(ctx, u); err != nil {
+		return "", err
+	}
+	s.rehashPassword(ctx, u, pw)
+	if u.PasswordResetRequired {
+		if err := s.loginFailed(ctx, u, username, "password reset required", nil); err != nil {
+			return "", err
+		}
+		return "", ErrPasswordResetRequired
+	}
+	return s.finishLogin(ctx, u)
+}
 
-	// create jwt token
-	claims := jwt.RegisteredClaims{
-		Subject:   string(rune(u.ID)), // we'll use ID stored in `Subject` but convert more robustly below
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(s.cfg.TokenTTL) * time.Second)),
+func (s *userService) CompleteLogin(ctx context.Context, userID uint) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CompleteLogin")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.findUser(ctx, userID)
+	if err != nil {
+		return "", err
 	}
-	// Instead of using Subject as rune conversion hack, create a map claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": u.ID,
-		"exp":     claims.ExpiresAt.Unix(),
-		"iat":     claims.IssuedAt.Unix(),
-		"sub":     u.Username,
-	})
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	return s.finishLogin(ctx, u)
+}
+
+// finishLogin issues the access token once the first factor is verified, or
+// a 2FA challenge when the account has a second factor.
+func (s *userService) finishLogin(ctx context.Context, u *model.User) (string, error) {
+	if u.DisabledAt != nil {
+		if err := s.loginFailed(ctx, u, u.Username, "account disabled", nil); err != nil {
+			return "", err
+		}
+		return "", ErrAccountDisabled
+	}
+	// logging in during the grace period cancels a pending account deletion
+	if u.DeletionScheduledAt != nil {
+		u.DeletionScheduledAt = nil
+		if err := s.repo.Update(ctx, u); err != nil {
+			return "", err
+		}
+	}
+
+	// the first factor is fine, but the second factor is still missing
+	if u.TOTPEnabled {
+		ttl := s.cfg.TwoFactorChallengeTTL
+		if ttl <= 0 {
+			ttl = defaultChallengeTTL
+		}
+		challenge, err := s.signToken(u, tokenTypeChallenge, ttl)
+		if err != nil {
+			return "", err
+		}
+		return "", &TwoFactorRequiredError{ChallengeToken: challenge}
+	}
+
+	tokenString, err := s.signToken(u, tokenTypeAccess, time.Duration(s.cfg.TokenTTL)*time.Second)
 	if err != nil {
 		return "", err
 	}
+	if err := s.audit.Record(ctx, loginEvent(u), nil); err != nil {
+		return "", err
+	}
+	metrics.Logins.WithLabelValues("succeeded").Inc()
 	return tokenString, nil
 }
 
-func (s *userService) ParseToken(tokenStr string) (uint, error) {
-	tok, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		// ensure signing method
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// loginFailed counts a failed login and records it in the audit log together
+// with change. u is nil when username does not exist.
+func (s *userService) loginFailed(ctx context.Context, u *model.User, username, reason string, change func(ctx context.Context) error) error {
+	metrics.Logins.WithLabelValues("failed").Inc()
+	event := &model.AuditEvent{Action: model.AuditUserLoginFailed, After: model.AuditSummary{"username": username, "reason": reason}}
+	if u != nil {
+		event.TargetType, event.TargetID = model.AuditTargetUser, u.ID
+	}
+	return s.audit.Record(ctx, event, change)
+}
+
+func loginEvent(u *model.User) *model.AuditEvent {
+	return &model.AuditEvent{ActorID: u.ID, Action: model.AuditUserLogin, TargetType: model.AuditTargetUser, TargetID: u.ID}
+}
+
+// userSummary describes an account in audit events.
+func userSummary(u *model.User) model.AuditSummary {
+	return model.AuditSummary{"username": u.Username, "email": u.Email}
+}
+
+// userEventData is the data of the user.* events: the account, without
+// contact details, and who changed it.
+func userEventData(actorID uint, u *model.User) any {
+	type eventUser struct {
+		ID       uint   `json:"id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	}
+	return struct {
+		ActorID uint      `json:"actor_id"`
+		User    eventUser `json:"user"`
+	}{actorID, eventUser{u.ID, u.Username, u.Name}}
+}
+
+func (s *userService) resetFailedLogins(ctx context.Context, u *model.User) error {
+	if u.FailedLogins == 0 && u.LockedUntil == nil {
+		return nil
+	}
+	u.FailedLogins = 0
+	u.LockedUntil = nil
+	return s.repo.Update(ctx, u)
+}
+
+// signToken creates a JWT of the given type ("typ" claim) for u.
+func (s *userService) signToken(u *model.User, typ string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		UserID:       u.ID,
+		Type:         typ,
+		TokenVersion: u.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.JWTIssuer,
+			Subject:   u.Username,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if s.cfg.JWTAudience != "" {
+		claims.Audience = jwt.ClaimStrings{s.cfg.JWTAudience}
+	}
+	return s.keys.Sign(claims)
+}
+
+// recordFailedLogin counts a failed attempt and, once the threshold is
+// reached, locks the account for a period that doubles with each further
+// failure. The count is incremented in the database, and the lock derived
+// from the new count, so that concurrent attempts cannot undo each other.
+func (s *userService) recordFailedLogin(ctx context.Context, u *model.User, now time.Time) error {
+	if s.cfg.LoginLockoutThreshold <= 0 {
+		return nil
+	}
+	n, err := s.repo.RecordFailedLogin(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	u.FailedLogins = n
+	over := n - s.cfg.LoginLockoutThreshold
+	if over < 0 {
+		return nil
+	}
+	d := s.cfg.LoginLockoutBase
+	for i := 0; i < over && d < s.cfg.LoginLockoutMax; i++ {
+		d *= 2
+	}
+	if s.cfg.LoginLockoutMax > 0 && d > s.cfg.LoginLockoutMax {
+		d = s.cfg.LoginLockoutMax
+	}
+	until := now.Add(d)
+	u.LockedUntil = &until
+	return s.repo.LockUntil(ctx, u.ID, n, until)
+}
+
+// ParseToken validates an access token and checks that its session has not
+// been revoked (password change, account deletion) since it was issued and
+// that the account is not disabled.
+func (s *userService) ParseToken(ctx context.Context, tokenStr string) (uint, error) {
+	uid, version, err := s.parseToken(tokenStr, tokenTypeAccess)
+	if err != nil {
+		return 0, err
+	}
+	u, err := s.repo.FindByID(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+	if u == nil || u.TokenVersion != version || u.DeletionScheduledAt != nil {
+		return 0, errors.New("session revoked")
+	}
+	if u.DisabledAt != nil {
+		return 0, ErrAccountDisabled
+	}
+	return uid, nil
+}
+
+func (s *userService) ChangePassword(ctx context.Context, userID uint, current, newPassword string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.policy.Check(newPassword); err != nil {
+		return "", err
+	}
+	u, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if u == nil {
+		return "", errors.New("user not found")
+	}
+	if !s.checkPassword(ctx, u, current) {
+		return "", errors.New("current password is incorrect")
+	}
+	hashed, err := s.hashPassword(ctx, newPassword)
+	if err != nil {
+		return "", err
+	}
+	u.Password = hashed
+	u.PasswordResetRequired = false
+	u.TokenVersion++
+	err = s.events.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, u); err != nil {
+			return err
 		}
-		return []byte(s.cfg.JWTSecret), nil
+		return s.events.Emit(ctx, model.EventUserPasswordChanged, model.AggregateUser, u.ID, userEventData(u.ID, u))
 	})
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+	return s.signToken(u, tokenTypeAccess, time.Duration(s.cfg.TokenTTL)*time.Second)
+}
+
+// parseToken validates tokenStr and returns the user id and token version.
+// It checks the issuer and audience when configured, and the "typ" claim, so
+// a 2FA challenge token can never be used as an access token.
+func (s *userService) parseToken(tokenStr, typ string) (uint, int, error) {
+	var claims tokenClaims
+	tok, err := jwt.ParseWithClaims(tokenStr, &claims, s.keys.Keyfunc)
+	if err != nil {
+		return 0, 0, err
 	}
 	if !tok.Valid {
-		return 0, errors.New("invalid token")
+		return 0, 0, errors.New("invalid token")
+	}
+	if s.cfg.JWTIssuer != "" && !claims.VerifyIssuer(s.cfg.JWTIssuer, true) {
+		return 0, 0, errors.New("invalid token issuer")
+	}
+	if s.cfg.JWTAudience != "" && !claims.VerifyAudience(s.cfg.JWTAudience, true) {
+		return 0, 0, errors.New("invalid token audience")
 	}
-	claims, ok := tok.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, errors.New("invalid token claims")
+	if claims.Type != typ {
+		return 0, 0, errors.New("invalid token type")
 	}
-	// extract user_id
-	uidFloat, ok := claims["user_id"].(float64)
-	if !ok {
-		return 0, errors.New("user_id missing in token")
+	if claims.UserID == 0 {
+		return 0, 0, errors.New("user_id missing in token")
 	}
-	return uint(uidFloat), nil
+	return claims.UserID, claims.TokenVersion, nil
 }
@@ -1,12 +1,18 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+	"github.com/MujiRahman/golang-simple-note/pkg/password"
 )
 
 type mockUserRepo struct {
@@ -19,7 +25,7 @@ func newMockUserRepo() *mockUserRepo {
 	return &mockUserRepo{byName: make(map[string]*model.User), byID: make(map[uint]*model.User), nextID: 1}
 }
 
-func (m *mockUserRepo) Create(user *model.User) error {
+func (m *mockUserRepo) Create(ctx context.Context, user *model.User) error {
 	if user.ID == 0 {
 		user.ID = m.nextID
 		m.nextID++
@@ -30,7 +36,7 @@ func (m *mockUserRepo) Create(user *model.User) error {
 	return nil
 }
 
-func (m *mockUserRepo) FindByUsername(username string) (*model.User, error) {
+func (m *mockUserRepo) FindByUsername(ctx context.Context, username string) (*model.User, error) {
 	u, ok := m.byName[username]
 	if !ok {
 		return nil, nil
@@ -38,7 +44,16 @@ func (m *mockUserRepo) FindByUsername(username string) (*model.User, error) {
 	return u, nil
 }
 
-func (m *mockUserRepo) FindByID(id uint) (*model.User, error) {
+func (m *mockUserRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range m.byID {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockUserRepo) FindByID(ctx context.Context, id uint) (*model.User, error) {
 	u, ok := m.byID[id]
 	if !ok {
 		return nil, nil
@@ -46,26 +61,83 @@ func (m *mockUserRepo) FindByID(id uint) (*model.User, error) {
 	return u, nil
 }
 
+func (m *mockUserRepo) Update(ctx context.Context, user *model.User) error {
+	m.byName[user.Username] = user
+	m.byID[user.ID] = user
+	return nil
+}
+
+func (m *mockUserRepo) RecordFailedLogin(ctx context.Context, id uint) (int, error) {
+	u := m.byID[id]
+	u.FailedLogins++
+	return u.FailedLogins, nil
+}
+
+func (m *mockUserRepo) LockUntil(ctx context.Context, id uint, failedLogins int, t time.Time) error {
+	if u := m.byID[id]; u.FailedLogins == failedLogins {
+		u.LockedUntil = &t
+	}
+	return nil
+}
+
+func (m *mockUserRepo) ClaimTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	u := m.byID[id]
+	if step <= u.TOTPLastStep {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+func (m *mockUserRepo) ReplaceRecoveryCodes(ctx context.Context, id uint, old, codes string) (bool, error) {
+	u := m.byID[id]
+	if u.RecoveryCodes != old {
+		return false, nil
+	}
+	u.RecoveryCodes = codes
+	return true, nil
+}
+
+func (m *mockUserRepo) ListDueForDeletion(ctx context.Context, t time.Time) ([]model.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserRepo) DeleteWithData(ctx context.Context, id uint) ([]string, error) {
+	if u, ok := m.byID[id]; ok {
+		delete(m.byName, u.Username)
+		delete(m.byID, id)
+	}
+	return nil, nil
+}
+
 func TestUserService_RegisterAndLogin_ParseToken(t *testing.T) {
 	repo := newMockUserRepo()
 	cfg := &config.Config{JWTSecret: "testsecret", TokenTTL: 3600}
-	svc := NewUserService(repo, cfg)
+	workspaces := newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	svc := NewUserService(repo, workspaces, audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
 
 	// Register
-	u, err := svc.Register("alice", "password123")
+	u, err := svc.Register(context.Background(), "Alice", "alice", "alice@example.com", "password123")
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
 	if u.Username != "alice" {
 		t.Fatalf("unexpected username: %v", u.Username)
 	}
-	// password should be hashed
-	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("password123")); err != nil {
-		t.Fatalf("stored password is not a valid bcrypt hash: %v", err)
+	if ws, _ := workspaces.FindPersonal(context.Background(), u.ID); ws == nil || ws.Name != model.PersonalWorkspaceName {
+		t.Fatalf("expected a personal workspace, got %+v", ws)
+	}
+	// password should be hashed, with argon2id by default
+	if !strings.HasPrefix(u.Password, "$argon2id$") {
+		t.Fatalf("stored password is not an argon2id hash: %q", u.Password)
+	}
+	if ok, err := password.NewArgon2id(password.Argon2Params{}).Verify(u.Password, "password123"); !ok || err != nil {
+		t.Fatalf("stored password does not verify: %v", err)
 	}
 
 	// Login
-	token, err := svc.Login("alice", "password123")
+	token, err := svc.Login(context.Background(), "alice", "password123")
 	if err != nil {
 		t.Fatalf("Login failed: %v", err)
 	}
@@ -74,7 +146,7 @@ func TestUserService_RegisterAndLogin_ParseToken(t *testing.T) {
 	}
 
 	// Parse token
-	uid, err := svc.ParseToken(token)
+	uid, err := svc.ParseToken(context.Background(), token)
 	if err != nil {
 		t.Fatalf("ParseToken failed: %v", err)
 	}
@@ -86,13 +158,14 @@ func TestUserService_RegisterAndLogin_ParseToken(t *testing.T) {
 func TestUserService_Register_Existing(t *testing.T) {
 	repo := newMockUserRepo()
 	cfg := &config.Config{JWTSecret: "s", TokenTTL: 3600}
-	svc := NewUserService(repo, cfg)
+	audit, _ := newMockAuditService()
+	svc := NewUserService(repo, newMockWorkspaceRepo(), audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
 
 	// Create existing user in repo
 	existing := &model.User{Username: "bob", Password: "x"}
-	repo.Create(existing)
+	repo.Create(context.Background(), existing)
 
-	_, err := svc.Register("bob", "pw")
+	_, err := svc.Register(context.Background(), "Bob", "bob", "bob@example.com", "pw")
 	if err == nil {
 		t.Fatalf("expected error when registering existing username")
 	}
@@ -101,15 +174,137 @@ func TestUserService_Register_Existing(t *testing.T) {
 func TestUserService_Login_InvalidPassword(t *testing.T) {
 	repo := newMockUserRepo()
 	cfg := &config.Config{JWTSecret: "s", TokenTTL: 3600}
-	svc := NewUserService(repo, cfg)
+	audit, events := newMockAuditService()
+	svc := NewUserService(repo, newMockWorkspaceRepo(), audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
 
 	// prepare user with hashed password
 	hashed, _ := bcrypt.GenerateFromPassword([]byte("rightpw"), bcrypt.DefaultCost)
 	user := &model.User{Username: "carol", Password: string(hashed)}
-	repo.Create(user)
+	repo.Create(context.Background(), user)
 
-	_, err := svc.Login("carol", "wrongpw")
+	_, err := svc.Login(context.Background(), "carol", "wrongpw")
 	if err == nil {
 		t.Fatalf("expected error for wrong password")
 	}
+	if _, err := svc.Login(context.Background(), "nobody", "pw"); err == nil {
+		t.Fatalf("expected error for an unknown user")
+	}
+
+	// both attempts are audited; only the first names an account
+	if len(events.events) != 2 || events.events[0].Action != model.AuditUserLoginFailed || events.events[0].TargetID != user.ID || events.events[1].TargetID != 0 {
+		t.Fatalf("unexpected audit events: %+v", events.events)
+	}
+}
+
+func TestUserService_Login_RehashesLegacyPassword(t *testing.T) {
+	repo := newMockUserRepo()
+	cfg := &config.Config{JWTSecret: "s", TokenTTL: 3600, PasswordHashAlgorithm: "argon2id", Argon2Memory: 1024, Argon2Iterations: 1}
+	audit, _ := newMockAuditService()
+	svc := NewUserService(repo, newMockWorkspaceRepo(), audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("rightpw"), bcrypt.MinCost)
+	user := &model.User{Username: "erin", Password: string(hashed)}
+	repo.Create(context.Background(), user)
+
+	if _, err := svc.Login(context.Background(), "erin", "wrongpw"); err == nil {
+		t.Fatalf("expected error for wrong password")
+	}
+	if user.Password != string(hashed) {
+		t.Fatalf("hash must not change on a failed login")
+	}
+	if _, err := svc.Login(context.Background(), "erin", "rightpw"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if !strings.HasPrefix(user.Password, "$argon2id$v=19$m=1024,t=1,") {
+		t.Fatalf("expected the bcrypt hash to be upgraded, got %q", user.Password)
+	}
+	// the upgraded hash keeps working
+	if _, err := svc.Login(context.Background(), "erin", "rightpw"); err != nil {
+		t.Fatalf("Login after rehash failed: %v", err)
+	}
+}
+
+func TestUserService_Register_PasswordPolicy(t *testing.T) {
+	repo := newMockUserRepo()
+	cfg := &config.Config{JWTSecret: "s", TokenTTL: 3600, PasswordMinLength: 8, PasswordCheckBreached: true}
+	audit, _ := newMockAuditService()
+	svc := NewUserService(repo, newMockWorkspaceRepo(), audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
+
+	for _, pw := range []string{"short", "password123", "Password123"} {
+		if _, err := svc.Register(context.Background(), "", "frank", "frank@example.com", pw); err == nil {
+			t.Fatalf("expected %q to be rejected", pw)
+		}
+	}
+	if _, err := svc.Register(context.Background(), "", "frank", "frank@example.com", "correct horse battery"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+}
+
+func TestUserService_Register_BcryptLength(t *testing.T) {
+	repo := newMockUserRepo()
+	cfg := &config.Config{JWTSecret: "s", TokenTTL: 3600, PasswordHashAlgorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost}
+	audit, _ := newMockAuditService()
+	svc := NewUserService(repo, newMockWorkspaceRepo(), audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
+
+	// bcrypt fails on more than 72 bytes; that is a bad request, not a failed hash
+	_, err := svc.Register(context.Background(), "", "gina", "gina@example.com", strings.Repeat("ü", 40))
+	if err == nil || !strings.Contains(err.Error(), "at most 72 bytes") {
+		t.Fatalf("expected a too long password to be refused, got %v", err)
+	}
+	if _, err := svc.Register(context.Background(), "", "gina", "gina@example.com", strings.Repeat("ü", 36)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+}
+
+func TestUserService_Login_ProgressiveLockout(t *testing.T) {
+	repo := newMockUserRepo()
+	cfg := &config.Config{JWTSecret: "s", TokenTTL: 3600, LoginLockoutThreshold: 3, LoginLockoutBase: time.Minute, LoginLockoutMax: time.Hour}
+	audit, _ := newMockAuditService()
+	svc := NewUserService(repo, newMockWorkspaceRepo(), audit, &mockUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("rightpw"), bcrypt.MinCost)
+	user := &model.User{Username: "dave", Password: string(hashed)}
+	repo.Create(context.Background(), user)
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Login(context.Background(), "dave", "wrongpw"); err == nil {
+			t.Fatalf("expected error for wrong password")
+		}
+	}
+	if user.LockedUntil == nil || time.Until(*user.LockedUntil) > time.Minute {
+		t.Fatalf("expected a one minute lock after threshold, got %v", user.LockedUntil)
+	}
+
+	// even the right password is rejected while locked
+	var locked *AccountLockedError
+	if _, err := svc.Login(context.Background(), "dave", "rightpw"); !errors.As(err, &locked) {
+		t.Fatalf("expected AccountLockedError, got %v", err)
+	}
+
+	// the next failure after the lock expires doubles the lock
+	past := time.Now().Add(-time.Second)
+	user.LockedUntil = &past
+	svc.Login(context.Background(), "dave", "wrongpw")
+	if d := time.Until(*user.LockedUntil); d <= time.Minute || d > 2*time.Minute {
+		t.Fatalf("expected lock to double to two minutes, got %v", d)
+	}
+
+	// a successful login resets the counter
+	user.LockedUntil = &past
+	if _, err := svc.Login(context.Background(), "dave", "rightpw"); err != nil {
+		t.Fatalf("login after lock expiry failed: %v", err)
+	}
+	if user.FailedLogins != 0 || user.LockedUntil != nil {
+		t.Fatalf("expected lockout state to reset, got %d %v", user.FailedLogins, user.LockedUntil)
+	}
+}
+
+// hmacKeys returns a key set signing with secret, as configured by JWT_SECRET.
+func hmacKeys(t *testing.T, secret string) *jwtkeys.KeySet {
+	t.Helper()
+	keys, err := jwtkeys.NewKeySet(jwtkeys.NewHMACKey([]byte(secret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
 }
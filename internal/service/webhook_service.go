@@ -0,0 +1,511 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/pkg/webhook"
+)
+
+const (
+	defaultWebhookTimeout      = 10 * time.Second
+	defaultWebhookMaxAttempts  = 10
+	defaultWebhookRetryBase    = 30 * time.Second
+	defaultWebhookDisableAfter = 20
+
+	// webhookBatchSize is how many due deliveries one DeliverDue run sends.
+	webhookBatchSize = 50
+	// webhookResponseLimit is how much of a receiver's response is kept.
+	webhookResponseLimit = 1024
+	webhookUserAgent     = "simple-note-webhooks/1"
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrDeliveryNotFound    = errors.New("delivery not found")
+	ErrWebhookDisabled     = errors.New("webhook is disabled")
+	ErrInvalidWebhookURL   = errors.New("url must be an absolute http or https URL")
+	ErrInvalidWebhookEvent = errors.New("unknown event")
+	ErrNoWebhookEvents     = errors.New("at least one event is required")
+
+	errPrivateAddress = errors.New("receiver address is not public")
+)
+
+// WebhookUpdate holds the fields of a webhook to change; nil fields are kept.
+type WebhookUpdate struct {
+	URL    *string
+	Events []string
+	// Enabled re-enables a disabled webhook, resetting its failure count, or
+	// disables it.
+	Enabled *bool
+}
+
+// DeliveryPage is one page of WebhookService.Deliveries.
+type DeliveryPage struct {
+	Deliveries []model.WebhookDelivery
+	Total      int64
+	Page       int
+	PerPage    int
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks.
+type WebhookPayload struct {
+	ID          string    `json:"id"` // event ID, also sent as X-Webhook-Delivery
+	Event       string    `json:"event"`
+	CreatedAt   time.Time `json:"created_at"`
+	WorkspaceID uint      `json:"workspace_id"`
+	Data        any       `json:"data"`
+}
+
+// WebhookService manages the webhooks of a user and delivers events to them.
+// Events are queued in the database and sent by DeliverDue, which the server
+// runs periodically; failed attempts are retried with exponential backoff.
+type WebhookService interface {
+	// Create registers a webhook with a new random secret, returned in
+	// Secret only this once.
+	Create(ctx context.Context, userID uint, rawURL string, events []string) (*model.Webhook, error)
+	List(ctx context.Context, userID uint) ([]model.Webhook, error)
+	Get(ctx context.Context, userID, id uint) (*model.Webhook, error)
+	Update(ctx context.Context, userID, id uint, upd WebhookUpdate) (*model.Webhook, error)
+	Delete(ctx context.Context, userID, id uint) error
+	// Deliveries returns the webhook's delivery log, newest first.
+	Deliveries(ctx context.Context, userID, id uint, page, perPage int) (*DeliveryPage, error)
+	// Redeliver queues the event of a past delivery again.
+	Redeliver(ctx context.Context, userID, id, deliveryID uint) (*model.WebhookDelivery, error)
+
+	// Publish queues event for the webhooks of the workspace's members that
+	// subscribe to it. Called within a transaction, the deliveries are only
+	// queued if it commits.
+	Publish(ctx context.Context, workspaceID uint, event string, data any) error
+	// HandleEvent publishes a note event of the EventBus to the webhooks,
+	// as an EventHandler.
+	HandleEvent(ctx context.Context, e Event) error
+	// DeliverDue sends the deliveries that are due and returns how many were
+	// attempted.
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+type webhookService struct {
+	repo         repository.WebhookRepository
+	client       *http.Client
+	maxAttempts  int
+	retryBase    time.Duration
+	disableAfter int
+}
+
+func NewWebhookService(repo repository.WebhookRepository, cfg *config.Config) WebhookService {
+	s := &webhookService{
+		repo:         repo,
+		maxAttempts:  cfg.WebhookMaxAttempts,
+		retryBase:    cfg.WebhookRetryBase,
+		disableAfter: cfg.WebhookDisableAfter,
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultWebhookMaxAttempts
+	}
+	if s.retryBase <= 0 {
+		s.retryBase = defaultWebhookRetryBase
+	}
+	if s.disableAfter <= 0 {
+		s.disableAfter = defaultWebhookDisableAfter
+	}
+	timeout := cfg.WebhookTimeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	s.client = newWebhookClient(timeout, cfg.WebhookAllowPrivateNetworks)
+	return s
+}
+
+// newWebhookClient returns a client that does not follow redirects and,
+// unless allowPrivate, refuses to connect to loopback, private and
+// link-local addresses, so webhooks cannot be pointed at internal services.
+func newWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // connect directly, so the address check sees the receiver
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (s *webhookService) Create(ctx context.Context, userID uint, rawURL string, events []string) (_ *model.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Create", attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	rawURL, err = validWebhookURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	list, err := validWebhookEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	w := &model.Webhook{UserID: userID, URL: rawURL, Secret: secret, Events: list}
+	if err := s.repo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *webhookService) List(ctx context.Context, userID uint) ([]model.Webhook, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *webhookService) Get(ctx context.Context, userID, id uint) (*model.Webhook, error) {
+	w, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil || w.UserID != userID {
+		return nil, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+func (s *webhookService) Update(ctx context.Context, userID, id uint, upd WebhookUpdate) (_ *model.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Update", attribute.Int("user.id", int(userID)), attribute.Int("webhook.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+
+	w, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if upd.URL != nil {
+		if w.URL, err = validWebhookURL(*upd.URL); err != nil {
+			return nil, err
+		}
+	}
+	if upd.Events != nil {
+		if w.Events, err = validWebhookEvents(upd.Events); err != nil {
+			return nil, err
+		}
+	}
+	if upd.Enabled != nil {
+		switch {
+		case *upd.Enabled && w.DisabledAt != nil:
+			w.DisabledAt = nil
+			w.ConsecutiveFailures = 0
+		case !*upd.Enabled && w.DisabledAt == nil:
+			now := time.Now()
+			w.DisabledAt = &now
+		}
+	}
+	if err := s.repo.Update(ctx, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, userID, id uint) error {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *webhookService) Deliveries(ctx context.Context, userID, id uint, page, perPage int) (*DeliveryPage, error) {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	page, perPage = normalizePage(page, perPage)
+	deliveries, total, err := s.repo.ListDeliveries(ctx, id, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryPage{Deliveries: deliveries, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+func (s *webhookService) Redeliver(ctx context.Context, userID, id, deliveryID uint) (_ *model.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Redeliver", attribute.Int("webhook.id", int(id)), attribute.Int("delivery.id", int(deliveryID)))
+	defer func() { tracing.End(span, err) }()
+
+	w, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w.DisabledAt != nil {
+		return nil, ErrWebhookDisabled
+	}
+	d, err := s.repo.FindDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.WebhookID != w.ID {
+		return nil, ErrDeliveryNotFound
+	}
+	now := time.Now()
+	again := &model.WebhookDelivery{
+		WebhookID:     w.ID,
+		EventID:       d.EventID,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		Status:        model.DeliveryPending,
+		NextAttemptAt: &now,
+		TraceParent:   tracing.TraceParent(ctx),
+	}
+	if err := s.repo.CreateDelivery(ctx, again); err != nil {
+		return nil, err
+	}
+	return again, nil
+}
+
+func (s *webhookService) Publish(ctx context.Context, workspaceID uint, event string, data any) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Publish", attribute.String("webhook.event", event), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	hooks, err := s.repo.ListSubscribers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	// every subscriber gets the same event, encoded once
+	var eventID string
+	var payload []byte
+	now := time.Now()
+	traceParent := tracing.TraceParent(ctx)
+	for _, w := range hooks {
+		if !w.Subscribes(event) {
+			continue
+		}
+		if payload == nil {
+			if eventID, err = randomHex(16); err != nil {
+				return err
+			}
+			if payload, err = json.Marshal(WebhookPayload{ID: eventID, Event: event, CreatedAt: now.UTC(), WorkspaceID: workspaceID, Data: data}); err != nil {
+				return err
+			}
+		}
+		d := &model.WebhookDelivery{
+			WebhookID:     w.ID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        model.DeliveryPending,
+			NextAttemptAt: &now,
+			TraceParent:   traceParent,
+		}
+		if err := s.repo.CreateDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) HandleEvent(ctx context.Context, e Event) error {
+	var payload struct {
+		Note struct {
+			WorkspaceID uint `json:"workspace_id"`
+		} `json:"note"`
+	}
+	if err := json.Unmarshal(e.Data, &payload); err != nil {
+		return err
+	}
+	return s.Publish(ctx, payload.Note.WorkspaceID, e.Topic, e.Data)
+}
+
+func (s *webhookService) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := s.repo.ListDue(ctx, now, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	// a claim outlasts the request, so a delivery is not sent twice at once
+	lease := now.Add(s.client.Timeout + time.Minute)
+	sent := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		d := &due[i]
+		ok, err := s.repo.Claim(ctx, d.ID, now, lease)
+		if err != nil {
+			return sent, err
+		}
+		if !ok {
+			continue
+		}
+		if err := s.deliver(ctx, d); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// deliver makes one attempt at d and records its outcome, in the trace of
+// the change that queued it.
+func (s *webhookService) deliver(ctx context.Context, d *model.WebhookDelivery) (err error) {
+	ctx, span := tracing.Start(tracing.WithTraceParent(ctx, d.TraceParent), "WebhookService.deliver", attribute.Int("delivery.id", int(d.ID)), attribute.String("webhook.event", d.Event))
+	defer func() { tracing.End(span, err) }()
+
+	w, err := s.repo.FindByID(ctx, d.WebhookID)
+	if err != nil {
+		return err
+	}
+	if w == nil || w.DisabledAt != nil {
+		d.Status = model.DeliveryFailed
+		d.NextAttemptAt = nil
+		d.Error = ErrWebhookDisabled.Error()
+		return s.repo.UpdateDelivery(ctx, d)
+	}
+
+	start := time.Now()
+	code, body, sendErr := s.send(ctx, w, d)
+	d.Attempts++
+	d.DurationMS = time.Since(start).Milliseconds()
+	d.ResponseCode = code
+	d.ResponseBody = body
+	d.Error = ""
+	if sendErr == nil && code >= 200 && code < 300 {
+		d.Status = model.DeliverySucceeded
+		d.NextAttemptAt = nil
+		if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+			return err
+		}
+		if w.ConsecutiveFailures == 0 {
+			return nil
+		}
+		return s.repo.ResetFailures(ctx, w.ID)
+	}
+
+	if sendErr != nil {
+		d.Error = truncate(sendErr.Error(), 255)
+	} else {
+		d.Error = fmt.Sprintf("receiver answered %d", code)
+	}
+	if d.Attempts >= s.maxAttempts {
+		d.Status = model.DeliveryFailed
+		d.NextAttemptAt = nil
+	} else {
+		next := time.Now().Add(s.backoff(d.Attempts))
+		d.NextAttemptAt = &next
+	}
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+		return err
+	}
+	disabled, err := s.repo.RecordFailure(ctx, w.ID, s.disableAfter, time.Now())
+	if disabled {
+		log.Printf("webhook %d disabled after %d consecutive failures", w.ID, s.disableAfter)
+	}
+	return err
+}
+
+// send POSTs the delivery's payload, signed with the webhook secret, and
+// returns the response status and the start of its body.
+func (s *webhookService) send(ctx context.Context, w *model.Webhook, d *model.WebhookDelivery) (int, string, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(webhook.HeaderEvent, d.Event)
+	req.Header.Set(webhook.HeaderDelivery, d.EventID)
+	req.Header.Set(webhook.HeaderTimestamp, fmt.Sprint(now.Unix()))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(w.Secret, now, body))
+	tracing.Inject(ctx, req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // lets the connection be reused
+	return resp.StatusCode, string(b), nil
+}
+
+// backoff returns the wait after the attempts-th failed attempt: the retry
+// base, doubled for every attempt after the first.
+func (s *webhookService) backoff(attempts int) time.Duration {
+	d := s.retryBase
+	for i := 1; i < attempts && d < 24*time.Hour; i++ {
+		d *= 2
+	}
+	return d
+}
+
+func validWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(raw) > 2048 {
+		return "", ErrInvalidWebhookURL
+	}
+	return raw, nil
+}
+
+// validWebhookEvents checks events and returns them space separated, as
+// stored.
+func validWebhookEvents(events []string) (string, error) {
+	if len(events) == 0 {
+		return "", ErrNoWebhookEvents
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range events {
+		known := false
+		for _, k := range model.WebhookEvents {
+			known = known || e == k
+		}
+		if !known {
+			return "", fmt.Errorf("%w %q", ErrInvalidWebhookEvent, e)
+		}
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return strings.Join(out, " "), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
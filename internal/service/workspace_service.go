@@ -0,0 +1,464 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+)
+
+var (
+	// ErrWorkspaceNotFound is also returned to non-members, so they cannot
+	// probe which workspaces exist.
+	ErrWorkspaceNotFound    = errors.New("workspace not found")
+	ErrWorkspaceForbidden   = errors.New("your workspace role does not allow this")
+	ErrPersonalWorkspace    = errors.New("a personal workspace cannot be shared, left or deleted")
+	ErrInvalidWorkspaceRole = errors.New("role must be one of owner, admin, member, guest")
+	ErrLastOwner            = errors.New("a workspace needs at least one owner")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrAlreadyMember        = errors.New("user is already a member of this workspace")
+	ErrAlreadyInvited       = errors.New("user is already invited to this workspace")
+	ErrInviteeNotFound      = errors.New("no user with this username or email")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+)
+
+// WorkspaceService manages workspaces, their members and invitations, and
+// decides what a user may do in a workspace.
+type WorkspaceService interface {
+	Create(ctx context.Context, userID uint, name string) (*model.Workspace, error)
+	// List returns the caller's workspaces, personal first, with their role.
+	List(ctx context.Context, userID uint) ([]model.Workspace, error)
+	Get(ctx context.Context, userID, workspaceID uint) (*model.Workspace, error)
+	Rename(ctx context.Context, userID, workspaceID uint, name string) (*model.Workspace, error)
+	// Delete removes a shared workspace together with its notes. Those get
+	// no note.deleted events or webhooks of their own: the workspace.deleted
+	// event and the audit record stand for them, and no member is left to
+	// send webhooks to.
+	Delete(ctx context.Context, userID, workspaceID uint) error
+
+	Members(ctx context.Context, userID, workspaceID uint) ([]model.WorkspaceMember, error)
+	UpdateMemberRole(ctx context.Context, userID, workspaceID, memberID uint, role model.WorkspaceRole) (*model.WorkspaceMember, error)
+	// RemoveMember removes memberID from the workspace; with memberID ==
+	// userID the caller leaves it.
+	RemoveMember(ctx context.Context, userID, workspaceID, memberID uint) error
+
+	// Invite offers the user with the given username or email address a
+	// membership with role.
+	Invite(ctx context.Context, userID, workspaceID uint, invitee string, role model.WorkspaceRole) (*model.WorkspaceInvitation, error)
+	// PendingInvitations lists the open invitations of a workspace.
+	PendingInvitations(ctx context.Context, userID, workspaceID uint) ([]model.WorkspaceInvitation, error)
+	RevokeInvitation(ctx context.Context, userID, workspaceID, invitationID uint) error
+	// Invitations lists the invitations addressed to the caller.
+	Invitations(ctx context.Context, userID uint) ([]model.WorkspaceInvitation, error)
+	AcceptInvitation(ctx context.Context, userID, invitationID uint) (*model.Workspace, error)
+	DeclineInvitation(ctx context.Context, userID, invitationID uint) error
+
+	// Authorize returns the workspace with the caller's role if that role
+	// grants perm. A workspaceID of 0 selects the caller's personal workspace.
+	Authorize(ctx context.Context, userID, workspaceID uint, perm model.WorkspacePermission) (*model.Workspace, error)
+}
+
+type workspaceService struct {
+	repo   repository.WorkspaceRepository
+	users  repository.UserRepository
+	audit  AuditService
+	events UnitOfWork
+}
+
+func NewWorkspaceService(repo repository.WorkspaceRepository, users repository.UserRepository, audit AuditService, events UnitOfWork) WorkspaceService {
+	return &workspaceService{repo: repo, users: users, audit: audit, events: events}
+}
+
+func (s *workspaceService) Create(ctx context.Context, userID uint, name string) (_ *model.Workspace, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Create", attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	if name, err = workspaceName(name); err != nil {
+		return nil, err
+	}
+	ws := &model.Workspace{Name: name, CreatedBy: userID}
+	if err := s.repo.CreateWithOwner(ctx, ws, userID); err != nil {
+		return nil, err
+	}
+	ws.Role = model.WorkspaceRoleOwner
+	return ws, nil
+}
+
+func (s *workspaceService) List(ctx context.Context, userID uint) (_ []model.Workspace, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.List", attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.personal(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *workspaceService) Get(ctx context.Context, userID, workspaceID uint) (_ *model.Workspace, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Get", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.Authorize(ctx, userID, workspaceID, model.PermNotesRead)
+}
+
+func (s *workspaceService) Rename(ctx context.Context, userID, workspaceID uint, name string) (_ *model.Workspace, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Rename", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	if name, err = workspaceName(name); err != nil {
+		return nil, err
+	}
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermWorkspaceUpdate)
+	if err != nil {
+		return nil, err
+	}
+	ws.Name = name
+	if err := s.repo.Update(ctx, ws); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+func (s *workspaceService) Delete(ctx context.Context, userID, workspaceID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Delete", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermWorkspaceDelete)
+	if err != nil {
+		return err
+	}
+	if ws.Personal {
+		return ErrPersonalWorkspace
+	}
+	event := &model.AuditEvent{
+		ActorID: userID, Action: model.AuditWorkspaceDelete, TargetType: model.AuditTargetWorkspace, TargetID: ws.ID,
+		Before: model.AuditSummary{"name": ws.Name},
+	}
+	return s.audit.Record(ctx, event, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, ws.ID); err != nil {
+			return err
+		}
+		return s.events.Emit(ctx, model.EventWorkspaceDeleted, model.AggregateWorkspace, ws.ID, workspaceEventData(userID, ws))
+	})
+}
+
+// workspaceEventData is the data of the workspace.deleted event: the
+// workspace and who deleted it.
+func workspaceEventData(actorID uint, ws *model.Workspace) any {
+	return struct {
+		ActorID   uint            `json:"actor_id"`
+		Workspace model.Workspace `json:"workspace"`
+	}{actorID, *ws}
+}
+
+func (s *workspaceService) Members(ctx context.Context, userID, workspaceID uint) (_ []model.WorkspaceMember, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Members", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermNotesRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, ws.ID)
+}
+
+func (s *workspaceService) UpdateMemberRole(ctx context.Context, userID, workspaceID, memberID uint, role model.WorkspaceRole) (_ *model.WorkspaceMember, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.UpdateMemberRole", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	if !role.Valid() {
+		return nil, ErrInvalidWorkspaceRole
+	}
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermMembersManage)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.repo.FindMember(ctx, ws.ID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMemberNotFound
+	}
+	if !canAssign(ws.Role, m.Role) || !canAssign(ws.Role, role) {
+		return nil, ErrWorkspaceForbidden
+	}
+	if m.Role == model.WorkspaceRoleOwner && role != model.WorkspaceRoleOwner {
+		if err := s.keepAnOwner(ctx, ws.ID); err != nil {
+			return nil, err
+		}
+	}
+	m.Role = role
+	if err := s.repo.UpdateMember(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *workspaceService) RemoveMember(ctx context.Context, userID, workspaceID, memberID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.RemoveMember", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	perm := model.PermMembersManage
+	if memberID == userID {
+		perm = model.PermNotesRead // anyone may leave
+	}
+	ws, err := s.Authorize(ctx, userID, workspaceID, perm)
+	if err != nil {
+		return err
+	}
+	if ws.Personal {
+		return ErrPersonalWorkspace
+	}
+	m, err := s.repo.FindMember(ctx, ws.ID, memberID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return ErrMemberNotFound
+	}
+	if memberID != userID && !canAssign(ws.Role, m.Role) {
+		return ErrWorkspaceForbidden
+	}
+	if m.Role == model.WorkspaceRoleOwner {
+		if err := s.keepAnOwner(ctx, ws.ID); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveMember(ctx, ws.ID, memberID)
+}
+
+func (s *workspaceService) Invite(ctx context.Context, userID, workspaceID uint, invitee string, role model.WorkspaceRole) (_ *model.WorkspaceInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Invite", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	if !role.Valid() {
+		return nil, ErrInvalidWorkspaceRole
+	}
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermMembersManage)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Personal {
+		return nil, ErrPersonalWorkspace
+	}
+	if !canAssign(ws.Role, role) {
+		return nil, ErrWorkspaceForbidden
+	}
+	u, err := s.findInvitee(ctx, invitee)
+	if err != nil {
+		return nil, err
+	}
+	if m, err := s.repo.FindMember(ctx, ws.ID, u.ID); err != nil {
+		return nil, err
+	} else if m != nil {
+		return nil, ErrAlreadyMember
+	}
+	if inv, err := s.repo.FindInvitationFor(ctx, ws.ID, u.ID); err != nil {
+		return nil, err
+	} else if inv != nil {
+		return nil, ErrAlreadyInvited
+	}
+	inv := &model.WorkspaceInvitation{WorkspaceID: ws.ID, UserID: u.ID, InvitedBy: userID, Role: role}
+	if err := s.repo.CreateInvitation(ctx, inv); err != nil {
+		return nil, err
+	}
+	inv.WorkspaceName = ws.Name
+	return inv, nil
+}
+
+// findInvitee looks invitee up by email address if it contains an "@" and
+// by username otherwise.
+func (s *workspaceService) findInvitee(ctx context.Context, invitee string) (*model.User, error) {
+	invitee = strings.TrimSpace(invitee)
+	var (
+		u   *model.User
+		err error
+	)
+	if strings.Contains(invitee, "@") {
+		email, nerr := normalizeEmail(invitee)
+		if nerr != nil {
+			return nil, nerr
+		}
+		u, err = s.users.FindByEmail(ctx, email)
+	} else if invitee != "" {
+		u, err = s.users.FindByUsername(ctx, invitee)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.DeletionScheduledAt != nil {
+		return nil, ErrInviteeNotFound
+	}
+	return u, nil
+}
+
+func (s *workspaceService) PendingInvitations(ctx context.Context, userID, workspaceID uint) (_ []model.WorkspaceInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.PendingInvitations", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermMembersManage)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListInvitationsByWorkspace(ctx, ws.ID)
+}
+
+func (s *workspaceService) RevokeInvitation(ctx context.Context, userID, workspaceID, invitationID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.RevokeInvitation", attribute.Int("user.id", int(userID)), attribute.Int("workspace.id", int(workspaceID)))
+	defer func() { tracing.End(span, err) }()
+
+	ws, err := s.Authorize(ctx, userID, workspaceID, model.PermMembersManage)
+	if err != nil {
+		return err
+	}
+	inv, err := s.repo.FindInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if inv == nil || inv.WorkspaceID != ws.ID {
+		return ErrInvitationNotFound
+	}
+	return s.repo.DeleteInvitation(ctx, inv.ID)
+}
+
+func (s *workspaceService) Invitations(ctx context.Context, userID uint) (_ []model.WorkspaceInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Invitations", attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.ListInvitationsByUser(ctx, userID)
+}
+
+func (s *workspaceService) AcceptInvitation(ctx context.Context, userID, invitationID uint) (_ *model.Workspace, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.AcceptInvitation", attribute.Int("user.id", int(userID)), attribute.Int("invitation.id", int(invitationID)))
+	defer func() { tracing.End(span, err) }()
+
+	inv, err := s.invitationFor(ctx, userID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := s.repo.FindByID(ctx, inv.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if ws == nil {
+		return nil, ErrInvitationNotFound
+	}
+	m, err := s.repo.AcceptInvitation(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	ws.Role = m.Role
+	return ws, nil
+}
+
+func (s *workspaceService) DeclineInvitation(ctx context.Context, userID, invitationID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.DeclineInvitation", attribute.Int("user.id", int(userID)), attribute.Int("invitation.id", int(invitationID)))
+	defer func() { tracing.End(span, err) }()
+
+	inv, err := s.invitationFor(ctx, userID, invitationID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteInvitation(ctx, inv.ID)
+}
+
+// invitationFor returns the invitation if it is addressed to userID.
+func (s *workspaceService) invitationFor(ctx context.Context, userID, invitationID uint) (*model.WorkspaceInvitation, error) {
+	inv, err := s.repo.FindInvitation(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.UserID != userID {
+		return nil, ErrInvitationNotFound
+	}
+	return inv, nil
+}
+
+func (s *workspaceService) Authorize(ctx context.Context, userID, workspaceID uint, perm model.WorkspacePermission) (*model.Workspace, error) {
+	if workspaceID == 0 {
+		// the personal workspace always has its user as owner
+		ws, err := s.personal(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		ws.Role = model.WorkspaceRoleOwner
+		return ws, nil
+	}
+	m, err := s.repo.FindMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrWorkspaceNotFound
+	}
+	ws, err := s.repo.FindByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if ws == nil {
+		return nil, ErrWorkspaceNotFound
+	}
+	ws.Role = m.Role
+	if !ws.Role.Can(perm) {
+		return nil, ErrWorkspaceForbidden
+	}
+	return ws, nil
+}
+
+// personal returns the personal workspace of userID, creating it for
+// accounts that do not have one yet. Of concurrent requests only one
+// creates it; the others read the one it created.
+func (s *workspaceService) personal(ctx context.Context, userID uint) (*model.Workspace, error) {
+	ws, err := s.repo.FindPersonal(ctx, userID)
+	if err != nil || ws != nil {
+		return ws, err
+	}
+	ws = model.NewPersonalWorkspace(userID)
+	created, err := s.repo.CreatePersonal(ctx, ws, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return s.repo.FindPersonal(ctx, userID)
+	}
+	return ws, nil
+}
+
+// keepAnOwner fails unless the workspace has another owner besides the one
+// about to be demoted or removed.
+func (s *workspaceService) keepAnOwner(ctx context.Context, workspaceID uint) error {
+	n, err := s.repo.CountOwners(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// canAssign reports whether a member with role actor may grant or take away
+// role: admins manage members and guests, only owners manage admins and owners.
+func canAssign(actor, role model.WorkspaceRole) bool {
+	if role.AtLeast(model.WorkspaceRoleAdmin) {
+		return actor == model.WorkspaceRoleOwner
+	}
+	return actor.Can(model.PermMembersManage)
+}
+
+func workspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("workspace name is required")
+	}
+	if len(name) > 100 {
+		return "", errors.New("workspace name is too long")
+	}
+	return name, nil
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+)
+
+// mockWorkspaceRepo keeps workspaces and members in memory. Methods that are
+// not implemented panic through the nil embedded interface.
+type mockWorkspaceRepo struct {
+	repository.WorkspaceRepository
+	workspaces map[uint]*model.Workspace
+	members    []*model.WorkspaceMember
+	nextID     uint
+}
+
+func newMockWorkspaceRepo() *mockWorkspaceRepo {
+	return &mockWorkspaceRepo{workspaces: make(map[uint]*model.Workspace), nextID: 1}
+}
+
+func (m *mockWorkspaceRepo) CreateWithOwner(ctx context.Context, ws *model.Workspace, ownerID uint) error {
+	ws.ID = m.nextID
+	m.nextID++
+	m.workspaces[ws.ID] = ws
+	m.members = append(m.members, &model.WorkspaceMember{ID: uint(len(m.members) + 1), WorkspaceID: ws.ID, UserID: ownerID, Role: model.WorkspaceRoleOwner})
+	return nil
+}
+
+func (m *mockWorkspaceRepo) FindByID(ctx context.Context, id uint) (*model.Workspace, error) {
+	ws, ok := m.workspaces[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *ws
+	return &cp, nil
+}
+
+func (m *mockWorkspaceRepo) FindPersonal(ctx context.Context, userID uint) (*model.Workspace, error) {
+	for id := uint(1); id < m.nextID; id++ {
+		if ws, ok := m.workspaces[id]; ok && ws.Personal && ws.CreatedBy == userID {
+			cp := *ws
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockWorkspaceRepo) CreatePersonal(ctx context.Context, ws *model.Workspace, ownerID uint) (bool, error) {
+	if existing, _ := m.FindPersonal(ctx, ownerID); existing != nil {
+		return false, nil
+	}
+	return true, m.CreateWithOwner(ctx, ws, ownerID)
+}
+
+func (m *mockWorkspaceRepo) Delete(ctx context.Context, id uint) error {
+	delete(m.workspaces, id)
+	kept := m.members[:0]
+	for _, mem := range m.members {
+		if mem.WorkspaceID != id {
+			kept = append(kept, mem)
+		}
+	}
+	m.members = kept
+	return nil
+}
+
+func (m *mockWorkspaceRepo) FindMember(ctx context.Context, workspaceID, userID uint) (*model.WorkspaceMember, error) {
+	for _, mem := range m.members {
+		if mem.WorkspaceID == workspaceID && mem.UserID == userID {
+			cp := *mem
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockWorkspaceRepo) ListMembers(ctx context.Context, workspaceID uint) ([]model.WorkspaceMember, error) {
+	var out []model.WorkspaceMember
+	for _, mem := range m.members {
+		if mem.WorkspaceID == workspaceID {
+			out = append(out, *mem)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockWorkspaceRepo) UpdateMember(ctx context.Context, upd *model.WorkspaceMember) error {
+	for _, mem := range m.members {
+		if mem.ID == upd.ID {
+			mem.Role = upd.Role
+		}
+	}
+	return nil
+}
+
+func (m *mockWorkspaceRepo) RemoveMember(ctx context.Context, workspaceID, userID uint) error {
+	kept := m.members[:0]
+	for _, mem := range m.members {
+		if mem.WorkspaceID != workspaceID || mem.UserID != userID {
+			kept = append(kept, mem)
+		}
+	}
+	m.members = kept
+	return nil
+}
+
+func (m *mockWorkspaceRepo) CountOwners(ctx context.Context, workspaceID uint) (int64, error) {
+	var n int64
+	for _, mem := range m.members {
+		if mem.WorkspaceID == workspaceID && mem.Role == model.WorkspaceRoleOwner {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// addMember joins userID to the workspace with role, as an accepted invitation would.
+func (m *mockWorkspaceRepo) addMember(workspaceID, userID uint, role model.WorkspaceRole) {
+	m.members = append(m.members, &model.WorkspaceMember{ID: uint(len(m.members) + 1), WorkspaceID: workspaceID, UserID: userID, Role: role})
+}
+
+func TestWorkspaceService_Authorize(t *testing.T) {
+	repo := newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	svc := NewWorkspaceService(repo, newMockUserRepo(), audit, &mockUnitOfWork{})
+	ctx := context.Background()
+
+	// the personal workspace is created on first use
+	personal, err := svc.Authorize(ctx, 1, 0, model.PermWorkspaceUpdate)
+	if err != nil || !personal.Personal || personal.Role != model.WorkspaceRoleOwner {
+		t.Fatalf("personal workspace: %+v, %v", personal, err)
+	}
+	if again, _ := svc.Authorize(ctx, 1, 0, model.PermNotesRead); again.ID != personal.ID {
+		t.Fatalf("expected the same personal workspace, got %d and %d", personal.ID, again.ID)
+	}
+
+	team, _ := svc.Create(ctx, 1, "Team")
+	repo.addMember(team.ID, 2, model.WorkspaceRoleGuest)
+
+	if _, err := svc.Authorize(ctx, 2, team.ID, model.PermNotesRead); err != nil {
+		t.Fatalf("guest read: %v", err)
+	}
+	if _, err := svc.Authorize(ctx, 2, team.ID, model.PermNotesWrite); !errors.Is(err, ErrWorkspaceForbidden) {
+		t.Fatalf("expected ErrWorkspaceForbidden for a guest writing, got %v", err)
+	}
+	if _, err := svc.Authorize(ctx, 3, team.ID, model.PermNotesRead); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Fatalf("expected ErrWorkspaceNotFound for a non-member, got %v", err)
+	}
+}
+
+func TestWorkspaceService_RoleChanges(t *testing.T) {
+	repo := newMockWorkspaceRepo()
+	audit, _ := newMockAuditService()
+	svc := NewWorkspaceService(repo, newMockUserRepo(), audit, &mockUnitOfWork{})
+	ctx := context.Background()
+
+	team, _ := svc.Create(ctx, 1, "Team")
+	repo.addMember(team.ID, 2, model.WorkspaceRoleAdmin)
+	repo.addMember(team.ID, 3, model.WorkspaceRoleMember)
+
+	// admins manage members and guests, but cannot hand out admin or owner
+	if m, err := svc.UpdateMemberRole(ctx, 2, team.ID, 3, model.WorkspaceRoleGuest); err != nil || m.Role != model.WorkspaceRoleGuest {
+		t.Fatalf("admin demoting a member: %+v, %v", m, err)
+	}
+	if _, err := svc.UpdateMemberRole(ctx, 2, team.ID, 3, model.WorkspaceRoleAdmin); !errors.Is(err, ErrWorkspaceForbidden) {
+		t.Fatalf("expected ErrWorkspaceForbidden for an admin promoting to admin, got %v", err)
+	}
+	if err := svc.RemoveMember(ctx, 2, team.ID, 1); !errors.Is(err, ErrWorkspaceForbidden) {
+		t.Fatalf("expected ErrWorkspaceForbidden for an admin removing the owner, got %v", err)
+	}
+	if _, err := svc.UpdateMemberRole(ctx, 1, team.ID, 3, "superuser"); !errors.Is(err, ErrInvalidWorkspaceRole) {
+		t.Fatalf("expected ErrInvalidWorkspaceRole, got %v", err)
+	}
+
+	// the last owner can neither step down nor leave
+	if _, err := svc.UpdateMemberRole(ctx, 1, team.ID, 1, model.WorkspaceRoleAdmin); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected ErrLastOwner when demoting, got %v", err)
+	}
+	if err := svc.RemoveMember(ctx, 1, team.ID, 1); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected ErrLastOwner when leaving, got %v", err)
+	}
+	if _, err := svc.UpdateMemberRole(ctx, 1, team.ID, 2, model.WorkspaceRoleOwner); err != nil {
+		t.Fatalf("promote to owner: %v", err)
+	}
+	if err := svc.RemoveMember(ctx, 1, team.ID, 1); err != nil {
+		t.Fatalf("leave with another owner: %v", err)
+	}
+
+	// guests may leave, and personal workspaces cannot be left
+	if err := svc.RemoveMember(ctx, 3, team.ID, 3); err != nil {
+		t.Fatalf("guest leaving: %v", err)
+	}
+	personal, _ := svc.Authorize(ctx, 3, 0, model.PermNotesRead)
+	if err := svc.RemoveMember(ctx, 3, personal.ID, 3); !errors.Is(err, ErrPersonalWorkspace) {
+		t.Fatalf("expected ErrPersonalWorkspace, got %v", err)
+	}
+}
+
+func TestWorkspaceService_Delete(t *testing.T) {
+	repo := newMockWorkspaceRepo()
+	audit, auditRepo := newMockAuditService()
+	outbox := &mockUnitOfWork{}
+	svc := NewWorkspaceService(repo, newMockUserRepo(), audit, outbox)
+	ctx := context.Background()
+
+	personal, _ := svc.Authorize(ctx, 1, 0, model.PermNotesRead)
+	if err := svc.Delete(ctx, 1, personal.ID); !errors.Is(err, ErrPersonalWorkspace) {
+		t.Fatalf("expected ErrPersonalWorkspace, got %v", err)
+	}
+	team, _ := svc.Create(ctx, 1, "Team")
+	if err := svc.Delete(ctx, 1, team.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := repo.workspaces[team.ID]; ok {
+		t.Fatal("expected the workspace to be deleted")
+	}
+	// the notes deleted with it have no events of their own
+	if len(outbox.topics) != 1 || outbox.topics[0] != model.EventWorkspaceDeleted {
+		t.Fatalf("expected a workspace.deleted event, got %v", outbox.topics)
+	}
+	if len(auditRepo.events) != 1 || auditRepo.events[0].Action != model.AuditWorkspaceDelete || auditRepo.events[0].TargetID != team.ID {
+		t.Fatalf("expected a workspace.delete audit event, got %+v", auditRepo.events)
+	}
+}
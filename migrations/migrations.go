@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned SQL migrations, one directory per
+// supported database dialect. Files follow the golang-migrate naming scheme:
+// {version}_{name}.up.sql and {version}_{name}.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+  DROP COLUMN `locked_until`,
+  DROP COLUMN `failed_logins`;
@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+  ADD COLUMN `failed_logins` bigint NOT NULL DEFAULT 0,
+  ADD COLUMN `locked_until` datetime(3) DEFAULT NULL;
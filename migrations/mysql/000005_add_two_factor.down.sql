@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+  DROP COLUMN `recovery_codes`,
+  DROP COLUMN `totp_enabled`,
+  DROP COLUMN `totp_secret`;
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+  ADD COLUMN `totp_secret` varchar(64) DEFAULT NULL,
+  ADD COLUMN `totp_enabled` boolean NOT NULL DEFAULT false,
+  ADD COLUMN `recovery_codes` text;
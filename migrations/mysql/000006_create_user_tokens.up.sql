@@ -0,0 +1,15 @@
+ALTER TABLE `users`
+  ADD COLUMN `email_verified_at` datetime(3) DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `user_tokens` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `purpose` varchar(32) NOT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `used_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_user_tokens_token_hash` (`token_hash`),
+  KEY `idx_user_tokens_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
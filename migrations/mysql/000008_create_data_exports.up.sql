@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `data_exports` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `error` varchar(255) DEFAULT NULL,
+  `path` varchar(255) DEFAULT NULL,
+  `size` bigint DEFAULT NULL,
+  `completed_at` datetime(3) DEFAULT NULL,
+  `expires_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_data_exports_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
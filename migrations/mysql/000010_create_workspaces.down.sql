@@ -0,0 +1,6 @@
+ALTER TABLE `notes`
+  DROP KEY `idx_notes_workspace_id`,
+  DROP COLUMN `workspace_id`;
+DROP TABLE IF EXISTS `workspace_invitations`;
+DROP TABLE IF EXISTS `workspace_members`;
+DROP TABLE IF EXISTS `workspaces`;
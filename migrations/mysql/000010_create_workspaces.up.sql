@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS `workspaces` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `personal` tinyint(1) NOT NULL DEFAULT 0,
+  `created_by` bigint unsigned NOT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_workspaces_created_by` (`created_by`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `workspace_members` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `workspace_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `role` varchar(16) NOT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_workspace_members_workspace_user` (`workspace_id`, `user_id`),
+  KEY `idx_workspace_members_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `workspace_invitations` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `workspace_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `invited_by` bigint unsigned NOT NULL,
+  `role` varchar(16) NOT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_workspace_invitations_workspace_user` (`workspace_id`, `user_id`),
+  KEY `idx_workspace_invitations_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+ALTER TABLE `notes`
+  ADD COLUMN `workspace_id` bigint unsigned NOT NULL DEFAULT 0,
+  ADD KEY `idx_notes_workspace_id` (`workspace_id`);
+INSERT INTO `workspaces` (`name`, `personal`, `created_by`, `created_at`, `updated_at`)
+  SELECT 'Personal', 1, `id`, `created_at`, `created_at` FROM `users`;
+INSERT INTO `workspace_members` (`workspace_id`, `user_id`, `role`, `created_at`)
+  SELECT `id`, `created_by`, 'owner', `created_at` FROM `workspaces` WHERE `personal` = 1;
+UPDATE `notes` `n`
+  JOIN `workspaces` `w` ON `w`.`personal` = 1 AND `w`.`created_by` = `n`.`user_id`
+  SET `n`.`workspace_id` = `w`.`id`;
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+  ADD COLUMN `role` varchar(16) NOT NULL DEFAULT 'user',
+  ADD COLUMN `disabled_at` datetime(3) DEFAULT NULL,
+  ADD COLUMN `password_reset_required` tinyint(1) NOT NULL DEFAULT 0;
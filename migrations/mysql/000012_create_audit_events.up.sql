@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `audit_events` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `actor_id` bigint unsigned NOT NULL DEFAULT 0,
+  `action` varchar(64) NOT NULL,
+  `target_type` varchar(32) NOT NULL DEFAULT '',
+  `target_id` bigint unsigned NOT NULL DEFAULT 0,
+  `ip` varchar(64) DEFAULT NULL,
+  `user_agent` varchar(255) DEFAULT NULL,
+  `request_id` varchar(64) DEFAULT NULL,
+  `before_state` text,
+  `after_state` text,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_audit_events_actor_id` (`actor_id`),
+  KEY `idx_audit_events_action` (`action`),
+  KEY `idx_audit_events_target` (`target_type`, `target_id`),
+  KEY `idx_audit_events_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS `webhooks` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `url` varchar(2048) NOT NULL,
+  `secret` varchar(64) NOT NULL,
+  `events` varchar(255) NOT NULL,
+  `consecutive_failures` int NOT NULL DEFAULT 0,
+  `disabled_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_webhooks_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `webhook_id` bigint unsigned NOT NULL,
+  `event_id` varchar(32) NOT NULL,
+  `event` varchar(64) NOT NULL,
+  `payload` mediumtext NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `attempts` int NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(3) DEFAULT NULL,
+  `response_code` int NOT NULL DEFAULT 0,
+  `response_body` text,
+  `error` varchar(255) DEFAULT NULL,
+  `duration_ms` bigint NOT NULL DEFAULT 0,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_webhook_deliveries_webhook_id` (`webhook_id`),
+  KEY `idx_webhook_deliveries_due` (`status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS `jobs` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `kind` varchar(64) NOT NULL,
+  `payload` text,
+  `status` varchar(16) NOT NULL,
+  `attempts` int NOT NULL DEFAULT 0,
+  `max_attempts` int NOT NULL,
+  `run_at` datetime(3) NOT NULL,
+  `locked_by` varchar(64) DEFAULT NULL,
+  `locked_until` datetime(3) DEFAULT NULL,
+  `last_error` text,
+  `finished_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_jobs_kind` (`kind`),
+  KEY `idx_jobs_due` (`status`, `run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `job_schedules` (
+  `name` varchar(64) NOT NULL,
+  `spec` varchar(100) NOT NULL,
+  `kind` varchar(64) NOT NULL,
+  `payload` text,
+  `next_run_at` datetime(3) NOT NULL,
+  `last_run_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS `outbox_events` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `topic` varchar(64) NOT NULL,
+  `aggregate_type` varchar(32) NOT NULL,
+  `aggregate_id` bigint unsigned NOT NULL,
+  `payload` mediumtext NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `attempts` int NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(3) NOT NULL,
+  `last_error` text,
+  `published_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_outbox_events_status` (`status`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `leases` (
+  `name` varchar(64) NOT NULL,
+  `holder` varchar(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
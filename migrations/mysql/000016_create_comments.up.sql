@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS `comments` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `note_id` bigint unsigned NOT NULL,
+  `workspace_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `parent_id` bigint unsigned DEFAULT NULL,
+  `body` text NOT NULL,
+  `edited_at` datetime(3) DEFAULT NULL,
+  `deleted_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  `updated_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_comments_note_id` (`note_id`),
+  KEY `idx_comments_workspace_id` (`workspace_id`),
+  KEY `idx_comments_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `comment_revisions` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `comment_id` bigint unsigned NOT NULL,
+  `body` text NOT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_comment_revisions_comment_id` (`comment_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS `notifications` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `actor_id` bigint unsigned NOT NULL,
+  `workspace_id` bigint unsigned NOT NULL,
+  `note_id` bigint unsigned NOT NULL,
+  `comment_id` bigint unsigned DEFAULT NULL,
+  `read_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_notifications_user` (`user_id`, `read_at`),
+  KEY `idx_notifications_actor_id` (`actor_id`),
+  KEY `idx_notifications_workspace_id` (`workspace_id`),
+  KEY `idx_notifications_note_id` (`note_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
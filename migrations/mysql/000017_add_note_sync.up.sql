@@ -0,0 +1,13 @@
+ALTER TABLE `notes`
+  ADD COLUMN `change_seq` bigint unsigned NOT NULL DEFAULT 0,
+  ADD COLUMN `deleted_at` datetime(3) DEFAULT NULL,
+  ADD KEY `idx_notes_workspace_change_seq` (`workspace_id`, `change_seq`),
+  ADD KEY `idx_notes_deleted_at` (`deleted_at`);
+ALTER TABLE `workspaces`
+  ADD COLUMN `change_seq` bigint unsigned NOT NULL DEFAULT 0,
+  ADD COLUMN `purged_change_seq` bigint unsigned NOT NULL DEFAULT 0;
+UPDATE `notes` SET `change_seq` = `id`;
+UPDATE `workspaces` `w`
+  JOIN (SELECT `workspace_id`, MAX(`change_seq`) AS `seq` FROM `notes` GROUP BY `workspace_id`) `n`
+    ON `n`.`workspace_id` = `w`.`id`
+  SET `w`.`change_seq` = `n`.`seq`;
@@ -0,0 +1 @@
+ALTER TABLE `webhook_deliveries` ADD COLUMN `trace_parent` varchar(55) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE `workspaces`
+  DROP KEY `idx_workspaces_personal_created_by`,
+  DROP COLUMN `personal_owner`;
@@ -0,0 +1,19 @@
+-- concurrent first requests could give an account a second personal
+-- workspace; keep the oldest and move what the others hold into it
+CREATE TEMPORARY TABLE `personal_duplicates` AS
+  SELECT `w`.`id`, `k`.`keep`
+  FROM `workspaces` `w`
+  JOIN (SELECT `created_by`, MIN(`id`) AS `keep` FROM `workspaces` WHERE `personal` = 1 GROUP BY `created_by`) `k`
+    ON `k`.`created_by` = `w`.`created_by`
+  WHERE `w`.`personal` = 1 AND `w`.`id` <> `k`.`keep`;
+UPDATE `notes` `n` JOIN `personal_duplicates` `d` ON `d`.`id` = `n`.`workspace_id` SET `n`.`workspace_id` = `d`.`keep`;
+UPDATE `comments` `c` JOIN `personal_duplicates` `d` ON `d`.`id` = `c`.`workspace_id` SET `c`.`workspace_id` = `d`.`keep`;
+UPDATE `notifications` `n` JOIN `personal_duplicates` `d` ON `d`.`id` = `n`.`workspace_id` SET `n`.`workspace_id` = `d`.`keep`;
+DELETE `m` FROM `workspace_members` `m` JOIN `personal_duplicates` `d` ON `d`.`id` = `m`.`workspace_id`;
+DELETE `w` FROM `workspaces` `w` JOIN `personal_duplicates` `d` ON `d`.`id` = `w`.`id`;
+DROP TEMPORARY TABLE `personal_duplicates`;
+-- MySQL has no partial indexes: the generated column is only set for
+-- personal workspaces, and NULLs do not collide
+ALTER TABLE `workspaces`
+  ADD COLUMN `personal_owner` bigint unsigned AS (CASE WHEN `personal` = 1 THEN `created_by` END) STORED,
+  ADD UNIQUE KEY `idx_workspaces_personal_created_by` (`personal_owner`);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+  id bigserial PRIMARY KEY,
+  name varchar(100) NOT NULL,
+  username varchar(100),
+  password text,
+  email varchar(100) NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS notes (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  title varchar(255) NOT NULL,
+  content text,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_notes_user_id ON notes (user_id);
@@ -0,0 +1,5 @@
+ALTER TABLE users
+  DROP COLUMN deletion_scheduled_at,
+  DROP COLUMN token_version,
+  DROP COLUMN locale,
+  DROP COLUMN time_zone;
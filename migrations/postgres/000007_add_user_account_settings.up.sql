@@ -0,0 +1,5 @@
+ALTER TABLE users
+  ADD COLUMN time_zone varchar(64) NOT NULL DEFAULT 'UTC',
+  ADD COLUMN locale varchar(16) NOT NULL DEFAULT 'en',
+  ADD COLUMN token_version bigint NOT NULL DEFAULT 0,
+  ADD COLUMN deletion_scheduled_at timestamptz;
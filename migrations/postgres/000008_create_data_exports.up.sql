@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  status varchar(16) NOT NULL,
+  error varchar(255),
+  path varchar(255),
+  size bigint,
+  completed_at timestamptz,
+  expires_at timestamptz,
+  created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  provider varchar(64) NOT NULL,
+  subject varchar(255) NOT NULL,
+  email varchar(100),
+  created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject ON user_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
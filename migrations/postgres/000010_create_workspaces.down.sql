@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notes_workspace_id;
+ALTER TABLE notes DROP COLUMN workspace_id;
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
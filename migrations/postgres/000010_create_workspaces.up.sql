@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+  id bigserial PRIMARY KEY,
+  name varchar(100) NOT NULL,
+  personal boolean NOT NULL DEFAULT false,
+  created_by bigint NOT NULL,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_workspaces_created_by ON workspaces (created_by);
+CREATE TABLE IF NOT EXISTS workspace_members (
+  id bigserial PRIMARY KEY,
+  workspace_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  role varchar(16) NOT NULL,
+  created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspace_members_workspace_user ON workspace_members (workspace_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members (user_id);
+CREATE TABLE IF NOT EXISTS workspace_invitations (
+  id bigserial PRIMARY KEY,
+  workspace_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  invited_by bigint NOT NULL,
+  role varchar(16) NOT NULL,
+  created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspace_invitations_workspace_user ON workspace_invitations (workspace_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_workspace_invitations_user_id ON workspace_invitations (user_id);
+ALTER TABLE notes ADD COLUMN workspace_id bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_notes_workspace_id ON notes (workspace_id);
+INSERT INTO workspaces (name, personal, created_by, created_at, updated_at)
+  SELECT 'Personal', true, id, created_at, created_at FROM users;
+INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+  SELECT id, created_by, 'owner', created_at FROM workspaces WHERE personal;
+UPDATE notes SET workspace_id = w.id FROM workspaces w WHERE w.personal AND w.created_by = notes.user_id;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+  ADD COLUMN role varchar(16) NOT NULL DEFAULT 'user',
+  ADD COLUMN disabled_at timestamptz,
+  ADD COLUMN password_reset_required boolean NOT NULL DEFAULT false;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+  id bigserial PRIMARY KEY,
+  actor_id bigint NOT NULL DEFAULT 0,
+  action varchar(64) NOT NULL,
+  target_type varchar(32) NOT NULL DEFAULT '',
+  target_id bigint NOT NULL DEFAULT 0,
+  ip varchar(64),
+  user_agent varchar(255),
+  request_id varchar(64),
+  before_state text,
+  after_state text,
+  created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
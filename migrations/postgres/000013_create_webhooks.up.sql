@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  url varchar(2048) NOT NULL,
+  secret varchar(64) NOT NULL,
+  events varchar(255) NOT NULL,
+  consecutive_failures integer NOT NULL DEFAULT 0,
+  disabled_at timestamptz,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id bigserial PRIMARY KEY,
+  webhook_id bigint NOT NULL,
+  event_id varchar(32) NOT NULL,
+  event varchar(64) NOT NULL,
+  payload text NOT NULL,
+  status varchar(16) NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz,
+  response_code integer NOT NULL DEFAULT 0,
+  response_body text,
+  error varchar(255),
+  duration_ms bigint NOT NULL DEFAULT 0,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS jobs (
+  id bigserial PRIMARY KEY,
+  kind varchar(64) NOT NULL,
+  payload text,
+  status varchar(16) NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  max_attempts integer NOT NULL,
+  run_at timestamptz NOT NULL,
+  locked_by varchar(64),
+  locked_until timestamptz,
+  last_error text,
+  finished_at timestamptz,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_kind ON jobs (kind);
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs (status, run_at);
+CREATE TABLE IF NOT EXISTS job_schedules (
+  name varchar(64) PRIMARY KEY,
+  spec varchar(100) NOT NULL,
+  kind varchar(64) NOT NULL,
+  payload text,
+  next_run_at timestamptz NOT NULL,
+  last_run_at timestamptz,
+  updated_at timestamptz
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id bigserial PRIMARY KEY,
+  topic varchar(64) NOT NULL,
+  aggregate_type varchar(32) NOT NULL,
+  aggregate_id bigint NOT NULL,
+  payload text NOT NULL,
+  status varchar(16) NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz NOT NULL,
+  last_error text,
+  published_at timestamptz,
+  created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_status ON outbox_events (status, id);
+CREATE TABLE IF NOT EXISTS leases (
+  name varchar(64) PRIMARY KEY,
+  holder varchar(64) NOT NULL,
+  expires_at timestamptz NOT NULL
+);
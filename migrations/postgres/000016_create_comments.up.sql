@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS comments (
+  id bigserial PRIMARY KEY,
+  note_id bigint NOT NULL,
+  workspace_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  parent_id bigint,
+  body text NOT NULL,
+  edited_at timestamptz,
+  deleted_at timestamptz,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_comments_note_id ON comments (note_id);
+CREATE INDEX IF NOT EXISTS idx_comments_workspace_id ON comments (workspace_id);
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+CREATE TABLE IF NOT EXISTS comment_revisions (
+  id bigserial PRIMARY KEY,
+  comment_id bigint NOT NULL,
+  body text NOT NULL,
+  created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions (comment_id);
+CREATE TABLE IF NOT EXISTS notifications (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  type varchar(32) NOT NULL,
+  actor_id bigint NOT NULL,
+  workspace_id bigint NOT NULL,
+  note_id bigint NOT NULL,
+  comment_id bigint,
+  read_at timestamptz,
+  created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_actor_id ON notifications (actor_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_workspace_id ON notifications (workspace_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_note_id ON notifications (note_id);
@@ -0,0 +1,9 @@
+ALTER TABLE workspaces
+  DROP COLUMN purged_change_seq,
+  DROP COLUMN change_seq;
+DELETE FROM notes WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_notes_deleted_at;
+DROP INDEX IF EXISTS idx_notes_workspace_change_seq;
+ALTER TABLE notes
+  DROP COLUMN deleted_at,
+  DROP COLUMN change_seq;
@@ -0,0 +1 @@
+ALTER TABLE user_tokens ADD COLUMN email varchar(100) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE outbox_events ADD COLUMN trace_parent varchar(55) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_workspaces_personal_created_by;
@@ -0,0 +1,13 @@
+-- concurrent first requests could give an account a second personal
+-- workspace; keep the oldest and move what the others hold into it
+CREATE TEMPORARY TABLE personal_duplicates AS
+  SELECT w.id, (SELECT MIN(p.id) FROM workspaces p WHERE p.personal AND p.created_by = w.created_by) AS keep
+  FROM workspaces w WHERE w.personal;
+DELETE FROM personal_duplicates WHERE id = keep;
+UPDATE notes SET workspace_id = d.keep FROM personal_duplicates d WHERE notes.workspace_id = d.id;
+UPDATE comments SET workspace_id = d.keep FROM personal_duplicates d WHERE comments.workspace_id = d.id;
+UPDATE notifications SET workspace_id = d.keep FROM personal_duplicates d WHERE notifications.workspace_id = d.id;
+DELETE FROM workspace_members WHERE workspace_id IN (SELECT id FROM personal_duplicates);
+DELETE FROM workspaces WHERE id IN (SELECT id FROM personal_duplicates);
+DROP TABLE personal_duplicates;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspaces_personal_created_by ON workspaces (created_by) WHERE personal;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  name text NOT NULL,
+  username text,
+  password text,
+  email text NOT NULL,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS notes (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  title text NOT NULL,
+  content text,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_notes_user_id ON notes (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN totp_secret text;
+ALTER TABLE users ADD COLUMN totp_enabled numeric NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN recovery_codes text;
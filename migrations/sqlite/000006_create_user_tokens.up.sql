@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN email_verified_at datetime;
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  purpose text NOT NULL,
+  token_hash text NOT NULL,
+  expires_at datetime NOT NULL,
+  used_at datetime,
+  created_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id);
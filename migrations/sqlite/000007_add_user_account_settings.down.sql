@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
+ALTER TABLE users DROP COLUMN token_version;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN time_zone;
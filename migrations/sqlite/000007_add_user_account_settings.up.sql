@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN time_zone text NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN locale text NOT NULL DEFAULT 'en';
+ALTER TABLE users ADD COLUMN token_version integer NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN deletion_scheduled_at datetime;
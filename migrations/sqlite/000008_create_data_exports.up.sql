@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  status text NOT NULL,
+  error text,
+  path text,
+  size integer,
+  completed_at datetime,
+  expires_at datetime,
+  created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id);
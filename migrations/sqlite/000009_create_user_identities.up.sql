@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  provider text NOT NULL,
+  subject text NOT NULL,
+  email text,
+  created_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject ON user_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  name text NOT NULL,
+  personal integer NOT NULL DEFAULT 0,
+  created_by integer NOT NULL,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_workspaces_created_by ON workspaces (created_by);
+CREATE TABLE IF NOT EXISTS workspace_members (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  workspace_id integer NOT NULL,
+  user_id integer NOT NULL,
+  role text NOT NULL,
+  created_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspace_members_workspace_user ON workspace_members (workspace_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members (user_id);
+CREATE TABLE IF NOT EXISTS workspace_invitations (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  workspace_id integer NOT NULL,
+  user_id integer NOT NULL,
+  invited_by integer NOT NULL,
+  role text NOT NULL,
+  created_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspace_invitations_workspace_user ON workspace_invitations (workspace_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_workspace_invitations_user_id ON workspace_invitations (user_id);
+ALTER TABLE notes ADD COLUMN workspace_id integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_notes_workspace_id ON notes (workspace_id);
+INSERT INTO workspaces (name, personal, created_by, created_at, updated_at)
+  SELECT 'Personal', 1, id, created_at, created_at FROM users;
+INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+  SELECT id, created_by, 'owner', created_at FROM workspaces WHERE personal = 1;
+UPDATE notes SET workspace_id = (
+  SELECT w.id FROM workspaces w WHERE w.personal = 1 AND w.created_by = notes.user_id
+)
+WHERE user_id IN (SELECT created_by FROM workspaces WHERE personal = 1);
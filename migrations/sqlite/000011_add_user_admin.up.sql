@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled_at datetime;
+ALTER TABLE users ADD COLUMN password_reset_required numeric NOT NULL DEFAULT false;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  actor_id integer NOT NULL DEFAULT 0,
+  action text NOT NULL,
+  target_type text NOT NULL DEFAULT '',
+  target_id integer NOT NULL DEFAULT 0,
+  ip text,
+  user_agent text,
+  request_id text,
+  before_state text,
+  after_state text,
+  created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
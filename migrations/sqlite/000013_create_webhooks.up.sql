@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  url text NOT NULL,
+  secret text NOT NULL,
+  events text NOT NULL,
+  consecutive_failures integer NOT NULL DEFAULT 0,
+  disabled_at datetime,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  webhook_id integer NOT NULL,
+  event_id text NOT NULL,
+  event text NOT NULL,
+  payload text NOT NULL,
+  status text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at datetime,
+  response_code integer NOT NULL DEFAULT 0,
+  response_body text,
+  error text,
+  duration_ms integer NOT NULL DEFAULT 0,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
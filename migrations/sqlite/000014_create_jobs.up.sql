@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS jobs (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  kind text NOT NULL,
+  payload text,
+  status text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  max_attempts integer NOT NULL,
+  run_at datetime NOT NULL,
+  locked_by text,
+  locked_until datetime,
+  last_error text,
+  finished_at datetime,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_kind ON jobs (kind);
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs (status, run_at);
+CREATE TABLE IF NOT EXISTS job_schedules (
+  name text PRIMARY KEY,
+  spec text NOT NULL,
+  kind text NOT NULL,
+  payload text,
+  next_run_at datetime NOT NULL,
+  last_run_at datetime,
+  updated_at datetime
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  topic text NOT NULL,
+  aggregate_type text NOT NULL,
+  aggregate_id integer NOT NULL,
+  payload text NOT NULL,
+  status text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at datetime NOT NULL,
+  last_error text,
+  published_at datetime,
+  created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_status ON outbox_events (status, id);
+CREATE TABLE IF NOT EXISTS leases (
+  name text PRIMARY KEY,
+  holder text NOT NULL,
+  expires_at datetime NOT NULL
+);
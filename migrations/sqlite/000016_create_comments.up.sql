@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS comments (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  note_id integer NOT NULL,
+  workspace_id integer NOT NULL,
+  user_id integer NOT NULL,
+  parent_id integer,
+  body text NOT NULL,
+  edited_at datetime,
+  deleted_at datetime,
+  created_at datetime,
+  updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_comments_note_id ON comments (note_id);
+CREATE INDEX IF NOT EXISTS idx_comments_workspace_id ON comments (workspace_id);
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+CREATE TABLE IF NOT EXISTS comment_revisions (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  comment_id integer NOT NULL,
+  body text NOT NULL,
+  created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions (comment_id);
+CREATE TABLE IF NOT EXISTS notifications (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  type text NOT NULL,
+  actor_id integer NOT NULL,
+  workspace_id integer NOT NULL,
+  note_id integer NOT NULL,
+  comment_id integer,
+  read_at datetime,
+  created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_actor_id ON notifications (actor_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_workspace_id ON notifications (workspace_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_note_id ON notifications (note_id);
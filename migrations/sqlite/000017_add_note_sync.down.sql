@@ -0,0 +1,7 @@
+ALTER TABLE workspaces DROP COLUMN purged_change_seq;
+ALTER TABLE workspaces DROP COLUMN change_seq;
+DELETE FROM notes WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_notes_deleted_at;
+DROP INDEX IF EXISTS idx_notes_workspace_change_seq;
+ALTER TABLE notes DROP COLUMN deleted_at;
+ALTER TABLE notes DROP COLUMN change_seq;
@@ -0,0 +1,10 @@
+ALTER TABLE notes ADD COLUMN change_seq integer NOT NULL DEFAULT 0;
+ALTER TABLE notes ADD COLUMN deleted_at datetime;
+CREATE INDEX IF NOT EXISTS idx_notes_workspace_change_seq ON notes (workspace_id, change_seq);
+CREATE INDEX IF NOT EXISTS idx_notes_deleted_at ON notes (deleted_at);
+ALTER TABLE workspaces ADD COLUMN change_seq integer NOT NULL DEFAULT 0;
+ALTER TABLE workspaces ADD COLUMN purged_change_seq integer NOT NULL DEFAULT 0;
+UPDATE notes SET change_seq = id;
+UPDATE workspaces SET change_seq = (
+  SELECT COALESCE(MAX(n.change_seq), 0) FROM notes n WHERE n.workspace_id = workspaces.id
+);
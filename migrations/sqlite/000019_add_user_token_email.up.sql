@@ -0,0 +1 @@
+ALTER TABLE user_tokens ADD COLUMN email text NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE webhook_deliveries ADD COLUMN trace_parent text NOT NULL DEFAULT '';
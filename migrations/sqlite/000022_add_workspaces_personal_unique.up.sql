@@ -0,0 +1,16 @@
+-- concurrent first requests could give an account a second personal
+-- workspace; keep the oldest and move what the others hold into it
+CREATE TEMPORARY TABLE personal_duplicates AS
+  SELECT w.id, (SELECT MIN(p.id) FROM workspaces p WHERE p.personal = 1 AND p.created_by = w.created_by) AS keep
+  FROM workspaces w WHERE w.personal = 1;
+DELETE FROM personal_duplicates WHERE id = keep;
+UPDATE notes SET workspace_id = (SELECT keep FROM personal_duplicates d WHERE d.id = notes.workspace_id)
+  WHERE workspace_id IN (SELECT id FROM personal_duplicates);
+UPDATE comments SET workspace_id = (SELECT keep FROM personal_duplicates d WHERE d.id = comments.workspace_id)
+  WHERE workspace_id IN (SELECT id FROM personal_duplicates);
+UPDATE notifications SET workspace_id = (SELECT keep FROM personal_duplicates d WHERE d.id = notifications.workspace_id)
+  WHERE workspace_id IN (SELECT id FROM personal_duplicates);
+DELETE FROM workspace_members WHERE workspace_id IN (SELECT id FROM personal_duplicates);
+DELETE FROM workspaces WHERE id IN (SELECT id FROM personal_duplicates);
+DROP TABLE personal_duplicates;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspaces_personal_created_by ON workspaces (created_by) WHERE personal = 1;
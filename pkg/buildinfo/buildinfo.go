@@ -0,0 +1,30 @@
+// Package buildinfo holds version information injected at build time:
+//
+//	go build -ldflags "-X github.com/MujiRahman/golang-simple-note/pkg/buildinfo.Version=v1.2.3 \
+//	  -X github.com/MujiRahman/golang-simple-note/pkg/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/MujiRahman/golang-simple-note/pkg/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+package buildinfo
+
+import "runtime"
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
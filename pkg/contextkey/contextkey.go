@@ -3,3 +3,10 @@ package contextkey
 type ctxKey string
 
 const UserIDKey ctxKey = "user_id"
+
+// ScopesKey holds the []string scopes granted to the current credential.
+const ScopesKey ctxKey = "scopes"
+
+// TokenKey holds the *model.PersonalAccessToken the current request
+// authenticated with. It is not set for an interactive (JWT) session.
+const TokenKey ctxKey = "token"
@@ -0,0 +1,300 @@
+// Package jwtkeys manages the keys JWTs are signed and verified with. A KeySet
+// holds one active key that signs new tokens and any number of older keys that
+// still verify the tokens they signed; every token names its key in the "kid"
+// header. The public halves of RSA and Ed25519 keys can be published as a
+// JWK Set so other services can verify tokens without sharing a secret.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported algorithms, as named in the "alg" header.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// Key is a named signing or verification key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{} // nil for verification-only keys
+	verify interface{}
+}
+
+// CanSign reports whether the key holds private (or secret) material.
+func (k *Key) CanSign() bool { return k.sign != nil }
+
+// NewHMACKey returns an HS256 key. Its id is derived from the secret, so the
+// same secret gets the same id on every instance.
+func NewHMACKey(secret []byte) *Key {
+	sum := sha256.Sum256(secret)
+	return &Key{ID: "hs-" + hex.EncodeToString(sum[:4]), Method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+// NewPrivateKey wraps an *rsa.PrivateKey (RS256) or ed25519.PrivateKey (EdDSA).
+func NewPrivateKey(id string, priv crypto.PrivateKey) (*Key, error) {
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, sign: p, verify: &p.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, sign: p, verify: p.Public()}, nil
+	default:
+		return nil, fmt.Errorf("jwtkeys: unsupported private key type %T", priv)
+	}
+}
+
+// NewPublicKey returns a verification-only key for an *rsa.PublicKey or
+// ed25519.PublicKey.
+func NewPublicKey(id string, pub crypto.PublicKey) (*Key, error) {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verify: p}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verify: p}, nil
+	default:
+		return nil, fmt.Errorf("jwtkeys: unsupported public key type %T", pub)
+	}
+}
+
+// Generate creates a new RS256 or EdDSA key.
+func Generate(id, alg string) (*Key, error) {
+	switch alg {
+	case RS256:
+		priv, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrivateKey(id, priv)
+	case EdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrivateKey(id, priv)
+	default:
+		return nil, fmt.Errorf("jwtkeys: cannot generate %q keys", alg)
+	}
+}
+
+// ParsePEM reads a PKCS#8 or PKCS#1 private key, or a PKIX public key.
+func ParsePEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwtkeys: no PEM block found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrivateKey(id, priv)
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrivateKey(id, priv)
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewPublicKey(id, pub)
+	default:
+		return nil, fmt.Errorf("jwtkeys: unsupported PEM block %q", block.Type)
+	}
+}
+
+// MarshalPEM encodes the private key of k as PKCS#8.
+func MarshalPEM(k *Key) ([]byte, error) {
+	if !k.CanSign() || k.Method == jwt.SigningMethodHS256 {
+		return nil, errors.New("jwtkeys: only RSA and Ed25519 private keys can be marshalled")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.sign)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// KeySet is safe for concurrent use; Replace swaps the keys while tokens are
+// being signed and verified.
+type KeySet struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+}
+
+// NewKeySet returns a set in which active signs and all keys verify.
+func NewKeySet(active *Key, others ...*Key) (*KeySet, error) {
+	s := &KeySet{}
+	if err := s.Replace(active, others...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Replace sets the keys of s, e.g. after a rotation.
+func (s *KeySet) Replace(active *Key, others ...*Key) error {
+	if active == nil || !active.CanSign() {
+		return errors.New("jwtkeys: the active key must be able to sign")
+	}
+	keys := map[string]*Key{active.ID: active}
+	for _, k := range others {
+		if _, dup := keys[k.ID]; dup {
+			return fmt.Errorf("jwtkeys: duplicate key id %q", k.ID)
+		}
+		keys[k.ID] = k
+	}
+	s.mu.Lock()
+	s.active, s.keys = active, keys
+	s.mu.Unlock()
+	return nil
+}
+
+// ActiveID returns the id of the key that signs new tokens.
+func (s *KeySet) ActiveID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active.ID
+}
+
+// Sign signs claims with the active key and sets the "kid" header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	k := s.active
+	s.mu.RUnlock()
+	t := jwt.NewWithClaims(k.Method, claims)
+	t.Header["kid"] = k.ID
+	return t.SignedString(k.sign)
+}
+
+// Keyfunc resolves the verification key of a token for jwt.Parse. The "alg"
+// header has to match the algorithm of the key, so a public key can never be
+// used as an HMAC secret.
+func (s *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	s.mu.RLock()
+	k, ok := s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k.Keyfunc(t)
+}
+
+// Keyfunc returns the verification key of k for t, provided t was signed
+// with the algorithm of k.
+func (k *Key) Keyfunc(t *jwt.Token) (interface{}, error) {
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.verify, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Key returns the verification key described by j. Only RSA and Ed25519
+// signing keys are supported.
+func (j JWK) Key() (*Key, error) {
+	if j.Use != "" && j.Use != "sig" {
+		return nil, fmt.Errorf("jwtkeys: key %q is not a signing key", j.Kid)
+	}
+	switch {
+	case j.Kty == "RSA" && (j.Alg == "" || j.Alg == RS256):
+		n, err := b64.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwtkeys: invalid RSA exponent in key %q", j.Kid)
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return NewPublicKey(j.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp})
+	case j.Kty == "OKP" && j.Crv == "Ed25519" && (j.Alg == "" || j.Alg == EdDSA):
+		x, err := b64.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwtkeys: invalid Ed25519 key %q", j.Kid)
+		}
+		return NewPublicKey(j.Kid, ed25519.PublicKey(x))
+	default:
+		return nil, fmt.Errorf("jwtkeys: unsupported key %q (kty %s, alg %s)", j.Kid, j.Kty, j.Alg)
+	}
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, ordered by id. HMAC keys are secret
+// and never included.
+func (s *KeySet) JWKS() JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range s.keys {
+		jwk := JWK{Use: "sig", Alg: k.Method.Alg(), Kid: k.ID}
+		switch pub := k.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64.EncodeToString(pub.N.Bytes())
+			jwk.E = b64.EncodeToString(bigEndian(pub.E))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+var b64 = base64.RawURLEncoding
+
+// bigEndian encodes a small exponent without leading zero bytes.
+func bigEndian(n int) []byte {
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return b
+}
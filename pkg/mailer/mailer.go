@@ -0,0 +1,62 @@
+// Package mailer sends plain-text transactional email through a pluggable
+// transport: SMTP in production, a logger or an in-memory outbox in
+// development and tests.
+package mailer
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type logMailer struct {
+	logger *log.Logger
+}
+
+// NewLogMailer returns a Mailer that only writes messages to logger (or the
+// standard logger when nil). Useful in development to pick up links from the console.
+func NewLogMailer(logger *log.Logger) Mailer {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &logMailer{logger: logger}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Printf("mail to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// MemoryMailer keeps every sent message in memory.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far, oldest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
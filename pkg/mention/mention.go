@@ -0,0 +1,33 @@
+// Package mention finds @username mentions in text.
+package mention
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxMentions bounds the usernames returned for one text.
+const MaxMentions = 50
+
+// a mention starts a word, so e-mail addresses are not mentions
+var mentionRe = regexp.MustCompile(`(?:^|[^\w@.+-])@([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// Usernames returns the usernames mentioned in text, each once, in order of
+// first mention. Dots ending a mention are taken as punctuation: "@bob."
+// mentions bob.
+func Usernames(text string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range mentionRe.FindAllStringSubmatch(text, -1) {
+		name := strings.TrimRight(m[1], ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+		if len(out) == MaxMentions {
+			break
+		}
+	}
+	return out
+}
@@ -7,26 +7,96 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/MujiRahman/golang-simple-note/internal/model"
 	"github.com/MujiRahman/golang-simple-note/internal/service"
 	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
 )
 
-// AuthMiddleware returns a gin middleware that validates JWT and injects userID into request context.
-// It accepts a UserService to parse token (DI).
-func AuthMiddleware(userSvc service.UserService) gin.HandlerFunc {
+// AuthMiddleware returns a gin middleware that validates the bearer token and injects userID
+// and the granted scopes into request context. The token is either a JWT issued by Login,
+// which grants every scope, or a personal access token limited to its own scopes.
+// It accepts a UserService to parse JWTs and an optional TokenService for PATs (DI).
+func AuthMiddleware(userSvc service.UserService, tokenSvc service.TokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := extractBearerToken(c.GetHeader("Authorization"))
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid authorization header"})
 			return
 		}
-		uid, err := userSvc.ParseToken(token)
+
+		if tokenSvc != nil && strings.HasPrefix(token, model.PATPrefix) {
+			pat, err := tokenSvc.Authenticate(c.Request.Context(), token)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+			c.Set(string(contextkey.UserIDKey), pat.UserID)
+			c.Set(string(contextkey.ScopesKey), pat.ScopeList())
+			c.Set(string(contextkey.TokenKey), pat)
+			c.Next()
+			return
+		}
+
+		uid, err := userSvc.ParseToken(c.Request.Context(), token)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 		// inject userID into context
 		c.Set(string(contextkey.UserIDKey), uid)
+		c.Set(string(contextkey.ScopesKey), model.AllScopes)
+		c.Next()
+	}
+}
+
+// WebSocketTokenProtocol prefixes the WebSocket subprotocol that carries a
+// bearer token, as in "bearer.<token>".
+const WebSocketTokenProtocol = "bearer."
+
+// WebSocketToken lets a WebSocket handshake pass its bearer token as a
+// subprotocol, since browsers cannot set the Authorization header on
+// WebSockets and a token in the URL would end up in access logs. It must
+// run before AuthMiddleware; an Authorization header takes precedence.
+func WebSocketToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			for _, p := range strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",") {
+				if token, ok := strings.CutPrefix(strings.TrimSpace(p), WebSocketTokenProtocol); ok && token != "" {
+					c.Request.Header.Set("Authorization", "Bearer "+token)
+					break
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose credential was not granted scope. It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, s := range c.GetStringSlice(string(contextkey.ScopesKey)) {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope", "required_scope": scope})
+	}
+}
+
+// RequireAdmin rejects requests from users without the admin role. It must
+// run after AuthMiddleware.
+func RequireAdmin(adminSvc service.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := adminSvc.IsAdmin(c.Request.Context(), c.GetUint(string(contextkey.UserIDKey)))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
+	"github.com/MujiRahman/golang-simple-note/pkg/ratelimit"
+)
+
+// KeyFunc picks the identity a request is rate limited by.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP limits per client IP.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser limits per authenticated user, falling back to the client IP. It
+// must run after AuthMiddleware.
+func ByUser(c *gin.Context) string {
+	if uid := c.GetUint(string(contextkey.UserIDKey)); uid != 0 {
+		return fmt.Sprintf("user:%d", uid)
+	}
+	return ByIP(c)
+}
+
+// RateLimit enforces limit per key within the named bucket group and sets the
+// RateLimit-* headers. Store failures let the request through.
+func RateLimit(store ratelimit.Store, name string, limit ratelimit.Limit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := store.Allow(c.Request.Context(), name+":"+key(c), limit)
+		if err != nil {
+			log.Printf("rate limit store error: %v", err)
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.ResetAfter))
+		if !res.Allowed {
+			h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
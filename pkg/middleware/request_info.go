@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/requestinfo"
+)
+
+// requestIDRe limits the request IDs accepted from clients or proxies.
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestInfo stores the client IP, user agent and request ID in the request
+// context. The request ID is taken from the X-Request-ID header when it is
+// well-formed, generated otherwise, and echoed in the response.
+func RequestInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestinfo.Header)
+		if !requestIDRe.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Header(requestinfo.Header, id)
+		c.Request = c.Request.WithContext(requestinfo.NewContext(c.Request.Context(), requestinfo.Info{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			RequestID: id,
+		}))
+		c.Next()
+	}
+}
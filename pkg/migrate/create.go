@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nameRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes an empty up/down migration pair named name into every dialect
+// directory under root, using the next free version number across all of them.
+// It returns the paths of the created files.
+func Create(root string, dialects []Dialect, name string) ([]string, error) {
+	name = strings.Trim(nameRe.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("invalid migration name")
+	}
+
+	var next uint64 = 1
+	for _, d := range dialects {
+		migs, err := Load(os.DirFS(root), string(d))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(migs) > 0 && migs[len(migs)-1].Version >= next {
+			next = migs[len(migs)-1].Version + 1
+		}
+	}
+
+	var created []string
+	for _, d := range dialects {
+		dir := filepath.Join(root, string(d))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return created, err
+		}
+		for _, direction := range []string{"up", "down"} {
+			file := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+			body := fmt.Sprintf("-- %s migration %06d_%s (%s)\n", direction, next, name, d)
+			if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+				return created, err
+			}
+			created = append(created, file)
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,78 @@
+package ot
+
+import "errors"
+
+// ErrUnexpectedAck means the server acknowledged an operation the client
+// did not send.
+var ErrUnexpectedAck = errors.New("ot: acknowledgement without a pending operation")
+
+// Client is the editor's side of the protocol. It keeps at most one
+// operation in flight: local edits made while waiting for the server's
+// acknowledgement are buffered and sent, composed into one, once it comes.
+type Client struct {
+	// Revision is the last server revision the client has seen.
+	Revision int
+
+	sent   *Operation // waiting for acknowledgement
+	buffer *Operation // not sent yet
+}
+
+// NewClient returns a client for a document loaded at revision.
+func NewClient(revision int) *Client {
+	return &Client{Revision: revision}
+}
+
+// Pending reports whether local edits have not been acknowledged yet.
+func (c *Client) Pending() bool {
+	return c.sent != nil
+}
+
+// ApplyLocal records op, already applied to the local document. It returns
+// op when it is to be sent to the server now, with Revision, or nil when it
+// waits for the operation in flight.
+func (c *Client) ApplyLocal(op *Operation) (*Operation, error) {
+	if c.sent == nil {
+		c.sent = op
+		return op, nil
+	}
+	if c.buffer == nil {
+		c.buffer = op
+		return nil, nil
+	}
+	buffer, err := Compose(c.buffer, op)
+	if err != nil {
+		return nil, err
+	}
+	c.buffer = buffer
+	return nil, nil
+}
+
+// ApplyRemote takes an operation of another editor from the server and
+// returns it transformed against the local edits the server has not seen,
+// ready to apply to the local document.
+func (c *Client) ApplyRemote(op *Operation) (*Operation, error) {
+	var err error
+	if c.sent != nil {
+		if c.sent, op, err = Transform(c.sent, op); err != nil {
+			return nil, err
+		}
+	}
+	if c.buffer != nil {
+		if c.buffer, op, err = Transform(c.buffer, op); err != nil {
+			return nil, err
+		}
+	}
+	c.Revision++
+	return op, nil
+}
+
+// Ack handles the server's acknowledgement of the operation in flight and
+// returns the buffered edits to send next, or nil.
+func (c *Client) Ack() (*Operation, error) {
+	if c.sent == nil {
+		return nil, ErrUnexpectedAck
+	}
+	c.Revision++
+	c.sent, c.buffer = c.buffer, nil
+	return c.sent, nil
+}
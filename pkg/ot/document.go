@@ -0,0 +1,87 @@
+package ot
+
+import "errors"
+
+var (
+	// ErrUnknownRevision means an operation claims a revision the document
+	// has not reached.
+	ErrUnknownRevision = errors.New("ot: unknown revision")
+	// ErrRevisionTooOld means an operation is based on a revision whose
+	// later history was already dropped; its editor has to reload.
+	ErrRevisionTooOld = errors.New("ot: revision too old")
+)
+
+// Document is the central copy of a text that orders the operations of all
+// its editors. Every accepted operation advances the revision by one. An
+// operation is sent along with the revision its editor last saw and is
+// transformed against the operations accepted since.
+//
+// A Document is not safe for concurrent use.
+type Document struct {
+	text     string
+	revision int
+	// history holds the operations that led from revision
+	// revision-len(history) to revision.
+	history    []*Operation
+	maxHistory int
+}
+
+// NewDocument returns a document at revision 0 holding text. It keeps the
+// last maxHistory operations, or all of them when maxHistory is 0.
+func NewDocument(text string, maxHistory int) *Document {
+	return &Document{text: text, maxHistory: maxHistory}
+}
+
+func (d *Document) Text() string  { return d.text }
+func (d *Document) Revision() int { return d.revision }
+
+// Receive applies op, made for the document at revision, and returns it
+// transformed to the revision before the new one, as it is to be sent to
+// the other editors.
+func (d *Document) Receive(revision int, op *Operation) (*Operation, error) {
+	concurrent, err := d.since(revision)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range concurrent {
+		if op, _, err = Transform(op, h); err != nil {
+			return nil, err
+		}
+	}
+	text, err := op.Apply(d.text)
+	if err != nil {
+		return nil, err
+	}
+	d.text = text
+	d.revision++
+	d.history = append(d.history, op)
+	if d.maxHistory > 0 && len(d.history) > d.maxHistory {
+		d.history = append([]*Operation(nil), d.history[len(d.history)-d.maxHistory:]...)
+	}
+	return op, nil
+}
+
+// TransformIndex moves a position in the document at revision, such as a
+// cursor, to the current revision.
+func (d *Document) TransformIndex(revision, index int) (int, error) {
+	ops, err := d.since(revision)
+	if err != nil {
+		return 0, err
+	}
+	for _, op := range ops {
+		index = TransformIndex(index, op)
+	}
+	return index, nil
+}
+
+// since returns the operations accepted after revision.
+func (d *Document) since(revision int) ([]*Operation, error) {
+	if revision < 0 || revision > d.revision {
+		return nil, ErrUnknownRevision
+	}
+	oldest := d.revision - len(d.history)
+	if revision < oldest {
+		return nil, ErrRevisionTooOld
+	}
+	return d.history[revision-oldest:], nil
+}
@@ -0,0 +1,228 @@
+// Package ot implements operational transformation for plain text, in the
+// style of ot.js: an Operation walks the whole document with retain, insert
+// and delete components, concurrent operations are merged with Transform,
+// and a central Document orders the operations of all editors. Positions
+// and lengths count Unicode code points.
+package ot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// MaxLength bounds the lengths of the documents that decoded operations
+// apply to and produce, in code points.
+const MaxLength = 1 << 24
+
+var (
+	// ErrBaseLength means an operation was applied to, or combined with,
+	// a document of another length than it was made for.
+	ErrBaseLength = errors.New("ot: operation does not match the document length")
+	// ErrInvalidOperation means an encoded operation could not be decoded.
+	ErrInvalidOperation = errors.New("ot: invalid operation")
+)
+
+// component is one step of an Operation: n > 0 retains n code points,
+// n < 0 deletes -n and a non-empty s inserts s.
+type component struct {
+	n int
+	s []rune
+}
+
+func (c component) isRetain() bool { return c.n > 0 }
+func (c component) isDelete() bool { return c.n < 0 }
+func (c component) isInsert() bool { return len(c.s) > 0 }
+
+// Operation is an edit of a whole document. Build one with Retain, Insert and
+// Delete in document order; adjacent components of the same kind are merged
+// and an insert always comes before a delete at the same position, so that
+// equal edits have equal operations. The zero value is the empty operation
+// on the empty document.
+type Operation struct {
+	ops       []component
+	baseLen   int
+	targetLen int
+}
+
+// New returns an empty operation to build on.
+func New() *Operation {
+	return &Operation{}
+}
+
+// BaseLen is the length of the documents the operation applies to.
+func (o *Operation) BaseLen() int { return o.baseLen }
+
+// TargetLen is the length of the document after applying the operation.
+func (o *Operation) TargetLen() int { return o.targetLen }
+
+// IsNoop reports whether the operation leaves the document unchanged.
+func (o *Operation) IsNoop() bool {
+	for _, c := range o.ops {
+		if !c.isRetain() {
+			return false
+		}
+	}
+	return true
+}
+
+// Retain skips over n code points.
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLen += n
+	o.targetLen += n
+	if last := len(o.ops) - 1; last >= 0 && o.ops[last].isRetain() {
+		o.ops[last].n += n
+		return o
+	}
+	o.ops = append(o.ops, component{n: n})
+	return o
+}
+
+// Insert inserts text at the current position.
+func (o *Operation) Insert(text string) *Operation {
+	return o.insert([]rune(text))
+}
+
+func (o *Operation) insert(s []rune) *Operation {
+	if len(s) == 0 {
+		return o
+	}
+	o.targetLen += len(s)
+	last := len(o.ops) - 1
+	switch {
+	case last >= 0 && o.ops[last].isInsert():
+		o.ops[last].s = appendRunes(o.ops[last].s, s)
+	case last >= 0 && o.ops[last].isDelete():
+		// keep inserts before deletes
+		if last > 0 && o.ops[last-1].isInsert() {
+			o.ops[last-1].s = appendRunes(o.ops[last-1].s, s)
+		} else {
+			o.ops = append(o.ops, o.ops[last])
+			o.ops[last] = component{s: appendRunes(nil, s)}
+		}
+	default:
+		o.ops = append(o.ops, component{s: appendRunes(nil, s)})
+	}
+	return o
+}
+
+// Delete deletes n code points at the current position.
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLen += n
+	if last := len(o.ops) - 1; last >= 0 && o.ops[last].isDelete() {
+		o.ops[last].n -= n
+		return o
+	}
+	o.ops = append(o.ops, component{n: -n})
+	return o
+}
+
+// appendRunes appends s to a copy of dst, so that operations never share
+// their inserted text.
+func appendRunes(dst, s []rune) []rune {
+	out := make([]rune, 0, len(dst)+len(s))
+	return append(append(out, dst...), s...)
+}
+
+// Apply returns doc edited by the operation.
+func (o *Operation) Apply(doc string) (string, error) {
+	if utf8.RuneCountInString(doc) != o.baseLen {
+		return "", ErrBaseLength
+	}
+	src := []rune(doc)
+	out := make([]rune, 0, o.targetLen)
+	pos := 0
+	for _, c := range o.ops {
+		switch {
+		case c.isRetain():
+			if c.n > len(src)-pos {
+				return "", ErrBaseLength
+			}
+			out = append(out, src[pos:pos+c.n]...)
+			pos += c.n
+		case c.isInsert():
+			out = append(out, c.s...)
+		default:
+			if -c.n > len(src)-pos {
+				return "", ErrBaseLength
+			}
+			pos -= c.n
+		}
+	}
+	return string(out), nil
+}
+
+// String shows the operation in its JSON form.
+func (o *Operation) String() string {
+	b, _ := o.MarshalJSON()
+	return string(b)
+}
+
+// MarshalJSON encodes the operation like ot.js does: an array with a
+// positive number for a retain, a string for an insert and a negative
+// number for a delete.
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	out := make([]any, len(o.ops))
+	for i, c := range o.ops {
+		if c.isInsert() {
+			out[i] = string(c.s)
+		} else {
+			out[i] = c.n
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON. Components that are
+// not in canonical order are accepted and normalized. Operations on or
+// making documents longer than MaxLength are refused.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+	*o = Operation{}
+	for _, r := range raw {
+		r = bytes.TrimSpace(r)
+		if len(r) > 0 && r[0] == '"' {
+			var s string
+			if err := json.Unmarshal(r, &s); err != nil || s == "" {
+				return fmt.Errorf("%w: bad insert %s", ErrInvalidOperation, r)
+			}
+			if utf8.RuneCountInString(s) > MaxLength-o.targetLen {
+				return fmt.Errorf("%w: too long", ErrInvalidOperation)
+			}
+			o.Insert(s)
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(r, &n); err != nil || n == 0 {
+			return fmt.Errorf("%w: bad component %s", ErrInvalidOperation, r)
+		}
+		// checked before adding up, so that the lengths cannot overflow
+		if n < -MaxLength || n > MaxLength || abs(n) > MaxLength-o.baseLen || n > MaxLength-o.targetLen {
+			return fmt.Errorf("%w: too long", ErrInvalidOperation)
+		}
+		if n > 0 {
+			o.Retain(n)
+		} else {
+			o.Delete(-n)
+		}
+	}
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
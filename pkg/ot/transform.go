@@ -0,0 +1,176 @@
+package ot
+
+// iterator hands out the components of an operation one at a time, letting
+// the caller consume part of the current one.
+type iterator struct {
+	ops []component
+	i   int
+	cur component
+	ok  bool
+}
+
+func newIterator(o *Operation) *iterator {
+	it := &iterator{ops: o.ops}
+	it.next()
+	return it
+}
+
+func (it *iterator) next() {
+	if it.i < len(it.ops) {
+		it.cur, it.ok = it.ops[it.i], true
+		it.i++
+		return
+	}
+	it.cur, it.ok = component{}, false
+}
+
+// length is how many code points the current component covers.
+func (it *iterator) length() int {
+	switch {
+	case it.cur.isInsert():
+		return len(it.cur.s)
+	case it.cur.isDelete():
+		return -it.cur.n
+	default:
+		return it.cur.n
+	}
+}
+
+// consume uses n code points of the current component and moves on once it
+// is used up.
+func (it *iterator) consume(n int) {
+	switch {
+	case n >= it.length():
+		it.next()
+	case it.cur.isInsert():
+		it.cur.s = it.cur.s[n:]
+	case it.cur.isDelete():
+		it.cur.n += n
+	default:
+		it.cur.n -= n
+	}
+}
+
+// Compose merges a and b, where b was made for the document a produces, into
+// one operation with the effect of applying a and then b.
+func Compose(a, b *Operation) (*Operation, error) {
+	if a.targetLen != b.baseLen {
+		return nil, ErrBaseLength
+	}
+	out := New()
+	ia, ib := newIterator(a), newIterator(b)
+	for ia.ok || ib.ok {
+		if ia.ok && ia.cur.isDelete() {
+			out.Delete(ia.length())
+			ia.next()
+			continue
+		}
+		if ib.ok && ib.cur.isInsert() {
+			out.insert(ib.cur.s)
+			ib.next()
+			continue
+		}
+		if !ia.ok || !ib.ok {
+			return nil, ErrBaseLength
+		}
+		n := min(ia.length(), ib.length())
+		switch {
+		case ia.cur.isRetain() && ib.cur.isRetain():
+			out.Retain(n)
+		case ia.cur.isRetain() && ib.cur.isDelete():
+			out.Delete(n)
+		case ia.cur.isInsert() && ib.cur.isRetain():
+			out.insert(ia.cur.s[:n])
+		case ia.cur.isInsert() && ib.cur.isDelete():
+			// b deletes what a inserted
+		}
+		ia.consume(n)
+		ib.consume(n)
+	}
+	return out, nil
+}
+
+// Transform takes two operations made concurrently for the same document
+// and returns a' and b' such that applying a then b' gives the same document
+// as applying b then a'. When both insert at the same position, a's text
+// comes first.
+func Transform(a, b *Operation) (aPrime, bPrime *Operation, err error) {
+	if a.baseLen != b.baseLen {
+		return nil, nil, ErrBaseLength
+	}
+	aPrime, bPrime = New(), New()
+	ia, ib := newIterator(a), newIterator(b)
+	for ia.ok || ib.ok {
+		if ia.ok && ia.cur.isInsert() {
+			aPrime.insert(ia.cur.s)
+			bPrime.Retain(len(ia.cur.s))
+			ia.next()
+			continue
+		}
+		if ib.ok && ib.cur.isInsert() {
+			aPrime.Retain(len(ib.cur.s))
+			bPrime.insert(ib.cur.s)
+			ib.next()
+			continue
+		}
+		if !ia.ok || !ib.ok {
+			return nil, nil, ErrBaseLength
+		}
+		n := min(ia.length(), ib.length())
+		switch {
+		case ia.cur.isRetain() && ib.cur.isRetain():
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case ia.cur.isDelete() && ib.cur.isRetain():
+			aPrime.Delete(n)
+		case ia.cur.isRetain() && ib.cur.isDelete():
+			bPrime.Delete(n)
+		case ia.cur.isDelete() && ib.cur.isDelete():
+			// both deleted the same text
+		}
+		ia.consume(n)
+		ib.consume(n)
+	}
+	return aPrime, bPrime, nil
+}
+
+// TransformIndex moves a position in the document o applies to, such as a
+// cursor, to the matching position in the document o produces. Text
+// inserted at the position pushes it forward.
+func TransformIndex(index int, o *Operation) int {
+	moved := index
+	for _, c := range o.ops {
+		switch {
+		case c.isRetain():
+			index -= c.n
+		case c.isInsert():
+			moved += len(c.s)
+		default:
+			moved -= min(index, -c.n)
+			index += c.n
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return moved
+}
+
+// Diff returns an operation that turns a into b, replacing the text between
+// their common prefix and suffix.
+func Diff(a, b string) *Operation {
+	ra, rb := []rune(a), []rune(b)
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix && ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+	return New().
+		Retain(prefix).
+		insert(rb[prefix : len(rb)-suffix]).
+		Delete(len(ra) - prefix - suffix).
+		Retain(suffix)
+}
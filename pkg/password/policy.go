@@ -0,0 +1,93 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLength bounds the work a single hash can cause.
+const MaxLength = 128
+
+// BcryptMaxBytes is the longest password bcrypt hashes; it refuses longer ones.
+const BcryptMaxBytes = 72
+
+//go:embed common.txt
+var commonList string
+
+// Policy validates new passwords: a minimum length and, optionally, a list of
+// breached or common passwords that are refused.
+type Policy struct {
+	MinLength int
+	// MaxBytes, when set, also bounds the length in bytes, for hashers like
+	// bcrypt that cannot take MaxLength multi-byte runes.
+	MaxBytes int
+	breached map[string]struct{}
+}
+
+// NewPolicy returns a policy refusing passwords shorter than minLength runes
+// or contained (case-insensitively) in breached.
+func NewPolicy(minLength int, breached []string) *Policy {
+	p := &Policy{MinLength: minLength, breached: make(map[string]struct{}, len(breached))}
+	for _, b := range breached {
+		p.breached[strings.ToLower(b)] = struct{}{}
+	}
+	return p
+}
+
+func (p *Policy) Check(password string) error {
+	n := utf8.RuneCountInString(password)
+	if n == 0 {
+		return errors.New("password is required")
+	}
+	if n < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if n > MaxLength {
+		return fmt.Errorf("password must be at most %d characters", MaxLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return fmt.Errorf("password must be at most %d bytes", p.MaxBytes)
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return errors.New("password is too common or appeared in a data breach")
+	}
+	return nil
+}
+
+// CommonPasswords returns the built-in list of very common passwords.
+func CommonPasswords() []string {
+	return parseList(commonList)
+}
+
+// LoadList reads a breached-password list with one password per line, e.g.
+// an export of a public breach corpus. Blank lines and lines starting with # are skipped.
+func LoadList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			out = append(out, line)
+		}
+	}
+	return out, sc.Err()
+}
+
+func parseList(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			out = append(out, line)
+		}
+	}
+	return out
+}
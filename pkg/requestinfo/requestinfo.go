@@ -0,0 +1,28 @@
+// Package requestinfo carries details of the HTTP request being served
+// through a context, for code that records who did something and from where.
+package requestinfo
+
+import "context"
+
+// Header carries the request ID in requests and responses.
+const Header = "X-Request-ID"
+
+// Info describes the request a context belongs to.
+type Info struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying info.
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext returns the Info stored in ctx, or the zero Info outside a request.
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
@@ -0,0 +1,100 @@
+package app_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+)
+
+func pemFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestKeySet_HS256WithPreviousSecrets(t *testing.T) {
+	keys, dir, err := app.NewKeySet(&config.Config{JWTSecret: "new", JWTPreviousSecrets: []string{"old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != nil {
+		t.Fatal("HS256 keys do not come from a directory")
+	}
+	if len(keys.JWKS().Keys) != 0 {
+		t.Fatal("HS256 secrets must not be published")
+	}
+	if _, _, err := app.NewKeySet(&config.Config{JWTAlgorithm: "none"}); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+}
+
+func TestKeyDir_GeneratesAndReloadsKey(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{JWTAlgorithm: "EdDSA", JWTKeysDir: dir, TokenTTL: 3600}
+
+	keys, _, err := app.NewKeySet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := pemFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one generated key, got %v", files)
+	}
+	if info, _ := os.Stat(files[0]); info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode = %v", info.Mode().Perm())
+	}
+
+	// a restart keeps using the same key
+	again, _, err := app.NewKeySet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ActiveID() != keys.ActiveID() || len(pemFiles(t, dir)) != 1 {
+		t.Fatalf("restart should reuse key %s, got %s", keys.ActiveID(), again.ActiveID())
+	}
+}
+
+func TestKeyDir_RotatesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{JWTAlgorithm: "EdDSA", JWTKeysDir: dir, TokenTTL: 3600, JWTKeyRotationInterval: 24 * time.Hour}
+	keys, kd, err := app.NewKeySet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := keys.ActiveID()
+
+	// pretend the key was created two days ago: it is due for rotation
+	old := filepath.Join(dir, first+".pem")
+	twoDaysAgo := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, twoDaysAgo, twoDaysAgo)
+	os.Rename(old, filepath.Join(dir, "20000101T000000Z.pem"))
+
+	removed, err := kd.Sync(context.Background())
+	if err != nil || removed != 0 {
+		t.Fatalf("sync: removed=%d err=%v", removed, err)
+	}
+	second := keys.ActiveID()
+	if second == "20000101T000000Z" || len(keys.JWKS().Keys) != 2 {
+		t.Fatalf("expected a new active key next to the retired one, got %s and %d keys", second, len(keys.JWKS().Keys))
+	}
+
+	// the retired key is deleted once its tokens have expired
+	newest := filepath.Join(dir, second+".pem")
+	longAgo := time.Now().Add(-3 * time.Hour)
+	os.Chtimes(newest, longAgo, longAgo)
+	removed, err = kd.Sync(context.Background())
+	if err != nil || removed != 1 {
+		t.Fatalf("sync: removed=%d err=%v", removed, err)
+	}
+	if keys.ActiveID() != second || len(pemFiles(t, dir)) != 1 || len(keys.JWKS().Keys) != 1 {
+		t.Fatalf("expected only %s to remain, files %v", second, pemFiles(t, dir))
+	}
+}
@@ -0,0 +1,80 @@
+package app_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+)
+
+func TestServer_GracefulShutdownDrainsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	cfg := &config.Config{ShutdownTimeout: 5 * time.Second}
+	srv := app.NewServer(cfg, handler)
+	hookCalled := make(chan struct{})
+	srv.OnShutdown("test", func(ctx context.Context) error {
+		close(hookCalled)
+		return nil
+	})
+	drained := make(chan struct{})
+	srv.OnDrain(func() { close(drained) })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ctx, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		resCh <- result{body: string(b)}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-resCh
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request was not drained: body=%q err=%v", res.body, res.err)
+	}
+	if err := <-serveErr; err != nil {
+		t.Fatalf("serve returned error: %v", err)
+	}
+	select {
+	case <-hookCalled:
+	default:
+		t.Fatalf("shutdown hook was not called")
+	}
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatalf("drain callback was not called")
+	}
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), 200*time.Millisecond); err == nil {
+		t.Fatalf("expected listener to be closed after shutdown")
+	}
+}
@@ -0,0 +1,69 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/migrate"
+	"github.com/MujiRahman/golang-simple-note/test/testutil"
+)
+
+func TestReadyz_DatabaseAndMigrations(t *testing.T) {
+	conn := &app.Connect{DB: testutil.NewMigratedSQLiteDB(t), Dialect: migrate.SQLite}
+	hs := service.NewHealthService(app.DatabaseCheck(conn), app.MigrationsCheck(conn))
+	router := app.NewRouter(newFakeUserService(), &fakeNoteService{}, &config.Config{}, app.WithHealthService(hs))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+	var report service.ReadinessReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "database" || report.Checks[0].Status != service.StatusUp {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestReadyz_FailingCheck(t *testing.T) {
+	hs := service.NewHealthService(service.NewHealthCheck("cache", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+	router := app.NewRouter(newFakeUserService(), &fakeNoteService{}, &config.Config{}, app.WithHealthService(hs))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d, body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHealthzAndVersion(t *testing.T) {
+	router := app.NewRouter(newFakeUserService(), &fakeNoteService{}, &config.Config{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from /healthz, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode version: %v", err)
+	}
+	if info["go_version"] != runtime.Version() || info["version"] == "" {
+		t.Fatalf("unexpected version info: %v", info)
+	}
+}
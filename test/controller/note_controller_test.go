@@ -2,6 +2,7 @@ package controller_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,16 +11,22 @@ import (
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/app"
 	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
 )
 
 // fakeUserService for middleware token parsing
-type fakeUserSvcForAuth struct{}
+type fakeUserSvcForAuth struct {
+	// methods not stubbed here panic if called
+	service.UserService
+}
 
-func (f *fakeUserSvcForAuth) Register(username, password string) (*model.User, error) {
+func (f *fakeUserSvcForAuth) Register(ctx context.Context, name, username, email, password string) (*model.User, error) {
 	return nil, nil
 }
-func (f *fakeUserSvcForAuth) Login(username, password string) (string, error) { return "tok-1", nil }
-func (f *fakeUserSvcForAuth) ParseToken(tokenStr string) (uint, error) {
+func (f *fakeUserSvcForAuth) Login(ctx context.Context, username, password string) (string, error) {
+	return "tok-1", nil
+}
+func (f *fakeUserSvcForAuth) ParseToken(ctx context.Context, tokenStr string) (uint, error) {
 	if tokenStr == "tok-1" {
 		return 7, nil
 	}
@@ -28,22 +35,35 @@ func (f *fakeUserSvcForAuth) ParseToken(tokenStr string) (uint, error) {
 
 // fake NoteService that records created notes
 type fakeNoteSvc struct {
-	created *model.Note
+	created     *model.Note
+	workspaceID uint
 }
 
-func (f *fakeNoteSvc) Create(userID uint, title, content string) (*model.Note, error) {
-	n := &model.Note{ID: 11, UserID: userID, Title: title, Content: content}
+func (f *fakeNoteSvc) Create(ctx context.Context, userID, workspaceID uint, title, content string) (*model.Note, error) {
+	if workspaceID == 99 {
+		return nil, service.ErrWorkspaceForbidden
+	}
+	n := &model.Note{ID: 11, UserID: userID, WorkspaceID: workspaceID, Title: title, Content: content}
 	f.created = n
+	f.workspaceID = workspaceID
 	return n, nil
 }
-func (f *fakeNoteSvc) GetByID(userID, id uint) (*model.Note, error) { return f.created, nil }
-func (f *fakeNoteSvc) ListByUser(userID uint) ([]model.Note, error) {
+func (f *fakeNoteSvc) GetByID(ctx context.Context, userID, workspaceID, id uint) (*model.Note, error) {
+	return f.created, nil
+}
+func (f *fakeNoteSvc) ListByWorkspace(ctx context.Context, userID, workspaceID uint) ([]model.Note, error) {
 	return []model.Note{*f.created}, nil
 }
-func (f *fakeNoteSvc) Update(userID, id uint, title, content string) (*model.Note, error) {
+func (f *fakeNoteSvc) Update(ctx context.Context, userID, workspaceID, id uint, title, content string) (*model.Note, error) {
 	return f.created, nil
 }
-func (f *fakeNoteSvc) Delete(userID, id uint) error { return nil }
+func (f *fakeNoteSvc) Delete(ctx context.Context, userID, workspaceID, id uint) error { return nil }
+func (f *fakeNoteSvc) UpdateIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64, title, content string) (*model.Note, error) {
+	return f.created, nil
+}
+func (f *fakeNoteSvc) DeleteIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64) error {
+	return nil
+}
 
 func TestCreateNote_Unauthorized(t *testing.T) {
 	us := &fakeUserSvcForAuth{}
@@ -79,3 +99,39 @@ func TestCreateNote_Success(t *testing.T) {
 		t.Fatalf("expected 201, got %d, body=%s", rr.Code, rr.Body.String())
 	}
 }
+
+func TestCreateNote_ActiveWorkspace(t *testing.T) {
+	us := &fakeUserSvcForAuth{}
+	ns := &fakeNoteSvc{}
+	router := app.NewRouter(us, ns, &config.Config{})
+
+	post := func(path, workspace string) int {
+		b, _ := json.Marshal(map[string]string{"title": "t1", "content": "c1"})
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer tok-1")
+		if workspace != "" {
+			req.Header.Set("X-Workspace-ID", workspace)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := post("/notes", ""); code != http.StatusCreated || ns.workspaceID != 0 {
+		t.Fatalf("personal workspace: status %d, workspace %d", code, ns.workspaceID)
+	}
+	if code := post("/notes", "5"); code != http.StatusCreated || ns.workspaceID != 5 {
+		t.Fatalf("header: status %d, workspace %d", code, ns.workspaceID)
+	}
+	// the path wins over the header
+	if code := post("/workspaces/7/notes", "5"); code != http.StatusCreated || ns.workspaceID != 7 {
+		t.Fatalf("path: status %d, workspace %d", code, ns.workspaceID)
+	}
+	if code := post("/notes", "abc"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed workspace id, got %d", code)
+	}
+	if code := post("/workspaces/99/notes", ""); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an insufficient role, got %d", code)
+	}
+}
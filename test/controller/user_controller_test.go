@@ -2,6 +2,7 @@ package controller_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -12,10 +13,14 @@ import (
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/app"
 	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
 )
 
 // fakeUserService implements service.UserService for controller tests
 type fakeUserService struct {
+	// methods not stubbed here panic if called
+	service.UserService
+
 	registered map[string]*model.User
 }
 
@@ -23,18 +28,18 @@ func newFakeUserService() *fakeUserService {
 	return &fakeUserService{registered: map[string]*model.User{}}
 }
 
-func (f *fakeUserService) Register(username, password string) (*model.User, error) {
+func (f *fakeUserService) Register(ctx context.Context, name, username, email, password string) (*model.User, error) {
 	u := &model.User{ID: 100, Username: username}
 	f.registered[username] = u
 	return u, nil
 }
-func (f *fakeUserService) Login(username, password string) (string, error) {
+func (f *fakeUserService) Login(ctx context.Context, username, password string) (string, error) {
 	if _, ok := f.registered[username]; !ok {
 		return "", errors.New("invalid credentials")
 	}
 	return "tok-123", nil
 }
-func (f *fakeUserService) ParseToken(tokenStr string) (uint, error) {
+func (f *fakeUserService) ParseToken(ctx context.Context, tokenStr string) (uint, error) {
 	// token "tok-123" maps to user id 100
 	if tokenStr == "tok-123" {
 		return 100, nil
@@ -47,7 +52,7 @@ func TestRegisterHandler(t *testing.T) {
 	ns := &fakeNoteService{} // not used here
 	router := app.NewRouter(us, ns, &config.Config{})
 
-	body := map[string]string{"username": "alice", "password": "pw"}
+	body := map[string]string{"name": "Alice", "username": "alice", "email": "alice@example.com", "password": "pw"}
 	b, _ := json.Marshal(body)
 	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
@@ -61,7 +66,7 @@ func TestRegisterHandler(t *testing.T) {
 
 func TestLoginHandler(t *testing.T) {
 	us := newFakeUserService()
-	us.Register("bob", "pw")
+	us.Register(context.Background(), "Bob", "bob", "bob@example.com", "pw")
 	ns := &fakeNoteService{}
 	router := app.NewRouter(us, ns, &config.Config{})
 
@@ -80,12 +85,22 @@ func TestLoginHandler(t *testing.T) {
 // minimal fakeNoteService used to satisfy NewRouter; real tests for notes in other file
 type fakeNoteService struct{}
 
-func (f *fakeNoteService) Create(userID uint, title, content string) (*model.Note, error) {
+func (f *fakeNoteService) Create(ctx context.Context, userID, workspaceID uint, title, content string) (*model.Note, error) {
+	return nil, nil
+}
+func (f *fakeNoteService) GetByID(ctx context.Context, userID, workspaceID, id uint) (*model.Note, error) {
+	return nil, nil
+}
+func (f *fakeNoteService) ListByWorkspace(ctx context.Context, userID, workspaceID uint) ([]model.Note, error) {
+	return nil, nil
+}
+func (f *fakeNoteService) Update(ctx context.Context, userID, workspaceID, id uint, title, content string) (*model.Note, error) {
 	return nil, nil
 }
-func (f *fakeNoteService) GetByID(userID, id uint) (*model.Note, error) { return nil, nil }
-func (f *fakeNoteService) ListByUser(userID uint) ([]model.Note, error) { return nil, nil }
-func (f *fakeNoteService) Update(userID, id uint, title, content string) (*model.Note, error) {
+func (f *fakeNoteService) Delete(ctx context.Context, userID, workspaceID, id uint) error { return nil }
+func (f *fakeNoteService) UpdateIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64, title, content string) (*model.Note, error) {
 	return nil, nil
 }
-func (f *fakeNoteService) Delete(userID, id uint) error { return nil }
+func (f *fakeNoteService) DeleteIfUnchanged(ctx context.Context, userID, workspaceID, id uint, baseSeq uint64) error {
+	return nil
+}
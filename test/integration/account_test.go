@@ -0,0 +1,130 @@
+package integration_test
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/mailer"
+)
+
+var linkTokenRe = regexp.MustCompile(`\?token=(\S+)`)
+
+// lastToken returns the token from the link in the most recent email to addr.
+func (a *testApp) lastToken(addr string) string {
+	a.t.Helper()
+	msgs := a.Container.Mailer.(*mailer.MemoryMailer).Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].To != addr {
+			continue
+		}
+		m := linkTokenRe.FindStringSubmatch(msgs[i].Body)
+		if m == nil {
+			a.t.Fatalf("no link in email %q", msgs[i].Body)
+		}
+		token, _ := url.QueryUnescape(m[1])
+		return token
+	}
+	a.t.Fatalf("no email sent to %s", addr)
+	return ""
+}
+
+func TestRegister_RequiresValidUniqueEmail(t *testing.T) {
+	a := newTestApp(t)
+	a.registerAndLogin("first", "pass")
+
+	for name, body := range map[string]map[string]string{
+		"missing":   {"username": "u1", "password": "pass"},
+		"malformed": {"username": "u2", "email": "not-an-email", "password": "pass"},
+		"taken":     {"username": "u3", "email": "FIRST@example.com", "password": "pass"},
+	} {
+		if resp := a.do(http.MethodPost, "/register", "", body, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s email: expected 400, got %d", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("verify-me", "pass")
+
+	// resending replaces the link sent on registration
+	first := a.lastToken("verify-me@example.com")
+	if resp := a.do(http.MethodPost, "/email/verify/resend", jwt, nil, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("resend: status %d", resp.StatusCode)
+	}
+	token := a.lastToken("verify-me@example.com")
+	if resp := a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": first}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected superseded token to be rejected, got %d", resp.StatusCode)
+	}
+
+	if resp := a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": token}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("verify: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": token}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected token to be single-use, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/email/verify/resend", jwt, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected resend to fail for a verified address, got %d", resp.StatusCode)
+	}
+}
+
+func TestEmailVerification_OnlyForTheAddressItWasSentTo(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("mover", "pass")
+	old := a.lastToken("mover@example.com")
+	a.do(http.MethodPost, "/password/forgot", "", map[string]string{"email": "mover@example.com"}, nil)
+	oldReset := a.lastToken("mover@example.com")
+
+	if resp := a.do(http.MethodPatch, "/me", jwt, map[string]string{"email": "moved@example.com"}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("change email: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": old}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the link sent to the previous address to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/password/reset", "", map[string]string{"token": oldReset, "password": "new-pass"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the reset link sent to the previous address to be rejected, got %d", resp.StatusCode)
+	}
+	var me map[string]any
+	if a.do(http.MethodGet, "/me", jwt, nil, &me); me["email_verified"] != false {
+		t.Fatalf("expected the new address to be unverified, got %v", me)
+	}
+
+	if resp := a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": a.lastToken("moved@example.com")}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("verify the new address: status %d", resp.StatusCode)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	a := newTestApp(t)
+	a.registerAndLogin("forgetful", "old-pass")
+
+	// unknown addresses get the same answer and no email
+	sent := len(a.Container.Mailer.(*mailer.MemoryMailer).Messages())
+	if resp := a.do(http.MethodPost, "/password/forgot", "", map[string]string{"email": "nobody@example.com"}, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("forgot (unknown): status %d", resp.StatusCode)
+	}
+	if n := len(a.Container.Mailer.(*mailer.MemoryMailer).Messages()); n != sent {
+		t.Fatalf("expected no email for an unknown address, %d sent", n-sent)
+	}
+
+	if resp := a.do(http.MethodPost, "/password/forgot", "", map[string]string{"email": "forgetful@example.com"}, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("forgot: status %d", resp.StatusCode)
+	}
+	token := a.lastToken("forgetful@example.com")
+	reset := map[string]string{"token": token, "password": "new-pass"}
+	if resp := a.do(http.MethodPost, "/password/reset", "", reset, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("reset: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/password/reset", "", reset, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected reset token to be single-use, got %d", resp.StatusCode)
+	}
+
+	if resp := a.do(http.MethodPost, "/login", "", map[string]string{"username": "forgetful", "password": "old-pass"}, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected old password to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/login", "", map[string]string{"username": "forgetful", "password": "new-pass"}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected new password to work, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,177 @@
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type userPageResp struct {
+	Users []struct {
+		ID       uint   `json:"id"`
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	} `json:"users"`
+	Total   int64 `json:"total"`
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+}
+
+// registerAdmin registers username and promotes it as cmd/admin does. The
+// role is checked per request, so the token from before the promotion works.
+func (a *testApp) registerAdmin(username string) string {
+	a.t.Helper()
+	token := a.registerAndLogin(username, "pass")
+	if _, err := a.Container.Svcs.Admin.SetRole(a.t.Context(), username, model.RoleAdmin); err != nil {
+		a.t.Fatalf("promote %s: %v", username, err)
+	}
+	return token
+}
+
+func (a *testApp) userID(username string) uint {
+	a.t.Helper()
+	var u model.User
+	if err := a.DB.Where("username = ?", username).First(&u).Error; err != nil {
+		a.t.Fatalf("find %s: %v", username, err)
+	}
+	return u.ID
+}
+
+func TestAdmin_RequiresAdminRole(t *testing.T) {
+	a := newTestApp(t)
+	user := a.registerAndLogin("plain", "pass")
+	admin := a.registerAdmin("root")
+
+	if resp := a.do(http.MethodGet, "/admin/users", user, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/admin/users", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", resp.StatusCode)
+	}
+
+	// a PAT needs the admin scope on top of the role
+	var pat struct {
+		Token string `json:"token"`
+	}
+	a.do(http.MethodPost, "/account/tokens", admin, map[string]any{"name": "ro", "scopes": []string{"notes:read"}}, &pat)
+	if resp := a.do(http.MethodGet, "/admin/users", pat.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a PAT without the admin scope, got %d", resp.StatusCode)
+	}
+	a.do(http.MethodPost, "/account/tokens", admin, map[string]any{"name": "ops", "scopes": []string{"admin"}}, &pat)
+	if resp := a.do(http.MethodGet, "/admin/users", pat.Token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the admin PAT to list users, got %d", resp.StatusCode)
+	}
+
+	// admins cannot act on admins, themselves included
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/admin/users/%d/disable", a.userID("root")), admin, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 disabling an admin, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdmin_ListUsersAndStats(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.registerAdmin("root")
+	for _, name := range []string{"alice", "alicia", "bob", "Al_ex"} {
+		a.registerAndLogin(name, "pass")
+	}
+
+	var page userPageResp
+	if resp := a.do(http.MethodGet, "/admin/users?q=ALI&per_page=1&page=2", admin, nil, &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list: status %d", resp.StatusCode)
+	}
+	if page.Total != 2 || page.Page != 2 || page.PerPage != 1 || len(page.Users) != 1 || page.Users[0].Username != "alicia" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	// wildcards in the query are taken literally
+	a.do(http.MethodGet, "/admin/users?q=l_e", admin, nil, &page)
+	if page.Total != 1 || page.Users[0].Username != "Al_ex" {
+		t.Fatalf("expected only Al_ex to match l_e, got %+v", page)
+	}
+	if resp := a.do(http.MethodGet, "/admin/users?page=x", admin, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad page, got %d", resp.StatusCode)
+	}
+
+	var stats model.UsageStats
+	if resp := a.do(http.MethodGet, "/admin/stats?days=7", admin, nil, &stats); resp.StatusCode != http.StatusOK {
+		t.Fatalf("stats: status %d", resp.StatusCode)
+	}
+	if stats.Users != 5 || stats.Admins != 1 || stats.NewUsers != 5 || stats.Workspaces != 5 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestAdmin_DisableEnableAndDelete(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.registerAdmin("root")
+	user := a.registerAndLogin("target", "pass")
+	var pat struct {
+		Token string `json:"token"`
+	}
+	a.do(http.MethodPost, "/account/tokens", user, map[string]any{"name": "ci", "scopes": []string{"notes:read"}}, &pat)
+	a.do(http.MethodPost, "/notes", user, map[string]string{"title": "t", "content": "c"}, nil)
+	path := fmt.Sprintf("/admin/users/%d", a.userID("target"))
+
+	var got struct {
+		DisabledAt *string          `json:"disabled_at"`
+		Usage      *model.UserUsage `json:"usage"`
+	}
+	a.do(http.MethodGet, path, admin, nil, &got)
+	if got.Usage == nil || got.Usage.Notes != 1 || got.Usage.Workspaces != 1 || got.Usage.ActiveTokens != 1 {
+		t.Fatalf("unexpected usage: %+v", got.Usage)
+	}
+
+	if resp := a.do(http.MethodPost, path+"/disable", admin, nil, &got); resp.StatusCode != http.StatusOK || got.DisabledAt == nil {
+		t.Fatalf("disable: status %d, %+v", resp.StatusCode, got)
+	}
+	for name, token := range map[string]string{"jwt": user, "pat": pat.Token} {
+		if resp := a.do(http.MethodGet, "/notes", token, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the %s of a disabled user to be rejected, got %d", name, resp.StatusCode)
+		}
+	}
+	if resp := a.do(http.MethodPost, "/login", "", map[string]string{"username": "target", "password": "pass"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 logging into a disabled account, got %d", resp.StatusCode)
+	}
+
+	if resp := a.do(http.MethodPost, path+"/enable", admin, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("enable: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/notes", pat.Token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the PAT to work again once enabled, got %d", resp.StatusCode)
+	}
+
+	if resp := a.do(http.MethodDelete, path, admin, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, path, admin, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after deletion, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdmin_ForcePasswordReset(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.registerAdmin("root")
+	user := a.registerAndLogin("reset-me", "old-pass")
+
+	path := fmt.Sprintf("/admin/users/%d/password-reset", a.userID("reset-me"))
+	if resp := a.do(http.MethodPost, path, admin, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("force reset: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/notes", user, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected existing sessions to be revoked, got %d", resp.StatusCode)
+	}
+	creds := map[string]string{"username": "reset-me", "password": "old-pass"}
+	if resp := a.do(http.MethodPost, "/login", "", creds, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 until the password is reset, got %d", resp.StatusCode)
+	}
+
+	reset := map[string]string{"token": a.lastToken("reset-me@example.com"), "password": "new-pass"}
+	if resp := a.do(http.MethodPost, "/password/reset", "", reset, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("reset: status %d", resp.StatusCode)
+	}
+	creds["password"] = "new-pass"
+	if resp := a.do(http.MethodPost, "/login", "", creds, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected login with the new password, got %d", resp.StatusCode)
+	}
+}
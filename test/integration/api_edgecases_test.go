@@ -30,7 +30,7 @@ func TestE2E_LoginInvalidCredentials(t *testing.T) {
 	defer server.Close()
 
 	// register first
-	regBody := map[string]string{"username": "e2euser2", "password": "pass"}
+	regBody := map[string]string{"username": "e2euser2", "email": "e2euser2@example.com", "password": "pass"}
 	rb, _ := json.Marshal(regBody)
 	resp, err := http.Post(server.URL+"/register", "application/json", bytes.NewReader(rb))
 	if err != nil {
@@ -76,7 +76,7 @@ func TestE2E_CreateNote_InvalidPayload(t *testing.T) {
 	defer server.Close()
 
 	// register & login
-	regBody := map[string]string{"username": "e2euser3", "password": "pass"}
+	regBody := map[string]string{"username": "e2euser3", "email": "e2euser3@example.com", "password": "pass"}
 	rb, _ := json.Marshal(regBody)
 	resp, err := http.Post(server.URL+"/register", "application/json", bytes.NewReader(rb))
 	if err != nil {
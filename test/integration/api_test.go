@@ -24,16 +24,19 @@ func setupRouterForTest(t *testing.T) http.Handler {
 		t.Fatalf("open gorm sqlite: %v", err)
 	}
 	// migrate
-	if err := gdb.AutoMigrate(&model.User{}, &model.Note{}); err != nil {
+	if err := gdb.AutoMigrate(&model.User{}, &model.Note{}, &model.Workspace{}, &model.WorkspaceMember{}, &model.AuditEvent{}, &model.Webhook{}, &model.WebhookDelivery{}, &model.OutboxEvent{}, &model.Comment{}, &model.CommentRevision{}, &model.Notification{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 
 	userRepo := repository.NewUserRepository(gdb)
 	noteRepo := repository.NewNoteRepository(gdb)
+	workspaceRepo := repository.NewWorkspaceRepository(gdb)
 
 	cfg := &config.Config{JWTSecret: "integration-secret", TokenTTL: 3600}
-	userSvc := service.NewUserService(userRepo, cfg)
-	noteSvc := service.NewNoteService(noteRepo)
+	audit := service.NewAuditService(repository.NewAuditRepository(gdb), repository.NewTransactor(gdb))
+	uow := service.NewUnitOfWork(repository.NewTransactor(gdb), repository.NewOutboxRepository(gdb))
+	userSvc := service.NewUserService(userRepo, workspaceRepo, audit, uow, hmacKeys(t, cfg.JWTSecret), cfg)
+	noteSvc := service.NewNoteService(noteRepo, service.NewWorkspaceService(workspaceRepo, userRepo, audit, uow), audit, uow, service.NewNotificationService(repository.NewNotificationRepository(gdb), userRepo, workspaceRepo, uow))
 
 	return app.NewRouter(userSvc, noteSvc, cfg)
 }
@@ -44,7 +47,7 @@ func TestEndToEnd_RegisterLoginCreateList(t *testing.T) {
 	defer server.Close()
 
 	// register
-	regBody := map[string]string{"username": "e2euser", "password": "pass"}
+	regBody := map[string]string{"username": "e2euser", "email": "e2euser@example.com", "password": "pass"}
 	b, _ := json.Marshal(regBody)
 	resp, err := http.Post(server.URL+"/register", "application/json", bytes.NewReader(b))
 	if err != nil {
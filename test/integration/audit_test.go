@@ -0,0 +1,89 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type auditPageResp struct {
+	Events []model.AuditEvent `json:"events"`
+	Total  int64              `json:"total"`
+}
+
+func TestAudit_NoteLifecycleIsRecorded(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.registerAdmin("root")
+	user := a.registerAndLogin("writer", "pass")
+
+	// the create request carries its own request ID, which is echoed back
+	req, _ := http.NewRequest(http.MethodPost, a.Server.URL+"/notes", strings.NewReader(`{"title":"t","content":"c"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+user)
+	req.Header.Set("User-Agent", "audit-test/1.0")
+	req.Header.Set("X-Request-ID", "req-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("X-Request-ID") != "req-123" {
+		t.Fatalf("create note: status %d, request id %q", resp.StatusCode, resp.Header.Get("X-Request-ID"))
+	}
+	var note model.Note
+	json.NewDecoder(resp.Body).Decode(&note)
+
+	a.do(http.MethodPut, fmt.Sprintf("/notes/%d", note.ID), user, map[string]string{"title": "t2", "content": "c2"}, nil)
+	a.do(http.MethodDelete, fmt.Sprintf("/notes/%d", note.ID), user, nil, nil)
+
+	var page auditPageResp
+	path := fmt.Sprintf("/admin/audit?target_type=note&target_id=%d", note.ID)
+	if resp := a.do(http.MethodGet, path, admin, nil, &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list audit: status %d", resp.StatusCode)
+	}
+	if page.Total != 3 || len(page.Events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", page)
+	}
+	// newest first
+	del, upd, create := page.Events[0], page.Events[1], page.Events[2]
+	if create.Action != model.AuditNoteCreate || upd.Action != model.AuditNoteUpdate || del.Action != model.AuditNoteDelete {
+		t.Fatalf("unexpected actions: %s, %s, %s", create.Action, upd.Action, del.Action)
+	}
+	if create.ActorID != a.userID("writer") || create.RequestID != "req-123" || create.UserAgent != "audit-test/1.0" || create.IP == "" {
+		t.Fatalf("unexpected request details: %+v", create)
+	}
+	if upd.Before["title"] != "t" || upd.After["title"] != "t2" || del.After != nil {
+		t.Fatalf("unexpected summaries: update %+v, delete %+v", upd, del)
+	}
+
+	if resp := a.do(http.MethodGet, "/admin/audit?since=yesterday", admin, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed since, got %d", resp.StatusCode)
+	}
+}
+
+func TestAudit_UsersSeeTheirOwnEvents(t *testing.T) {
+	a := newTestApp(t)
+	user := a.registerAndLogin("victim", "pass")
+	other := a.registerAndLogin("other", "pass")
+
+	a.do(http.MethodPost, "/login", "", map[string]string{"username": "victim", "password": "wrong"}, nil)
+
+	var page auditPageResp
+	if resp := a.do(http.MethodGet, "/me/audit?action="+model.AuditUserLoginFailed, user, nil, &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("my audit: status %d", resp.StatusCode)
+	}
+	if page.Total != 1 || page.Events[0].TargetID != a.userID("victim") || page.Events[0].ActorID != 0 {
+		t.Fatalf("expected the failed login on my account, got %+v", page)
+	}
+	a.do(http.MethodGet, "/me/audit?action="+model.AuditUserLoginFailed, other, nil, &page)
+	if page.Total != 0 {
+		t.Fatalf("expected no failed logins for another user, got %+v", page)
+	}
+	if resp := a.do(http.MethodGet, "/admin/audit", user, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,316 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/MujiRahman/golang-simple-note/internal/controller"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/middleware"
+	"github.com/MujiRahman/golang-simple-note/pkg/ot"
+)
+
+// collabEditor is a test editor: it keeps its copy of the document in sync
+// the way a real client does, through an ot.Client.
+type collabEditor struct {
+	t      *testing.T
+	ws     *websocket.Conn
+	id     string
+	text   string
+	client *ot.Client
+	// presence is the last list of collaborators received
+	presence []service.Collaborator
+}
+
+// collab connects to path, authenticating like a browser does, with the
+// token as a WebSocket subprotocol, when asBrowser is set.
+func (a *testApp) collab(path, token string, asBrowser bool) *collabEditor {
+	a.t.Helper()
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(a.Server.URL, "http")+path, a.Server.URL)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if asBrowser {
+		cfg.Protocol = []string{controller.CollabProtocol, middleware.WebSocketTokenProtocol + token}
+	} else {
+		cfg.Header.Set("Authorization", "Bearer "+token)
+	}
+	ws, err := websocket.DialConfig(cfg)
+	if err != nil {
+		a.t.Fatalf("dial %s: %v", path, err)
+	}
+	a.t.Cleanup(func() { ws.Close() })
+	e := &collabEditor{t: a.t, ws: ws}
+	init := e.read()
+	if init.Type != service.CollabInit || init.Content == nil {
+		a.t.Fatalf("expected init, got %+v", init)
+	}
+	e.id, e.text, e.client, e.presence = init.ClientID, *init.Content, ot.NewClient(init.Revision), init.Collaborators
+	return e
+}
+
+func (e *collabEditor) read() service.CollabMessage {
+	e.t.Helper()
+	e.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg service.CollabMessage
+	if err := websocket.JSON.Receive(e.ws, &msg); err != nil {
+		e.t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+// next returns the next message that is not about presence.
+func (e *collabEditor) next() service.CollabMessage {
+	e.t.Helper()
+	for {
+		if msg := e.read(); msg.Type != service.CollabPresence {
+			return msg
+		}
+	}
+}
+
+func (e *collabEditor) send(msg any) {
+	e.t.Helper()
+	if err := websocket.JSON.Send(e.ws, msg); err != nil {
+		e.t.Fatalf("send: %v", err)
+	}
+}
+
+func (e *collabEditor) sendOp(op *ot.Operation) {
+	e.send(map[string]any{"type": "op", "revision": e.client.Revision, "op": op})
+}
+
+// edit applies op to the local copy and sends it, unless it has to wait
+// for the operation in flight.
+func (e *collabEditor) edit(op *ot.Operation) {
+	e.t.Helper()
+	text, err := op.Apply(e.text)
+	if err != nil {
+		e.t.Fatalf("edit: %v", err)
+	}
+	e.text = text
+	send, err := e.client.ApplyLocal(op)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if send != nil {
+		e.sendOp(send)
+	}
+}
+
+// handle processes one message from the server.
+func (e *collabEditor) handle(msg service.CollabMessage) {
+	e.t.Helper()
+	switch msg.Type {
+	case service.CollabAck:
+		next, err := e.client.Ack()
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		if next != nil {
+			e.sendOp(next)
+		}
+	case service.CollabOp:
+		op, err := e.client.ApplyRemote(msg.Op)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		if e.text, err = op.Apply(e.text); err != nil {
+			e.t.Fatal(err)
+		}
+	case service.CollabPresence:
+		e.presence = msg.Collaborators
+	case service.CollabError:
+		e.t.Fatalf("server error: %s", msg.Error)
+	}
+	if msg.Revision != 0 && msg.Type != service.CollabPresence && msg.Revision != e.client.Revision {
+		e.t.Fatalf("revision %d after %s, expected %d", e.client.Revision, msg.Type, msg.Revision)
+	}
+}
+
+// settle handles messages until the local copy is want and acknowledged.
+func (e *collabEditor) settle(want string) {
+	e.t.Helper()
+	for e.text != want || e.client.Pending() {
+		e.handle(e.read())
+	}
+}
+
+// waitFor handles messages until cond holds.
+func (e *collabEditor) waitFor(cond func() bool) {
+	e.t.Helper()
+	for !cond() {
+		e.handle(e.read())
+	}
+}
+
+func (a *testApp) noteContent(token string, path string) string {
+	a.t.Helper()
+	var n model.Note
+	if resp := a.do(http.MethodGet, path, token, nil, &n); resp.StatusCode != http.StatusOK {
+		a.t.Fatalf("get %s: status %d", path, resp.StatusCode)
+	}
+	return n.Content
+}
+
+func TestCollab_EditorsConvergeAndSave(t *testing.T) {
+	a := newTestApp(t)
+	alice := a.registerAndLogin("alice", "pass")
+	bob := a.registerAndLogin("bob", "pass")
+	carol := a.registerAndLogin("carol", "pass")
+
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", alice, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(alice, team.ID, "bob", bob, "member")
+	a.inviteAndAccept(alice, team.ID, "carol", carol, "guest")
+	var note model.Note
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), alice, map[string]string{"title": "plan", "content": "hello"}, &note)
+	notePath := fmt.Sprintf("/workspaces/%d/notes/%d", team.ID, note.ID)
+
+	ea := a.collab(notePath+"/collab", alice, false)
+	eb := a.collab(notePath+"/collab", bob, true)
+	if eb.text != "hello" || len(eb.presence) != 2 {
+		t.Fatalf("unexpected init for bob: %q, %+v", eb.text, eb.presence)
+	}
+	ea.waitFor(func() bool { return len(ea.presence) == 2 })
+
+	// concurrent edits of the same revision, and a second edit buffered
+	// while the first is in flight
+	ea.edit(ot.New().Retain(5).Insert(" world"))
+	eb.edit(ot.New().Insert("Oh, ").Retain(5))
+	ea.edit(ot.New().Retain(11).Insert("!"))
+	ea.settle("Oh, hello world!")
+	eb.settle("Oh, hello world!")
+	if ea.client.Revision != 3 || eb.client.Revision != 3 {
+		t.Fatalf("expected revision 3, got %d and %d", ea.client.Revision, eb.client.Revision)
+	}
+
+	// cursors are shown to the others and move along with edits
+	eb.send(map[string]any{"type": "cursor", "revision": 3, "cursor": map[string]int{"position": 3, "anchor": 0}})
+	bobCursor := func() *service.Cursor {
+		for _, c := range ea.presence {
+			if c.ClientID == eb.id {
+				return c.Cursor
+			}
+		}
+		return nil
+	}
+	ea.waitFor(func() bool { return bobCursor() != nil })
+	if c := bobCursor(); c.Position != 3 || c.Anchor != 0 {
+		t.Fatalf("unexpected cursor %+v", c)
+	}
+
+	// a guest may watch but not edit
+	ec := a.collab(notePath+"/collab", carol, false)
+	if ec.text != "Oh, hello world!" {
+		t.Fatalf("unexpected text for carol: %q", ec.text)
+	}
+	ec.sendOp(ot.New().Retain(16).Insert("?"))
+	if msg := ec.next(); msg.Type != service.CollabError || msg.Error != service.ErrCollabReadOnly.Error() {
+		t.Fatalf("expected a read-only error, got %+v", msg)
+	}
+
+	// snapshots save the document through the note service
+	if n, err := a.Container.Svcs.Collab.Snapshot(a.t.Context()); err != nil || n != 1 {
+		t.Fatalf("snapshot: %d, %v", n, err)
+	}
+	if got := a.noteContent(alice, notePath); got != "Oh, hello world!" {
+		t.Fatalf("expected the edits saved, got %q", got)
+	}
+
+	// a change through the REST API is merged into the open document
+	a.do(http.MethodPut, notePath, bob, map[string]string{"title": "plan", "content": "Oh, hello there world!"}, nil)
+	ea.edit(ot.New().Insert("> ").Retain(16))
+	ea.settle("> Oh, hello world!")
+	if _, err := a.Container.Svcs.Collab.Snapshot(a.t.Context()); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	ea.settle("> Oh, hello there world!")
+	eb.settle("> Oh, hello there world!")
+	if got := a.noteContent(alice, notePath); got != "> Oh, hello there world!" {
+		t.Fatalf("expected the merged document saved, got %q", got)
+	}
+
+	// the document is saved when the last editor leaves
+	eb.edit(ot.New().Retain(24).Insert(" :)"))
+	eb.settle("> Oh, hello there world! :)")
+	ea.ws.Close()
+	eb.ws.Close()
+	ec.ws.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for a.noteContent(alice, notePath) != "> Oh, hello there world! :)" {
+		if time.Now().After(deadline) {
+			t.Fatalf("document not saved after the editors left: %q", a.noteContent(alice, notePath))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// upgradeStatus sends a WebSocket handshake and returns the status code.
+func (a *testApp) upgradeStatus(path, token, protocols string) int {
+	a.t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, a.Server.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if protocols != "" {
+		req.Header.Set("Sec-WebSocket-Protocol", protocols)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("upgrade %s: %v", path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestCollab_Rejections(t *testing.T) {
+	a := newTestApp(t)
+	alice := a.registerAndLogin("alice", "pass")
+	mallory := a.registerAndLogin("mallory", "pass")
+	var note model.Note
+	a.do(http.MethodPost, "/notes", alice, map[string]string{"title": "t", "content": "c"}, &note)
+	path := fmt.Sprintf("/notes/%d/collab", note.ID)
+
+	if resp := a.do(http.MethodGet, path, alice, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an upgrade, got %d", resp.StatusCode)
+	}
+	cases := []struct {
+		name, path, token, protocols string
+		want                         int
+	}{
+		{"no token", path, "", "", http.StatusUnauthorized},
+		{"another user's note", path, mallory, "", http.StatusNotFound},
+		{"unknown note", "/notes/9999/collab", alice, "", http.StatusNotFound},
+		{"unsupported subprotocol", path, alice, "chat", http.StatusForbidden},
+		{"ok", path, alice, controller.CollabProtocol, http.StatusSwitchingProtocols},
+	}
+	for _, c := range cases {
+		if got := a.upgradeStatus(c.path, c.token, c.protocols); got != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, got)
+		}
+	}
+
+	// invalid messages end the session with an error
+	e := a.collab(path, alice, false)
+	e.send(map[string]any{"type": "op", "revision": 0, "op": []any{5}})
+	if msg := e.next(); msg.Type != service.CollabError {
+		t.Fatalf("expected an error for an op of the wrong length, got %+v", msg)
+	}
+	e = a.collab(path, alice, false)
+	e.send(json.RawMessage(`{"type":"dance"}`))
+	if msg := e.next(); msg.Type != service.CollabError {
+		t.Fatalf("expected an error for an unknown message, got %+v", msg)
+	}
+}
@@ -0,0 +1,214 @@
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type notificationPage struct {
+	Notifications []model.Notification `json:"notifications"`
+	Total         int64                `json:"total"`
+	Unread        int64                `json:"unread"`
+}
+
+func (a *testApp) notifications(token string, unreadOnly bool) notificationPage {
+	a.t.Helper()
+	var page notificationPage
+	if resp := a.do(http.MethodGet, fmt.Sprintf("/notifications?unread=%t", unreadOnly), token, nil, &page); resp.StatusCode != http.StatusOK {
+		a.t.Fatalf("notifications: status %d", resp.StatusCode)
+	}
+	return page
+}
+
+func TestComments_ThreadsHistoryAndDelete(t *testing.T) {
+	a := newTestApp(t)
+	owner := a.registerAndLogin("owner", "pass")
+	member := a.registerAndLogin("member", "pass")
+	guest := a.registerAndLogin("guest", "pass")
+	outsider := a.registerAndLogin("outsider", "pass")
+
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", owner, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(owner, team.ID, "member", member, "member")
+	a.inviteAndAccept(owner, team.ID, "guest", guest, "guest")
+
+	var note model.Note
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), member, map[string]string{"title": "plan", "content": "c"}, &note)
+	comments := fmt.Sprintf("/workspaces/%d/notes/%d/comments", team.ID, note.ID)
+
+	// a guest may comment, an outsider may not see the thread
+	var root model.Comment
+	if resp := a.do(http.MethodPost, comments, guest, map[string]string{"body": "looks good"}, &root); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("guest comment: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, comments, outsider, nil, nil); resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected outsider to be refused, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, comments, guest, map[string]string{"body": ""}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty comment, got %d", resp.StatusCode)
+	}
+
+	// replies through the header-scoped route
+	var reply model.Comment
+	headerPath := fmt.Sprintf("/notes/%d/comments", note.ID)
+	if resp := a.doInWorkspace(http.MethodPost, headerPath, member, team.ID, map[string]any{"body": "thanks", "parent_id": root.ID}, &reply); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("reply: status %d", resp.StatusCode)
+	}
+	if reply.ParentID == nil || *reply.ParentID != root.ID {
+		t.Fatalf("expected reply to %d, got %+v", root.ID, reply)
+	}
+	if resp := a.do(http.MethodPost, comments, member, map[string]any{"body": "x", "parent_id": 9999}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 replying to an unknown comment, got %d", resp.StatusCode)
+	}
+
+	// only the author edits; edits keep a history
+	rootPath := fmt.Sprintf("%s/%d", comments, root.ID)
+	if resp := a.do(http.MethodPatch, rootPath, member, map[string]string{"body": "hijack"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 editing someone else's comment, got %d", resp.StatusCode)
+	}
+	var edited model.Comment
+	if resp := a.do(http.MethodPatch, rootPath, guest, map[string]string{"body": "looks great"}, &edited); resp.StatusCode != http.StatusOK {
+		t.Fatalf("edit: status %d", resp.StatusCode)
+	}
+	if edited.Body != "looks great" || edited.EditedAt == nil {
+		t.Fatalf("unexpected edited comment: %+v", edited)
+	}
+	var history struct {
+		Revisions []model.CommentRevision `json:"revisions"`
+	}
+	a.do(http.MethodGet, rootPath+"/history", member, nil, &history)
+	if len(history.Revisions) != 1 || history.Revisions[0].Body != "looks good" {
+		t.Fatalf("expected the original body in the history, got %+v", history.Revisions)
+	}
+
+	// a member cannot delete the guest's comment, a workspace admin can
+	if resp := a.do(http.MethodDelete, rootPath, member, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 deleting someone else's comment, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodDelete, rootPath, owner, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("owner delete: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPatch, rootPath, guest, map[string]string{"body": "again"}, nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 editing a deleted comment, got %d", resp.StatusCode)
+	}
+
+	// the deleted comment stays in the thread without its body, so the reply keeps its parent
+	var list struct {
+		Comments []model.Comment `json:"comments"`
+	}
+	a.do(http.MethodGet, comments, member, nil, &list)
+	if len(list.Comments) != 2 || list.Comments[0].ID != root.ID || list.Comments[0].DeletedAt == nil || list.Comments[0].Body != "" {
+		t.Fatalf("unexpected thread after delete: %+v", list.Comments)
+	}
+	a.do(http.MethodGet, rootPath+"/history", member, nil, &history)
+	if len(history.Revisions) != 0 {
+		t.Fatalf("expected the history to be dropped, got %+v", history.Revisions)
+	}
+
+	// deleting the note takes its comments along
+	if resp := a.do(http.MethodDelete, fmt.Sprintf("/workspaces/%d/notes/%d", team.ID, note.ID), member, nil, nil); resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete note: status %d", resp.StatusCode)
+	}
+	var left int64
+	a.DB.Model(&model.Comment{}).Where("note_id = ?", note.ID).Count(&left)
+	if left != 0 {
+		t.Fatalf("expected the note's comments to be deleted, %d left", left)
+	}
+}
+
+func TestComments_MentionsNotifyTheInbox(t *testing.T) {
+	a := newTestApp(t)
+	rec := a.recordEvents(model.EventNotificationCreated)
+	alice := a.registerAndLogin("alice", "pass")
+	bob := a.registerAndLogin("bob", "pass")
+	carol := a.registerAndLogin("carol", "pass")
+	a.registerAndLogin("dave", "pass")
+
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", alice, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(alice, team.ID, "bob", bob, "member")
+	a.inviteAndAccept(alice, team.ID, "carol", carol, "guest")
+
+	// mentions in note content; dave is no member and hears nothing
+	var note model.Note
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), alice, map[string]string{"title": "plan", "content": "@bob and @dave, have a look"}, &note)
+	page := a.notifications(bob, false)
+	if page.Total != 1 || page.Notifications[0].Type != model.NotificationMention || page.Notifications[0].CommentID != nil {
+		t.Fatalf("expected a note mention for bob, got %+v", page)
+	}
+	// editing without a new mention does not notify again
+	a.do(http.MethodPut, fmt.Sprintf("/workspaces/%d/notes/%d", team.ID, note.ID), alice, map[string]string{"title": "plan", "content": "@bob and @dave, have a look!"}, nil)
+	if page := a.notifications(bob, false); page.Total != 1 {
+		t.Fatalf("expected no new notification, got %d", page.Total)
+	}
+
+	// a comment notifies the note's author, a reply the parent's, a mention the mentioned
+	comments := fmt.Sprintf("/workspaces/%d/notes/%d/comments", team.ID, note.ID)
+	var c1 model.Comment
+	a.do(http.MethodPost, comments, bob, map[string]string{"body": "on it"}, &c1)
+	a.do(http.MethodPost, comments, carol, map[string]any{"body": "me too, @alice", "parent_id": c1.ID}, nil)
+
+	page = a.notifications(alice, false)
+	if page.Total != 2 || page.Notifications[0].Type != model.NotificationMention || page.Notifications[1].Type != model.NotificationComment {
+		t.Fatalf("unexpected notifications for alice: %+v", page.Notifications)
+	}
+	page = a.notifications(bob, false)
+	if page.Total != 2 || page.Notifications[0].Type != model.NotificationReply {
+		t.Fatalf("unexpected notifications for bob: %+v", page.Notifications)
+	}
+
+	// the inbox endpoints
+	var count struct {
+		Unread int64 `json:"unread"`
+	}
+	a.do(http.MethodGet, "/notifications/unread-count", bob, nil, &count)
+	if count.Unread != 2 {
+		t.Fatalf("expected 2 unread, got %d", count.Unread)
+	}
+	id := page.Notifications[0].ID
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/notifications/%d/read", id), alice, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 reading someone else's notification, got %d", resp.StatusCode)
+	}
+	// a read-only token lists the inbox but does not change it
+	var readOnly struct {
+		Token string `json:"token"`
+	}
+	a.do(http.MethodPost, "/account/tokens", bob, map[string]any{"name": "ro", "scopes": []string{model.ScopeNotesRead}}, &readOnly)
+	if resp := a.do(http.MethodGet, "/notifications", readOnly.Token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a read-only token to list notifications, got %d", resp.StatusCode)
+	}
+	for _, path := range []string{fmt.Sprintf("/notifications/%d/read", id), fmt.Sprintf("/notifications/%d/unread", id), "/notifications/read-all"} {
+		if resp := a.do(http.MethodPost, path, readOnly.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403 for %s with a read-only token, got %d", path, resp.StatusCode)
+		}
+	}
+	var read model.Notification
+	a.do(http.MethodPost, fmt.Sprintf("/notifications/%d/read", id), bob, nil, &read)
+	if read.ReadAt == nil {
+		t.Fatalf("expected the notification to be read: %+v", read)
+	}
+	if page := a.notifications(bob, true); page.Total != 1 || page.Unread != 1 {
+		t.Fatalf("expected one unread notification, got %+v", page)
+	}
+	a.do(http.MethodPost, fmt.Sprintf("/notifications/%d/unread", id), bob, nil, nil)
+	var marked struct {
+		Marked int64 `json:"marked"`
+	}
+	a.do(http.MethodPost, "/notifications/read-all", bob, nil, &marked)
+	if marked.Marked != 2 {
+		t.Fatalf("expected 2 marked read, got %d", marked.Marked)
+	}
+	a.do(http.MethodGet, "/notifications/unread-count", bob, nil, &count)
+	if count.Unread != 0 {
+		t.Fatalf("expected 0 unread, got %d", count.Unread)
+	}
+
+	// every notification reaches the bus for real-time channels
+	a.relay()
+	if got := rec.topics(); len(got) != 4 {
+		t.Fatalf("expected 4 notification events, got %v", got)
+	}
+}
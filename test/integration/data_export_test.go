@@ -0,0 +1,268 @@
+package integration_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+func TestDataExport_DownloadZip(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		DataExportDir: t.TempDir(),
+	})
+	jwt := a.registerAndLogin("exporter", "pass")
+	for i := 0; i < 3; i++ {
+		a.do(http.MethodPost, "/notes", jwt, map[string]string{"title": fmt.Sprintf("note %d", i), "content": "body"}, nil)
+	}
+	a.do(http.MethodPost, "/account/tokens", jwt, map[string]any{"name": "ci", "scopes": []string{"notes:read"}}, nil)
+	// a team note, which the exporter comments on and another member's
+	// comment notifies them of
+	other := a.registerAndLogin("snoop", "pass")
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", jwt, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(jwt, team.ID, "snoop", other, "member")
+	var plan model.Note
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), jwt, map[string]string{"title": "plan", "content": "v1"}, &plan)
+	comments := fmt.Sprintf("/workspaces/%d/notes/%d/comments", team.ID, plan.ID)
+	a.do(http.MethodPost, comments, jwt, map[string]string{"body": "first draft"}, nil)
+	a.do(http.MethodPost, comments, other, map[string]string{"body": "looks good"}, nil)
+
+	var export model.DataExport
+	if resp := a.do(http.MethodPost, "/me/data-export", jwt, nil, &export); resp.StatusCode != http.StatusAccepted || export.ID == 0 {
+		t.Fatalf("request export: status %d, %+v", resp.StatusCode, export)
+	}
+
+	if resp := a.do(http.MethodGet, fmt.Sprintf("/me/data-export/%d", export.ID), other, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected another user's export to be hidden, got %d", resp.StatusCode)
+	}
+
+	body := a.waitForExport(jwt, export.ID)
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var profile map[string]any
+	json.Unmarshal(files["profile.json"], &profile)
+	if profile["username"] != "exporter" || profile["email"] != "exporter@example.com" {
+		t.Fatalf("unexpected profile.json: %s", files["profile.json"])
+	}
+	var notes []map[string]any
+	if err := json.Unmarshal(files["notes.json"], &notes); err != nil || len(notes) != 4 || notes[2]["title"] != "note 2" {
+		t.Fatalf("unexpected notes.json (%v): %s", err, files["notes.json"])
+	}
+	var tokens []map[string]any
+	if err := json.Unmarshal(files["access_tokens.json"], &tokens); err != nil || len(tokens) != 1 || tokens[0]["token_hash"] != nil {
+		t.Fatalf("unexpected access_tokens.json (%v): %s", err, files["access_tokens.json"])
+	}
+	var workspaces []map[string]any
+	if err := json.Unmarshal(files["workspaces.json"], &workspaces); err != nil || len(workspaces) != 2 {
+		t.Fatalf("unexpected workspaces.json (%v): %s", err, files["workspaces.json"])
+	}
+	var mine []map[string]any
+	if err := json.Unmarshal(files["comments.json"], &mine); err != nil || len(mine) != 1 || mine[0]["body"] != "first draft" {
+		t.Fatalf("unexpected comments.json (%v): %s", err, files["comments.json"])
+	}
+	var inbox []map[string]any
+	if err := json.Unmarshal(files["notifications.json"], &inbox); err != nil || len(inbox) != 1 || inbox[0]["type"] != model.NotificationComment {
+		t.Fatalf("unexpected notifications.json (%v): %s", err, files["notifications.json"])
+	}
+	var audit []map[string]any
+	if err := json.Unmarshal(files["audit_events.json"], &audit); err != nil {
+		t.Fatalf("unexpected audit_events.json (%v): %s", err, files["audit_events.json"])
+	}
+	actions := map[any]bool{}
+	for _, e := range audit {
+		actions[e["action"]] = true
+	}
+	if !actions[model.AuditUserRegister] || !actions[model.AuditUserLogin] || !actions[model.AuditNoteCreate] || !actions[model.AuditTokenCreate] {
+		t.Fatalf("expected the exporter's audit events, got %s", files["audit_events.json"])
+	}
+	if _, ok := files["manifest.json"]; !ok {
+		t.Fatal("missing manifest.json")
+	}
+
+	// once expired the archive is gone
+	a.DB.Model(&model.DataExport{}).Where("id = ?", export.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if resp := a.do(http.MethodGet, fmt.Sprintf("/me/data-export/%d", export.ID), jwt, nil, nil); resp.StatusCode != http.StatusGone {
+		t.Fatalf("expected 410 for an expired export, got %d", resp.StatusCode)
+	}
+	if n, err := a.Container.Svcs.DataExport.PurgeExpired(context.Background()); err != nil || n != 1 {
+		t.Fatalf("purge: n=%d err=%v", n, err)
+	}
+}
+
+func TestDataExport_StaleExportDoesNotBlock(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		DataExportDir: t.TempDir(),
+	})
+	jwt := a.registerAndLogin("exporter", "pass")
+
+	var first model.DataExport
+	a.do(http.MethodPost, "/me/data-export", jwt, nil, &first)
+	var again model.DataExport
+	if a.do(http.MethodPost, "/me/data-export", jwt, nil, &again); again.ID != first.ID {
+		t.Fatalf("expected the export in progress, got %d and %d", first.ID, again.ID)
+	}
+
+	// its server died mid-export long ago, and the job was given up on
+	a.DB.Exec("UPDATE data_exports SET status = ?, created_at = ? WHERE id = ?", model.ExportRunning, time.Now().Add(-24*time.Hour), first.ID)
+	a.DB.Model(&model.Job{}).Where("kind = ?", model.JobDataExportBuild).Update("status", model.JobDead)
+
+	var next model.DataExport
+	if resp := a.do(http.MethodPost, "/me/data-export", jwt, nil, &next); resp.StatusCode != http.StatusAccepted || next.ID == first.ID {
+		t.Fatalf("expected a new export, got status %d, %+v", resp.StatusCode, next)
+	}
+	var stale model.DataExport
+	a.DB.First(&stale, first.ID)
+	if stale.Status != model.ExportFailed {
+		t.Fatalf("expected the stale export failed, got %q", stale.Status)
+	}
+	a.waitForExport(jwt, next.ID)
+}
+
+func TestDataExport_RetriedUntilTheLastAttempt(t *testing.T) {
+	// the export directory cannot be created while a file is in the way
+	dir := filepath.Join(t.TempDir(), "exports")
+	if err := os.WriteFile(dir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		DataExportDir: dir, JobMaxAttempts: 2,
+	})
+	jwt := a.registerAndLogin("exporter", "pass")
+
+	var export model.DataExport
+	a.do(http.MethodPost, "/me/data-export", jwt, nil, &export)
+	var job model.Job
+	a.DB.Where("kind = ?", model.JobDataExportBuild).First(&job)
+	path := fmt.Sprintf("/me/data-export/%d", export.ID)
+
+	// a failed attempt leaves the export in progress for the retry
+	a.runJob()
+	if resp := a.do(http.MethodGet, path, jwt, nil, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected the export still in progress, got %d", resp.StatusCode)
+	}
+	a.jobDue(job.ID)
+	a.runJob()
+	var got model.DataExport
+	if a.do(http.MethodGet, path, jwt, nil, &got); got.Status != model.ExportFailed || a.job(job.ID).Status != model.JobDead {
+		t.Fatalf("expected the export failed after the last attempt, got %q", got.Status)
+	}
+
+	// once the problem is gone a new export succeeds
+	os.Remove(dir)
+	var next model.DataExport
+	a.do(http.MethodPost, "/me/data-export", jwt, nil, &next)
+	a.waitForExport(jwt, next.ID)
+}
+
+// waitForExport runs the queued jobs and polls the export until it is
+// downloadable, then returns the ZIP.
+func (a *testApp) waitForExport(token string, id uint) []byte {
+	a.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := a.Container.Svcs.Job.RunNext(a.t.Context()); err != nil {
+			a.t.Fatalf("run job: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/me/data-export/%d", a.Server.URL, id), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusAccepted:
+			time.Sleep(20 * time.Millisecond)
+		case http.StatusOK:
+			if resp.Header.Get("Content-Type") == "application/zip" || bytes.HasPrefix(body, []byte("PK")) {
+				return body
+			}
+			a.t.Fatalf("export failed: %s", body)
+		default:
+			a.t.Fatalf("poll export: status %d: %s", resp.StatusCode, body)
+		}
+	}
+	a.t.Fatal("export did not finish in time")
+	return nil
+}
+
+func TestDataExport_DeletedWithTheUser(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		DataExportDir: t.TempDir(),
+	})
+	admin := a.registerAdmin("root")
+	jwt := a.registerAndLogin("leaver", "pass")
+	var export model.DataExport
+	a.do(http.MethodPost, "/me/data-export", jwt, nil, &export)
+	a.waitForExport(jwt, export.ID)
+	a.DB.First(&export, export.ID)
+	if _, err := os.Stat(export.Path); err != nil {
+		t.Fatalf("expected the archive on disk: %v", err)
+	}
+
+	if resp := a.do(http.MethodDelete, fmt.Sprintf("/admin/users/%d", a.userID("leaver")), admin, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete user: status %d", resp.StatusCode)
+	}
+	var n int64
+	a.DB.Model(&model.DataExport{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("expected the export to be deleted, %d left", n)
+	}
+	if _, err := os.Stat(export.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the archive to be removed, got %v", err)
+	}
+}
+
+func TestDataExport_RateLimitedApartFromOtherRequests(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		DataExportDir:    t.TempDir(),
+		RateLimitEnabled: true, RateLimitPublic: 100, RateLimitAuthenticated: 100, RateLimitLogin: 100, RateLimitRegister: 100,
+		RateLimitExport: 1, RateLimitSearch: 1,
+	})
+	jwt := a.registerAndLogin("exporter", "pass")
+	var export model.DataExport
+	if resp := a.do(http.MethodPost, "/me/data-export", jwt, nil, &export); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("request export: status %d", resp.StatusCode)
+	}
+	a.waitForExport(jwt, export.ID)
+	if resp := a.do(http.MethodPost, "/me/data-export", jwt, nil, nil); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the second export in a minute to be refused, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/me/audit", jwt, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a search to have its own limit, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/me/audit?action=user.login", jwt, nil, nil); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the second search in a minute to be refused, got %d", resp.StatusCode)
+	}
+	// other requests only count towards the per-user limit
+	if resp := a.do(http.MethodGet, "/notes", jwt, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list notes: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,286 @@
+package integration_test
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+// sseEvent is a server-sent event, or a comment when Comment is set.
+type sseEvent struct {
+	ID      uint
+	Event   string
+	Data    string
+	Comment string
+}
+
+type eventStream struct {
+	t      *testing.T
+	resp   *http.Response
+	events chan sseEvent
+}
+
+// openEvents opens /events, resuming after lastEventID when it is not 0.
+func (a *testApp) openEvents(token string, lastEventID uint) *eventStream {
+	a.t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, a.Server.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(uint64(lastEventID), 10))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("open events: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		a.t.Fatalf("open events: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	s := &eventStream{t: a.t, resp: resp, events: make(chan sseEvent, 100)}
+	a.t.Cleanup(s.close)
+	go func() {
+		defer close(s.events)
+		var e sseEvent
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			field, value, _ := strings.Cut(sc.Text(), ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "":
+				if value != "" {
+					s.events <- sseEvent{Comment: value}
+					continue
+				}
+				if e != (sseEvent{}) {
+					s.events <- e
+				}
+				e = sseEvent{}
+			case "id":
+				id, _ := strconv.ParseUint(value, 10, 0)
+				e.ID = uint(id)
+			case "event":
+				e.Event = value
+			case "data":
+				e.Data = value
+			}
+		}
+	}()
+	return s
+}
+
+func (s *eventStream) close() {
+	s.resp.Body.Close()
+}
+
+// next returns the next event, skipping heartbeats.
+func (s *eventStream) next() sseEvent {
+	s.t.Helper()
+	for {
+		select {
+		case e, ok := <-s.events:
+			if !ok {
+				s.t.Fatal("event stream ended")
+			}
+			if e.Comment == "" {
+				return e
+			}
+		case <-time.After(5 * time.Second):
+			s.t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+// none fails if an event other than a heartbeat arrives soon.
+func (s *eventStream) none() {
+	s.t.Helper()
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case e, ok := <-s.events:
+			if ok && e.Comment == "" {
+				s.t.Fatalf("unexpected event %+v", e)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func (a *testApp) pollEvents() {
+	a.t.Helper()
+	if _, err := a.Container.Svcs.EventStream.Poll(a.t.Context()); err != nil {
+		a.t.Fatalf("poll events: %v", err)
+	}
+}
+
+func TestEvents_StreamAndResume(t *testing.T) {
+	a := newTestApp(t)
+	t.Cleanup(a.Container.Svcs.EventStream.Close)
+	owner := a.registerAndLogin("owner", "pass")
+	guest := a.registerAndLogin("guest", "pass")
+	outsider := a.registerAndLogin("outsider", "pass")
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", owner, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(owner, team.ID, "guest", guest, "guest")
+	notes := fmt.Sprintf("/workspaces/%d/notes", team.ID)
+
+	guestStream := a.openEvents(guest, 0)
+	outsiderStream := a.openEvents(outsider, 0)
+
+	var n model.Note
+	a.do(http.MethodPost, notes, owner, map[string]string{"title": "plan", "content": "v1"}, &n)
+	a.do(http.MethodPut, fmt.Sprintf("%s/%d", notes, n.ID), owner, map[string]string{"title": "plan", "content": "v2"}, nil)
+	a.pollEvents()
+
+	created, updated := guestStream.next(), guestStream.next()
+	if created.Event != model.EventNoteCreated || updated.Event != model.EventNoteUpdated || created.ID == 0 || updated.ID <= created.ID {
+		t.Fatalf("unexpected events %+v, %+v", created, updated)
+	}
+	if !strings.Contains(updated.Data, `"content":"v2"`) {
+		t.Fatalf("expected the updated note in the event, got %s", updated.Data)
+	}
+	outsiderStream.none()
+
+	// events while the guest is disconnected are replayed on reconnect
+	guestStream.close()
+	a.do(http.MethodDelete, fmt.Sprintf("%s/%d", notes, n.ID), owner, nil, nil)
+	a.pollEvents()
+	resumed := a.openEvents(guest, created.ID)
+	if e := resumed.next(); e.ID != updated.ID {
+		t.Fatalf("expected the update replayed first, got %+v", e)
+	}
+	if e := resumed.next(); e.Event != model.EventNoteDeleted {
+		t.Fatalf("expected the deletion replayed, got %+v", e)
+	}
+
+	// and only once: live events follow the replay
+	a.do(http.MethodPost, notes, owner, map[string]string{"title": "next", "content": "x"}, nil)
+	a.pollEvents()
+	if e := resumed.next(); e.Event != model.EventNoteCreated {
+		t.Fatalf("expected the new note, got %+v", e)
+	}
+	resumed.none()
+
+	// closing the streams, on shutdown, ends them
+	a.Container.Svcs.EventStream.Close()
+	select {
+	case <-waitClosed(resumed):
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end")
+	}
+}
+
+func TestEvents_NotificationsToTheirRecipient(t *testing.T) {
+	a := newTestApp(t)
+	t.Cleanup(a.Container.Svcs.EventStream.Close)
+	alice := a.registerAndLogin("alice", "pass")
+	bob := a.registerAndLogin("bob", "pass")
+	carol := a.registerAndLogin("carol", "pass")
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", alice, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(alice, team.ID, "bob", bob, "member")
+	a.inviteAndAccept(alice, team.ID, "carol", carol, "member")
+
+	bobStream := a.openEvents(bob, 0)
+	carolStream := a.openEvents(carol, 0)
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), alice, map[string]string{"title": "plan", "content": "@bob, have a look"}, nil)
+	a.pollEvents()
+
+	// the notification is emitted with the note
+	note, created := bobStream.next(), bobStream.next()
+	if note.Event != model.EventNoteCreated || created.Event != model.EventNotificationCreated {
+		t.Fatalf("expected the note and bob's notification, got %+v, %+v", note, created)
+	}
+	if !strings.Contains(created.Data, `"type":"`+model.NotificationMention+`"`) {
+		t.Fatalf("expected the mention in the event, got %s", created.Data)
+	}
+	// carol sees the note, but not bob's notification
+	if e := carolStream.next(); e.ID != note.ID {
+		t.Fatalf("expected the note for carol, got %+v", e)
+	}
+	carolStream.none()
+
+	// nor is it replayed to her
+	bobStream.close()
+	carolStream.close()
+	if e := a.openEvents(bob, note.ID).next(); e.ID != created.ID {
+		t.Fatalf("expected the notification replayed to bob, got %+v", e)
+	}
+	a.openEvents(carol, note.ID).none()
+}
+
+func waitClosed(s *eventStream) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range s.events {
+		}
+		close(done)
+	}()
+	return done
+}
+
+func TestEvents_ResetWhenReplayBufferExceeded(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		EventsReplayBuffer: 2, EventsHeartbeatInterval: 50 * time.Millisecond,
+	})
+	t.Cleanup(a.Container.Svcs.EventStream.Close)
+	alice := a.registerAndLogin("alice", "pass")
+
+	stream := a.openEvents(alice, 0)
+	a.do(http.MethodPost, "/notes", alice, map[string]string{"title": "first", "content": "1"}, nil)
+	a.pollEvents()
+	first := stream.next()
+	stream.close()
+
+	for i := range 3 {
+		a.do(http.MethodPost, "/notes", alice, map[string]string{"title": fmt.Sprint("later ", i), "content": "x"}, nil)
+	}
+	a.pollEvents()
+	resumed := a.openEvents(alice, first.ID)
+	if e := resumed.next(); e.Event != "reset" {
+		t.Fatalf("expected a reset, got %+v", e)
+	}
+	resumed.none()
+
+	// idle streams get heartbeats
+	select {
+	case e := <-resumed.events:
+		if e.Comment != "heartbeat" {
+			t.Fatalf("expected a heartbeat, got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a heartbeat")
+	}
+}
+
+func TestEvents_RequestValidation(t *testing.T) {
+	a := newTestApp(t)
+	if resp := a.do(http.MethodGet, "/events", "", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", resp.StatusCode)
+	}
+	alice := a.registerAndLogin("alice", "pass")
+	req, _ := http.NewRequest(http.MethodGet, a.Server.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+alice)
+	req.Header.Set("Last-Event-ID", "yesterday")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid Last-Event-ID, got %d", resp.StatusCode)
+	}
+
+	a.Container.Svcs.EventStream.Close()
+	if resp := a.do(http.MethodGet, "/events", alice, nil, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once closed, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,96 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+	"github.com/MujiRahman/golang-simple-note/pkg/migrate"
+	"github.com/MujiRahman/golang-simple-note/test/testutil"
+)
+
+// testApp is the full application wired through app.NewContainer on a
+// migrated sqlite database.
+type testApp struct {
+	t         *testing.T
+	DB        *gorm.DB
+	Container *app.Container
+	Server    *httptest.Server
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	return newTestAppWithConfig(t, &config.Config{JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory"})
+}
+
+func newTestAppWithConfig(t *testing.T, cfg *config.Config) *testApp {
+	t.Helper()
+	gdb := testutil.NewMigratedSQLiteDB(t)
+	conn := &app.Connect{DB: gdb, Dialect: migrate.SQLite}
+	container := app.NewContainer(conn, cfg)
+	router := app.NewRouter(container.Svcs.User, container.Svcs.Note, cfg, container.RouterOptions()...)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return &testApp{t: t, DB: gdb, Container: container, Server: server}
+}
+
+// do sends a JSON request with an optional bearer token and decodes the
+// response into out when it is not nil.
+func (a *testApp) do(method, path, token string, body any, out any) *http.Response {
+	a.t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		r = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, a.Server.URL+path, r)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			a.t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+// registerAndLogin creates a user and returns a JWT for it.
+func (a *testApp) registerAndLogin(username, password string) string {
+	a.t.Helper()
+	creds := map[string]string{"username": username, "password": password}
+	reg := map[string]string{"username": username, "email": username + "@example.com", "password": password}
+	if resp := a.do(http.MethodPost, "/register", "", reg, nil); resp.StatusCode != http.StatusCreated {
+		a.t.Fatalf("register %s: status %d", username, resp.StatusCode)
+	}
+	var login map[string]any
+	if resp := a.do(http.MethodPost, "/login", "", creds, &login); resp.StatusCode != http.StatusOK {
+		a.t.Fatalf("login %s: status %d", username, resp.StatusCode)
+	}
+	token, _ := login["token"].(string)
+	return token
+}
+
+// hmacKeys returns a key set signing with secret, as configured by JWT_SECRET.
+func hmacKeys(t *testing.T, secret string) *jwtkeys.KeySet {
+	t.Helper()
+	keys, err := jwtkeys.NewKeySet(jwtkeys.NewHMACKey([]byte(secret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
@@ -0,0 +1,272 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+type jobPageResp struct {
+	Jobs  []model.Job `json:"jobs"`
+	Total int64       `json:"total"`
+}
+
+// runJob runs the next due job, failing the test when none was due.
+func (a *testApp) runJob() {
+	a.t.Helper()
+	ran, err := a.Container.Svcs.Job.RunNext(a.t.Context())
+	if err != nil {
+		a.t.Fatalf("run job: %v", err)
+	}
+	if !ran {
+		a.t.Fatal("expected a job to be due")
+	}
+}
+
+// jobDue moves the next run of a queued job to now.
+func (a *testApp) jobDue(id uint) {
+	a.t.Helper()
+	if err := a.DB.Model(&model.Job{}).Where("id = ?", id).Update("run_at", time.Now().Add(-time.Second)).Error; err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+func (a *testApp) job(id uint) *model.Job {
+	a.t.Helper()
+	j, err := a.Container.Svcs.Job.Get(a.t.Context(), id)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return j
+}
+
+func TestJobs_RetriedWithBackoffUntilSuccess(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory", JobRetryBase: time.Minute})
+	jobs := a.Container.Svcs.Job
+
+	var calls atomic.Int32
+	var got struct{ Name string }
+	jobs.Register("test.flaky", func(ctx context.Context, payload json.RawMessage) error {
+		if err := json.Unmarshal(payload, &got); err != nil {
+			return err
+		}
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	j, err := jobs.Enqueue(t.Context(), "test.flaky", map[string]string{"name": "flaky"}, service.JobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.runJob()
+	first := a.job(j.ID)
+	if first.Status != model.JobQueued || first.Attempts != 1 || first.LastError != "not yet" {
+		t.Fatalf("expected the job to be requeued after a failure, got %+v", first)
+	}
+	if wait := time.Until(first.RunAt); wait < 50*time.Second || wait > time.Minute {
+		t.Fatalf("expected the first retry in a minute, got %v", wait)
+	}
+	if ran, _ := jobs.RunNext(t.Context()); ran {
+		t.Fatal("a job waiting for its retry should not run")
+	}
+
+	a.jobDue(j.ID)
+	a.runJob()
+	if wait := time.Until(a.job(j.ID).RunAt); wait < 110*time.Second || wait > 2*time.Minute {
+		t.Fatalf("expected the backoff to double, got %v", wait)
+	}
+
+	a.jobDue(j.ID)
+	a.runJob()
+	done := a.job(j.ID)
+	if done.Status != model.JobSucceeded || done.Attempts != 3 || done.FinishedAt == nil || done.LastError != "" || done.LockedBy != "" {
+		t.Fatalf("expected the job to succeed on its third attempt, got %+v", done)
+	}
+	if got.Name != "flaky" {
+		t.Fatalf("expected the handler to get the payload, got %+v", got)
+	}
+}
+
+func TestJobs_DeadJobsRetriedByAdmins(t *testing.T) {
+	a := newTestApp(t)
+	jobs := a.Container.Svcs.Job
+	var fail atomic.Bool
+	fail.Store(true)
+	jobs.Register("test.broken", func(ctx context.Context, _ json.RawMessage) error {
+		if fail.Load() {
+			panic("boom")
+		}
+		return nil
+	})
+	j, err := jobs.Enqueue(t.Context(), "test.broken", nil, service.JobOptions{MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.runJob()
+	a.jobDue(j.ID)
+	a.runJob()
+	dead := a.job(j.ID)
+	if dead.Status != model.JobDead || dead.Attempts != 2 || dead.LastError != "job panicked: boom" {
+		t.Fatalf("expected the job to be dead after two attempts, got %+v", dead)
+	}
+
+	unknown, _ := jobs.Enqueue(t.Context(), "test.unknown", nil, service.JobOptions{})
+	a.runJob()
+	if got := a.job(unknown.ID); got.Status != model.JobDead || got.Attempts != 1 {
+		t.Fatalf("expected a job without a handler to be dead at once, got %+v", got)
+	}
+
+	adminToken := a.registerAdmin("root")
+	userToken := a.registerAndLogin("alice", "pass")
+	if resp := a.do(http.MethodGet, "/admin/jobs", userToken, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", resp.StatusCode)
+	}
+
+	var page jobPageResp
+	if resp := a.do(http.MethodGet, "/admin/jobs?status=dead&kind=test.broken", adminToken, nil, &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list jobs: %d", resp.StatusCode)
+	}
+	if page.Total != 1 || len(page.Jobs) != 1 || page.Jobs[0].ID != j.ID {
+		t.Fatalf("expected the dead job to be listed, got %+v", page)
+	}
+
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/admin/jobs/%d/retry", j.ID), adminToken, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("retry: %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/admin/jobs/%d/retry", j.ID), adminToken, nil, nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 when retrying a queued job, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/admin/jobs/999999", adminToken, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing job, got %d", resp.StatusCode)
+	}
+
+	fail.Store(false)
+	a.runJob()
+	var retried model.Job
+	a.do(http.MethodGet, fmt.Sprintf("/admin/jobs/%d", j.ID), adminToken, nil, &retried)
+	if retried.Status != model.JobSucceeded || retried.Attempts != 1 {
+		t.Fatalf("expected the retried job to succeed, got %+v", retried)
+	}
+}
+
+func TestJobs_ExpiredLocksAreReclaimed(t *testing.T) {
+	a := newTestApp(t)
+	jobs := a.Container.Svcs.Job
+	var calls atomic.Int32
+	jobs.Register("test.count", func(ctx context.Context, _ json.RawMessage) error {
+		calls.Add(1)
+		return nil
+	})
+	j, _ := jobs.Enqueue(t.Context(), "test.count", nil, service.JobOptions{})
+	// a worker that died while running the job
+	past := time.Now().Add(-time.Minute)
+	a.DB.Model(&model.Job{}).Where("id = ?", j.ID).Updates(map[string]any{"status": model.JobRunning, "attempts": 1, "locked_by": "gone", "locked_until": past})
+
+	a.runJob()
+	if got := a.job(j.ID); got.Status != model.JobSucceeded || got.Attempts != 2 || calls.Load() != 1 {
+		t.Fatalf("expected the abandoned job to be run again, got %+v", got)
+	}
+}
+
+func TestJobs_SchedulesEnqueueOncePerRun(t *testing.T) {
+	a := newTestApp(t)
+	jobs := a.Container.Svcs.Job
+	if err := jobs.Schedule(t.Context(), "cleanup", "@every 1h", "test.cleanup", map[string]int{"keep": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobs.Schedule(t.Context(), "bad", "61 * * * *", "test.cleanup", nil); err == nil {
+		t.Fatal("expected an invalid spec to be rejected")
+	}
+	if n, _ := jobs.EnqueueScheduled(t.Context()); n != 0 {
+		t.Fatalf("expected nothing to be due yet, enqueued %d", n)
+	}
+
+	a.DB.Model(&model.JobSchedule{}).Where("name = ?", "cleanup").Update("next_run_at", time.Now().Add(-time.Second))
+	if n, err := jobs.EnqueueScheduled(t.Context()); err != nil || n != 1 {
+		t.Fatalf("expected one job to be enqueued, got %d, %v", n, err)
+	}
+	if n, _ := jobs.EnqueueScheduled(t.Context()); n != 0 {
+		t.Fatalf("expected the run to be enqueued once, enqueued %d more", n)
+	}
+	page, err := jobs.List(t.Context(), repository.JobFilter{Kind: "test.cleanup"}, 1, 10)
+	if err != nil || page.Total != 1 || page.Jobs[0].Payload != `{"keep":3}` {
+		t.Fatalf("expected the scheduled job with its payload, got %+v, %v", page, err)
+	}
+
+	// rescheduling with the same spec keeps the next run
+	schedules, _ := jobs.ListSchedules(t.Context())
+	next := schedules[0].NextRunAt
+	if err := jobs.Schedule(t.Context(), "cleanup", "@every 1h", "test.cleanup", nil); err != nil {
+		t.Fatal(err)
+	}
+	schedules, _ = jobs.ListSchedules(t.Context())
+	if !schedules[0].NextRunAt.Equal(next) || schedules[0].LastRunAt == nil {
+		t.Fatalf("expected the schedule to keep its next run, got %+v", schedules[0])
+	}
+
+	adminToken := a.registerAdmin("root")
+	var resp struct {
+		Schedules []model.JobSchedule `json:"schedules"`
+	}
+	a.do(http.MethodGet, "/admin/jobs/schedules", adminToken, nil, &resp)
+	if len(resp.Schedules) != 1 || resp.Schedules[0].Name != "cleanup" {
+		t.Fatalf("expected the schedule to be listed, got %+v", resp.Schedules)
+	}
+}
+
+func TestJobs_WorkersRunJobsAndDrainOnShutdown(t *testing.T) {
+	a := newTestApp(t)
+	jobs := a.Container.Svcs.Job
+	release := make(chan struct{})
+	var done atomic.Int32
+	jobs.Register("test.slow", func(ctx context.Context, _ json.RawMessage) error {
+		<-release
+		done.Add(1)
+		return nil
+	})
+	jobs.Register("test.fast", func(ctx context.Context, _ json.RawMessage) error {
+		done.Add(1)
+		return nil
+	})
+	slow, _ := jobs.Enqueue(t.Context(), "test.slow", nil, service.JobOptions{})
+	for range 5 {
+		jobs.Enqueue(t.Context(), "test.fast", nil, service.JobOptions{})
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	shutdown := app.StartJobWorkers(ctx, jobs, 2, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for done.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if done.Load() != 5 {
+		t.Fatalf("expected the fast jobs to run beside the slow one, %d ran", done.Load())
+	}
+
+	cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	shutdownCtx, cancelShutdown := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancelShutdown()
+	if err := shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if got := a.job(slow.ID); got.Status != model.JobSucceeded {
+		t.Fatalf("expected shutdown to wait for the running job, got %+v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package integration_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+)
+
+func TestJWKS_PublishedKeyVerifiesAccessToken(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{TokenTTL: 3600, MailDriver: "memory", JWTAlgorithm: "EdDSA", JWTIssuer: "notes-test", JWTAudience: "notes-api"})
+	token := a.registerAndLogin("jwks-user", "pass")
+
+	var set jwtkeys.JWKSet
+	if resp := a.do(http.MethodGet, "/.well-known/jwks.json", "", nil, &set); resp.StatusCode != http.StatusOK {
+		t.Fatalf("jwks: status %d", resp.StatusCode)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "OKP" {
+		t.Fatalf("unexpected key set %+v", set)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(set.Keys[0].X)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify the token the way another service would, with only the JWKS
+	var claims jwt.RegisteredClaims
+	tok, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Header["kid"] != set.Keys[0].Kid {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		return ed25519.PublicKey(x), nil
+	})
+	if err != nil || !tok.Valid {
+		t.Fatalf("token does not verify with the published key: %v", err)
+	}
+	if tok.Method.Alg() != "EdDSA" || claims.Issuer != "notes-test" || !claims.VerifyAudience("notes-api", true) || claims.Subject != "jwks-user" {
+		t.Fatalf("unexpected token %v %+v", tok.Header, claims)
+	}
+
+	if resp := a.do(http.MethodGet, "/me", token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("token rejected by the API: %d", resp.StatusCode)
+	}
+}
+
+func TestJWT_IssuerAndAudienceAreChecked(t *testing.T) {
+	a := newTestApp(t)
+	u, err := a.Container.Svcs.User.Register(t.Context(), "", "aud-user", "aud-user@example.com", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := hmacKeys(t, "integration-secret")
+	for name, cfg := range map[string]*config.Config{
+		"issuer":   {JWTSecret: "integration-secret", TokenTTL: 3600, JWTIssuer: "someone-else"},
+		"audience": {JWTSecret: "integration-secret", TokenTTL: 3600, JWTAudience: "other-api"},
+	} {
+		// same key, other issuer/audience: e.g. a sibling service sharing keys
+		other := service.NewUserService(a.Container.Repos.User, a.Container.Repos.Workspace, a.Container.Svcs.Audit, a.Container.Svcs.UnitOfWork, keys, cfg)
+		token, err := other.Login(t.Context(), u.Username, "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.ParseToken(t.Context(), token); err != nil {
+			t.Fatalf("%s: the issuing service should accept its token: %v", name, err)
+		}
+
+		strict := service.NewUserService(a.Container.Repos.User, a.Container.Repos.Workspace, a.Container.Svcs.Audit, a.Container.Svcs.UnitOfWork, keys, &config.Config{JWTSecret: "integration-secret", TokenTTL: 3600, JWTIssuer: "simple-note", JWTAudience: "simple-note"})
+		if _, err := strict.ParseToken(t.Context(), token); err == nil {
+			t.Fatalf("%s: token for another %s should be rejected", name, name)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+func TestMe_ProfileUpdate(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("profile-user", "pass")
+
+	var me map[string]any
+	a.do(http.MethodGet, "/me", jwt, nil, &me)
+	if me["username"] != "profile-user" || me["time_zone"] != "UTC" || me["locale"] != "en" || me["email_verified"] != false {
+		t.Fatalf("unexpected profile: %v", me)
+	}
+
+	update := map[string]string{"name": "Profile User", "time_zone": "Asia/Jakarta", "locale": "id-ID", "email": "new@example.com"}
+	if resp := a.do(http.MethodPatch, "/me", jwt, update, &me); resp.StatusCode != http.StatusOK {
+		t.Fatalf("update: status %d, %v", resp.StatusCode, me)
+	}
+	if me["name"] != "Profile User" || me["time_zone"] != "Asia/Jakarta" || me["locale"] != "id-ID" || me["email"] != "new@example.com" {
+		t.Fatalf("profile not updated: %v", me)
+	}
+	if a.lastToken("new@example.com") == "" {
+		t.Fatal("expected a verification email for the new address")
+	}
+
+	for _, bad := range []map[string]string{{"time_zone": "Mars/Olympus"}, {"locale": "english"}, {"email": "nope"}, {"name": " "}} {
+		if resp := a.do(http.MethodPatch, "/me", jwt, bad, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", bad, resp.StatusCode)
+		}
+	}
+}
+
+func TestMe_ChangePasswordRevokesOtherSessions(t *testing.T) {
+	a := newTestApp(t)
+	other := a.registerAndLogin("pw-user", "old-pass")
+	var login map[string]string
+	a.do(http.MethodPost, "/login", "", map[string]string{"username": "pw-user", "password": "old-pass"}, &login)
+
+	body := map[string]string{"current_password": "wrong", "new_password": "new-pass"}
+	if resp := a.do(http.MethodPost, "/me/password", login["token"], body, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected wrong current password to fail, got %d", resp.StatusCode)
+	}
+	body["current_password"] = "old-pass"
+	var changed map[string]string
+	if resp := a.do(http.MethodPost, "/me/password", login["token"], body, &changed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("change password: status %d", resp.StatusCode)
+	}
+
+	if resp := a.do(http.MethodGet, "/me", other, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected other session to be revoked, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/me", changed["token"], nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the returned token to work, got %d", resp.StatusCode)
+	}
+}
+
+func TestMe_DeleteCascades(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("leaving", "pass")
+	a.do(http.MethodPost, "/notes", jwt, map[string]string{"title": "bye"}, nil)
+	a.do(http.MethodPost, "/account/tokens", jwt, map[string]any{"name": "ci", "scopes": []string{"notes:read"}}, nil)
+
+	if resp := a.do(http.MethodDelete, "/me", jwt, map[string]string{"password": "wrong"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected wrong password to fail, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodDelete, "/me", jwt, map[string]string{"password": "pass"}, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+
+	for _, m := range []any{&model.User{}, &model.Note{}, &model.PersonalAccessToken{}, &model.UserToken{}} {
+		var n int64
+		a.DB.Model(m).Count(&n)
+		if n != 0 {
+			t.Errorf("%T: %d rows left after account deletion", m, n)
+		}
+	}
+	if resp := a.do(http.MethodGet, "/me", jwt, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected token of deleted user to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestMe_DeleteWithGracePeriod(t *testing.T) {
+	a := newTestAppWithConfig(t, &config.Config{
+		JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory",
+		AccountDeletionGracePeriod: 24 * time.Hour,
+	})
+	creds := map[string]string{"username": "undecided", "password": "pass"}
+	jwt := a.registerAndLogin("undecided", "pass")
+
+	var scheduled map[string]any
+	if resp := a.do(http.MethodDelete, "/me", jwt, map[string]string{"password": "pass"}, &scheduled); resp.StatusCode != http.StatusAccepted || scheduled["deletion_scheduled_at"] == nil {
+		t.Fatalf("delete: status %d, %v", resp.StatusCode, scheduled)
+	}
+	if resp := a.do(http.MethodGet, "/me", jwt, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected sessions to end when deletion is scheduled, got %d", resp.StatusCode)
+	}
+
+	// logging in again cancels the deletion
+	var login map[string]string
+	a.do(http.MethodPost, "/login", "", creds, &login)
+	if resp := a.do(http.MethodGet, "/me", login["token"], nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected login to restore the account, got %d", resp.StatusCode)
+	}
+
+	// schedule again and let the grace period lapse
+	a.do(http.MethodDelete, "/me", login["token"], map[string]string{"password": "pass"}, nil)
+	a.DB.Model(&model.User{}).Where("username = ?", "undecided").Update("deletion_scheduled_at", time.Now().Add(-time.Minute))
+	n, err := a.Container.Svcs.Account.PurgeDeletedAccounts(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("purge: n=%d err=%v", n, err)
+	}
+	if resp := a.do(http.MethodPost, "/login", "", creds, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected purged account to be gone, got %d", resp.StatusCode)
+	}
+}
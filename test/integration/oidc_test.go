@@ -0,0 +1,196 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/pkg/totp"
+	"github.com/MujiRahman/golang-simple-note/test/testutil"
+)
+
+const oidcRedirect = "http://notes.test/auth/oidc/mock/callback"
+
+func newOIDCTestApp(t *testing.T, mutate func(*config.Config)) (*testApp, *testutil.MockOIDCProvider) {
+	t.Helper()
+	idp := testutil.NewMockOIDCProvider(t)
+	cfg := &config.Config{JWTSecret: "integration-secret", TokenTTL: 3600, MailDriver: "memory", OIDCLinkByEmail: true, OIDCAutoProvision: true,
+		OIDCProviders: []config.OIDCProvider{{
+			Name:         "mock",
+			IssuerURL:    idp.Issuer(),
+			ClientID:     idp.ClientID,
+			ClientSecret: idp.ClientSecret,
+			RedirectURL:  oidcRedirect,
+			Scopes:       []string{"openid", "email", "profile"},
+		}}}
+	if mutate != nil {
+		mutate(cfg)
+	}
+	return newTestAppWithConfig(t, cfg), idp
+}
+
+var noRedirects = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+// oidcLogin runs the browser side of the flow: start, the provider's
+// authorization endpoint, and the callback carrying the flow cookie.
+func oidcLogin(t *testing.T, a *testApp, tamper func(callback *url.URL)) (int, map[string]any) {
+	t.Helper()
+	start, err := noRedirects.Get(a.Server.URL + "/auth/oidc/mock/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start.Body.Close()
+	if start.StatusCode != http.StatusFound {
+		t.Fatalf("start: status %d", start.StatusCode)
+	}
+	authURL, _ := url.Parse(start.Header.Get("Location"))
+	if q := authURL.Query(); q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" || q.Get("redirect_uri") != oidcRedirect {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	authz, err := noRedirects.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	authz.Body.Close()
+	if authz.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", authz.StatusCode)
+	}
+	callback, _ := url.Parse(authz.Header.Get("Location"))
+	if tamper != nil {
+		tamper(callback)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, a.Server.URL+callback.Path+"?"+callback.RawQuery, nil)
+	for _, c := range start.Cookies() {
+		req.AddCookie(c)
+	}
+	resp, err := noRedirects.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestOIDC_ProvisionsAndLogsInAgain(t *testing.T) {
+	a, idp := newOIDCTestApp(t, nil)
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-1", Email: "Jane.Doe@Corp.example", EmailVerified: true, Name: "Jane Doe", PreferredUsername: "jane.doe"})
+
+	status, body := oidcLogin(t, a, nil)
+	token, _ := body["token"].(string)
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("login: status %d, %v", status, body)
+	}
+	var me map[string]any
+	if resp := a.do(http.MethodGet, "/me", token, nil, &me); resp.StatusCode != http.StatusOK {
+		t.Fatalf("me: status %d", resp.StatusCode)
+	}
+	if me["username"] != "jane.doe" || me["email"] != "jane.doe@corp.example" || me["name"] != "Jane Doe" || me["email_verified"] != true {
+		t.Fatalf("unexpected provisioned account %v", me)
+	}
+
+	// the same subject logs into the same account, even with a changed email
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-1", Email: "jane@elsewhere.example", EmailVerified: true})
+	if status, body := oidcLogin(t, a, nil); status != http.StatusOK {
+		t.Fatalf("second login: status %d, %v", status, body)
+	}
+	var users, identities int64
+	a.DB.Model(&model.User{}).Count(&users)
+	a.DB.Model(&model.UserIdentity{}).Count(&identities)
+	if users != 1 || identities != 1 {
+		t.Fatalf("expected one user and one identity, got %d and %d", users, identities)
+	}
+
+	// a username that is taken gets a suffix
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-2", Email: "other-jane@corp.example", EmailVerified: true, PreferredUsername: "Jane.Doe"})
+	status, body = oidcLogin(t, a, nil)
+	token, _ = body["token"].(string)
+	a.do(http.MethodGet, "/me", token, nil, &me)
+	if status != http.StatusOK || me["username"] != "jane.doe2" {
+		t.Fatalf("expected username jane.doe2, got %d %v", status, me)
+	}
+}
+
+func TestOIDC_LinksByVerifiedEmail(t *testing.T) {
+	a, idp := newOIDCTestApp(t, nil)
+	local := a.registerAndLogin("local-user", "pass")
+
+	// the local address is not verified yet: linking could hijack the account
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-link", Email: "local-user@example.com", EmailVerified: true})
+	if status, body := oidcLogin(t, a, nil); status != http.StatusConflict {
+		t.Fatalf("expected 409 for an unverified local account, got %d %v", status, body)
+	}
+
+	a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": a.lastToken("local-user@example.com")}, nil)
+	status, body := oidcLogin(t, a, nil)
+	token, _ := body["token"].(string)
+	if status != http.StatusOK {
+		t.Fatalf("link: status %d, %v", status, body)
+	}
+	var viaSSO, viaPassword map[string]any
+	a.do(http.MethodGet, "/me", token, nil, &viaSSO)
+	a.do(http.MethodGet, "/me", local, nil, &viaPassword)
+	if viaSSO["id"] == nil || viaSSO["id"] != viaPassword["id"] {
+		t.Fatalf("expected the SSO login to reach the local account: %v vs %v", viaSSO, viaPassword)
+	}
+
+	// an unverified provider email is never used to link or provision
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-unverified", Email: "local-user@example.com", EmailVerified: false})
+	if status, _ := oidcLogin(t, a, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a verified email, got %d", status)
+	}
+}
+
+func TestOIDC_ProvisioningCanBeDisabled(t *testing.T) {
+	a, idp := newOIDCTestApp(t, func(cfg *config.Config) { cfg.OIDCAutoProvision = false })
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-new", Email: "new@corp.example", EmailVerified: true})
+	if status, body := oidcLogin(t, a, nil); status != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d %v", status, body)
+	}
+}
+
+func TestOIDC_RejectsTamperedState(t *testing.T) {
+	a, idp := newOIDCTestApp(t, nil)
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-3", Email: "x@corp.example", EmailVerified: true})
+
+	status, _ := oidcLogin(t, a, func(cb *url.URL) {
+		q := cb.Query()
+		q.Set("state", "forged")
+		cb.RawQuery = q.Encode()
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a forged state, got %d", status)
+	}
+
+	if resp := a.do(http.MethodGet, "/auth/oidc/unknown/start", "", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown provider, got %d", resp.StatusCode)
+	}
+}
+
+func TestOIDC_RequiresSecondFactor(t *testing.T) {
+	a, idp := newOIDCTestApp(t, nil)
+	jwt := a.registerAndLogin("sso-2fa", "pass")
+	a.do(http.MethodPost, "/email/verify", "", map[string]string{"token": a.lastToken("sso-2fa@example.com")}, nil)
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	a.do(http.MethodPost, "/account/2fa/setup", jwt, nil, &setup)
+	code, _ := totp.Code(setup.Secret, time.Now())
+	if resp := a.do(http.MethodPost, "/account/2fa/verify", jwt, map[string]string{"code": code}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("enable 2fa: status %d", resp.StatusCode)
+	}
+
+	idp.SetUser(testutil.OIDCUser{Subject: "sub-2fa", Email: "sso-2fa@example.com", EmailVerified: true})
+	status, body := oidcLogin(t, a, nil)
+	if status != http.StatusOK || body["two_factor_required"] != true || !strings.Contains(body["challenge_token"].(string), ".") {
+		t.Fatalf("expected a 2FA challenge, got %d %v", status, body)
+	}
+}
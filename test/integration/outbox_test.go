@@ -0,0 +1,166 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+// eventRecorder collects the events published on the bus.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []service.Event
+}
+
+func (a *testApp) recordEvents(topic string) *eventRecorder {
+	rec := &eventRecorder{}
+	a.t.Cleanup(a.Container.Events.Subscribe(topic, func(ctx context.Context, e service.Event) error {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.events = append(rec.events, e)
+		return nil
+	}))
+	return rec
+}
+
+func (r *eventRecorder) topics() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, e := range r.events {
+		out = append(out, e.Topic)
+	}
+	return out
+}
+
+// relay runs one pass of the outbox relay.
+func (a *testApp) relay() int {
+	a.t.Helper()
+	n, err := a.Container.Svcs.Outbox.Relay(a.t.Context())
+	if err != nil {
+		a.t.Fatalf("relay: %v", err)
+	}
+	return n
+}
+
+func TestOutbox_ChangesAreRelayedInOrder(t *testing.T) {
+	a := newTestApp(t)
+	rec := a.recordEvents("*")
+
+	token := a.registerAndLogin("alice", "pass")
+	var note model.Note
+	a.do(http.MethodPost, "/notes", token, map[string]string{"title": "t1", "content": "c1"}, &note)
+	a.do(http.MethodPut, fmt.Sprintf("/notes/%d", note.ID), token, map[string]string{"title": "t2", "content": "c2"}, nil)
+	a.do(http.MethodPatch, "/me", token, map[string]string{"name": "Alice A."}, nil)
+	a.do(http.MethodDelete, fmt.Sprintf("/notes/%d", note.ID), token, nil, nil)
+	a.do(http.MethodPost, "/me/password", token, map[string]string{"current_password": "pass", "new_password": "new-pass"}, nil)
+
+	// nothing is published before the relay runs
+	if got := rec.topics(); len(got) != 0 {
+		t.Fatalf("expected no events before relaying, got %v", got)
+	}
+	if n := a.relay(); n != 6 {
+		t.Fatalf("expected 6 events to be relayed, got %d", n)
+	}
+	want := []string{model.EventUserRegistered, model.EventNoteCreated, model.EventNoteUpdated, model.EventUserUpdated, model.EventNoteDeleted, model.EventUserPasswordChanged}
+	got := rec.topics()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	updated := rec.events[2]
+	var data struct {
+		ActorID uint `json:"actor_id"`
+		Note    struct {
+			ID    uint   `json:"id"`
+			Title string `json:"title"`
+		} `json:"note"`
+	}
+	if err := json.Unmarshal(updated.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if updated.AggregateType != model.AggregateNote || updated.AggregateID != note.ID || data.ActorID != a.userID("alice") || data.Note.Title != "t2" {
+		t.Fatalf("unexpected note.updated event: %+v %s", updated, updated.Data)
+	}
+
+	var pending int64
+	a.DB.Model(&model.OutboxEvent{}).Where("status <> ?", model.OutboxPublished).Count(&pending)
+	if pending != 0 || a.relay() != 0 {
+		t.Fatalf("expected every event to be marked published, %d are not", pending)
+	}
+}
+
+func TestOutbox_EventsAreWrittenWithTheirTransaction(t *testing.T) {
+	a := newTestApp(t)
+	uow := a.Container.Svcs.UnitOfWork
+	ctx := t.Context()
+
+	boom := errors.New("boom")
+	err := uow.Do(ctx, func(ctx context.Context) error {
+		if err := uow.Emit(ctx, model.EventUserUpdated, model.AggregateUser, 1, map[string]int{"n": 1}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected the change's error, got %v", err)
+	}
+	if err := uow.Emit(ctx, model.EventUserUpdated, model.AggregateUser, 1, nil); !errors.Is(err, service.ErrNoUnitOfWork) {
+		t.Fatalf("expected an event outside a unit of work to be refused, got %v", err)
+	}
+	var count int64
+	a.DB.Model(&model.OutboxEvent{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no event to be written, found %d", count)
+	}
+}
+
+func TestOutbox_FailedEventsAreRetried(t *testing.T) {
+	a := newTestApp(t)
+	fail := true
+	a.t.Cleanup(a.Container.Events.Subscribe(model.EventUserRegistered, func(ctx context.Context, e service.Event) error {
+		if fail {
+			return errors.New("subscriber down")
+		}
+		return nil
+	}))
+	rec := a.recordEvents(model.EventNoteCreated)
+
+	token := a.registerAndLogin("bob", "pass")
+	a.do(http.MethodPost, "/notes", token, map[string]string{"title": "t", "content": "c"}, nil)
+	if n := a.relay(); n != 0 || len(rec.topics()) != 0 {
+		t.Fatalf("expected the note event to wait for the failed one, %d relayed", n)
+	}
+	var failed model.OutboxEvent
+	a.DB.Where("topic = ?", model.EventUserRegistered).First(&failed)
+	if failed.Status != model.OutboxPending || failed.Attempts != 1 || failed.LastError != "subscriber down" {
+		t.Fatalf("expected the event to be retried, got %+v", failed)
+	}
+
+	fail = false
+	a.DB.Model(&model.OutboxEvent{}).Where("id = ?", failed.ID).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if n := a.relay(); n != 2 || len(rec.topics()) != 1 {
+		t.Fatalf("expected both events once the subscriber is back, %d relayed", n)
+	}
+
+	// published events are purged after the retention period
+	a.DB.Model(&model.OutboxEvent{}).Where("1 = 1").Update("published_at", time.Now().Add(-30*24*time.Hour))
+	purged, err := service.NewOutboxRelay(a.Container.Repos.Outbox, a.Container.Events, &config.Config{OutboxRetention: time.Hour}).Purge(t.Context())
+	if err != nil || purged != 2 {
+		t.Fatalf("expected the published events to be purged, got %d, %v", purged, err)
+	}
+}
@@ -0,0 +1,201 @@
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+)
+
+// syncChanges fetches the changes since the sync token since; path may
+// carry other query parameters.
+func (a *testApp) syncChanges(path, token, since string) service.SyncChanges {
+	a.t.Helper()
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	var res service.SyncChanges
+	if resp := a.do(http.MethodGet, path+sep+"since="+since, token, nil, &res); resp.StatusCode != http.StatusOK {
+		a.t.Fatalf("sync since %q: status %d", since, resp.StatusCode)
+	}
+	return res
+}
+
+func (a *testApp) syncPush(path, token string, changes ...map[string]any) []service.SyncResult {
+	a.t.Helper()
+	var res struct {
+		Results []service.SyncResult `json:"results"`
+	}
+	if resp := a.do(http.MethodPost, path, token, map[string]any{"changes": changes}, &res); resp.StatusCode != http.StatusOK {
+		a.t.Fatalf("sync push: status %d", resp.StatusCode)
+	}
+	return res.Results
+}
+
+func noteIDs(notes []model.Note) []uint {
+	ids := make([]uint, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func TestSync_DeltasAndTombstones(t *testing.T) {
+	a := newTestApp(t)
+	alice := a.registerAndLogin("alice", "pass")
+
+	var n1, n2, n3 model.Note
+	a.do(http.MethodPost, "/notes", alice, map[string]string{"title": "one", "content": "1"}, &n1)
+	a.do(http.MethodPost, "/notes", alice, map[string]string{"title": "two", "content": "2"}, &n2)
+	if n1.ChangeSeq == 0 || n2.ChangeSeq <= n1.ChangeSeq {
+		t.Fatalf("expected increasing change sequence numbers, got %d and %d", n1.ChangeSeq, n2.ChangeSeq)
+	}
+
+	// a sync from scratch returns every note
+	full := a.syncChanges("/sync", alice, "")
+	if fmt.Sprint(noteIDs(full.Notes)) != fmt.Sprint([]uint{n1.ID, n2.ID}) || len(full.Deleted) != 0 || full.HasMore {
+		t.Fatalf("unexpected full sync: %+v", full)
+	}
+	if again := a.syncChanges("/sync", alice, full.Token); len(again.Notes) != 0 || again.Token != full.Token {
+		t.Fatalf("expected no changes, got %+v", again)
+	}
+
+	// changes since then, in order, with the deletion as a tombstone
+	a.do(http.MethodPut, fmt.Sprintf("/notes/%d", n1.ID), alice, map[string]string{"title": "one", "content": "1b"}, nil)
+	a.do(http.MethodDelete, fmt.Sprintf("/notes/%d", n2.ID), alice, nil, nil)
+	a.do(http.MethodPost, "/notes", alice, map[string]string{"title": "three", "content": "3"}, &n3)
+	delta := a.syncChanges("/sync", alice, full.Token)
+	if fmt.Sprint(noteIDs(delta.Notes)) != fmt.Sprint([]uint{n1.ID, n3.ID}) || delta.Notes[0].Content != "1b" {
+		t.Fatalf("unexpected changed notes: %+v", delta.Notes)
+	}
+	if len(delta.Deleted) != 1 || delta.Deleted[0].ID != n2.ID {
+		t.Fatalf("expected a tombstone for note %d, got %+v", n2.ID, delta.Deleted)
+	}
+	var list []model.Note
+	a.do(http.MethodGet, "/notes", alice, nil, &list)
+	if len(list) != 2 {
+		t.Fatalf("expected tombstones hidden from the list, got %+v", list)
+	}
+
+	// the same changes, one at a time
+	var paged []uint
+	token := full.Token
+	for {
+		page := a.syncChanges("/sync?limit=1", alice, token)
+		for _, n := range page.Notes {
+			paged = append(paged, n.ID)
+		}
+		for _, d := range page.Deleted {
+			paged = append(paged, d.ID)
+		}
+		token = page.Token
+		if !page.HasMore {
+			break
+		}
+	}
+	if fmt.Sprint(paged) != fmt.Sprint([]uint{n1.ID, n2.ID, n3.ID}) || token != delta.Token {
+		t.Fatalf("unexpected pages: %v ending at %q", paged, token)
+	}
+
+	// tokens are checked
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", alice, map[string]string{"name": "Team"}, &team)
+	teamSync := fmt.Sprintf("/workspaces/%d/sync", team.ID)
+	for _, bad := range []string{"garbage", delta.Token} {
+		if resp := a.do(http.MethodGet, teamSync+"?since="+bad, alice, nil, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for token %q, got %d", bad, resp.StatusCode)
+		}
+	}
+
+	// once tombstones the client has not seen are purged, it starts over
+	if _, err := a.Container.Repos.Note.PurgeDeleted(t.Context(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if resp := a.do(http.MethodGet, "/sync?since="+full.Token, alice, nil, nil); resp.StatusCode != http.StatusGone {
+		t.Fatalf("expected 410 for an expired token, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/sync?since="+delta.Token, alice, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the latest token to stay valid, got %d", resp.StatusCode)
+	}
+}
+
+func TestSync_PushDetectsConflicts(t *testing.T) {
+	a := newTestApp(t)
+	owner := a.registerAndLogin("owner", "pass")
+	guest := a.registerAndLogin("guest", "pass")
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", owner, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(owner, team.ID, "guest", guest, "guest")
+	notes := fmt.Sprintf("/workspaces/%d/notes", team.ID)
+	sync := fmt.Sprintf("/workspaces/%d/sync", team.ID)
+
+	var kept, edited, removed model.Note
+	a.do(http.MethodPost, notes, owner, map[string]string{"title": "kept", "content": "k"}, &kept)
+	a.do(http.MethodPost, notes, owner, map[string]string{"title": "edited", "content": "e"}, &edited)
+	a.do(http.MethodPost, notes, owner, map[string]string{"title": "removed", "content": "r"}, &removed)
+	base := a.syncChanges(sync, owner, "")
+
+	// meanwhile, on another device
+	a.do(http.MethodPut, fmt.Sprintf("%s/%d", notes, edited.ID), owner, map[string]string{"title": "edited", "content": "elsewhere"}, nil)
+	a.do(http.MethodDelete, fmt.Sprintf("%s/%d", notes, removed.ID), owner, nil, nil)
+
+	results := a.syncPush(sync, owner,
+		map[string]any{"op": "create", "client_id": "tmp-1", "title": "offline", "content": "o"},
+		map[string]any{"op": "update", "id": kept.ID, "base_change_seq": kept.ChangeSeq, "title": "kept", "content": "k2"},
+		map[string]any{"op": "update", "id": edited.ID, "base_change_seq": edited.ChangeSeq, "title": "edited", "content": "offline"},
+		map[string]any{"op": "update", "id": removed.ID, "base_change_seq": removed.ChangeSeq, "title": "removed", "content": "r2"},
+		map[string]any{"op": "delete", "id": removed.ID, "base_change_seq": removed.ChangeSeq},
+		map[string]any{"op": "move", "id": kept.ID},
+	)
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got %+v", results)
+	}
+	created := results[0]
+	if created.Status != service.SyncApplied || created.ClientID != "tmp-1" || created.Note == nil || created.ID != created.Note.ID {
+		t.Fatalf("unexpected create result: %+v", created)
+	}
+	if r := results[1]; r.Status != service.SyncApplied || r.Note.Content != "k2" || r.Note.ChangeSeq <= kept.ChangeSeq {
+		t.Fatalf("unexpected update result: %+v", r)
+	}
+	if r := results[2]; r.Status != service.SyncConflict || r.Note == nil || r.Note.Content != "elsewhere" {
+		t.Fatalf("expected a conflict with the server's copy, got %+v", r)
+	}
+	if r := results[3]; r.Status != service.SyncConflict || !r.Deleted || r.Note != nil {
+		t.Fatalf("expected a conflict with the deletion, got %+v", r)
+	}
+	if r := results[4]; r.Status != service.SyncApplied {
+		t.Fatalf("expected deleting a deleted note to succeed, got %+v", r)
+	}
+	if r := results[5]; r.Status != service.SyncRejected || r.Error == "" {
+		t.Fatalf("expected an unknown op to be rejected, got %+v", r)
+	}
+	if got := a.noteContent(owner, fmt.Sprintf("%s/%d", notes, edited.ID)); got != "elsewhere" {
+		t.Fatalf("expected the conflicting change not applied, got %q", got)
+	}
+
+	// pushing the same update again, as after a lost response, is no conflict
+	retry := a.syncPush(sync, owner, map[string]any{"op": "update", "id": kept.ID, "base_change_seq": kept.ChangeSeq, "title": "kept", "content": "k2"})
+	if retry[0].Status != service.SyncApplied {
+		t.Fatalf("expected the retried update to be applied, got %+v", retry[0])
+	}
+
+	// the pushed changes come back in the next delta
+	delta := a.syncChanges(sync, owner, base.Token)
+	if fmt.Sprint(noteIDs(delta.Notes)) != fmt.Sprint([]uint{edited.ID, created.ID, kept.ID}) || len(delta.Deleted) != 1 {
+		t.Fatalf("unexpected delta: %+v", delta)
+	}
+
+	// guests may sync but not push
+	a.syncChanges(sync, guest, "")
+	if resp := a.do(http.MethodPost, sync, guest, map[string]any{"changes": []any{}}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a guest's push, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, sync, owner, map[string]any{}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without changes, got %d", resp.StatusCode)
+	}
+}
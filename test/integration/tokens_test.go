@@ -0,0 +1,82 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPersonalAccessTokens_ScopesAndRevocation(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("pat-user", "pass")
+
+	var created struct {
+		ID     uint     `json:"id"`
+		Token  string   `json:"token"`
+		Scopes []string `json:"scopes"`
+	}
+	resp := a.do(http.MethodPost, "/account/tokens", jwt, map[string]any{"name": "ci", "scopes": []string{"notes:read"}}, &created)
+	if resp.StatusCode != http.StatusCreated || created.Token == "" {
+		t.Fatalf("create token: status %d, %+v", resp.StatusCode, created)
+	}
+
+	if resp := a.do(http.MethodGet, "/notes", created.Token, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected PAT with notes:read to list notes, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/notes", created.Token, map[string]string{"title": "t"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for write without notes:write, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/account/tokens", created.Token, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for token management without account:admin, got %d", resp.StatusCode)
+	}
+
+	var list []map[string]any
+	a.do(http.MethodGet, "/account/tokens", jwt, nil, &list)
+	if len(list) != 1 || list[0]["last_used_at"] == nil || list[0]["token"] != nil {
+		t.Fatalf("unexpected token listing: %v", list)
+	}
+
+	if resp := a.do(http.MethodDelete, "/account/tokens/1", jwt, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("revoke: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, "/notes", created.Token, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestPersonalAccessTokens_RejectsUnknownScope(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("pat-user2", "pass")
+
+	resp := a.do(http.MethodPost, "/account/tokens", jwt, map[string]any{"name": "x", "scopes": []string{"notes:everything"}}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown scope, got %d", resp.StatusCode)
+	}
+}
+
+func TestPersonalAccessTokens_CannotGrantMoreThanTheyHave(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("pat-user3", "pass")
+
+	var manager struct {
+		Token string `json:"token"`
+	}
+	a.do(http.MethodPost, "/account/tokens", jwt, map[string]any{"name": "manager", "scopes": []string{"account:admin", "notes:read"}}, &manager)
+
+	if resp := a.do(http.MethodPost, "/account/tokens", manager.Token, map[string]any{"name": "ro", "scopes": []string{"notes:read"}}, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected a token to hand on its own scope, got %d", resp.StatusCode)
+	}
+	for _, scope := range []string{"notes:write", "admin"} {
+		if resp := a.do(http.MethodPost, "/account/tokens", manager.Token, map[string]any{"name": "more", "scopes": []string{scope}}, nil); resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403 granting %s through a token, got %d", scope, resp.StatusCode)
+		}
+	}
+
+	// only an interactive session grants admin, even to a token holding it
+	var admin struct {
+		Token string `json:"token"`
+	}
+	a.do(http.MethodPost, "/account/tokens", jwt, map[string]any{"name": "ops", "scopes": []string{"account:admin", "admin"}}, &admin)
+	if resp := a.do(http.MethodPost, "/account/tokens", admin.Token, map[string]any{"name": "copy", "scopes": []string{"admin"}}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 granting admin through a token, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,97 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/internal/tracing"
+	"github.com/MujiRahman/golang-simple-note/test/testutil"
+)
+
+func TestTracing_RequestServiceAndQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	gdb := testutil.NewMigratedSQLiteDB(t)
+	if err := gdb.Use(tracing.GormPlugin{}); err != nil {
+		t.Fatalf("use tracing plugin: %v", err)
+	}
+	cfg := &config.Config{JWTSecret: "trace-secret", TokenTTL: 3600}
+	users, workspaces := repository.NewUserRepository(gdb), repository.NewWorkspaceRepository(gdb)
+	audit := service.NewAuditService(repository.NewAuditRepository(gdb), repository.NewTransactor(gdb))
+	uow := service.NewUnitOfWork(repository.NewTransactor(gdb), repository.NewOutboxRepository(gdb))
+	userSvc := service.NewUserService(users, workspaces, audit, uow, hmacKeys(t, cfg.JWTSecret), cfg)
+	noteSvc := service.NewNoteService(repository.NewNoteRepository(gdb), service.NewWorkspaceService(workspaces, users, audit, uow), audit, uow, service.NewNotificationService(repository.NewNotificationRepository(gdb), users, workspaces, uow))
+	if _, err := userSvc.Register(context.Background(), "Tracer", "tracer", "tracer@example.com", "pw"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	token, err := userSvc.Login(context.Background(), "tracer", "pw")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	router := app.NewRouter(userSvc, noteSvc, cfg)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	b, _ := json.Marshal(map[string]string{"title": "t", "content": "c"})
+	req := httptest.NewRequest(http.MethodPost, "/notes", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", traceparent)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			spans[s.Name()] = s
+		}
+	}
+	server, ok := spans["POST /notes"]
+	if !ok {
+		t.Fatalf("missing server span, got %v", keys(spans))
+	}
+	if server.SpanKind() != trace.SpanKindServer || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span does not continue incoming traceparent")
+	}
+	svcSpan, ok := spans["NoteService.Create"]
+	if !ok || svcSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("NoteService.Create span missing or not a child of the server span")
+	}
+	dbSpan, ok := spans["gorm.create"]
+	if !ok || dbSpan.Parent().SpanID() != svcSpan.SpanContext().SpanID() {
+		t.Fatalf("gorm.create span missing or not a child of the service span")
+	}
+
+	// outgoing propagation
+	out, _ := http.NewRequestWithContext(trace.ContextWithSpanContext(context.Background(), server.SpanContext()), http.MethodPost, "http://example.invalid", nil)
+	tracing.Inject(out.Context(), out)
+	if got := out.Header.Get("traceparent"); got == "" {
+		t.Fatalf("expected traceparent header on outgoing request")
+	}
+}
+
+func keys(m map[string]sdktrace.ReadOnlySpan) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/totp"
+)
+
+func TestTwoFactor_EnrollLoginRecoverDisable(t *testing.T) {
+	a := newTestApp(t)
+	jwt := a.registerAndLogin("mfa-user", "pass")
+	creds := map[string]string{"username": "mfa-user", "password": "pass"}
+
+	var setup struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+	if resp := a.do(http.MethodPost, "/account/2fa/setup", jwt, nil, &setup); resp.StatusCode != http.StatusOK || setup.Secret == "" {
+		t.Fatalf("setup: status %d, %+v", resp.StatusCode, setup)
+	}
+	if resp := a.do(http.MethodPost, "/account/2fa/verify", jwt, map[string]string{"code": "000000"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected wrong code to be rejected, got %d", resp.StatusCode)
+	}
+	code, _ := totp.Code(setup.Secret, time.Now())
+	var verify struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if resp := a.do(http.MethodPost, "/account/2fa/verify", jwt, map[string]string{"code": code}, &verify); resp.StatusCode != http.StatusOK {
+		t.Fatalf("verify: status %d", resp.StatusCode)
+	}
+	if len(verify.RecoveryCodes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(verify.RecoveryCodes))
+	}
+
+	// password alone only yields a challenge, which is not an access token
+	var login struct {
+		Token          string `json:"token"`
+		Required       bool   `json:"two_factor_required"`
+		ChallengeToken string `json:"challenge_token"`
+	}
+	a.do(http.MethodPost, "/login", "", creds, &login)
+	if !login.Required || login.Token != "" || login.ChallengeToken == "" {
+		t.Fatalf("expected a 2FA challenge, got %+v", login)
+	}
+	if resp := a.do(http.MethodGet, "/notes", login.ChallengeToken, nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected challenge token to be rejected as access token, got %d", resp.StatusCode)
+	}
+
+	// the code that enabled 2FA is spent
+	if resp := a.do(http.MethodPost, "/login/2fa", "", map[string]string{"challenge_token": login.ChallengeToken, "code": code}, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the enrolment code to be rejected, got %d", resp.StatusCode)
+	}
+
+	var second map[string]string
+	a.do(http.MethodPost, "/login", "", creds, &login)
+	code, _ = totp.Code(setup.Secret, time.Now().Add(30*time.Second))
+	resp := a.do(http.MethodPost, "/login/2fa", "", map[string]string{"challenge_token": login.ChallengeToken, "code": code}, &second)
+	if resp.StatusCode != http.StatusOK || second["token"] == "" {
+		t.Fatalf("login/2fa: status %d, %v", resp.StatusCode, second)
+	}
+	if resp := a.do(http.MethodGet, "/notes", second["token"], nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected access token to work, got %d", resp.StatusCode)
+	}
+
+	// a TOTP code is accepted once, even within its time step
+	a.do(http.MethodPost, "/login", "", creds, &login)
+	if resp := a.do(http.MethodPost, "/login/2fa", "", map[string]string{"challenge_token": login.ChallengeToken, "code": code}, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a replayed TOTP code to be rejected, got %d", resp.StatusCode)
+	}
+
+	// recovery codes work exactly once
+	recovery := verify.RecoveryCodes[0]
+	a.do(http.MethodPost, "/login", "", creds, &login)
+	if resp := a.do(http.MethodPost, "/login/2fa", "", map[string]string{"challenge_token": login.ChallengeToken, "code": recovery}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected recovery code to be accepted, got %d", resp.StatusCode)
+	}
+	a.do(http.MethodPost, "/login", "", creds, &login)
+	if resp := a.do(http.MethodPost, "/login/2fa", "", map[string]string{"challenge_token": login.ChallengeToken, "code": recovery}, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected reused recovery code to be rejected, got %d", resp.StatusCode)
+	}
+
+	code = verify.RecoveryCodes[1]
+	if resp := a.do(http.MethodPost, "/account/2fa/disable", second["token"], map[string]string{"password": "wrong", "code": code}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected disable with wrong password to fail, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, "/account/2fa/disable", second["token"], map[string]string{"password": "pass", "code": code}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("disable: status %d", resp.StatusCode)
+	}
+	var plain map[string]any
+	a.do(http.MethodPost, "/login", "", creds, &plain)
+	if plain["token"] == nil {
+		t.Fatalf("expected plain password login after disabling 2FA, got %v", plain)
+	}
+}
@@ -0,0 +1,307 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/pkg/webhook"
+)
+
+type webhookResp struct {
+	ID         uint       `json:"id"`
+	Events     []string   `json:"events"`
+	Enabled    bool       `json:"enabled"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	Secret     string     `json:"secret"`
+}
+
+type deliveryPageResp struct {
+	Deliveries []model.WebhookDelivery `json:"deliveries"`
+	Total      int64                   `json:"total"`
+}
+
+// receivedHook is a request received by a webhookReceiver.
+type receivedHook struct {
+	Header http.Header
+	Body   []byte
+}
+
+// webhookReceiver records the requests it receives and answers them with
+// status.
+type webhookReceiver struct {
+	*httptest.Server
+	status atomic.Int32
+	mu     sync.Mutex
+	got    []receivedHook
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	rcv := &webhookReceiver{}
+	rcv.status.Store(http.StatusOK)
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.got = append(rcv.got, receivedHook{Header: r.Header.Clone(), Body: body})
+		rcv.mu.Unlock()
+		w.WriteHeader(int(rcv.status.Load()))
+		io.WriteString(w, "thanks")
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (r *webhookReceiver) received() []receivedHook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedHook(nil), r.got...)
+}
+
+func newWebhookTestApp(t *testing.T, cfg *config.Config) *testApp {
+	cfg.JWTSecret = "integration-secret"
+	cfg.TokenTTL = 3600
+	cfg.MailDriver = "memory"
+	cfg.WebhookAllowPrivateNetworks = true // the receiver listens on 127.0.0.1
+	return newTestAppWithConfig(t, cfg)
+}
+
+// deliver relays the outbox, which queues the deliveries, and runs one pass
+// of the delivery worker.
+func (a *testApp) deliver() int {
+	a.t.Helper()
+	a.relay()
+	n, err := a.Container.Svcs.Webhook.DeliverDue(a.t.Context())
+	if err != nil {
+		a.t.Fatalf("deliver: %v", err)
+	}
+	return n
+}
+
+// makeDue makes the pending deliveries of the webhook due now, as if their
+// retry delay had passed.
+func (a *testApp) makeDue(webhookID uint) {
+	a.t.Helper()
+	err := a.DB.Model(&model.WebhookDelivery{}).
+		Where("webhook_id = ? AND status = ?", webhookID, model.DeliveryPending).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		a.t.Fatalf("make due: %v", err)
+	}
+}
+
+func TestWebhooks_SignedDeliveries(t *testing.T) {
+	a := newWebhookTestApp(t, &config.Config{})
+	rcv := newWebhookReceiver(t)
+	user := a.registerAndLogin("hooked", "pass")
+	other := a.registerAndLogin("other", "pass")
+
+	var hook webhookResp
+	body := map[string]any{"url": rcv.URL, "events": []string{model.WebhookNoteCreated, model.WebhookNoteDeleted}}
+	if resp := a.do(http.MethodPost, "/webhooks", user, body, &hook); resp.StatusCode != http.StatusCreated || hook.Secret == "" || !hook.Enabled {
+		t.Fatalf("create: status %d, %+v", resp.StatusCode, hook)
+	}
+	for _, bad := range []map[string]any{
+		{"url": "ftp://example.com", "events": []string{model.WebhookNoteCreated}},
+		{"url": rcv.URL, "events": []string{"note.exploded"}},
+		{"url": rcv.URL},
+	} {
+		if resp := a.do(http.MethodPost, "/webhooks", user, bad, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d", bad, resp.StatusCode)
+		}
+	}
+	path := fmt.Sprintf("/webhooks/%d", hook.ID)
+	var got webhookResp
+	if a.do(http.MethodGet, path, user, nil, &got); got.Secret != "" {
+		t.Fatalf("the secret must only be shown on creation")
+	}
+	if resp := a.do(http.MethodGet, path, other, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's webhook, got %d", resp.StatusCode)
+	}
+
+	var note model.Note
+	a.do(http.MethodPost, "/notes", user, map[string]string{"title": "t", "content": "c"}, &note)
+	a.do(http.MethodPut, fmt.Sprintf("/notes/%d", note.ID), user, map[string]string{"title": "t2", "content": "c2"}, nil)
+	a.do(http.MethodDelete, fmt.Sprintf("/notes/%d", note.ID), user, nil, nil)
+	// notes of workspaces the subscriber is not a member of are not sent
+	a.do(http.MethodPost, "/notes", other, map[string]string{"title": "private", "content": "c"}, nil)
+
+	if n := a.deliver(); n != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", n)
+	}
+	reqs := rcv.received()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(reqs))
+	}
+	for i, want := range []string{model.WebhookNoteCreated, model.WebhookNoteDeleted} {
+		r := reqs[i]
+		if r.Header.Get(webhook.HeaderEvent) != want {
+			t.Fatalf("request %d: expected %s, got %s", i, want, r.Header.Get(webhook.HeaderEvent))
+		}
+		if err := webhook.Verify(hook.Secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), r.Body, time.Now(), 0); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		var payload struct {
+			ID    string `json:"id"`
+			Event string `json:"event"`
+			Data  struct {
+				Note struct {
+					ID    uint   `json:"id"`
+					Title string `json:"title"`
+				} `json:"note"`
+			} `json:"data"`
+		}
+		json.Unmarshal(r.Body, &payload)
+		if payload.Event != want || payload.ID != r.Header.Get(webhook.HeaderDelivery) || payload.Data.Note.ID != note.ID {
+			t.Fatalf("request %d: unexpected payload %s", i, r.Body)
+		}
+	}
+
+	var log deliveryPageResp
+	a.do(http.MethodGet, path+"/deliveries", user, nil, &log)
+	if log.Total != 2 || log.Deliveries[0].Status != model.DeliverySucceeded || log.Deliveries[0].ResponseCode != http.StatusOK || log.Deliveries[0].ResponseBody != "thanks" {
+		t.Fatalf("unexpected delivery log: %+v", log)
+	}
+
+	// a redelivery sends the same event again
+	redeliver := fmt.Sprintf("%s/deliveries/%d/redeliver", path, log.Deliveries[0].ID)
+	if resp := a.do(http.MethodPost, redeliver, user, nil, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("redeliver: status %d", resp.StatusCode)
+	}
+	a.deliver()
+	reqs = rcv.received()
+	if len(reqs) != 3 || reqs[2].Header.Get(webhook.HeaderDelivery) != reqs[1].Header.Get(webhook.HeaderDelivery) {
+		t.Fatalf("expected the deleted event to be delivered again, got %d requests", len(reqs))
+	}
+
+	if resp := a.do(http.MethodDelete, path, user, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, path+"/deliveries", user, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after deletion, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebhooks_DeliveriesContinueTheTrace(t *testing.T) {
+	a := newWebhookTestApp(t, &config.Config{})
+	rcv := newWebhookReceiver(t)
+	user := a.registerAndLogin("hooked", "pass")
+	a.do(http.MethodPost, "/webhooks", user, map[string]any{"url": rcv.URL, "events": []string{model.WebhookNoteCreated}}, nil)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest(http.MethodPost, a.Server.URL+"/notes", strings.NewReader(`{"title":"t","content":"c"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+user)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create note: status %d", resp.StatusCode)
+	}
+
+	// the delivery is sent later, by a worker outside the request
+	if n := a.deliver(); n != 1 {
+		t.Fatalf("expected 1 delivery, got %d", n)
+	}
+	reqs := rcv.received()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	if got := reqs[0].Header.Get("traceparent"); !strings.HasPrefix(got, "00-"+traceID+"-") {
+		t.Fatalf("expected the delivery in trace %s, got traceparent %q", traceID, got)
+	}
+}
+
+func TestWebhooks_RetryAndAutoDisable(t *testing.T) {
+	a := newWebhookTestApp(t, &config.Config{WebhookRetryBase: time.Minute, WebhookMaxAttempts: 5, WebhookDisableAfter: 2})
+	rcv := newWebhookReceiver(t)
+	rcv.status.Store(http.StatusInternalServerError)
+	user := a.registerAndLogin("flaky", "pass")
+
+	var hook webhookResp
+	a.do(http.MethodPost, "/webhooks", user, map[string]any{"url": rcv.URL, "events": []string{model.WebhookNoteCreated}}, &hook)
+	path := fmt.Sprintf("/webhooks/%d", hook.ID)
+	a.do(http.MethodPost, "/notes", user, map[string]string{"title": "t", "content": "c"}, nil)
+
+	start := time.Now()
+	a.deliver()
+	var log deliveryPageResp
+	a.do(http.MethodGet, path+"/deliveries", user, nil, &log)
+	d := log.Deliveries[0]
+	if d.Status != model.DeliveryPending || d.Attempts != 1 || d.ResponseCode != http.StatusInternalServerError || d.NextAttemptAt == nil {
+		t.Fatalf("expected a pending retry, got %+v", d)
+	}
+	if wait := d.NextAttemptAt.Sub(start); wait < time.Minute || wait > 2*time.Minute {
+		t.Fatalf("expected the first retry after the base delay, got %v", wait)
+	}
+	if n := a.deliver(); n != 0 {
+		t.Fatalf("expected no delivery before the retry is due, got %d", n)
+	}
+
+	// make the retry due; the second failure disables the webhook
+	a.makeDue(hook.ID)
+	a.deliver()
+	log = deliveryPageResp{}
+	a.do(http.MethodGet, path+"/deliveries", user, nil, &log)
+	if d := log.Deliveries[0]; d.Attempts != 2 || d.NextAttemptAt.Sub(time.Now()) < time.Minute {
+		t.Fatalf("expected the second retry to wait twice as long, got %+v", d)
+	}
+	var got webhookResp
+	if a.do(http.MethodGet, path, user, nil, &got); got.Enabled || got.DisabledAt == nil {
+		t.Fatalf("expected the webhook to be disabled, got %+v", got)
+	}
+	redeliver := fmt.Sprintf("%s/deliveries/%d/redeliver", path, d.ID)
+	if resp := a.do(http.MethodPost, redeliver, user, nil, nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 redelivering to a disabled webhook, got %d", resp.StatusCode)
+	}
+
+	// once the receiver is fixed and the webhook re-enabled, the pending
+	// retry and the redelivery go through
+	rcv.status.Store(http.StatusNoContent)
+	if a.do(http.MethodPatch, path, user, map[string]any{"enabled": true}, &got); !got.Enabled {
+		t.Fatalf("expected the webhook to be enabled, got %+v", got)
+	}
+	if resp := a.do(http.MethodPost, redeliver, user, nil, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("redeliver: status %d", resp.StatusCode)
+	}
+	a.makeDue(hook.ID)
+	if n := a.deliver(); n != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", n)
+	}
+	log = deliveryPageResp{}
+	a.do(http.MethodGet, path+"/deliveries", user, nil, &log)
+	if log.Total != 2 || log.Deliveries[0].Status != model.DeliverySucceeded || log.Deliveries[1].Status != model.DeliverySucceeded || log.Deliveries[1].Attempts != 3 {
+		t.Fatalf("unexpected delivery log: %+v", log)
+	}
+}
+
+func TestWebhooks_PrivateReceiversRefused(t *testing.T) {
+	a := newTestApp(t)
+	rcv := newWebhookReceiver(t)
+	user := a.registerAndLogin("ssrf", "pass")
+
+	var hook webhookResp
+	a.do(http.MethodPost, "/webhooks", user, map[string]any{"url": rcv.URL, "events": []string{model.WebhookNoteCreated}}, &hook)
+	a.do(http.MethodPost, "/notes", user, map[string]string{"title": "t", "content": "c"}, nil)
+	a.deliver()
+
+	if len(rcv.received()) != 0 {
+		t.Fatalf("expected no request to reach a loopback receiver")
+	}
+	var log deliveryPageResp
+	a.do(http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", hook.ID), user, nil, &log)
+	if d := log.Deliveries[0]; d.Status != model.DeliveryPending || d.Error == "" {
+		t.Fatalf("expected a failed attempt, got %+v", d)
+	}
+}
@@ -0,0 +1,220 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+)
+
+type workspaceResp struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Personal bool   `json:"personal"`
+	Role     string `json:"role"`
+}
+
+// doInWorkspace is do with the X-Workspace-ID header set.
+func (a *testApp) doInWorkspace(method, path, token string, workspaceID uint, body any, out any) *http.Response {
+	a.t.Helper()
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, a.Server.URL+path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Workspace-ID", fmt.Sprint(workspaceID))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp
+}
+
+// invite invites username to the workspace and accepts on their behalf.
+func (a *testApp) inviteAndAccept(ownerToken string, workspaceID uint, username, userToken, role string) {
+	a.t.Helper()
+	var inv struct {
+		ID uint `json:"id"`
+	}
+	path := fmt.Sprintf("/workspaces/%d/invitations", workspaceID)
+	if resp := a.do(http.MethodPost, path, ownerToken, map[string]string{"username": username, "role": role}, &inv); resp.StatusCode != http.StatusCreated {
+		a.t.Fatalf("invite %s: status %d", username, resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/invitations/%d/accept", inv.ID), userToken, nil, nil); resp.StatusCode != http.StatusOK {
+		a.t.Fatalf("accept %s: status %d", username, resp.StatusCode)
+	}
+}
+
+func TestWorkspaces_PersonalWorkspaceAtRegistration(t *testing.T) {
+	a := newTestApp(t)
+	token := a.registerAndLogin("solo", "pass")
+
+	var list []workspaceResp
+	if resp := a.do(http.MethodGet, "/workspaces", token, nil, &list); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list: status %d", resp.StatusCode)
+	}
+	if len(list) != 1 || !list[0].Personal || list[0].Role != "owner" {
+		t.Fatalf("expected one personal workspace, got %+v", list)
+	}
+
+	// notes without a workspace land in the personal one
+	var note model.Note
+	a.do(http.MethodPost, "/notes", token, map[string]string{"title": "t", "content": "c"}, &note)
+	if note.WorkspaceID != list[0].ID {
+		t.Fatalf("expected note in workspace %d, got %d", list[0].ID, note.WorkspaceID)
+	}
+	if resp := a.do(http.MethodDelete, fmt.Sprintf("/workspaces/%d", list[0].ID), token, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 deleting the personal workspace, got %d", resp.StatusCode)
+	}
+}
+
+func TestWorkspaces_SharedNotesAndRoles(t *testing.T) {
+	a := newTestApp(t)
+	owner := a.registerAndLogin("owner", "pass")
+	member := a.registerAndLogin("member", "pass")
+	guest := a.registerAndLogin("guest", "pass")
+	outsider := a.registerAndLogin("outsider", "pass")
+
+	var team workspaceResp
+	if resp := a.do(http.MethodPost, "/workspaces", owner, map[string]string{"name": "Team"}, &team); resp.StatusCode != http.StatusCreated || team.Role != "owner" {
+		t.Fatalf("create: status %d, %+v", resp.StatusCode, team)
+	}
+	a.inviteAndAccept(owner, team.ID, "member", member, "member")
+
+	// invite by email, then decline
+	var inv struct {
+		ID uint `json:"id"`
+	}
+	path := fmt.Sprintf("/workspaces/%d/invitations", team.ID)
+	a.do(http.MethodPost, path, owner, map[string]string{"email": "Guest@Example.com", "role": "guest"}, &inv)
+	var pending []map[string]any
+	a.do(http.MethodGet, "/invitations", guest, nil, &pending)
+	if len(pending) != 1 || pending[0]["workspace_name"] != "Team" {
+		t.Fatalf("expected one invitation to Team, got %v", pending)
+	}
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/invitations/%d/accept", inv.ID), outsider, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 accepting someone else's invitation, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodPost, fmt.Sprintf("/invitations/%d/decline", inv.ID), guest, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("decline: status %d", resp.StatusCode)
+	}
+	a.inviteAndAccept(owner, team.ID, "guest", guest, "guest")
+	if resp := a.do(http.MethodPost, path, owner, map[string]string{"username": "guest"}, nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 inviting a member again, got %d", resp.StatusCode)
+	}
+
+	// a member writes through the path, the owner edits the same note through the header
+	var note model.Note
+	notesPath := fmt.Sprintf("/workspaces/%d/notes", team.ID)
+	if resp := a.do(http.MethodPost, notesPath, member, map[string]string{"title": "plan", "content": "c"}, &note); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("member create: status %d", resp.StatusCode)
+	}
+	if resp := a.doInWorkspace(http.MethodPut, fmt.Sprintf("/notes/%d", note.ID), owner, team.ID, map[string]string{"title": "plan v2", "content": "c"}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("owner update: status %d", resp.StatusCode)
+	}
+
+	// guests read but cannot write
+	var notes []model.Note
+	a.doInWorkspace(http.MethodGet, "/notes", guest, team.ID, nil, &notes)
+	if len(notes) != 1 || notes[0].Title != "plan v2" {
+		t.Fatalf("guest list: %+v", notes)
+	}
+	if resp := a.do(http.MethodPost, notesPath, guest, map[string]string{"title": "x"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a guest writing, got %d", resp.StatusCode)
+	}
+
+	// outsiders see nothing, and the note is not in anyone's personal workspace
+	if resp := a.do(http.MethodGet, notesPath, outsider, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an outsider, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, fmt.Sprintf("/notes/%d", note.ID), member, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 outside the note's workspace, got %d", resp.StatusCode)
+	}
+
+	// promote the guest; admins cannot touch the owner
+	var users []map[string]any
+	a.do(http.MethodGet, fmt.Sprintf("/workspaces/%d/members", team.ID), member, nil, &users)
+	if len(users) != 3 || users[0]["username"] != "owner" {
+		t.Fatalf("members: %v", users)
+	}
+	ownerID, memberID, guestID := users[0]["user_id"], users[1]["user_id"], users[2]["user_id"]
+	memberPath := func(id any) string { return fmt.Sprintf("/workspaces/%d/members/%v", team.ID, id) }
+	if resp := a.do(http.MethodPatch, memberPath(memberID), owner, map[string]string{"role": "admin"}, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("promote: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodDelete, memberPath(ownerID), member, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for an admin removing the owner, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodDelete, memberPath(ownerID), owner, nil, nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for the last owner leaving, got %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodDelete, memberPath(guestID), member, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("admin removing a guest: status %d", resp.StatusCode)
+	}
+	if resp := a.do(http.MethodGet, notesPath, guest, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a removed guest to lose access, got %d", resp.StatusCode)
+	}
+}
+
+func TestWorkspaces_AccountDeletionKeepsSharedNotes(t *testing.T) {
+	a := newTestApp(t)
+	owner := a.registerAndLogin("leaver", "pass")
+	admin := a.registerAndLogin("heir", "pass")
+
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", owner, map[string]string{"name": "Team"}, &team)
+	a.inviteAndAccept(owner, team.ID, "heir", admin, "admin")
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), owner, map[string]string{"title": "shared"}, nil)
+	a.do(http.MethodPost, "/notes", owner, map[string]string{"title": "private"}, nil)
+
+	var u model.User
+	a.DB.Where("username = ?", "leaver").First(&u)
+	if _, err := a.Container.Repos.User.DeleteWithData(t.Context(), u.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	var notes []model.Note
+	a.DB.Find(&notes)
+	if len(notes) != 1 || notes[0].Title != "shared" {
+		t.Fatalf("expected only the shared note to remain, got %+v", notes)
+	}
+	var got workspaceResp
+	if resp := a.do(http.MethodGet, fmt.Sprintf("/workspaces/%d", team.ID), admin, nil, &got); resp.StatusCode != http.StatusOK || got.Role != "owner" {
+		t.Fatalf("expected the admin to inherit ownership: status %d, %+v", resp.StatusCode, got)
+	}
+}
+
+func TestWorkspaces_DeleteIsAuditedAndRelayed(t *testing.T) {
+	a := newTestApp(t)
+	owner := a.registerAndLogin("owner", "pass")
+	var team workspaceResp
+	a.do(http.MethodPost, "/workspaces", owner, map[string]string{"name": "Team"}, &team)
+	a.do(http.MethodPost, fmt.Sprintf("/workspaces/%d/notes", team.ID), owner, map[string]string{"title": "shared"}, nil)
+	a.relay()
+
+	deleted := a.recordEvents(model.EventWorkspaceDeleted)
+	notes := a.recordEvents(model.EventNoteDeleted)
+	if resp := a.do(http.MethodDelete, fmt.Sprintf("/workspaces/%d", team.ID), owner, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	a.relay()
+	if got := deleted.topics(); len(got) != 1 {
+		t.Fatalf("expected one workspace.deleted event, got %v", got)
+	}
+	// the workspace's notes go with it without events of their own
+	if got := notes.topics(); len(got) != 0 {
+		t.Fatalf("expected no note.deleted events, got %v", got)
+	}
+
+	var page auditPageResp
+	a.do(http.MethodGet, "/me/audit?action="+model.AuditWorkspaceDelete, owner, nil, &page)
+	if len(page.Events) != 1 || page.Events[0].TargetID != team.ID || page.Events[0].Before["name"] != "Team" {
+		t.Fatalf("expected a workspace.delete audit event, got %+v", page.Events)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,24 +10,32 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/service"
 	"github.com/MujiRahman/golang-simple-note/pkg/contextkey"
 	"github.com/MujiRahman/golang-simple-note/pkg/middleware"
 )
 
 // fakeUserService implements the minimal UserService methods for middleware tests
 type fakeUserService struct {
+	// methods not stubbed here panic if called
+	service.UserService
+
 	uid uint
 	err error
 }
 
-func (f *fakeUserService) Register(username, password string) (*model.User, error) {
+func (f *fakeUserService) Register(ctx context.Context, name, username, email, password string) (*model.User, error) {
 	return &model.User{ID: f.uid, Username: username}, nil
 }
-func (f *fakeUserService) Login(username, password string) (string, error) { return "", nil }
-func (f *fakeUserService) ParseToken(tokenStr string) (uint, error)        { return f.uid, f.err }
+func (f *fakeUserService) Login(ctx context.Context, username, password string) (string, error) {
+	return "", nil
+}
+func (f *fakeUserService) ParseToken(ctx context.Context, tokenStr string) (uint, error) {
+	return f.uid, f.err
+}
 
 func TestAuthMiddleware_MissingHeader(t *testing.T) {
-	mw := middleware.AuthMiddleware(&fakeUserService{uid: 0, err: nil})
+	mw := middleware.AuthMiddleware(&fakeUserService{uid: 0, err: nil}, nil)
 
 	// Create a test gin context
 	w := httptest.NewRecorder()
@@ -42,7 +51,7 @@ func TestAuthMiddleware_MissingHeader(t *testing.T) {
 }
 
 func TestAuthMiddleware_InvalidHeaderFormat(t *testing.T) {
-	mw := middleware.AuthMiddleware(&fakeUserService{uid: 0, err: nil})
+	mw := middleware.AuthMiddleware(&fakeUserService{uid: 0, err: nil}, nil)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -58,7 +67,7 @@ func TestAuthMiddleware_InvalidHeaderFormat(t *testing.T) {
 }
 
 func TestAuthMiddleware_InvalidToken(t *testing.T) {
-	mw := middleware.AuthMiddleware(&fakeUserService{uid: 0, err: fmt.Errorf("invalid")})
+	mw := middleware.AuthMiddleware(&fakeUserService{uid: 0, err: fmt.Errorf("invalid")}, nil)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -74,7 +83,7 @@ func TestAuthMiddleware_InvalidToken(t *testing.T) {
 }
 
 func TestAuthMiddleware_ValidToken(t *testing.T) {
-	mw := middleware.AuthMiddleware(&fakeUserService{uid: 77, err: nil})
+	mw := middleware.AuthMiddleware(&fakeUserService{uid: 77, err: nil}, nil)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
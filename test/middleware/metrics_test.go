@@ -0,0 +1,69 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/metrics"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/pkg/middleware"
+	"github.com/MujiRahman/golang-simple-note/test/testutil"
+)
+
+func TestMetrics_LabelsByRouteTemplate(t *testing.T) {
+	r := gin.New()
+	r.Use(middleware.Metrics())
+	r.GET("/things/:id", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+
+	before := promtest.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/things/:id", "418"))
+	for _, id := range []string{"1", "2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/"+id, nil))
+	}
+	after := promtest.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/things/:id", "418"))
+	if after-before != 2 {
+		t.Fatalf("expected 2 requests recorded for route template, got %v", after-before)
+	}
+}
+
+func TestMetrics_GormPluginRecordsQueries(t *testing.T) {
+	gdb := testutil.NewMigratedSQLiteDB(t)
+	if err := gdb.Use(metrics.GormPlugin{}); err != nil {
+		t.Fatalf("use plugin: %v", err)
+	}
+	if err := gdb.Create(&model.Note{UserID: 1, Title: "t"}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	gdb.Table("missing_table").Find(&[]model.Note{})
+
+	if n := promtest.CollectAndCount(metrics.DBQueryDuration, "noteapp_db_query_duration_seconds"); n == 0 {
+		t.Fatalf("expected query duration samples")
+	}
+	if v := promtest.ToFloat64(metrics.DBQueryErrors.WithLabelValues("query", "missing_table")); v < 1 {
+		t.Fatalf("expected query error to be counted, got %v", v)
+	}
+}
+
+func TestMetricsEndpoint_TokenProtected(t *testing.T) {
+	router := app.NewRouter(&fakeUserService{}, nil, &config.Config{MetricsToken: "scrape"})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "noteapp_http_requests_total") {
+		t.Fatalf("expected metrics exposition, got %d", rr.Code)
+	}
+}
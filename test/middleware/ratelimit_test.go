@@ -0,0 +1,66 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/middleware"
+	"github.com/MujiRahman/golang-simple-note/pkg/ratelimit"
+)
+
+func TestRateLimit_RejectsOverLimitWithHeaders(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	now := time.Unix(1_700_000_000, 0)
+	store.SetClock(func() time.Time { return now })
+
+	r := gin.New()
+	r.GET("/login", middleware.RateLimit(store, "login", ratelimit.PerMinute(2), middleware.ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := do(); rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rr.Code)
+		}
+	}
+	rr := do()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "30" || rr.Header().Get("RateLimit-Limit") != "2" || rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected headers: %v", rr.Header())
+	}
+
+	// one token is refilled after 30s
+	now = now.Add(30 * time.Second)
+	if rr := do(); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 after refill, got %d", rr.Code)
+	}
+}
+
+func TestMemoryStore_KeysAreIndependent(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.PerMinute(1)
+	if res, _ := store.Allow(context.Background(), "user:1", limit); !res.Allowed {
+		t.Fatalf("first request for user 1 should pass")
+	}
+	if res, _ := store.Allow(context.Background(), "user:1", limit); res.Allowed {
+		t.Fatalf("second request for user 1 should be limited")
+	}
+	if res, _ := store.Allow(context.Background(), "user:2", limit); !res.Allowed {
+		t.Fatalf("user 2 must not share user 1's bucket")
+	}
+}
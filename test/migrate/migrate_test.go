@@ -0,0 +1,215 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"github.com/MujiRahman/golang-simple-note/migrations"
+	"github.com/MujiRahman/golang-simple-note/pkg/migrate"
+	"github.com/MujiRahman/golang-simple-note/test/testutil"
+)
+
+func TestMigrator_UpDownGotoStatus(t *testing.T) {
+	gdb := testutil.NewSQLiteDB(t)
+	sqlDB, _ := gdb.DB()
+	ctx := context.Background()
+
+	m, err := migrate.New(sqlDB, migrate.SQLite, migrations.FS)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	total := len(m.Migrations())
+	if total == 0 {
+		t.Fatalf("expected embedded sqlite migrations")
+	}
+
+	n, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if n != total {
+		t.Fatalf("expected %d applied, got %d", total, n)
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending migrations, got %d (err=%v)", len(pending), err)
+	}
+
+	// schema must be usable by the repositories
+	users := repository.NewUserRepository(gdb)
+	if err := users.Create(context.Background(), &model.User{Name: "n", Username: "u", Password: "p", Email: "u@example.com"}); err != nil {
+		t.Fatalf("create user on migrated schema: %v", err)
+	}
+	notes := repository.NewNoteRepository(gdb)
+	if err := notes.Create(context.Background(), &model.Note{UserID: 1, Title: "t", Content: "c"}); err != nil {
+		t.Fatalf("create note on migrated schema: %v", err)
+	}
+
+	if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("down 1: n=%d err=%v", n, err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if statuses[len(statuses)-1].Applied {
+		t.Fatalf("expected last migration to be rolled back")
+	}
+
+	if err := m.Goto(ctx, 0); err != nil {
+		t.Fatalf("goto 0: %v", err)
+	}
+	if v, _ := m.Version(ctx); v != 0 {
+		t.Fatalf("expected version 0, got %d", v)
+	}
+	latest := m.Migrations()[total-1].Version
+	if err := m.Goto(ctx, latest); err != nil {
+		t.Fatalf("goto latest: %v", err)
+	}
+	if v, _ := m.Version(ctx); v != latest {
+		t.Fatalf("expected version %d, got %d", latest, v)
+	}
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	gdb := testutil.NewSQLiteDB(t)
+	sqlDB, _ := gdb.DB()
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"sqlite/000001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id integer);")},
+		"sqlite/000001_create_things.down.sql": {Data: []byte("DROP TABLE things;")},
+	}
+	m, _ := migrate.New(sqlDB, migrate.SQLite, fsys)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	fsys["sqlite/000001_create_things.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE things (id integer, name text);")}
+	m, _ = migrate.New(sqlDB, migrate.SQLite, fsys)
+	if err := m.Verify(ctx); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Fatalf("expected up to refuse on checksum mismatch, got %v", err)
+	}
+}
+
+// Existing notes move into a personal workspace created for their author.
+func TestMigrations_BackfillPersonalWorkspaces(t *testing.T) {
+	gdb := testutil.NewSQLiteDB(t)
+	sqlDB, _ := gdb.DB()
+	ctx := context.Background()
+
+	m, err := migrate.New(sqlDB, migrate.SQLite, migrations.FS)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	if err := m.Goto(ctx, 9); err != nil {
+		t.Fatalf("goto 9: %v", err)
+	}
+	for _, q := range []string{
+		"INSERT INTO users (id, name, username, email, password) VALUES (1, 'a', 'a', 'a@example.com', 'x'), (2, 'b', 'b', 'b@example.com', 'x')",
+		"INSERT INTO notes (user_id, title) VALUES (1, 'a1'), (1, 'a2'), (2, 'b1')",
+	} {
+		if err := gdb.Exec(q).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	var workspaces []model.Workspace
+	gdb.Order("id").Find(&workspaces)
+	if len(workspaces) != 2 || !workspaces[0].Personal || workspaces[0].CreatedBy != 1 {
+		t.Fatalf("expected a personal workspace per user, got %+v", workspaces)
+	}
+	var notes []model.Note
+	gdb.Order("id").Find(&notes)
+	for _, n := range notes {
+		if want := workspaces[n.UserID-1].ID; n.WorkspaceID != want {
+			t.Fatalf("note %q: expected workspace %d, got %d", n.Title, want, n.WorkspaceID)
+		}
+	}
+	var owners int64
+	gdb.Model(&model.WorkspaceMember{}).Where("role = ?", model.WorkspaceRoleOwner).Count(&owners)
+	if owners != 2 {
+		t.Fatalf("expected 2 owners, got %d", owners)
+	}
+}
+
+// A second personal workspace of an account is merged into the first, and
+// no more can be added.
+func TestMigrations_MergeDuplicatePersonalWorkspaces(t *testing.T) {
+	gdb := testutil.NewSQLiteDB(t)
+	sqlDB, _ := gdb.DB()
+	ctx := context.Background()
+
+	m, err := migrate.New(sqlDB, migrate.SQLite, migrations.FS)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	if err := m.Goto(ctx, 21); err != nil {
+		t.Fatalf("goto 21: %v", err)
+	}
+	for _, q := range []string{
+		"INSERT INTO users (id, name, username, email, password) VALUES (1, 'a', 'a', 'a@example.com', 'x')",
+		"INSERT INTO workspaces (id, name, personal, created_by) VALUES (1, 'Personal', 1, 1), (2, 'Personal', 1, 1), (3, 'Team', 0, 1)",
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (1, 1, 'owner'), (2, 1, 'owner'), (3, 1, 'owner')",
+		"INSERT INTO notes (user_id, workspace_id, title) VALUES (1, 1, 'kept'), (1, 2, 'moved'), (1, 3, 'team')",
+	} {
+		if err := gdb.Exec(q).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	var ids []uint
+	gdb.Model(&model.Workspace{}).Order("id").Pluck("id", &ids)
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Fatalf("expected workspaces 1 and 3 to remain, got %v", ids)
+	}
+	var notes []model.Note
+	gdb.Order("id").Find(&notes)
+	if len(notes) != 3 || notes[1].WorkspaceID != 1 || notes[2].WorkspaceID != 3 {
+		t.Fatalf("expected the note of the duplicate to move, got %+v", notes)
+	}
+	var members int64
+	gdb.Model(&model.WorkspaceMember{}).Count(&members)
+	if members != 2 {
+		t.Fatalf("expected the duplicate's membership to go, got %d members", members)
+	}
+	if err := gdb.Exec("INSERT INTO workspaces (name, personal, created_by) VALUES ('Personal', 1, 1)").Error; err == nil {
+		t.Fatal("expected a second personal workspace to be refused")
+	}
+	if err := gdb.Exec("INSERT INTO workspaces (name, personal, created_by) VALUES ('Another team', 0, 1)").Error; err != nil {
+		t.Fatalf("shared workspaces are not limited: %v", err)
+	}
+}
+
+func TestCreate_WritesPairPerDialect(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sqlite")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "000007_existing.up.sql"), []byte("SELECT 1;"), 0o644)
+
+	files, err := migrate.Create(root, []migrate.Dialect{migrate.MySQL, migrate.SQLite}, "Add Tags")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files, got %v", files)
+	}
+	if filepath.Base(files[0]) != "000008_add_tags.up.sql" {
+		t.Fatalf("unexpected file name %s", files[0])
+	}
+}
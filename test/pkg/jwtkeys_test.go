@@ -0,0 +1,173 @@
+package pkg_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+)
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+}
+
+func verify(keys *jwtkeys.KeySet, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, keys.Keyfunc)
+	return err
+}
+
+func TestJWTKeys_SignAndVerify(t *testing.T) {
+	for _, alg := range []string{jwtkeys.RS256, jwtkeys.EdDSA} {
+		k, err := jwtkeys.Generate("k1", alg)
+		if err != nil {
+			t.Fatalf("generate %s: %v", alg, err)
+		}
+		keys, _ := jwtkeys.NewKeySet(k)
+		token, err := keys.Sign(testClaims())
+		if err != nil {
+			t.Fatalf("sign %s: %v", alg, err)
+		}
+		tok, err := jwt.Parse(token, keys.Keyfunc)
+		if err != nil {
+			t.Fatalf("verify %s: %v", alg, err)
+		}
+		if tok.Header["kid"] != "k1" || tok.Header["alg"] != alg {
+			t.Fatalf("unexpected header %v", tok.Header)
+		}
+	}
+}
+
+func TestJWTKeys_RotationKeepsOldTokensValid(t *testing.T) {
+	old, _ := jwtkeys.Generate("2026-01", jwtkeys.EdDSA)
+	keys, _ := jwtkeys.NewKeySet(old)
+	oldToken, _ := keys.Sign(testClaims())
+
+	next, _ := jwtkeys.Generate("2026-02", jwtkeys.EdDSA)
+	if err := keys.Replace(next, old); err != nil {
+		t.Fatal(err)
+	}
+	if keys.ActiveID() != "2026-02" {
+		t.Fatalf("active key = %s", keys.ActiveID())
+	}
+	if err := verify(keys, oldToken); err != nil {
+		t.Fatalf("token of the retired key should still verify: %v", err)
+	}
+
+	// once the old key is dropped its tokens are rejected
+	keys.Replace(next)
+	if err := verify(keys, oldToken); err == nil {
+		t.Fatal("token of a removed key should be rejected")
+	}
+}
+
+func TestJWTKeys_RejectsForeignAndConfusedTokens(t *testing.T) {
+	k, _ := jwtkeys.Generate("rsa", jwtkeys.RS256)
+	keys, _ := jwtkeys.NewKeySet(k)
+
+	// signed by a key with the same id that is not in the set
+	other, _ := jwtkeys.Generate("rsa", jwtkeys.RS256)
+	otherKeys, _ := jwtkeys.NewKeySet(other)
+	forged, _ := otherKeys.Sign(testClaims())
+	if err := verify(keys, forged); err == nil {
+		t.Fatal("token of a foreign key should be rejected")
+	}
+
+	// HS256 with the kid of the RSA key must not use the public key as secret
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	hs.Header["kid"] = "rsa"
+	confused, _ := hs.SignedString([]byte("whatever"))
+	if err := verify(keys, confused); err == nil {
+		t.Fatal("algorithm confusion should be rejected")
+	}
+
+	// no kid at all
+	plain := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	noKid, _ := plain.SignedString([]byte("secret"))
+	hmac, _ := jwtkeys.NewKeySet(jwtkeys.NewHMACKey([]byte("secret")))
+	if err := verify(hmac, noKid); err == nil {
+		t.Fatal("token without kid should be rejected")
+	}
+}
+
+func TestJWTKeys_HMACPreviousSecret(t *testing.T) {
+	old := jwtkeys.NewHMACKey([]byte("old-secret"))
+	keys, _ := jwtkeys.NewKeySet(old)
+	token, _ := keys.Sign(testClaims())
+
+	rotated, _ := jwtkeys.NewKeySet(jwtkeys.NewHMACKey([]byte("new-secret")), jwtkeys.NewHMACKey([]byte("old-secret")))
+	if err := verify(rotated, token); err != nil {
+		t.Fatalf("previous secret should still verify: %v", err)
+	}
+	if len(rotated.JWKS().Keys) != 0 {
+		t.Fatal("HMAC secrets must never be published")
+	}
+}
+
+func TestJWTKeys_PEMAndJWKS(t *testing.T) {
+	rsaKey, _ := jwtkeys.Generate("b-rsa", jwtkeys.RS256)
+	pemData, err := jwtkeys.MarshalPEM(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jwtkeys.ParsePEM("b-rsa", pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := jwtkeys.NewKeySet(rsaKey)
+	token, _ := keys.Sign(testClaims())
+	reloaded, _ := jwtkeys.NewKeySet(parsed)
+	if err := verify(reloaded, token); err != nil {
+		t.Fatalf("key read back from PEM should verify: %v", err)
+	}
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	verifyOnly, err := jwtkeys.NewPublicKey("a-ed", pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifyOnly.CanSign() {
+		t.Fatal("public key should not sign")
+	}
+	if _, err := jwtkeys.NewKeySet(verifyOnly); err == nil {
+		t.Fatal("a verify-only key cannot be the active key")
+	}
+
+	both, _ := jwtkeys.NewKeySet(rsaKey, verifyOnly, jwtkeys.NewHMACKey([]byte("s")))
+	set := both.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %+v", set.Keys)
+	}
+	ed, rs := set.Keys[0], set.Keys[1]
+	if ed.Kid != "a-ed" || ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || ed.X == "" {
+		t.Fatalf("unexpected Ed25519 JWK %+v", ed)
+	}
+	if rs.Kid != "b-rsa" || rs.Kty != "RSA" || rs.Alg != "RS256" || rs.E != "AQAB" || rs.N == "" || rs.Use != "sig" {
+		t.Fatalf("unexpected RSA JWK %+v", rs)
+	}
+}
+
+func TestJWTKeys_JWKRoundTrip(t *testing.T) {
+	for _, alg := range []string{jwtkeys.RS256, jwtkeys.EdDSA} {
+		k, _ := jwtkeys.Generate("rt", alg)
+		keys, _ := jwtkeys.NewKeySet(k)
+		token, _ := keys.Sign(testClaims())
+
+		pub, err := keys.JWKS().Keys[0].Key()
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if pub.CanSign() {
+			t.Fatalf("%s: a key from a JWK must be verify-only", alg)
+		}
+		if _, err := jwt.Parse(token, pub.Keyfunc); err != nil {
+			t.Fatalf("%s: key parsed from the JWKS should verify: %v", alg, err)
+		}
+	}
+	if _, err := (jwtkeys.JWK{Kty: "EC", Kid: "ec"}).Key(); err == nil {
+		t.Fatal("expected unsupported key types to be rejected")
+	}
+}
@@ -0,0 +1,119 @@
+package pkg_test
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/mailer"
+)
+
+// smtpSink is a minimal SMTP server that records the envelope and data of
+// every message it receives.
+type smtpSink struct {
+	ln       net.Listener
+	received chan string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{ln: ln, received: make(chan string, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 sink ready")
+	var env strings.Builder
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			tp.PrintfLine("250 sink")
+		case strings.HasPrefix(cmd, "MAIL FROM:"), strings.HasPrefix(cmd, "RCPT TO:"):
+			env.WriteString(line + "\n")
+			tp.PrintfLine("250 ok")
+		case cmd == "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.received <- env.String() + string(data)
+			tp.PrintfLine("250 queued")
+		case cmd == "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	sink := newSMTPSink(t)
+	addr := sink.ln.Addr().(*net.TCPAddr)
+	m := mailer.NewSMTPMailer(mailer.SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "no-reply@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := m.Send(ctx, mailer.Message{To: "alice@example.com", Subject: "Héllo", Body: "line one\n.dot line\n"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	got := <-sink.received
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"To: alice@example.com",
+		"Subject: =?utf-8?q?H=C3=A9llo?=",
+		"Content-Type: text/plain; charset=UTF-8",
+		".dot line",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestSMTPMailer_RejectsHeaderInjection(t *testing.T) {
+	m := mailer.NewSMTPMailer(mailer.SMTPConfig{Host: "127.0.0.1", Port: 1, From: "a@example.com"})
+	if err := m.Send(context.Background(), mailer.Message{To: "a@example.com\r\nBcc: b@example.com"}); err == nil {
+		t.Fatal("expected an error for a recipient containing a newline")
+	}
+}
+
+func TestMemoryMailer_RecordsMessages(t *testing.T) {
+	m := mailer.NewMemoryMailer()
+	m.Send(context.Background(), mailer.Message{To: "a@example.com", Subject: "one"})
+	m.Send(context.Background(), mailer.Message{To: "b@example.com", Subject: "two"})
+	msgs := m.Messages()
+	if len(msgs) != 2 || msgs[1].Subject != "two" {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+}
@@ -0,0 +1,26 @@
+package pkg_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/mention"
+)
+
+func TestMention_Usernames(t *testing.T) {
+	cases := map[string][]string{
+		"":                                    nil,
+		"no mentions here":                    nil,
+		"@alice":                              {"alice"},
+		"hi @alice and @bob.smith, ping @bob": {"alice", "bob.smith", "bob"},
+		"thanks @carol.":                      {"carol"},
+		"(@dave) @dave @erin_1-x":             {"dave", "erin_1-x"},
+		"mail alice@example.com or @@frank":   nil,
+		"line\n@grace":                        {"grace"},
+	}
+	for text, want := range cases {
+		if got := mention.Usernames(text); !slices.Equal(got, want) {
+			t.Errorf("Usernames(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
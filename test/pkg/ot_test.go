@@ -0,0 +1,398 @@
+package pkg_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/ot"
+)
+
+var otAlphabet = []rune("ab cé世🙂\n")
+
+func randomText(r *rand.Rand, max int) string {
+	n := r.Intn(max + 1)
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = otAlphabet[r.Intn(len(otAlphabet))]
+	}
+	return string(out)
+}
+
+// randomOperation returns a random edit of doc.
+func randomOperation(r *rand.Rand, doc string) *ot.Operation {
+	op := ot.New()
+	left := len([]rune(doc))
+	for left > 0 {
+		n := 1 + r.Intn(min(left, 5))
+		switch r.Intn(4) {
+		case 0:
+			op.Insert(randomText(r, 4))
+		case 1:
+			op.Delete(n)
+			left -= n
+		default:
+			op.Retain(n)
+			left -= n
+		}
+	}
+	if r.Intn(3) == 0 {
+		op.Insert(randomText(r, 4))
+	}
+	return op
+}
+
+func mustApply(t *testing.T, op *ot.Operation, doc string) string {
+	t.Helper()
+	out, err := op.Apply(doc)
+	if err != nil {
+		t.Fatalf("apply %s to %q: %v", op, doc, err)
+	}
+	return out
+}
+
+func TestOT_BuilderNormalizes(t *testing.T) {
+	cases := []struct {
+		op   *ot.Operation
+		want string
+	}{
+		{ot.New(), `[]`},
+		{ot.New().Retain(2).Retain(3), `[5]`},
+		{ot.New().Retain(0).Insert("").Delete(0), `[]`},
+		{ot.New().Insert("a").Insert("b").Delete(1).Delete(2), `["ab",-3]`},
+		{ot.New().Delete(1).Insert("x"), `["x",-1]`},
+		{ot.New().Insert("a").Delete(1).Insert("b"), `["ab",-1]`},
+		{ot.New().Retain(1).Delete(2).Insert("é").Retain(1), `[1,"é",-2,1]`},
+	}
+	for _, c := range cases {
+		if got := c.op.String(); got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+	op := ot.New().Retain(2).Insert("世界").Delete(3)
+	if op.BaseLen() != 5 || op.TargetLen() != 4 {
+		t.Fatalf("lengths %d -> %d, want 5 -> 4", op.BaseLen(), op.TargetLen())
+	}
+	if op.IsNoop() || !ot.New().Retain(3).IsNoop() {
+		t.Fatalf("IsNoop is wrong")
+	}
+}
+
+func TestOT_Apply(t *testing.T) {
+	cases := []struct {
+		doc  string
+		op   *ot.Operation
+		want string
+	}{
+		{"", ot.New().Insert("hi"), "hi"},
+		{"hello", ot.New().Retain(5).Insert(" world"), "hello world"},
+		{"hello", ot.New().Delete(1).Insert("J").Retain(4), "Jello"},
+		{"a🙂b", ot.New().Retain(1).Delete(1).Insert("-").Retain(1), "a-b"},
+		{"世界", ot.New().Retain(1).Insert("の").Retain(1), "世の界"},
+	}
+	for _, c := range cases {
+		if got := mustApply(t, c.op, c.doc); got != c.want {
+			t.Errorf("%s on %q = %q, want %q", c.op, c.doc, got, c.want)
+		}
+	}
+	if _, err := ot.New().Retain(3).Apply("ab"); !errors.Is(err, ot.ErrBaseLength) {
+		t.Fatalf("expected ErrBaseLength, got %v", err)
+	}
+}
+
+func TestOT_JSON(t *testing.T) {
+	var op ot.Operation
+	if err := json.Unmarshal([]byte(`[ 3, "x" , -2, 1]`), &op); err != nil {
+		t.Fatal(err)
+	}
+	if op.String() != `[3,"x",-2,1]` || op.BaseLen() != 6 || op.TargetLen() != 5 {
+		t.Fatalf("decoded %s (%d -> %d)", op.String(), op.BaseLen(), op.TargetLen())
+	}
+	// decoding normalizes
+	if err := json.Unmarshal([]byte(`[1, -1, "a", 2, 3]`), &op); err != nil || op.String() != `[1,"a",-1,5]` {
+		t.Fatalf("decoded %s, %v", op.String(), err)
+	}
+	for _, bad := range []string{`{}`, `[0]`, `[""]`, `[1.5]`, `[true]`, `[null]`, `"x"`} {
+		if err := json.Unmarshal([]byte(bad), &op); !errors.Is(err, ot.ErrInvalidOperation) {
+			t.Errorf("%s: expected ErrInvalidOperation, got %v", bad, err)
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for range 200 {
+		doc := randomText(r, 20)
+		want := randomOperation(r, doc)
+		b, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got ot.Operation
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if got.String() != want.String() || mustApply(t, &got, doc) != mustApply(t, want, doc) {
+			t.Fatalf("round trip of %s gave %s", want, got.String())
+		}
+	}
+}
+
+func TestOT_JSONRefusesHugeOperations(t *testing.T) {
+	// the counts add up to 5 once they overflow
+	huge := `[4611686018427387904,-4611686018427387904,4611686018427387904,-4611686018427387904,5]`
+	for _, bad := range []string{huge, fmt.Sprintf(`[%d]`, ot.MaxLength+1), fmt.Sprintf(`[%d,%d]`, ot.MaxLength, -1), fmt.Sprintf(`[%d,"x"]`, ot.MaxLength)} {
+		var op ot.Operation
+		if err := json.Unmarshal([]byte(bad), &op); !errors.Is(err, ot.ErrInvalidOperation) {
+			t.Errorf("%.40s: expected ErrInvalidOperation, got %v", bad, err)
+		}
+	}
+
+	if _, err := ot.New().Retain(6).Apply("hello"); !errors.Is(err, ot.ErrBaseLength) {
+		t.Fatalf("expected ErrBaseLength, got %v", err)
+	}
+}
+
+func TestOT_Compose(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for range 1000 {
+		doc := randomText(r, 20)
+		a := randomOperation(r, doc)
+		afterA := mustApply(t, a, doc)
+		b := randomOperation(r, afterA)
+		ab, err := ot.Compose(a, b)
+		if err != nil {
+			t.Fatalf("compose %s, %s: %v", a, b, err)
+		}
+		if got, want := mustApply(t, ab, doc), mustApply(t, b, afterA); got != want {
+			t.Fatalf("compose %s, %s = %s: %q, want %q", a, b, ab, got, want)
+		}
+	}
+	if _, err := ot.Compose(ot.New().Retain(1), ot.New().Retain(2)); !errors.Is(err, ot.ErrBaseLength) {
+		t.Fatalf("expected ErrBaseLength, got %v", err)
+	}
+}
+
+func TestOT_TransformConverges(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for range 1000 {
+		doc := randomText(r, 20)
+		a, b := randomOperation(r, doc), randomOperation(r, doc)
+		aPrime, bPrime, err := ot.Transform(a, b)
+		if err != nil {
+			t.Fatalf("transform %s, %s: %v", a, b, err)
+		}
+		viaA := mustApply(t, bPrime, mustApply(t, a, doc))
+		viaB := mustApply(t, aPrime, mustApply(t, b, doc))
+		if viaA != viaB {
+			t.Fatalf("%q: %s, %s diverge: %q vs %q", doc, a, b, viaA, viaB)
+		}
+	}
+	if _, _, err := ot.Transform(ot.New().Retain(1), ot.New().Retain(2)); !errors.Is(err, ot.ErrBaseLength) {
+		t.Fatalf("expected ErrBaseLength, got %v", err)
+	}
+}
+
+func TestOT_TransformTies(t *testing.T) {
+	a := ot.New().Retain(1).Insert("A").Retain(1)
+	b := ot.New().Retain(1).Insert("B").Retain(1)
+	aPrime, bPrime, err := ot.Transform(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mustApply(t, bPrime, mustApply(t, a, "xy")); got != "xABy" {
+		t.Fatalf("expected a's insert first, got %q", got)
+	}
+	if got := mustApply(t, aPrime, mustApply(t, b, "xy")); got != "xABy" {
+		t.Fatalf("expected a's insert first, got %q", got)
+	}
+
+	// both delete overlapping ranges
+	a = ot.New().Delete(3).Retain(2)
+	b = ot.New().Retain(1).Delete(3).Retain(1)
+	aPrime, bPrime, _ = ot.Transform(a, b)
+	if got := mustApply(t, bPrime, mustApply(t, a, "abcde")); got != "e" {
+		t.Fatalf("got %q, want %q", got, "e")
+	}
+	if got := mustApply(t, aPrime, mustApply(t, b, "abcde")); got != "e" {
+		t.Fatalf("got %q, want %q", got, "e")
+	}
+}
+
+func TestOT_TransformIndex(t *testing.T) {
+	// "hello world" -> "hey world!"
+	op := ot.New().Retain(2).Insert("y").Delete(3).Retain(6).Insert("!")
+	cases := map[int]int{0: 0, 2: 3, 3: 3, 5: 3, 6: 4, 11: 10}
+	for index, want := range cases {
+		if got := ot.TransformIndex(index, op); got != want {
+			t.Errorf("TransformIndex(%d) = %d, want %d", index, got, want)
+		}
+	}
+}
+
+func TestOT_Diff(t *testing.T) {
+	cases := map[[2]string]string{
+		{"", ""}:                   `[]`,
+		{"abc", "abc"}:             `[3]`,
+		{"", "new"}:                `["new"]`,
+		{"old", ""}:                `[-3]`,
+		{"hello world", "hey you"}: `[2,"y you",-9]`,
+		{"a🙂b", "a😀b"}:             `[1,"😀",-1,1]`,
+		{"aaa", "aaaa"}:            `[3,"a"]`,
+	}
+	for c, want := range cases {
+		op := ot.Diff(c[0], c[1])
+		if op.String() != want {
+			t.Errorf("Diff(%q, %q) = %s, want %s", c[0], c[1], op, want)
+		}
+		if got := mustApply(t, op, c[0]); got != c[1] {
+			t.Errorf("Diff(%q, %q) applies to %q", c[0], c[1], got)
+		}
+	}
+	r := rand.New(rand.NewSource(4))
+	for range 500 {
+		a, b := randomText(r, 15), randomText(r, 15)
+		if got := mustApply(t, ot.Diff(a, b), a); got != b {
+			t.Fatalf("Diff(%q, %q) applies to %q", a, b, got)
+		}
+	}
+}
+
+func TestOT_DocumentHistory(t *testing.T) {
+	doc := ot.NewDocument("abc", 2)
+	for range 3 {
+		if _, err := doc.Receive(doc.Revision(), ot.New().Retain(len([]rune(doc.Text()))).Insert("!")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if doc.Text() != "abc!!!" || doc.Revision() != 3 {
+		t.Fatalf("got %q at %d", doc.Text(), doc.Revision())
+	}
+	if _, err := doc.Receive(0, ot.New().Retain(3)); !errors.Is(err, ot.ErrRevisionTooOld) {
+		t.Fatalf("expected ErrRevisionTooOld, got %v", err)
+	}
+	if _, err := doc.Receive(4, ot.New().Retain(6)); !errors.Is(err, ot.ErrUnknownRevision) {
+		t.Fatalf("expected ErrUnknownRevision, got %v", err)
+	}
+	if _, err := doc.Receive(3, ot.New().Retain(2)); !errors.Is(err, ot.ErrBaseLength) {
+		t.Fatalf("expected ErrBaseLength, got %v", err)
+	}
+
+	// an edit based on revision 1 lands where it was meant to
+	op, err := doc.Receive(1, ot.New().Insert("~").Retain(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Text() != "~abc!!!" || op.String() != `["~",6]` {
+		t.Fatalf("got %q via %s", doc.Text(), op)
+	}
+	if i, err := doc.TransformIndex(2, 5); err != nil || i != 7 {
+		t.Fatalf("TransformIndex = %d, %v; want 7", i, err)
+	}
+}
+
+func TestOT_ClientAckWithoutPending(t *testing.T) {
+	if _, err := ot.NewClient(0).Ack(); !errors.Is(err, ot.ErrUnexpectedAck) {
+		t.Fatalf("expected ErrUnexpectedAck, got %v", err)
+	}
+}
+
+// simulated editor and server, connected by in-order message queues
+type simMessage struct {
+	revision int
+	op       *ot.Operation // nil for an acknowledgement
+}
+
+type simClient struct {
+	client  *ot.Client
+	text    string
+	inbox   []simMessage // from the server
+	outbox  []simMessage // to the server
+	pending bool
+}
+
+func TestOT_EditorsConverge(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		initial := randomText(r, 10)
+		server := ot.NewDocument(initial, 0)
+		clients := make([]*simClient, 2+r.Intn(3))
+		for i := range clients {
+			clients[i] = &simClient{client: ot.NewClient(0), text: initial}
+		}
+
+		step := func(edits bool) bool {
+			var moves []func()
+			for _, c := range clients {
+				if edits {
+					moves = append(moves, func() {
+						op := randomOperation(r, c.text)
+						c.text = mustApply(t, op, c.text)
+						send, err := c.client.ApplyLocal(op)
+						if err != nil {
+							t.Fatalf("seed %d: ApplyLocal: %v", seed, err)
+						}
+						if send != nil {
+							c.outbox = append(c.outbox, simMessage{c.client.Revision, send})
+						}
+					})
+				}
+				if len(c.outbox) > 0 {
+					moves = append(moves, func() {
+						msg := c.outbox[0]
+						c.outbox = c.outbox[1:]
+						op, err := server.Receive(msg.revision, msg.op)
+						if err != nil {
+							t.Fatalf("seed %d: Receive: %v", seed, err)
+						}
+						for _, other := range clients {
+							if other == c {
+								other.inbox = append(other.inbox, simMessage{server.Revision(), nil})
+							} else {
+								other.inbox = append(other.inbox, simMessage{server.Revision(), op})
+							}
+						}
+					})
+				}
+				if len(c.inbox) > 0 {
+					moves = append(moves, func() {
+						msg := c.inbox[0]
+						c.inbox = c.inbox[1:]
+						if msg.op == nil {
+							send, err := c.client.Ack()
+							if err != nil {
+								t.Fatalf("seed %d: Ack: %v", seed, err)
+							}
+							if send != nil {
+								c.outbox = append(c.outbox, simMessage{c.client.Revision, send})
+							}
+							return
+						}
+						op, err := c.client.ApplyRemote(msg.op)
+						if err != nil {
+							t.Fatalf("seed %d: ApplyRemote: %v", seed, err)
+						}
+						c.text = mustApply(t, op, c.text)
+					})
+				}
+			}
+			if len(moves) == 0 {
+				return false
+			}
+			moves[r.Intn(len(moves))]()
+			return true
+		}
+
+		for range 60 {
+			step(true)
+		}
+		for step(false) {
+		}
+
+		for i, c := range clients {
+			if c.text != server.Text() || c.client.Revision != server.Revision() || c.client.Pending() {
+				t.Fatalf("seed %d: client %d has %q at %d, server %q at %d", seed, i, c.text, c.client.Revision, server.Text(), server.Revision())
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package pkg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/password"
+)
+
+var cheapArgon2 = password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestPassword_HashAndVerify(t *testing.T) {
+	for _, h := range []password.Hasher{password.NewBcrypt(4), password.NewArgon2id(cheapArgon2)} {
+		encoded, err := h.Hash("s3cret pass")
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		if ok, err := h.Verify(encoded, "s3cret pass"); !ok || err != nil {
+			t.Fatalf("verify %q: ok=%v err=%v", encoded, ok, err)
+		}
+		if ok, err := h.Verify(encoded, "wrong"); ok || err != nil {
+			t.Fatalf("wrong password accepted for %q: ok=%v err=%v", encoded, ok, err)
+		}
+		if h.NeedsRehash(encoded) {
+			t.Fatalf("fresh hash %q should not need a rehash", encoded)
+		}
+	}
+}
+
+func TestPassword_VerifyAcrossAlgorithms(t *testing.T) {
+	legacy, _ := password.NewBcrypt(4).Hash("pw")
+	h := password.NewArgon2id(cheapArgon2)
+	if ok, err := h.Verify(legacy, "pw"); !ok || err != nil {
+		t.Fatalf("argon2id hasher should verify bcrypt hashes: ok=%v err=%v", ok, err)
+	}
+	if !h.NeedsRehash(legacy) {
+		t.Fatal("bcrypt hash should need a rehash to argon2id")
+	}
+	if _, err := h.Verify("plaintext", "pw"); err != password.ErrUnknownHash {
+		t.Fatalf("expected ErrUnknownHash, got %v", err)
+	}
+}
+
+func TestPassword_NeedsRehashOnParameterChange(t *testing.T) {
+	old, _ := password.NewArgon2id(cheapArgon2).Hash("pw")
+	if !strings.HasPrefix(old, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected encoding %q", old)
+	}
+	stronger := password.NewArgon2id(password.Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1})
+	if !stronger.NeedsRehash(old) {
+		t.Fatal("hash with less memory should need a rehash")
+	}
+	if ok, _ := stronger.Verify(old, "pw"); !ok {
+		t.Fatal("old parameters must still verify")
+	}
+
+	b4, _ := password.NewBcrypt(4).Hash("pw")
+	if !password.NewBcrypt(5).NeedsRehash(b4) {
+		t.Fatal("bcrypt hash with a lower cost should need a rehash")
+	}
+}
+
+func TestPassword_New(t *testing.T) {
+	if _, err := password.New("md5", 0, password.Argon2Params{}); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+	h, err := password.New(password.Bcrypt, 4, password.Argon2Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := h.Hash("pw")
+	if !strings.HasPrefix(encoded, "$2") {
+		t.Fatalf("expected a bcrypt hash, got %q", encoded)
+	}
+}
+
+func TestPassword_Policy(t *testing.T) {
+	p := password.NewPolicy(8, password.CommonPasswords())
+	cases := map[string]bool{
+		"":                       false,
+		"short":                  false,
+		"12345678":               false,
+		"PassWord123":            false, // common, case-insensitively
+		strings.Repeat("a", 129): false,
+		"correct horse battery":  true,
+		strings.Repeat("x", 128): true,
+		"ünïcödé-pässwörd":       true,
+	}
+	for pw, valid := range cases {
+		if err := p.Check(pw); (err == nil) != valid {
+			t.Errorf("Check(%q) = %v, want valid=%v", pw, err, valid)
+		}
+	}
+	if err := password.NewPolicy(0, nil).Check("pw"); err != nil {
+		t.Fatalf("an empty policy should only require a password: %v", err)
+	}
+
+	// 40 runes but 80 bytes: too long for bcrypt
+	p.MaxBytes = password.BcryptMaxBytes
+	if err := p.Check(strings.Repeat("ü", 40)); err == nil {
+		t.Fatal("expected a password over MaxBytes to be refused")
+	}
+	if err := p.Check(strings.Repeat("ü", 36)); err != nil {
+		t.Fatalf("expected a password of MaxBytes to be accepted: %v", err)
+	}
+}
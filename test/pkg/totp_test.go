@@ -0,0 +1,58 @@
+package pkg_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/totp"
+)
+
+// RFC 6238 appendix B test vectors (SHA1, secret "12345678901234567890"), truncated to 6 digits.
+func TestTOTP_RFC6238Vectors(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for ts, want := range cases {
+		got, err := totp.Code(secret, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		if got != want {
+			t.Fatalf("t=%d: got %s want %s", ts, got, want)
+		}
+	}
+}
+
+func TestTOTP_ValidateWithSkew(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("secret: %v", err)
+	}
+	now := time.Now()
+	prev, _ := totp.Code(secret, now.Add(-totp.Period))
+	if !totp.Validate(secret, prev, now, 1) {
+		t.Fatalf("expected previous step to be accepted with skew 1")
+	}
+	old, _ := totp.Code(secret, now.Add(-3*totp.Period))
+	if totp.Validate(secret, old, now, 1) {
+		t.Fatalf("expected code three steps old to be rejected")
+	}
+}
+
+func TestTOTP_MatchReturnsTheStep(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	now := time.Unix(1_700_000_000, 0)
+	step := now.Unix() / int64(totp.Period.Seconds())
+	prev, _ := totp.Code(secret, now.Add(-totp.Period))
+	if got, ok := totp.Match(secret, prev, now, 1); !ok || got != step-1 {
+		t.Fatalf("expected step %d, got %d (ok=%v)", step-1, got, ok)
+	}
+	if _, ok := totp.Match(secret, "12345", now, 1); ok {
+		t.Fatalf("expected a short code to be rejected")
+	}
+}
@@ -0,0 +1,35 @@
+package pkg_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/webhook"
+)
+
+func TestWebhook_SignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"note.created"}`)
+	sent := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(sent.Unix(), 10)
+	sig := webhook.Sign("secret", sent, body)
+
+	if err := webhook.Verify("secret", ts, sig, body, sent.Add(time.Minute), 0); err != nil {
+		t.Fatalf("expected a valid signature, got %v", err)
+	}
+	if err := webhook.Verify("other", ts, sig, body, sent, 0); !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Fatalf("expected a wrong secret to fail, got %v", err)
+	}
+	if err := webhook.Verify("secret", ts, sig, []byte(`{"event":"note.deleted"}`), sent, 0); !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Fatalf("expected a tampered body to fail, got %v", err)
+	}
+	// the timestamp is signed too, so it cannot be refreshed for a replay
+	later := strconv.FormatInt(sent.Add(time.Hour).Unix(), 10)
+	if err := webhook.Verify("secret", later, sig, body, sent.Add(time.Hour), 0); !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Fatalf("expected a changed timestamp to fail, got %v", err)
+	}
+	if err := webhook.Verify("secret", ts, sig, body, sent.Add(time.Hour), 0); !errors.Is(err, webhook.ErrExpired) {
+		t.Fatalf("expected an old request to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MujiRahman/golang-simple-note/config"
+	"github.com/MujiRahman/golang-simple-note/internal/app"
+	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
+	"gorm.io/gorm"
+)
+
+// driverConfigs returns the databases the repository suite runs against:
+// sqlite always (in-process), postgres and mysql only when a DSN is provided
+// through TEST_POSTGRES_URL / TEST_MYSQL_DSN.
+func driverConfigs(t *testing.T) map[string]*config.Config {
+	cfgs := map[string]*config.Config{
+		"sqlite": {DBDriver: "sqlite", DBName: filepath.Join(t.TempDir(), "repo.db")},
+	}
+	if dsn := os.Getenv("TEST_POSTGRES_URL"); dsn != "" {
+		cfgs["postgres"] = &config.Config{DBDriver: "postgres", DatabaseURL: dsn}
+	}
+	if dsn := os.Getenv("TEST_MYSQL_DSN"); dsn != "" {
+		cfgs["mysql"] = &config.Config{DBDriver: "mysql", DatabaseURL: dsn}
+	}
+	return cfgs
+}
+
+func openMigrated(t *testing.T, cfg *config.Config) *gorm.DB {
+	t.Helper()
+	conn, err := app.OpenDB(cfg)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	m, err := conn.Migrator()
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := m.Goto(ctx, 0); err != nil {
+			t.Errorf("migrate down: %v", err)
+		}
+		sqlDB, _ := conn.DB.DB()
+		sqlDB.Close()
+	})
+	return conn.DB
+}
+
+func TestRepositories_AllDrivers(t *testing.T) {
+	for name, cfg := range driverConfigs(t) {
+		t.Run(name, func(t *testing.T) {
+			gdb := openMigrated(t, cfg)
+			users := repository.NewUserRepository(gdb)
+			notes := repository.NewNoteRepository(gdb)
+
+			u := &model.User{Name: "Driver", Username: "driver", Password: "hashed", Email: "driver@example.com"}
+			if err := users.Create(context.Background(), u); err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			got, err := users.FindByUsername(context.Background(), "driver")
+			if err != nil || got == nil || got.ID != u.ID {
+				t.Fatalf("FindByUsername: got %+v err %v", got, err)
+			}
+			missing, err := users.FindByID(context.Background(), u.ID+100)
+			if err != nil || missing != nil {
+				t.Fatalf("expected nil for missing user, got %+v err %v", missing, err)
+			}
+
+			n := &model.Note{UserID: u.ID, Title: "T", Content: "C"}
+			if err := notes.Create(context.Background(), n); err != nil {
+				t.Fatalf("create note: %v", err)
+			}
+			n.Title = "T2"
+			if err := notes.Update(context.Background(), n); err != nil {
+				t.Fatalf("update note: %v", err)
+			}
+			list, err := notes.FindByUser(context.Background(), u.ID)
+			if err != nil || len(list) != 1 || list[0].Title != "T2" {
+				t.Fatalf("FindByUser: got %+v err %v", list, err)
+			}
+			if err := notes.Delete(context.Background(), n.ID, 1); err != nil {
+				t.Fatalf("delete note: %v", err)
+			}
+			gone, err := notes.FindByID(context.Background(), n.ID)
+			if err != nil || gone != nil {
+				t.Fatalf("expected note to be deleted, got %+v err %v", gone, err)
+			}
+
+			testFailedLogins(t, users, u.ID)
+			testSecondFactorUse(t, users, u.ID)
+			testNoteSync(t, gdb, u.ID)
+			testPersonalWorkspace(t, gdb, u.ID)
+		})
+	}
+}
+
+// testFailedLogins checks that failed logins are counted in the database,
+// whatever the caller last read, and that a lock only applies to the count
+// it was computed from.
+func testFailedLogins(t *testing.T, users repository.UserRepository, userID uint) {
+	ctx := context.Background()
+	for want := 1; want <= 2; want++ {
+		if n, err := users.RecordFailedLogin(ctx, userID); err != nil || n != want {
+			t.Fatalf("RecordFailedLogin: got %d err %v, want %d", n, err, want)
+		}
+	}
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := users.LockUntil(ctx, userID, 1, until); err != nil {
+		t.Fatalf("LockUntil: %v", err)
+	}
+	if u, _ := users.FindByID(ctx, userID); u.FailedLogins != 2 || u.LockedUntil != nil {
+		t.Fatalf("expected no lock from a stale count, got %+v", u)
+	}
+	if err := users.LockUntil(ctx, userID, 2, until); err != nil {
+		t.Fatalf("LockUntil: %v", err)
+	}
+	if u, _ := users.FindByID(ctx, userID); u.LockedUntil == nil || !u.LockedUntil.Equal(until) {
+		t.Fatalf("expected a lock until %v, got %+v", until, u.LockedUntil)
+	}
+}
+
+// testSecondFactorUse checks that TOTP steps and recovery codes are only
+// spent once, and that saving a user read earlier does not bring them back.
+func testSecondFactorUse(t *testing.T, users repository.UserRepository, userID uint) {
+	ctx := context.Background()
+	stale, _ := users.FindByID(ctx, userID)
+	if ok, err := users.ReplaceRecoveryCodes(ctx, userID, "", "a b"); err != nil || !ok {
+		t.Fatalf("set recovery codes: %v %v", ok, err)
+	}
+	if ok, err := users.ReplaceRecoveryCodes(ctx, userID, "a b", "b"); err != nil || !ok {
+		t.Fatalf("spend recovery code: %v %v", ok, err)
+	}
+	if ok, err := users.ReplaceRecoveryCodes(ctx, userID, "a b", "b"); err != nil || ok {
+		t.Fatalf("expected the code to be spent once, got %v %v", ok, err)
+	}
+	for _, c := range []struct {
+		step int64
+		want bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		if ok, err := users.ClaimTOTPStep(ctx, userID, c.step); err != nil || ok != c.want {
+			t.Fatalf("ClaimTOTPStep(%d): got %v %v, want %v", c.step, ok, err, c.want)
+		}
+	}
+	stale.Name = "Renamed"
+	if err := users.Update(ctx, stale); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	if u, _ := users.FindByID(ctx, userID); u.Name != "Renamed" || u.RecoveryCodes != "b" || u.TOTPLastStep != 101 {
+		t.Fatalf("expected Update to keep the second factor state, got %+v", u)
+	}
+}
+
+// testNoteSync checks the change sequence, the sync query and the purge of
+// tombstones, which use SQL of their own.
+func testNoteSync(t *testing.T, gdb *gorm.DB, userID uint) {
+	ctx := context.Background()
+	notes := repository.NewNoteRepository(gdb)
+	ws := &model.Workspace{Name: "Sync", CreatedBy: userID}
+	if err := repository.NewWorkspaceRepository(gdb).CreateWithOwner(ctx, ws, userID); err != nil {
+		t.Fatalf("create workspace: %v", err)
+	}
+	var created []*model.Note
+	for _, title := range []string{"a", "b"} {
+		seq, err := notes.NextChangeSeq(ctx, ws.ID)
+		if err != nil {
+			t.Fatalf("NextChangeSeq: %v", err)
+		}
+		n := &model.Note{UserID: userID, WorkspaceID: ws.ID, Title: title, ChangeSeq: seq}
+		if err := notes.Create(ctx, n); err != nil {
+			t.Fatalf("create note: %v", err)
+		}
+		created = append(created, n)
+	}
+	if created[0].ChangeSeq != 1 || created[1].ChangeSeq != 2 {
+		t.Fatalf("unexpected change sequence: %d, %d", created[0].ChangeSeq, created[1].ChangeSeq)
+	}
+	if err := notes.Delete(ctx, created[0].ID, 3); err != nil {
+		t.Fatalf("delete note: %v", err)
+	}
+	changes, err := notes.Changes(ctx, ws.ID, 1, 10)
+	if err != nil || len(changes) != 2 || changes[0].ID != created[1].ID || changes[1].ID != created[0].ID {
+		t.Fatalf("Changes: got %+v err %v", changes, err)
+	}
+	if tomb := changes[1]; !tomb.DeletedAt.Valid || tomb.ChangeSeq != 3 || tomb.Content != "" {
+		t.Fatalf("unexpected tombstone %+v", tomb)
+	}
+
+	// the tombstone of the note deleted above goes too
+	n, err := notes.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("PurgeDeleted: %d, %v", n, err)
+	}
+	got, err := repository.NewWorkspaceRepository(gdb).FindByID(ctx, ws.ID)
+	if err != nil || got.ChangeSeq != 2 || got.PurgedChangeSeq != 3 {
+		t.Fatalf("unexpected workspace after purge: %+v err %v", got, err)
+	}
+	if changes, _ := notes.Changes(ctx, ws.ID, 0, 10); len(changes) != 1 {
+		t.Fatalf("expected the tombstone purged, got %+v", changes)
+	}
+}
+
+// testPersonalWorkspace checks that a user gets one personal workspace, even
+// if it is created twice.
+func testPersonalWorkspace(t *testing.T, gdb *gorm.DB, userID uint) {
+	ctx := context.Background()
+	workspaces := repository.NewWorkspaceRepository(gdb)
+	first := model.NewPersonalWorkspace(userID)
+	if created, err := workspaces.CreatePersonal(ctx, first, userID); err != nil || !created {
+		t.Fatalf("CreatePersonal: %v, %v", created, err)
+	}
+	if created, err := workspaces.CreatePersonal(ctx, model.NewPersonalWorkspace(userID), userID); err != nil || created {
+		t.Fatalf("expected the second personal workspace to be refused, got %v, %v", created, err)
+	}
+	got, err := workspaces.FindPersonal(ctx, userID)
+	if err != nil || got == nil || got.ID != first.ID {
+		t.Fatalf("FindPersonal: got %+v err %v", got, err)
+	}
+	if m, err := workspaces.FindMember(ctx, first.ID, userID); err != nil || m == nil || m.Role != model.WorkspaceRoleOwner {
+		t.Fatalf("expected the user to own the personal workspace, got %+v err %v", m, err)
+	}
+}
@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"regexp"
 	"testing"
 	"time"
@@ -25,14 +26,14 @@ func TestNoteRepository_CreateFindByID(t *testing.T) {
 	mock.ExpectCommit()
 
 	n := &model.Note{UserID: 1, Title: "T", Content: "C"}
-	if err := repo.Create(n); err != nil {
+	if err := repo.Create(context.Background(), n); err != nil {
 		t.Fatalf("create note failed: %v", err)
 	}
 
 	rows := sqlmock.NewRows([]string{"id", "user_id", "title", "content", "created_at", "updated_at"}).AddRow(1, 1, "T", "C", time.Now(), time.Now())
 	mock.ExpectQuery("SELECT .* FROM .*notes.*WHERE .*LIMIT \\?").WillReturnRows(rows)
 
-	got, err := repo.FindByID(1)
+	got, err := repo.FindByID(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("FindByID error: %v", err)
 	}
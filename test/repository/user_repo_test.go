@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"regexp"
 	"testing"
 	"time"
@@ -26,7 +27,7 @@ func TestUserRepository_CreateFindByUsername(t *testing.T) {
 	mock.ExpectCommit()
 
 	u := &model.User{Name: "Test", Username: "tester", Password: "hashed", Email: "a@a.com"}
-	if err := repo.Create(u); err != nil {
+	if err := repo.Create(context.Background(), u); err != nil {
 		t.Fatalf("create failed: %v", err)
 	}
 
@@ -34,7 +35,7 @@ func TestUserRepository_CreateFindByUsername(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "name", "username", "password", "email", "created_at", "updated_at"}).AddRow(1, "Test", "tester", "hashed", "a@a.com", time.Now(), time.Now())
 	mock.ExpectQuery("SELECT .* FROM .*users.*WHERE .*username.*LIMIT \\?").WillReturnRows(rows)
 
-	got, err := repo.FindByUsername("tester")
+	got, err := repo.FindByUsername(context.Background(), "tester")
 	if err != nil {
 		t.Fatalf("FindByUsername error: %v", err)
 	}
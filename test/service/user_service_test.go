@@ -1,13 +1,17 @@
 package service_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/MujiRahman/golang-simple-note/config"
 	"github.com/MujiRahman/golang-simple-note/internal/model"
+	"github.com/MujiRahman/golang-simple-note/internal/repository"
 	"github.com/MujiRahman/golang-simple-note/internal/service"
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
 )
 
 // fake user repo implementing repository.UserRepository (minimal for tests)
@@ -15,28 +19,113 @@ type fakeUserRepo struct {
 	users map[string]*model.User
 }
 
-func (f *fakeUserRepo) Create(u *model.User) error {
+func (f *fakeUserRepo) Create(ctx context.Context, u *model.User) error {
 	if f.users == nil {
 		f.users = map[string]*model.User{}
 	}
 	f.users[u.Username] = u
 	return nil
 }
-func (f *fakeUserRepo) FindByUsername(username string) (*model.User, error) {
+func (f *fakeUserRepo) FindByUsername(ctx context.Context, username string) (*model.User, error) {
 	if u, ok := f.users[username]; ok {
 		return u, nil
 	}
 	return nil, nil
 }
-func (f *fakeUserRepo) FindByID(id uint) (*model.User, error) { return nil, nil }
+func (f *fakeUserRepo) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+func (f *fakeUserRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range f.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+func (f *fakeUserRepo) Update(ctx context.Context, u *model.User) error {
+	f.users[u.Username] = u
+	return nil
+}
+func (f *fakeUserRepo) RecordFailedLogin(ctx context.Context, id uint) (int, error) {
+	u, _ := f.FindByID(ctx, id)
+	u.FailedLogins++
+	return u.FailedLogins, nil
+}
+func (f *fakeUserRepo) LockUntil(ctx context.Context, id uint, failedLogins int, t time.Time) error {
+	if u, _ := f.FindByID(ctx, id); u.FailedLogins == failedLogins {
+		u.LockedUntil = &t
+	}
+	return nil
+}
+func (f *fakeUserRepo) ClaimTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	u, _ := f.FindByID(ctx, id)
+	if step <= u.TOTPLastStep {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+func (f *fakeUserRepo) ReplaceRecoveryCodes(ctx context.Context, id uint, old, codes string) (bool, error) {
+	u, _ := f.FindByID(ctx, id)
+	if u.RecoveryCodes != old {
+		return false, nil
+	}
+	u.RecoveryCodes = codes
+	return true, nil
+}
+
+func (f *fakeUserRepo) ListDueForDeletion(ctx context.Context, t time.Time) ([]model.User, error) {
+	return nil, nil
+}
+func (f *fakeUserRepo) DeleteWithData(ctx context.Context, id uint) ([]string, error) {
+	return nil, nil
+}
+
+// fakeWorkspaceRepo accepts the personal workspace created at registration.
+type fakeWorkspaceRepo struct {
+	repository.WorkspaceRepository
+}
+
+func (f *fakeWorkspaceRepo) CreateWithOwner(ctx context.Context, ws *model.Workspace, ownerID uint) error {
+	return nil
+}
+
+// fakeAuditService applies changes without recording them.
+type fakeAuditService struct {
+	service.AuditService
+}
+
+func (fakeAuditService) Record(ctx context.Context, event *model.AuditEvent, change func(ctx context.Context) error) error {
+	if change == nil {
+		return nil
+	}
+	return change(ctx)
+}
+
+// fakeUnitOfWork applies changes without writing their events.
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (fakeUnitOfWork) Emit(ctx context.Context, topic, aggregateType string, aggregateID uint, data any) error {
+	return nil
+}
 
 func TestUserService_RegisterLogin_ParseToken(t *testing.T) {
 	repo := &fakeUserRepo{users: map[string]*model.User{}}
 	cfg := &config.Config{JWTSecret: "testsecret", TokenTTL: 3600}
-	svc := service.NewUserService(repo, cfg)
+	svc := service.NewUserService(repo, &fakeWorkspaceRepo{}, fakeAuditService{}, fakeUnitOfWork{}, hmacKeys(t, cfg.JWTSecret), cfg)
 
 	// register
-	u, err := svc.Register("alice", "password123")
+	u, err := svc.Register(context.Background(), "Alice", "alice", "alice@example.com", "password123")
 	if err != nil {
 		t.Fatalf("register error: %v", err)
 	}
@@ -48,7 +137,7 @@ func TestUserService_RegisterLogin_ParseToken(t *testing.T) {
 	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
 	repo.users["bob"] = &model.User{ID: 42, Username: "bob", Password: string(hash)}
 
-	tok, err := svc.Login("bob", "password123")
+	tok, err := svc.Login(context.Background(), "bob", "password123")
 	if err != nil {
 		t.Fatalf("login failed: %v", err)
 	}
@@ -56,7 +145,7 @@ func TestUserService_RegisterLogin_ParseToken(t *testing.T) {
 		t.Fatalf("empty token")
 	}
 
-	uid, err := svc.ParseToken(tok)
+	uid, err := svc.ParseToken(context.Background(), tok)
 	if err != nil {
 		t.Fatalf("parse token failed: %v", err)
 	}
@@ -64,3 +153,13 @@ func TestUserService_RegisterLogin_ParseToken(t *testing.T) {
 		t.Fatalf("expected uid 42, got %d", uid)
 	}
 }
+
+// hmacKeys returns a key set signing with secret, as configured by JWT_SECRET.
+func hmacKeys(t *testing.T, secret string) *jwtkeys.KeySet {
+	t.Helper()
+	keys, err := jwtkeys.NewKeySet(jwtkeys.NewHMACKey([]byte(secret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
@@ -0,0 +1,151 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/MujiRahman/golang-simple-note/pkg/jwtkeys"
+	"github.com/MujiRahman/golang-simple-note/pkg/oidc"
+)
+
+// OIDCUser is the account the mock provider logs in.
+type OIDCUser struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// MockOIDCProvider is a minimal OpenID provider for tests. Its authorization
+// endpoint approves every request for the current user without any UI; the
+// token endpoint checks the client secret and the PKCE verifier.
+type MockOIDCProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	keys  *jwtkeys.KeySet
+	mu    sync.Mutex
+	user  OIDCUser
+	codes map[string]mockAuthRequest
+}
+
+type mockAuthRequest struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	user        OIDCUser
+}
+
+func NewMockOIDCProvider(t *testing.T) *MockOIDCProvider {
+	t.Helper()
+	key, err := jwtkeys.Generate("mock-key", jwtkeys.RS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := jwtkeys.NewKeySet(key)
+	m := &MockOIDCProvider{ClientID: "notes-client", ClientSecret: "notes-secret", keys: keys, codes: map[string]mockAuthRequest{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 m.Issuer(),
+			"authorization_endpoint": m.Issuer() + "/authorize",
+			"token_endpoint":         m.Issuer() + "/token",
+			"jwks_uri":               m.Issuer() + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.keys.JWKS())
+	})
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Server.Close)
+	return m
+}
+
+func (m *MockOIDCProvider) Issuer() string { return m.Server.URL }
+
+// SetUser selects the account of the following logins.
+func (m *MockOIDCProvider) SetUser(u OIDCUser) {
+	m.mu.Lock()
+	m.user = u
+	m.mu.Unlock()
+}
+
+func (m *MockOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code, _ := oidc.NewVerifier()
+	m.mu.Lock()
+	m.codes[code] = mockAuthRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri"), user: m.user}
+	m.mu.Unlock()
+
+	back, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	bq := back.Query()
+	bq.Set("code", code)
+	bq.Set("state", q.Get("state"))
+	back.RawQuery = bq.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (m *MockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	if id != m.ClientID || secret != m.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostFormValue("code")
+	m.mu.Lock()
+	req, ok := m.codes[code]
+	delete(m.codes, code) // codes are single use
+	m.mu.Unlock()
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != req.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if oidc.Challenge(r.PostFormValue("code_verifier")) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+	now := time.Now()
+	idToken, err := m.keys.Sign(jwt.MapClaims{
+		"iss":                m.Issuer(),
+		"sub":                req.user.Subject,
+		"aud":                m.ClientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              req.nonce,
+		"email":              req.user.Email,
+		"email_verified":     req.user.EmailVerified,
+		"name":               req.user.Name,
+		"preferred_username": req.user.PreferredUsername,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"access_token": "mock-access-token", "token_type": "Bearer", "expires_in": 300, "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,44 @@
+package testutil
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/MujiRahman/golang-simple-note/migrations"
+	"github.com/MujiRahman/golang-simple-note/pkg/migrate"
+)
+
+// NewSQLiteDB opens a file-backed sqlite database in a temp dir, so every pooled
+// connection sees the same schema. The file is removed when the test ends.
+func NewSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open gorm sqlite: %v", err)
+	}
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return gdb
+}
+
+// NewMigratedSQLiteDB is NewSQLiteDB with every embedded sqlite migration applied.
+func NewMigratedSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gdb := NewSQLiteDB(t)
+	sqlDB, _ := gdb.DB()
+	m, err := migrate.New(sqlDB, migrate.SQLite, migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return gdb
+}